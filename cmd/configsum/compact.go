@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lifesum/configsum/pkg/config"
+)
+
+func runCompact(args []string, logger log.Logger) error {
+	var (
+		flagset = flag.NewFlagSet("compact", flag.ExitOnError)
+
+		interval    = flagset.Duration("interval", 0, "Compact repeatedly in this interval instead of once")
+		keep        = flagset.Int("keep", 10, "Number of latest renders to keep per base and user")
+		maxAge      = flagset.Duration("max-age", 30*24*time.Hour, "Renders younger than this are kept")
+		postgresURI = flagset.String("postgres.uri", defaultPostgresURI, "URI for Posgres connection")
+	)
+
+	flagset.Usage = usageCmd(flagset, "compact [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	if *keep < 1 {
+		return fmt.Errorf("keep must be at least 1, got %d", *keep)
+	}
+
+	db, err := sqlx.Connect(storeRepo, *postgresURI)
+	if err != nil {
+		return err
+	}
+
+	svc := config.NewCompactionService(config.NewPostgresUserRepo(db))
+
+	compact := func() error {
+		begin := time.Now()
+
+		c, err := svc.Compact(*keep, *maxAge, begin)
+		if err != nil {
+			return err
+		}
+
+		return logger.Log(
+			logDeleted, c.Deleted,
+			logDuration, time.Since(begin).Nanoseconds(),
+			logUsers, c.Users,
+		)
+	}
+
+	if *interval <= 0 {
+		return compact()
+	}
+
+	_ = logger.Log(logLifecycle, lifecycleStart, logInterval, *interval)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		if err := compact(); err != nil {
+			_ = logger.Log(logError, err)
+		}
+
+		<-ticker.C
+	}
+}
@@ -14,7 +14,9 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 
+	"github.com/lifesum/configsum/pkg/auth/device"
 	"github.com/lifesum/configsum/pkg/auth/dory"
+	"github.com/lifesum/configsum/pkg/auth/registration"
 	"github.com/lifesum/configsum/pkg/auth/simple"
 	"github.com/lifesum/configsum/pkg/client"
 	"github.com/lifesum/configsum/pkg/config"
@@ -29,16 +31,27 @@ const (
 	authSimple = "simple"
 )
 
+const (
+	bucketingHash   = "hash"
+	bucketingRandom = "random"
+)
+
 func runConfig(args []string, logger log.Logger) error {
 	var (
 		begin   = time.Now()
 		flagset = flag.NewFlagSet("config", flag.ExitOnError)
 
-		authMethod    = flagset.String("auth", authSimple, "User authenticaiton method to use (dory, simple)")
-		dorySecret    = flagset.String("dory.secret", "", "Shared secret for Dory Authentication middleware")
-		intrumentAddr = flagset.String("instrument.addir", ":8701", "Listen address for instrumentation")
-		listenAddr    = flagset.String("listen.addr", ":8700", "Listen address for HTTP API")
-		postgresURI   = flagset.String("postgres.uri", defaultPostgresURI, "URI for Posgres connection")
+		authAnonymous      = flagset.String("auth.anonymous.secret", "", "Secret to sign device tokens, accepts requests without user credentials on behalf of the device if set")
+		authMethod         = flagset.String("auth", authSimple, "User authenticaiton method to use (dory, simple)")
+		bucketing          = flagset.String("rollout.bucketing", bucketingHash, "Dice roll method for new rollout decisions (hash, random), snapshots only match hash")
+		countryHeader      = flagset.String("country.header", "", "Header a trusted proxy sets to the country of the request, e.g. CF-IPCountry")
+		dorySecret         = flagset.String("dory.secret", "", "Shared secret for Dory Authentication middleware")
+		intrumentAddr      = flagset.String("instrument.addir", ":8701", "Listen address for instrumentation")
+		listenAddr         = flagset.String("listen.addr", ":8700", "Listen address for HTTP API")
+		postgresURI        = flagset.String("postgres.uri", defaultPostgresURI, "URI for Posgres connection")
+		registrationSecret = flagset.String("registration.secret", "", "Shared secret to verify registration dates, if set unsigned dates from the payload are ignored")
+		segmentTTL         = flagset.Duration("segment.ttl", time.Minute, "Duration segments are cached for before changes apply")
+		userListTTL        = flagset.Duration("userlist.ttl", time.Minute, "Interval in which cached user lists are checked for new uploads")
 	)
 
 	flagset.Usage = usageCmd(flagset, "config [flags]")
@@ -110,14 +123,56 @@ func runConfig(args []string, logger log.Logger) error {
 	)(ruleRepo)
 	ruleRepo = rule.NewRuleRepoLogMiddleware(logger, storeRepo)(ruleRepo)
 
+	var segmentRepo rule.SegmentRepo
+	segmentRepo = rule.NewPostgresSegmentRepo(db)
+	segmentRepo = rule.NewSegmentRepoInstrumentMiddleware(
+		instrument.ObserveRepo(instrumentNamespace, taskConfig),
+		storeRepo,
+	)(segmentRepo)
+	segmentRepo = rule.NewSegmentRepoLogMiddleware(logger, storeRepo)(segmentRepo)
+
+	var userListRepo rule.UserListRepo
+	userListRepo = rule.NewPostgresUserListRepo(db)
+	userListRepo = rule.NewUserListRepoInstrumentMiddleware(
+		instrument.ObserveRepo(instrumentNamespace, taskConfig),
+		storeRepo,
+	)(userListRepo)
+	userListRepo = rule.NewUserListRepoLogMiddleware(logger, storeRepo)(userListRepo)
+
+	userOpts := []config.UserServiceOption{
+		config.UserServiceDeprecatedServed(
+			instrument.CountDeprecatedServed(instrumentNamespace, taskConfig),
+		),
+		config.UserServiceSegments(rule.NewSegmentCache(segmentRepo, *segmentTTL)),
+		config.UserServiceUserLists(rule.NewUserListCache(userListRepo, *userListTTL)),
+	}
+
+	switch *bucketing {
+	case bucketingHash:
+		userOpts = append(userOpts, config.UserServiceDice(rule.HashDice))
+	case bucketingRandom:
+		_ = level.Warn(logger).Log(
+			logBucketing, *bucketing,
+			logMessage, "local evaluation of ruleset snapshots differs from rendered configs",
+		)
+	default:
+		return errors.Errorf("unsupported bucketing: '%s'", *bucketing)
+	}
+
 	// Setup service.
 	var (
 		seed         = rand.New(rand.NewSource(time.Now().UnixNano()))
 		mux          = http.NewServeMux()
 		prefixConfig = fmt.Sprintf(`/%s/config`, apiVersion)
 		clientSVC    = client.NewService(clientRepo, tokenRepo)
-		svc          = config.NewUserService(baseRepo, userRepo, ruleRepo, generate.RandPercentage(seed))
-		opts         = []kithttp.ServerOption{
+		svc          = config.NewUserService(
+			baseRepo,
+			userRepo,
+			ruleRepo,
+			generate.RandPercentage(seed),
+			userOpts...,
+		)
+		opts = []kithttp.ServerOption{
 			kithttp.ServerBefore(kithttp.PopulateRequestContext),
 			kithttp.ServerBefore(confhttp.PopulateRequestContext),
 			kithttp.ServerErrorEncoder(confhttp.ErrorEncoder),
@@ -129,23 +184,60 @@ func runConfig(args []string, logger log.Logger) error {
 			),
 		}
 
-		auth endpoint.Middleware
+		auth     endpoint.Middleware
+		userAuth endpoint.Middleware
 	)
 
-	auth = endpoint.Chain(client.AuthMiddleware(clientSVC))
 	opts = append(opts, kithttp.ServerBefore(client.HTTPToContext))
 
 	switch *authMethod {
 	case authDory:
-		auth = endpoint.Chain(auth, dory.AuthMiddleware(*dorySecret))
+		userAuth = dory.AuthMiddleware(*dorySecret)
 		opts = append(opts, kithttp.ServerBefore(dory.HTTPToContext))
 	case authSimple:
-		auth = endpoint.Chain(auth, simple.AuthMiddleware())
+		userAuth = simple.AuthMiddleware()
 		opts = append(opts, kithttp.ServerBefore(simple.HTTPToContext))
 	default:
 		return errors.Errorf("unsupported auth: '%s'", *authMethod)
 	}
 
+	if *authAnonymous != "" {
+		userAuth = device.AuthMiddleware(*authAnonymous, userAuth)
+		opts = append(opts, kithttp.ServerBefore(device.HTTPToContext))
+	}
+
+	auth = endpoint.Chain(client.AuthMiddleware(clientSVC), userAuth)
+
+	if *countryHeader != "" {
+		opts = append(opts, kithttp.ServerBefore(config.HTTPCountryToContext(*countryHeader)))
+	}
+
+	if *registrationSecret != "" {
+		auth = endpoint.Chain(auth, registration.AuthMiddleware(*registrationSecret))
+		opts = append(opts, kithttp.ServerBefore(registration.HTTPToContext))
+	}
+
+	if *authAnonymous != "" {
+		mux.Handle(
+			fmt.Sprintf(`%s/alias`, prefixConfig),
+			http.StripPrefix(
+				prefixConfig,
+				config.MakeAliasHandler(svc, auth, opts...),
+			),
+		)
+		mux.Handle(
+			fmt.Sprintf(`%s/device`, prefixConfig),
+			http.StripPrefix(
+				prefixConfig,
+				device.MakeHandler(
+					*authAnonymous,
+					client.AuthMiddleware(clientSVC),
+					opts...,
+				),
+			),
+		)
+	}
+
 	mux.Handle(
 		fmt.Sprintf(`%s/`, prefixConfig),
 		http.StripPrefix(
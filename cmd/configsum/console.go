@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -24,11 +25,17 @@ func runConsole(args []string, logger log.Logger) error {
 		begin   = time.Now()
 		flagset = flag.NewFlagSet("console", flag.ExitOnError)
 
-		instrumentAddr = flagset.String("instrument.addr", ":8711", "Listen address for instrumenation")
-		listenAddr     = flagset.String("listen.addr", ":8710", "HTTP API bind address")
-		postgresURI    = flagset.String("postgres.uri", defaultPostgresURI, "URI for Posgres connection")
-		uiBase         = flagset.String("ui.base", "/", "Base URI to use for path based mounting")
-		uiLocal        = flagset.Bool("ui.local", false, "Load static assets from the filesystem")
+		deprecationGrace  = flagset.Duration("deprecation.grace", 14*24*time.Hour, "Time a parameter has to be deprecated before it can be removed")
+		guardrailInterval = flagset.Duration("guardrail.interval", time.Minute, "Interval in which guardrails are checked")
+		guardrailMetrics  = flagset.String("guardrail.metrics", "", "Prometheus text file to scrape guardrail metrics from on every check")
+		guardrailWebhook  = flagset.String("guardrail.webhook", "", "URL to post breached guardrails to, breaches are only logged if empty")
+		instrumentAddr    = flagset.String("instrument.addr", ":8711", "Listen address for instrumenation")
+		listenAddr        = flagset.String("listen.addr", ":8710", "HTTP API bind address")
+		postgresURI       = flagset.String("postgres.uri", defaultPostgresURI, "URI for Posgres connection")
+		scheduleInterval  = flagset.Duration("schedule.interval", time.Minute, "Interval in which rollout schedules are advanced")
+		snapshotSecret    = flagset.String("snapshot.secret", "", "Shared secret to sign ruleset snapshots with, export is disabled if empty")
+		uiBase            = flagset.String("ui.base", "/", "Base URI to use for path based mounting")
+		uiLocal           = flagset.Bool("ui.local", false, "Load static assets from the filesystem")
 	)
 
 	flagset.Usage = usageCmd(flagset, "console [flags]")
@@ -73,12 +80,73 @@ func runConsole(args []string, logger log.Logger) error {
 	)(clientRepo)
 	clientRepo = client.NewRepoLogMiddleware(logger, storeRepo)(clientRepo)
 
+	var segmentRepo rule.SegmentRepo
+	segmentRepo = rule.NewPostgresSegmentRepo(db)
+	segmentRepo = rule.NewSegmentRepoInstrumentMiddleware(
+		instrument.ObserveRepo(instrumentNamespace, taskConsole),
+		storeRepo,
+	)(segmentRepo)
+	segmentRepo = rule.NewSegmentRepoLogMiddleware(logger, storeRepo)(segmentRepo)
+
+	var userListRepo rule.UserListRepo
+	userListRepo = rule.NewPostgresUserListRepo(db)
+	userListRepo = rule.NewUserListRepoInstrumentMiddleware(
+		instrument.ObserveRepo(instrumentNamespace, taskConsole),
+		storeRepo,
+	)(userListRepo)
+	userListRepo = rule.NewUserListRepoLogMiddleware(logger, storeRepo)(userListRepo)
+
 	ruleRepo := rule.NewPostgresRepo(db)
 	ruleRepo = rule.NewRuleRepoInstrumentMiddleware(
 		instrument.ObserveRepo(instrumentNamespace, taskConfig),
 		storeRepo,
 	)(ruleRepo)
 	ruleRepo = rule.NewRuleRepoLogMiddleware(logger, storeRepo)(ruleRepo)
+	ruleRepo = config.NewRuleRepoValidateMiddleware(baseRepo)(ruleRepo)
+	ruleRepo = rule.NewRuleRepoLayerMiddleware()(ruleRepo)
+	ruleRepo = rule.NewRuleRepoPrerequisiteMiddleware()(ruleRepo)
+	ruleRepo = rule.NewRuleRepoSegmentMiddleware(segmentRepo)(ruleRepo)
+	ruleRepo = rule.NewRuleRepoUserListMiddleware(userListRepo)(ruleRepo)
+
+	var scheduleRepo rule.ScheduleRepo
+	scheduleRepo = rule.NewPostgresScheduleRepo(db)
+	scheduleRepo = rule.NewScheduleRepoInstrumentMiddleware(
+		instrument.ObserveRepo(instrumentNamespace, taskConsole),
+		storeRepo,
+	)(scheduleRepo)
+	scheduleRepo = rule.NewScheduleRepoLogMiddleware(logger, storeRepo)(scheduleRepo)
+
+	var guardrailRepo rule.GuardrailRepo
+	guardrailRepo = rule.NewPostgresGuardrailRepo(db)
+	guardrailRepo = rule.NewGuardrailRepoInstrumentMiddleware(
+		instrument.ObserveRepo(instrumentNamespace, taskConsole),
+		storeRepo,
+	)(guardrailRepo)
+	guardrailRepo = rule.NewGuardrailRepoLogMiddleware(logger, storeRepo)(guardrailRepo)
+
+	notifier := rule.NewGuardrailLogNotifier(logger)
+	if *guardrailWebhook != "" {
+		notifier = rule.NewGuardrailWebhookNotifier(
+			&http.Client{Timeout: defaultTimeoutNotify},
+			*guardrailWebhook,
+		)
+	}
+
+	var promotionRepo config.PromotionRepo
+	promotionRepo = config.NewPostgresPromotionRepo(db)
+	promotionRepo = config.NewPromotionRepoInstrumentMiddleware(
+		instrument.ObserveRepo(instrumentNamespace, taskConsole),
+		storeRepo,
+	)(promotionRepo)
+	promotionRepo = config.NewPromotionRepoLogMiddleware(logger, storeRepo)(promotionRepo)
+
+	var userRepo config.UserRepo
+	userRepo = config.NewPostgresUserRepo(db)
+	userRepo = config.NewUserRepoInstrumentMiddleware(
+		instrument.ObserveRepo(instrumentNamespace, taskConsole),
+		storeRepo,
+	)(userRepo)
+	userRepo = config.NewUserRepoLogMiddleware(logger, storeRepo)(userRepo)
 
 	var tokenRepo client.TokenRepo
 	tokenRepo = client.NewPostgresTokenRepo(db)
@@ -89,12 +157,30 @@ func runConsole(args []string, logger log.Logger) error {
 	tokenRepo = client.NewTokenRepoLogMiddleware(logger, storeRepo)(tokenRepo)
 
 	var (
-		baseConfigSVC    = config.NewBaseService(baseRepo, clientRepo)
+		baseConfigSVC = config.NewBaseService(
+			baseRepo,
+			clientRepo,
+			ruleRepo,
+			config.BaseServiceGracePeriod(*deprecationGrace),
+		)
 		clientSVC        = client.NewService(clientRepo, tokenRepo)
 		ruleSVC          = rule.NewService(ruleRepo)
+		guardrailSVC     = rule.NewGuardrailService(ruleSVC, guardrailRepo, notifier)
+		privacySVC       = config.NewPrivacyService(userRepo, userListRepo)
+		promotionSVC     = config.NewPromotionService(baseRepo, promotionRepo, ruleRepo)
+		scheduleSVC      = rule.NewScheduleService(ruleRepo, scheduleRepo, guardrailRepo)
+		segmentSVC       = rule.NewSegmentService(segmentRepo, ruleRepo)
+		userListSVC      = rule.NewUserListService(userListRepo)
 		prefixBaseConfig = "/api/configs/base"
 		prefixClient     = "/api/clients"
+		prefixGuardrail  = "/api/guardrails"
+		prefixPrivacy    = "/api/users"
+		prefixPromotion  = "/api/promotions"
 		prefixRule       = "/api/rules"
+		prefixSchedule   = "/api/schedules"
+		prefixSegment    = "/api/segments"
+		prefixSnapshot   = "/api/snapshots"
+		prefixUserList   = "/api/userlists"
 		serveMux         = http.NewServeMux()
 		opts             = []kithttp.ServerOption{
 			kithttp.ServerBefore(kithttp.PopulateRequestContext),
@@ -123,6 +209,27 @@ func runConsole(args []string, logger log.Logger) error {
 			client.MakeHandler(clientSVC, opts...),
 		),
 	)
+	serveMux.Handle(
+		fmt.Sprintf("%s/", prefixGuardrail),
+		http.StripPrefix(
+			prefixGuardrail,
+			rule.MakeGuardrailHandler(guardrailSVC, opts...),
+		),
+	)
+	serveMux.Handle(
+		fmt.Sprintf("%s/", prefixPrivacy),
+		http.StripPrefix(
+			prefixPrivacy,
+			config.MakePrivacyHandler(privacySVC, opts...),
+		),
+	)
+	serveMux.Handle(
+		fmt.Sprintf("%s/", prefixPromotion),
+		http.StripPrefix(
+			prefixPromotion,
+			config.MakePromotionHandler(promotionSVC, opts...),
+		),
+	)
 	serveMux.Handle(
 		fmt.Sprintf("%s/", prefixRule),
 		http.StripPrefix(
@@ -130,6 +237,99 @@ func runConsole(args []string, logger log.Logger) error {
 			rule.MakeHandler(ruleSVC, opts...),
 		),
 	)
+	serveMux.Handle(
+		fmt.Sprintf("%s/", prefixSchedule),
+		http.StripPrefix(
+			prefixSchedule,
+			rule.MakeScheduleHandler(scheduleSVC, opts...),
+		),
+	)
+	serveMux.Handle(
+		fmt.Sprintf("%s/", prefixSegment),
+		http.StripPrefix(
+			prefixSegment,
+			rule.MakeSegmentHandler(segmentSVC, opts...),
+		),
+	)
+	serveMux.Handle(
+		fmt.Sprintf("%s/", prefixUserList),
+		http.StripPrefix(
+			prefixUserList,
+			rule.MakeUserListHandler(userListSVC, opts...),
+		),
+	)
+
+	if *snapshotSecret != "" {
+		snapshotSVC := config.NewSnapshotService(
+			baseRepo,
+			clientRepo,
+			ruleRepo,
+			[]byte(*snapshotSecret),
+			config.SnapshotServiceSegments(segmentRepo),
+		)
+
+		serveMux.Handle(
+			fmt.Sprintf("%s/", prefixSnapshot),
+			http.StripPrefix(
+				prefixSnapshot,
+				config.MakeSnapshotHandler(snapshotSVC, opts...),
+			),
+		)
+	}
+
+	go func(logger log.Logger, svc rule.ScheduleService, interval time.Duration) {
+		_ = logger.Log(
+			logInterval, interval,
+			logLifecycle, lifecycleStart,
+			logService, serviceSchedule,
+		)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			recs, err := svc.Advance(now)
+			if err != nil {
+				_ = logger.Log(logError, err, logService, serviceSchedule)
+			}
+
+			if len(recs) > 0 {
+				_ = logger.Log(logService, serviceSchedule, logSteps, len(recs))
+			}
+		}
+	}(logger, scheduleSVC, *scheduleInterval)
+
+	go func(logger log.Logger, svc rule.GuardrailService, interval time.Duration, metrics string) {
+		_ = logger.Log(
+			logInterval, interval,
+			logLifecycle, lifecycleStart,
+			logService, serviceGuardrail,
+		)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			if metrics != "" {
+				n, err := scrapeGuardrails(svc, metrics)
+				if err != nil {
+					_ = logger.Log(logError, err, logService, serviceGuardrail)
+				}
+
+				_ = logger.Log(logSamples, n, logService, serviceGuardrail)
+			}
+
+			bs, err := svc.Check(now)
+			if err != nil {
+				_ = logger.Log(logError, err, logService, serviceGuardrail)
+			}
+
+			if len(bs) > 0 {
+				_ = logger.Log(logBreaches, len(bs), logService, serviceGuardrail)
+			}
+		}
+	}(logger, guardrailSVC, *guardrailInterval, *guardrailMetrics)
+
 	serveMux.Handle("/", ui.MakeHandler(logger, *uiBase, *uiLocal))
 
 	srv := &http.Server{
@@ -148,3 +348,13 @@ func runConsole(args []string, logger log.Logger) error {
 
 	return srv.ListenAndServe()
 }
+
+func scrapeGuardrails(svc rule.GuardrailService, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return svc.Scrape(f)
+}
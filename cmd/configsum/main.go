@@ -26,17 +26,28 @@ const (
 
 // Log fields.
 const (
+	logBases     = "bases"
+	logBreaches  = "breaches"
+	logBucketing = "bucketing"
 	logCaller    = "caller"
+	logDeleted   = "deleted"
 	logDuration  = "duration"
 	logError     = "err"
 	logHostname  = "hostname"
+	logInterval  = "interval"
 	logJob       = "job"
 	logLifecycle = "lifecycle"
 	logListen    = "listen"
+	logMessage   = "msg"
+	logMigrators = "migrators"
 	logNow       = "now"
 	logRevision  = "revision"
+	logRules     = "rules"
+	logSamples   = "samples"
 	logService   = "service"
+	logSteps     = "steps"
 	logTask      = "task"
+	logUsers     = "users"
 )
 
 // Instrument fields.
@@ -53,19 +64,26 @@ const (
 // Services.
 const (
 	serviceAPI        = "api"
+	serviceGuardrail  = "guardrail"
 	serviceInstrument = "instrument"
+	serviceSchedule   = "schedule"
 )
 
 // Tasks.
 const (
+	taskApply   = "apply"
+	taskCompact = "compact"
 	taskConfig  = "config"
 	taskConsole = "console"
+	taskExport  = "export"
+	taskMigrate = "migrate"
 )
 
 // Timeouts.
 const (
-	defaultTimeoutRead  = 1 * time.Second
-	defaultTimeoutWrite = 1 * time.Second
+	defaultTimeoutNotify = 5 * time.Second
+	defaultTimeoutRead   = 1 * time.Second
+	defaultTimeoutWrite  = 1 * time.Second
 )
 
 const storeRepo = "postgres"
@@ -126,10 +144,18 @@ func main() {
 	)
 
 	switch task {
+	case taskApply:
+		run = runApply
+	case taskCompact:
+		run = runCompact
 	case taskConfig:
 		run = runConfig
 	case taskConsole:
 		run = runConsole
+	case taskExport:
+		run = runExport
+	case taskMigrate:
+		run = runMigrate
 	default:
 		usage()
 		os.Exit(1)
@@ -172,6 +198,10 @@ func usage() {
 
 COMMANDS
 	config	API offering access to per user rendered configs
+	export	Write base configs and rules as JSON or YAML declarations to a directory
+	apply	Plan and apply JSON or YAML declarations from a directory
+	migrate	Apply or revert database migrations
+	compact	Prune the render history of users
 
 VERSION
 	%s (%s)
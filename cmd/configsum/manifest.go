@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lifesum/configsum/pkg/client"
+	"github.com/lifesum/configsum/pkg/config"
+	"github.com/lifesum/configsum/pkg/manifest"
+	"github.com/lifesum/configsum/pkg/rule"
+)
+
+func runApply(args []string, logger log.Logger) error {
+	var (
+		begin   = time.Now()
+		flagset = flag.NewFlagSet("apply", flag.ExitOnError)
+
+		dir         = flagset.String("dir", ".", "Directory to read JSON (*.json) and YAML (*.yaml, *.yml) declarations from")
+		dryRun      = flagset.Bool("dry-run", false, "Print the plan without applying it")
+		postgresURI = flagset.String("postgres.uri", defaultPostgresURI, "URI for Posgres connection")
+	)
+
+	flagset.Usage = usageCmd(flagset, "apply [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	bs, err := manifest.Read(*dir)
+	if err != nil {
+		return err
+	}
+
+	baseSVC, ruleRepo, err := manifestDeps(*postgresURI)
+	if err != nil {
+		return err
+	}
+
+	applier := manifest.NewApplier(baseSVC, ruleRepo)
+
+	p, err := applier.Plan(bs)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(os.Stdout, p)
+
+	if p.Empty() || *dryRun {
+		return nil
+	}
+
+	if err := applier.Apply(p); err != nil {
+		return err
+	}
+
+	return logger.Log(
+		logDuration, time.Since(begin).Nanoseconds(),
+		logBases, len(p.Bases),
+		logRules, len(p.Rules),
+	)
+}
+
+func runExport(args []string, logger log.Logger) error {
+	var (
+		begin   = time.Now()
+		flagset = flag.NewFlagSet("export", flag.ExitOnError)
+
+		dir         = flagset.String("dir", ".", "Directory to write declarations to")
+		format      = flagset.String("format", "json", "Format of the declarations (json, yaml)")
+		postgresURI = flagset.String("postgres.uri", defaultPostgresURI, "URI for Posgres connection")
+	)
+
+	flagset.Usage = usageCmd(flagset, "export [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	baseSVC, ruleRepo, err := manifestDeps(*postgresURI)
+	if err != nil {
+		return err
+	}
+
+	bcs, err := baseSVC.List()
+	if err != nil {
+		return err
+	}
+
+	rs, err := ruleRepo.ListAll()
+	if err != nil {
+		return err
+	}
+
+	bs := manifest.Export(bcs, rs)
+
+	if err := manifest.Write(*dir, "."+*format, bs); err != nil {
+		return err
+	}
+
+	return logger.Log(
+		logDuration, time.Since(begin).Nanoseconds(),
+		logBases, len(bs),
+	)
+}
+
+func manifestDeps(postgresURI string) (config.BaseService, rule.Repo, error) {
+	db, err := sqlx.Connect(storeRepo, postgresURI)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		baseRepo     = config.NewPostgresBaseRepo(db)
+		clientRepo   = client.NewPostgresRepo(db)
+		ruleRepo     = rule.NewPostgresRepo(db)
+		segmentRepo  = rule.NewPostgresSegmentRepo(db)
+		userListRepo = rule.NewPostgresUserListRepo(db)
+	)
+
+	ruleRepo = config.NewRuleRepoValidateMiddleware(baseRepo)(ruleRepo)
+	ruleRepo = rule.NewRuleRepoLayerMiddleware()(ruleRepo)
+	ruleRepo = rule.NewRuleRepoPrerequisiteMiddleware()(ruleRepo)
+	ruleRepo = rule.NewRuleRepoSegmentMiddleware(segmentRepo)(ruleRepo)
+	ruleRepo = rule.NewRuleRepoUserListMiddleware(userListRepo)(ruleRepo)
+
+	return config.NewBaseService(baseRepo, clientRepo, ruleRepo), ruleRepo, nil
+}
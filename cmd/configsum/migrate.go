@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lifesum/configsum/pkg/client"
+	"github.com/lifesum/configsum/pkg/config"
+	"github.com/lifesum/configsum/pkg/pg"
+	"github.com/lifesum/configsum/pkg/rule"
+)
+
+func runMigrate(args []string, logger log.Logger) error {
+	var (
+		begin   = time.Now()
+		flagset = flag.NewFlagSet("migrate", flag.ExitOnError)
+
+		component   = flagset.String("component", "", "Only migrate the given component (e.g. bases, rules), all if empty")
+		down        = flagset.Int("down", 0, "Revert this many migrations per component instead of applying pending ones")
+		postgresURI = flagset.String("postgres.uri", defaultPostgresURI, "URI for Posgres connection")
+		status      = flagset.Bool("status", false, "Only print the migration status")
+	)
+
+	flagset.Usage = usageCmd(flagset, "migrate [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := sqlx.Connect(storeRepo, *postgresURI)
+	if err != nil {
+		return err
+	}
+
+	ms := []*pg.Migrator{}
+
+	for _, m := range append(
+		append(client.PGMigrators(db), config.PGMigrators(db)...),
+		rule.PGMigrators(db)...,
+	) {
+		if *component == "" || *component == m.Component() {
+			ms = append(ms, m)
+		}
+	}
+
+	if len(ms) == 0 {
+		return fmt.Errorf("unknown component '%s'", *component)
+	}
+
+	for _, m := range ms {
+		switch {
+		case *status:
+		case *down > 0:
+			err = m.Down(*down)
+		default:
+			err = m.Up()
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	fmt.Fprintf(w, "COMPONENT\tSCHEMA\tVERSION\tLATEST\n")
+
+	for _, m := range ms {
+		s, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", s.Component, s.Schema, s.Version, s.Latest)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return logger.Log(
+		logDuration, time.Since(begin).Nanoseconds(),
+		logMigrators, len(ms),
+	)
+}
@@ -1,8 +1,27 @@
 package auth
 
+import "strings"
+
 type contextKey string
 
 // Context keys to transport auth information.
 const (
-	ContextKeyUserID contextKey = "userID"
+	ContextKeyDeviceID   contextKey = "deviceID"
+	ContextKeyRegistered contextKey = "registered"
+	ContextKeyUserID     contextKey = "userID"
 )
+
+// anonymousPrefix marks user ids issued for devices which aren't linked to a
+// user yet.
+const anonymousPrefix = "device:"
+
+// AnonymousUserID returns the user id configs are rendered for on behalf of a
+// device before the user is known.
+func AnonymousUserID(deviceID string) string {
+	return anonymousPrefix + deviceID
+}
+
+// IsAnonymous reports if the user id was issued for a device.
+func IsAnonymous(userID string) bool {
+	return strings.HasPrefix(userID, anonymousPrefix)
+}
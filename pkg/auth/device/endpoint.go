@@ -0,0 +1,43 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+type issueRequest struct{}
+
+type issueResponse struct {
+	deviceID string
+	token    string
+}
+
+func (r issueResponse) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		DeviceID string `json:"device_id"`
+		Token    string `json:"token"`
+	}{
+		DeviceID: r.deviceID,
+		Token:    r.token,
+	})
+}
+
+func (r issueResponse) StatusCode() int {
+	return http.StatusCreated
+}
+
+func issueEndpoint(secret string) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		_ = request.(issueRequest)
+
+		deviceID, token, err := NewToken(secret)
+		if err != nil {
+			return nil, err
+		}
+
+		return issueResponse{deviceID: deviceID, token: token}, nil
+	}
+}
@@ -0,0 +1,68 @@
+package device
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/lifesum/configsum/pkg/auth"
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+type contextKey string
+
+const (
+	contextKeyToken contextKey = "deviceToken"
+)
+
+// AuthMiddleware returns a pluggable endpoint.Middleware which wraps the given
+// user authentication. Requests without user credentials are accepted on
+// behalf of the device and carry its anonymous user id. The request is
+// rejected if:
+// * the device token is not signed with the secret
+// * the user authentication rejects present credentials
+// * user credentials and device token are missing
+func AuthMiddleware(secret string, userAuth endpoint.Middleware) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			var deviceID string
+
+			token, ok := ctx.Value(contextKeyToken).(string)
+			if ok {
+				id, err := parseToken(secret, token)
+				if err != nil {
+					return nil, err
+				}
+
+				deviceID = id
+				ctx = context.WithValue(ctx, auth.ContextKeyDeviceID, deviceID)
+			}
+
+			authenticated := false
+
+			response, err := userAuth(
+				func(ctx context.Context, request interface{}) (interface{}, error) {
+					authenticated = true
+
+					return next(ctx, request)
+				},
+			)(ctx, request)
+			if err == nil || authenticated || !ok || !credentialsMissing(err) {
+				return response, err
+			}
+
+			ctx = context.WithValue(ctx, auth.ContextKeyUserID, auth.AnonymousUserID(deviceID))
+
+			return next(ctx, request)
+		}
+	}
+}
+
+func credentialsMissing(err error) bool {
+	switch errors.Cause(err) {
+	case errors.ErrSignatureMissing, errors.ErrUserIDMissing:
+		return true
+	default:
+		return false
+	}
+}
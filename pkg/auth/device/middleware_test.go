@@ -0,0 +1,132 @@
+package device
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/lifesum/configsum/pkg/auth"
+	"github.com/lifesum/configsum/pkg/auth/simple"
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/generate"
+)
+
+const testSecret = "secret"
+
+func TestAuthMiddlewareAnonymous(t *testing.T) {
+	deviceID, token, err := NewToken(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.TODO(), contextKeyToken, token)
+
+	_, err = AuthMiddleware(testSecret, simple.AuthMiddleware())(
+		nopEndpoint(t, auth.AnonymousUserID(deviceID), deviceID),
+	)(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthMiddlewareUser(t *testing.T) {
+	deviceID, token, err := NewToken(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		userID = generate.RandomString(24)
+		ctx    = context.WithValue(context.TODO(), contextKeyToken, token)
+	)
+
+	userAuth := func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			return next(context.WithValue(ctx, auth.ContextKeyUserID, userID), request)
+		}
+	}
+
+	_, err = AuthMiddleware(testSecret, userAuth)(nopEndpoint(t, userID, deviceID))(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthMiddlewareRejected(t *testing.T) {
+	_, token, err := NewToken(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx = context.WithValue(context.TODO(), contextKeyToken, token)
+		e   = func(context.Context, interface{}) (interface{}, error) {
+			t.Fatal("endpoint called")
+			return nil, nil
+		}
+	)
+
+	userAuth := func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			return nil, errors.Wrap(errors.ErrSignatureMissmatch, "auth")
+		}
+	}
+
+	// Present but invalid credentials don't fall back to the device.
+	_, err = AuthMiddleware(testSecret, userAuth)(e)(ctx, nil)
+	if have, want := errors.Cause(err), errors.ErrSignatureMissmatch; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	_, err = AuthMiddleware(testSecret, simple.AuthMiddleware())(e)(context.TODO(), nil)
+	if have, want := errors.Cause(err), errors.ErrUserIDMissing; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	// Tokens not issued with the secret are rejected.
+	for _, invalid := range []string{
+		generate.RandomString(24),
+		token + "0",
+		generate.RandomString(24) + token[strings.LastIndex(token, tokenSeparator):],
+	} {
+		ctx := context.WithValue(context.TODO(), contextKeyToken, invalid)
+
+		_, err = AuthMiddleware(testSecret, simple.AuthMiddleware())(e)(ctx, nil)
+		if err == nil {
+			t.Errorf("token '%s' accepted", invalid)
+		}
+	}
+
+	_, token, err = NewToken("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx = context.WithValue(context.TODO(), contextKeyToken, token)
+
+	_, err = AuthMiddleware(testSecret, simple.AuthMiddleware())(e)(ctx, nil)
+	if have, want := errors.Cause(err), errors.ErrSignatureMissmatch; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func nopEndpoint(t *testing.T, userID, deviceID string) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		have, ok := ctx.Value(auth.ContextKeyUserID).(string)
+		if !ok {
+			t.Fatalf("userID missing")
+		}
+
+		if want := userID; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		if have, want := ctx.Value(auth.ContextKeyDeviceID), deviceID; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		return true, nil
+	}
+}
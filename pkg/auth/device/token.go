@@ -0,0 +1,50 @@
+package device
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/generate"
+)
+
+const tokenSeparator = "."
+
+// NewToken issues a new deviceID and the token signed with the secret which
+// clients present on behalf of the device.
+func NewToken(secret string) (string, string, error) {
+	deviceID, err := generate.SecureToken(18)
+	if err != nil {
+		return "", "", errors.Wrap(err, "device id")
+	}
+
+	return deviceID, deviceID + tokenSeparator + signToken(secret, deviceID), nil
+}
+
+// parseToken returns the deviceID of the token if it was signed with the
+// secret.
+func parseToken(secret, token string) (string, error) {
+	i := strings.LastIndex(token, tokenSeparator)
+	if i <= 0 {
+		return "", errors.Wrap(errors.ErrSignatureMissing, "device token")
+	}
+
+	deviceID, signature := token[:i], token[i+1:]
+
+	if !hmac.Equal([]byte(signature), []byte(signToken(secret, deviceID))) {
+		return "", errors.Wrap(errors.ErrSignatureMissmatch, "device token")
+	}
+
+	return deviceID, nil
+}
+
+func signToken(secret, deviceID string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+
+	// Writes to a hash never fail.
+	_, _ = h.Write([]byte(deviceID))
+
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
@@ -0,0 +1,50 @@
+package device
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+const headerToken = "X-Configsum-Devicetoken"
+
+// MakeHandler returns an http.Handler which issues device tokens to
+// authenticated clients.
+func MakeHandler(
+	secret string,
+	auth endpoint.Middleware,
+	opts ...kithttp.ServerOption,
+) http.Handler {
+	r := mux.NewRouter()
+	r.StrictSlash(true)
+
+	r.Methods("POST").Path(`/device`).Name("deviceIssue").Handler(
+		kithttp.NewServer(
+			auth(issueEndpoint(secret)),
+			decodeIssueRequest,
+			kithttp.EncodeJSONResponse,
+			opts...,
+		),
+	)
+
+	return r
+}
+
+// HTTPToContext moves the device token from the X-Configsum-Devicetoken header
+// into the context of the request.
+func HTTPToContext(ctx context.Context, r *http.Request) context.Context {
+	token := r.Header.Get(headerToken)
+
+	if token == "" {
+		return ctx
+	}
+
+	return context.WithValue(ctx, contextKeyToken, token)
+}
+
+func decodeIssueRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return issueRequest{}, nil
+}
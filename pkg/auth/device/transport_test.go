@@ -0,0 +1,31 @@
+package device
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/lifesum/configsum/pkg/generate"
+)
+
+func TestHTTPToContext(t *testing.T) {
+	var (
+		token = generate.RandomString(24)
+		ts    = map[*http.Request]interface{}{
+			&http.Request{}: nil,
+			&http.Request{
+				Header: http.Header{
+					headerToken: []string{token},
+				},
+			}: token,
+		}
+	)
+
+	for r, want := range ts {
+		ctx := HTTPToContext(context.TODO(), r)
+
+		if have := ctx.Value(contextKeyToken); have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package registration
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/lifesum/configsum/pkg/auth"
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+const algHS256 = "HS256"
+
+type contextKey string
+
+// Context keys.
+const (
+	contextKeyRegistered contextKey = "registrationRegistered"
+	contextKeySignature  contextKey = "registrationSignature"
+	contextKeyToken      contextKey = "registrationToken"
+)
+
+// AuthMiddleware returns a pluggable endpoint.Middleware which verifies the
+// registration date of the authenticated user, passed either as the
+// registered_at claim of a HS256 signed JWT or as RFC 3339 date with a
+// HMAC-SHA256 signature over user id and date. The verified date is put on
+// the context, requests without one pass with the zero time. The request is
+// rejected if:
+// * userID is missing from the context
+// * the signature does not match
+// * the token is malformed, expired or issued for another user
+func AuthMiddleware(secret string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			userID, ok := ctx.Value(auth.ContextKeyUserID).(string)
+			if !ok {
+				return nil, errors.Wrap(errors.ErrUserIDMissing, "request context")
+			}
+
+			registered := time.Time{}
+
+			if token, ok := ctx.Value(contextKeyToken).(string); ok {
+				r, err := verifyToken(secret, userID, token, time.Now())
+				if err != nil {
+					return nil, err
+				}
+
+				registered = r
+			} else if date, ok := ctx.Value(contextKeyRegistered).(string); ok {
+				signature, _ := ctx.Value(contextKeySignature).(string)
+
+				r, err := verifySignature(secret, userID, date, signature)
+				if err != nil {
+					return nil, err
+				}
+
+				registered = r
+			}
+
+			ctx = context.WithValue(ctx, auth.ContextKeyRegistered, registered)
+
+			return next(ctx, request)
+		}
+	}
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+}
+
+type tokenClaims struct {
+	Expires      int64  `json:"exp"`
+	RegisteredAt int64  `json:"registered_at"`
+	Subject      string `json:"sub"`
+}
+
+func verifyToken(secret, userID, token string, now time.Time) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.Wrap(errors.ErrSignatureMissmatch, "token malformed")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return time.Time{}, errors.Wrap(errors.ErrSignatureMissmatch, "token signature malformed")
+	}
+
+	if !hmac.Equal(sig, sign(secret, parts[0]+"."+parts[1])) {
+		return time.Time{}, errors.Wrap(errors.ErrSignatureMissmatch, "token")
+	}
+
+	var header tokenHeader
+
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return time.Time{}, err
+	}
+
+	if header.Alg != algHS256 {
+		return time.Time{}, errors.Wrapf(errors.ErrSignatureMissmatch, "token alg '%s' not supported", header.Alg)
+	}
+
+	var claims tokenClaims
+
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return time.Time{}, err
+	}
+
+	if claims.Subject != userID {
+		return time.Time{}, errors.Wrap(errors.ErrSignatureMissmatch, "token subject")
+	}
+
+	if claims.Expires != 0 && now.Unix() >= claims.Expires {
+		return time.Time{}, errors.Wrap(errors.ErrSignatureMissmatch, "token expired")
+	}
+
+	if claims.RegisteredAt == 0 {
+		return time.Time{}, errors.Wrap(errors.ErrSignatureMissmatch, "token registered_at missing")
+	}
+
+	return time.Unix(claims.RegisteredAt, 0).UTC(), nil
+}
+
+func verifySignature(secret, userID, date, signature string) (time.Time, error) {
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return time.Time{}, errors.Wrap(errors.ErrSignatureMissmatch, "signature malformed")
+	}
+
+	if !hmac.Equal(sig, sign(secret, userID+date)) {
+		return time.Time{}, errors.Wrap(errors.ErrSignatureMissmatch, "registration")
+	}
+
+	registered, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(errors.ErrInvalidPayload, "registered: %s", err)
+	}
+
+	return registered.UTC(), nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.Wrap(errors.ErrSignatureMissmatch, "token segment malformed")
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return errors.Wrap(errors.ErrSignatureMissmatch, "token segment malformed")
+	}
+
+	return nil
+}
+
+func sign(secret, input string) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+
+	_, _ = h.Write([]byte(input))
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,162 @@
+package registration
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lifesum/configsum/pkg/auth"
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/generate"
+)
+
+func TestAuthMiddlewareToken(t *testing.T) {
+	var (
+		secret     = generate.RandomString(32)
+		userID     = generate.RandomString(24)
+		registered = time.Date(2017, 12, 4, 23, 11, 38, 0, time.UTC)
+		expires    = strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+		header     = `{"alg":"HS256","typ":"JWT"}`
+	)
+
+	cases := []struct {
+		token string
+		err   error
+	}{
+		{
+			signToken(secret, header, `{"sub":"`+userID+`","registered_at":1512429098}`),
+			nil,
+		},
+		{
+			signToken(secret, header, `{"sub":"`+userID+`","registered_at":1512429098,"exp":`+expires+`}`),
+			nil,
+		},
+		{
+			signToken(secret, header, `{"sub":"`+userID+`","registered_at":1512429098,"exp":1}`),
+			errors.ErrSignatureMissmatch,
+		},
+		{
+			signToken(secret, header, `{"sub":"someone-else","registered_at":1512429098}`),
+			errors.ErrSignatureMissmatch,
+		},
+		{
+			signToken(secret, header, `{"sub":"`+userID+`"}`),
+			errors.ErrSignatureMissmatch,
+		},
+		{
+			signToken(secret, `{"alg":"none"}`, `{"sub":"`+userID+`","registered_at":1512429098}`),
+			errors.ErrSignatureMissmatch,
+		},
+		{
+			signToken(generate.RandomString(32), header, `{"sub":"`+userID+`","registered_at":1512429098}`),
+			errors.ErrSignatureMissmatch,
+		},
+		{
+			generate.RandomString(12),
+			errors.ErrSignatureMissmatch,
+		},
+	}
+
+	for _, c := range cases {
+		ctx := context.WithValue(context.TODO(), contextKeyToken, c.token)
+		ctx = context.WithValue(ctx, auth.ContextKeyUserID, userID)
+
+		have, err := AuthMiddleware(secret)(registeredEndpoint)(ctx, nil)
+		if want := c.err; errors.Cause(err) != want {
+			t.Errorf("%s: have %v, want %v", c.token, err, want)
+			continue
+		}
+
+		if err == nil && !have.(time.Time).Equal(registered) {
+			t.Errorf("have %v, want %v", have, registered)
+		}
+	}
+}
+
+func TestAuthMiddlewareSignature(t *testing.T) {
+	var (
+		ctx        = context.TODO()
+		secret     = generate.RandomString(32)
+		userID     = generate.RandomString(24)
+		date       = "2017-12-04T23:11:38Z"
+		registered = time.Date(2017, 12, 4, 23, 11, 38, 0, time.UTC)
+	)
+
+	ctx = context.WithValue(ctx, auth.ContextKeyUserID, userID)
+	ctx = context.WithValue(ctx, contextKeyRegistered, date)
+	ctx = context.WithValue(ctx, contextKeySignature, hex.EncodeToString(sign(secret, userID+date)))
+
+	have, err := AuthMiddleware(secret)(registeredEndpoint)(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := registered; !have.(time.Time).Equal(want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestAuthMiddlewareSignatureMissmatch(t *testing.T) {
+	var (
+		ctx    = context.TODO()
+		secret = generate.RandomString(32)
+		userID = generate.RandomString(24)
+		date   = "2017-12-04T23:11:38Z"
+	)
+
+	ctx = context.WithValue(ctx, auth.ContextKeyUserID, userID)
+	ctx = context.WithValue(ctx, contextKeyRegistered, "2016-12-04T23:11:38Z")
+	ctx = context.WithValue(ctx, contextKeySignature, hex.EncodeToString(sign(secret, userID+date)))
+
+	_, err := AuthMiddleware(secret)(registeredEndpoint)(ctx, nil)
+	if have, want := errors.Cause(err), errors.ErrSignatureMissmatch; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestAuthMiddlewareRegistrationMissing(t *testing.T) {
+	var (
+		ctx    = context.TODO()
+		secret = generate.RandomString(32)
+		userID = generate.RandomString(24)
+	)
+
+	ctx = context.WithValue(ctx, auth.ContextKeyUserID, userID)
+
+	have, err := AuthMiddleware(secret)(registeredEndpoint)(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !have.(time.Time).IsZero() {
+		t.Errorf("have %v, want zero time", have)
+	}
+}
+
+func TestAuthMiddlewareUserIDMissing(t *testing.T) {
+	var (
+		ctx    = context.TODO()
+		secret = generate.RandomString(32)
+	)
+
+	_, err := AuthMiddleware(secret)(registeredEndpoint)(ctx, nil)
+	if have, want := errors.Cause(err), errors.ErrUserIDMissing; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func registeredEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	return ctx.Value(auth.ContextKeyRegistered), nil
+}
+
+func signToken(secret, header, claims string) string {
+	var (
+		enc     = base64.RawURLEncoding
+		payload = enc.EncodeToString([]byte(header)) + "." + enc.EncodeToString([]byte(claims))
+	)
+
+	return payload + "." + enc.EncodeToString(sign(secret, payload))
+}
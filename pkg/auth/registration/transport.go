@@ -0,0 +1,33 @@
+package registration
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	headerRegistered = "X-Configsum-Registered"
+	headerSignature  = "X-Configsum-Registered-Signature"
+	headerToken      = "X-Configsum-Registration-Token"
+)
+
+// HTTPToContext moves the registration token or the signed registration date
+// from the request headers to the context.
+func HTTPToContext(ctx context.Context, r *http.Request) context.Context {
+	if token := r.Header.Get(headerToken); token != "" {
+		return context.WithValue(ctx, contextKeyToken, token)
+	}
+
+	var (
+		registered = r.Header.Get(headerRegistered)
+		signature  = r.Header.Get(headerSignature)
+	)
+
+	if registered == "" || signature == "" {
+		return ctx
+	}
+
+	ctx = context.WithValue(ctx, contextKeyRegistered, registered)
+
+	return context.WithValue(ctx, contextKeySignature, signature)
+}
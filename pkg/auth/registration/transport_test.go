@@ -0,0 +1,61 @@
+package registration
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/lifesum/configsum/pkg/generate"
+)
+
+func TestHTTPToContext(t *testing.T) {
+	type expect struct {
+		registered interface{}
+		signature  interface{}
+		token      interface{}
+	}
+
+	var (
+		registered = "2017-12-04T23:11:38Z"
+		signature  = generate.RandomString(32)
+		token      = generate.RandomString(64)
+	)
+
+	ts := map[*http.Request]expect{
+		&http.Request{}: {nil, nil, nil},
+		&http.Request{
+			Header: http.Header{
+				headerRegistered: []string{registered},
+			},
+		}: {nil, nil, nil},
+		&http.Request{
+			Header: http.Header{
+				headerRegistered: []string{registered},
+				headerSignature:  []string{signature},
+			},
+		}: {registered, signature, nil},
+		&http.Request{
+			Header: http.Header{
+				headerRegistered: []string{registered},
+				headerSignature:  []string{signature},
+				headerToken:      []string{token},
+			},
+		}: {nil, nil, token},
+	}
+
+	for r, e := range ts {
+		ctx := HTTPToContext(context.TODO(), r)
+
+		if have, want := ctx.Value(contextKeyRegistered), e.registered; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		if have, want := ctx.Value(contextKeySignature), e.signature; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		if have, want := ctx.Value(contextKeyToken), e.token; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
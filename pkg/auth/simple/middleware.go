@@ -18,6 +18,7 @@ const (
 // AuthMiddleware returns a pluggable endpoint.Middleware which rejects the
 // request if:
 // * userID is missing from the context
+// * userID is reserved for anonymous devices
 func AuthMiddleware() endpoint.Middleware {
 	return func(next endpoint.Endpoint) endpoint.Endpoint {
 		return func(ctx context.Context, request interface{}) (interface{}, error) {
@@ -26,6 +27,10 @@ func AuthMiddleware() endpoint.Middleware {
 				return nil, errors.Wrap(errors.ErrUserIDMissing, "request context")
 			}
 
+			if auth.IsAnonymous(userID) {
+				return nil, errors.Wrap(errors.ErrUserIDInvalid, "reserved for devices")
+			}
+
 			ctx = context.WithValue(ctx, auth.ContextKeyUserID, userID)
 
 			return next(ctx, request)
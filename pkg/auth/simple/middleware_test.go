@@ -30,6 +30,19 @@ func TestAuthMiddlewareUserIDMissing(t *testing.T) {
 	}
 }
 
+func TestAuthMiddlewareUserIDInvalid(t *testing.T) {
+	ctx := context.WithValue(
+		context.TODO(),
+		contextKeyUserID,
+		auth.AnonymousUserID(generate.RandomString(24)),
+	)
+
+	_, err := AuthMiddleware()(nopEndpoint(t, ""))(ctx, nil)
+	if have, want := errors.Cause(err), errors.ErrUserIDInvalid; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
 func nopEndpoint(t *testing.T, want string) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		have, ok := ctx.Value(auth.ContextKeyUserID).(string)
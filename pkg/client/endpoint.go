@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/go-kit/kit/endpoint"
+
+	"github.com/lifesum/configsum/pkg/env"
 )
 
 type createRequest struct {
@@ -15,8 +17,8 @@ type createRequest struct {
 }
 
 type createResponse struct {
-	client Client
-	token  string
+	client  Client
+	secrets map[env.Env]string
 }
 
 func (r createResponse) MarshalJSON() ([]byte, error) {
@@ -25,7 +27,8 @@ func (r createResponse) MarshalJSON() ([]byte, error) {
 		Deleted:   r.client.deleted,
 		ID:        r.client.id,
 		Name:      r.client.name,
-		Token:     r.token,
+		Token:     r.secrets[env.Default],
+		Tokens:    r.secrets,
 	})
 }
 
@@ -40,13 +43,20 @@ type listResponse struct {
 func (r listResponse) MarshalJSON() ([]byte, error) {
 	cs := responseClientList{}
 
-	for c, t := range r.clientTokens {
+	for c, ts := range r.clientTokens {
+		secrets := map[env.Env]string{}
+
+		for e, t := range ts {
+			secrets[e] = t.secret
+		}
+
 		cs = append(cs, responseClient{
 			CreatedAt: c.createdAt,
 			Deleted:   c.deleted,
 			ID:        c.id,
 			Name:      c.name,
-			Token:     t.secret,
+			Token:     secrets[env.Default],
+			Tokens:    secrets,
 		})
 	}
 
@@ -60,11 +70,12 @@ func (r listResponse) MarshalJSON() ([]byte, error) {
 }
 
 type responseClient struct {
-	CreatedAt time.Time `json:"created_at"`
-	Deleted   bool      `json:"deleted"`
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Token     string    `json:"token"`
+	CreatedAt time.Time          `json:"created_at"`
+	Deleted   bool               `json:"deleted"`
+	ID        string             `json:"id"`
+	Name      string             `json:"name"`
+	Token     string             `json:"token"`
+	Tokens    map[env.Env]string `json:"tokens"`
 }
 
 type responseClientList []responseClient
@@ -85,14 +96,14 @@ func createEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		r := request.(createRequest)
 
-		c, secret, err := svc.Create(r.name)
+		c, secrets, err := svc.Create(r.name)
 		if err != nil {
 			return nil, err
 		}
 
 		return createResponse{
-			client: c,
-			token:  secret,
+			client:  c,
+			secrets: secrets,
 		}, nil
 	}
 }
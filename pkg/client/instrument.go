@@ -3,6 +3,7 @@ package client
 import (
 	"time"
 
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/instrument"
 )
 
@@ -93,12 +94,12 @@ func NewTokenRepoInstrumentMiddleware(
 	}
 }
 
-func (r *instrumentTokenRepo) GetLatest(clientID string) (token Token, err error) {
+func (r *instrumentTokenRepo) GetLatest(clientID string, e env.Env) (token Token, err error) {
 	defer func(begin time.Time) {
 		r.opObserve(r.store, labelRepoToken, "GetLatest", begin, err)
 	}(time.Now())
 
-	return r.next.GetLatest(clientID)
+	return r.next.GetLatest(clientID, e)
 }
 
 func (r *instrumentTokenRepo) Lookup(secret string) (token Token, err error) {
@@ -109,12 +110,12 @@ func (r *instrumentTokenRepo) Lookup(secret string) (token Token, err error) {
 	return r.next.Lookup(secret)
 }
 
-func (r *instrumentTokenRepo) Store(clientID, secret string) (token Token, err error) {
+func (r *instrumentTokenRepo) Store(clientID, secret string, e env.Env) (token Token, err error) {
 	defer func(begin time.Time) {
 		r.opObserve(r.store, labelRepoToken, "Store", begin, err)
 	}(time.Now())
 
-	return r.next.Store(clientID, secret)
+	return r.next.Store(clientID, secret, e)
 }
 
 func (r *instrumentTokenRepo) Setup() (err error) {
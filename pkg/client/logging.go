@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/go-kit/kit/log"
+
+	"github.com/lifesum/configsum/pkg/env"
 )
 
 // Log fields.
@@ -11,6 +13,7 @@ const (
 	logFieldClientID = "client_id"
 	logFieldDuration = "duration"
 	logFieldElements = "elements"
+	logFieldEnv      = "env"
 	logFieldErr      = "err"
 	logFieldID       = "id"
 	logFieldOp       = "op"
@@ -147,11 +150,12 @@ func NewTokenRepoLogMiddleware(logger log.Logger, store string) TokenRepoMiddlew
 	}
 }
 
-func (r *logTokenRepo) GetLatest(clientID string) (token Token, err error) {
+func (r *logTokenRepo) GetLatest(clientID string, e env.Env) (token Token, err error) {
 	defer func(begin time.Time) {
 		ps := []interface{}{
 			logFieldClientID, clientID,
 			logFieldDuration, time.Since(begin).Nanoseconds(),
+			logFieldEnv, e,
 			logFieldOp, "GetLatest",
 		}
 
@@ -162,7 +166,7 @@ func (r *logTokenRepo) GetLatest(clientID string) (token Token, err error) {
 		_ = r.logger.Log(ps...)
 	}(time.Now())
 
-	return r.next.GetLatest(clientID)
+	return r.next.GetLatest(clientID, e)
 }
 
 func (r *logTokenRepo) Lookup(secret string) (token Token, err error) {
@@ -183,11 +187,12 @@ func (r *logTokenRepo) Lookup(secret string) (token Token, err error) {
 	return r.next.Lookup(secret)
 }
 
-func (r *logTokenRepo) Store(clientID, secret string) (token Token, err error) {
+func (r *logTokenRepo) Store(clientID, secret string, e env.Env) (token Token, err error) {
 	defer func(begin time.Time) {
 		ps := []interface{}{
 			logFieldDuration, time.Since(begin).Nanoseconds(),
 			logFieldClientID, clientID,
+			logFieldEnv, e,
 			logFieldOp, "Store",
 			logFieldSecret, secret,
 		}
@@ -199,7 +204,7 @@ func (r *logTokenRepo) Store(clientID, secret string) (token Token, err error) {
 		_ = r.logger.Log(ps...)
 	}(time.Now())
 
-	return r.next.Store(clientID, secret)
+	return r.next.Store(clientID, secret, e)
 }
 
 func (r *logTokenRepo) Setup() (err error) {
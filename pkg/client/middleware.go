@@ -13,6 +13,7 @@ type contextKey string
 // Context keys.
 const (
 	ContextKeyClientID contextKey = "clientID"
+	ContextKeyEnv      contextKey = "env"
 	contextKeySecret   contextKey = "clientSecret"
 )
 
@@ -30,12 +31,13 @@ func AuthMiddleware(svc Service) endpoint.Middleware {
 				return nil, errors.Wrap(errors.ErrSecretMissing, "request context")
 			}
 
-			c, err := svc.LookupBySecret(secret)
+			c, e, err := svc.LookupBySecret(secret)
 			if err != nil {
 				return nil, errors.Wrap(errors.ErrClientNotFound, err.Error())
 			}
 
 			ctx = context.WithValue(ctx, ContextKeyClientID, c.id)
+			ctx = context.WithValue(ctx, ContextKeyEnv, e)
 
 			return next(ctx, request)
 		}
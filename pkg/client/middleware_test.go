@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/errors"
 	"github.com/lifesum/configsum/pkg/generate"
 )
@@ -24,7 +25,7 @@ func TestAuthMiddleware(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = tokenRepo.Store(clientID, secret)
+	_, err = tokenRepo.Store(clientID, secret, env.Default)
 	if err != nil {
 		t.Fatal(err)
 	}
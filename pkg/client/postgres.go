@@ -7,6 +7,7 @@ import (
 
 	"github.com/jmoiron/sqlx"
 
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/errors"
 	"github.com/lifesum/configsum/pkg/pg"
 )
@@ -14,17 +15,10 @@ import (
 const (
 	pgDefaultSchema = "client"
 
-	pgCreateSchema = `CREATE SCHEMA IF NOT EXISTS %s`
-
-	pgClientCreateTable = `
-		CREATE TABLE IF NOT EXISTS %s.clients(
-			id TEXT NOT NULL PRIMARY KEY,
-			deleted BOOL DEFAULT FALSE,
-			name TEXT NOT NULL UNIQUE,
-			created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (now() AT TIME ZONE 'utc')
-		)`
-	pgClientDropTable = `DROP TABLE IF EXISTS %s.clients CASCADE`
-	pgClientList      = `
+	pgClientComponent = "clients"
+	pgTokenComponent  = "tokens"
+
+	pgClientList = `
 		/* pgClientList */
 		SELECT
 			created_at, deleted, id, name
@@ -59,23 +53,16 @@ const (
 				:name
 			)`
 
-	pgTokenCreateTable = `
-		CREATE TABLE IF NOT EXISTS %s.tokens(
-			secret TEXT NOT NULL PRIMARY KEY,
-			deleted BOOL DEFAULT FALSE,
-			client_id TEXT NOT NULL,
-			created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (now() AT TIME ZONE 'utc')
-		)`
-	pgTokenDropTable = `DROP TABLE IF EXISTS %s.tokens CASCADE`
 	pgTokenGetLatest = `
 		/* pgTokenGetLatest */
 		SELECT
-			client_id, deleted, secret, created_at
+			client_id, deleted, environment, secret, created_at
 		FROM
 			%s.tokens
 		WHERE
 			client_id = :id
 			AND deleted = :deleted
+			AND environment = :environment
 		ORDER BY
 			created_at DESC
 		LIMIT
@@ -83,7 +70,7 @@ const (
 	pgTokenLookup = `
 		/* pgTokenLookup */
 		SELECT
-			client_id, deleted, secret, created_at
+			client_id, deleted, environment, secret, created_at
 		FROM
 			%s.tokens
 		WHERE
@@ -96,14 +83,73 @@ const (
 		INSERT INTO
 			%s.tokens(
 				client_id,
+				environment,
 				secret
 			)
 			VALUES(
 				:clientId,
+				:environment,
 				:secret
 			)`
 )
 
+var (
+	pgClientMigrations = []pg.Migration{
+		{
+			Version:     1,
+			Description: "create clients",
+			Up: []string{`
+				CREATE TABLE IF NOT EXISTS %s.clients(
+					id TEXT NOT NULL PRIMARY KEY,
+					deleted BOOL DEFAULT FALSE,
+					name TEXT NOT NULL UNIQUE,
+					created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (now() AT TIME ZONE 'utc')
+				)`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS %s.clients CASCADE`,
+			},
+		},
+	}
+
+	pgTokenMigrations = []pg.Migration{
+		{
+			Version:     1,
+			Description: "create tokens",
+			Up: []string{`
+				CREATE TABLE IF NOT EXISTS %s.tokens(
+					secret TEXT NOT NULL PRIMARY KEY,
+					deleted BOOL DEFAULT FALSE,
+					client_id TEXT NOT NULL,
+					created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (now() AT TIME ZONE 'utc')
+				)`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS %s.tokens CASCADE`,
+			},
+		},
+		{
+			Version:     2,
+			Description: "add environment to tokens",
+			Up: []string{
+				`ALTER TABLE %s.tokens ADD COLUMN IF NOT EXISTS environment TEXT NOT NULL DEFAULT 'prod'`,
+			},
+			Down: []string{
+				`ALTER TABLE IF EXISTS %s.tokens DROP COLUMN IF EXISTS environment`,
+			},
+		},
+	}
+)
+
+// PGMigrators returns the migrators of all Postgres repos of the package in
+// their default schema.
+func PGMigrators(db *sqlx.DB) []*pg.Migrator {
+	return []*pg.Migrator{
+		NewPostgresRepo(db).Migrator(),
+		NewPostgresTokenRepo(db).Migrator(),
+	}
+}
+
 // PGRepoOption sets an optiomal parameter for the repo.
 type PGRepoOption func(*PGRepo)
 
@@ -257,31 +303,17 @@ func (r *PGRepo) Store(id, name string) (Client, error) {
 
 // Setup prepares the PGRepo for operation.
 func (r *PGRepo) Setup() error {
-	for _, q := range []string{
-		r.prefixSchema(pgCreateSchema),
-		r.prefixSchema(pgClientCreateTable),
-	} {
-		_, err := r.db.Exec(q)
-		if err != nil {
-			return errors.Wrap(err, "pgRepo.setup()")
-		}
-	}
-
-	return nil
+	return errors.Wrap(r.Migrator().Up(), "pgRepo.setup()")
 }
 
 // Teardown deconstructs all dependencies of the repo.
 func (r *PGRepo) Teardown() error {
-	for _, q := range []string{
-		r.prefixSchema(pgClientDropTable),
-	} {
-		_, err := r.db.Exec(q)
-		if err != nil {
-			return errors.Wrap(err, "pgRepo.teardowm()")
-		}
-	}
+	return errors.Wrap(r.Migrator().Reset(), "pgRepo.teardowm()")
+}
 
-	return nil
+// Migrator returns the migrator for the tables of the repo.
+func (r *PGRepo) Migrator() *pg.Migrator {
+	return pg.NewMigrator(r.db, r.schema, pgClientComponent, pgClientMigrations)
 }
 
 func (r *PGRepo) prefixSchema(query string) string {
@@ -317,13 +349,14 @@ func NewPostgresTokenRepo(db *sqlx.DB, options ...PGTokenRepoOption) *PGTokenRep
 	return r
 }
 
-// GetLatest returns the newest token for the given client id.
-func (r *PGTokenRepo) GetLatest(clientID string) (Token, error) {
+// GetLatest returns the newest token for the given client id and environment.
+func (r *PGTokenRepo) GetLatest(clientID string, e env.Env) (Token, error) {
 	query, args, err := r.db.BindNamed(
 		r.prefixSchema(pgTokenGetLatest),
 		map[string]interface{}{
-			"id":      clientID,
-			"deleted": false,
+			"id":          clientID,
+			"deleted":     false,
+			"environment": e,
 		},
 	)
 	if err != nil {
@@ -333,6 +366,7 @@ func (r *PGTokenRepo) GetLatest(clientID string) (Token, error) {
 	raw := struct {
 		ClientID  string    `db:"client_id"`
 		Deleted   bool      `db:"deleted"`
+		Env       env.Env   `db:"environment"`
 		Secret    string    `db:"secret"`
 		CreatedAt time.Time `db:"created_at"`
 	}{}
@@ -345,7 +379,7 @@ func (r *PGTokenRepo) GetLatest(clientID string) (Token, error) {
 				return Token{}, err
 			}
 
-			return r.GetLatest(clientID)
+			return r.GetLatest(clientID, e)
 		case sql.ErrNoRows:
 			return Token{}, errors.Wrap(errors.ErrNotFound, "token lookup")
 		default:
@@ -356,6 +390,7 @@ func (r *PGTokenRepo) GetLatest(clientID string) (Token, error) {
 	return Token{
 		clientID:  raw.ClientID,
 		deleted:   raw.Deleted,
+		env:       raw.Env,
 		secret:    raw.Secret,
 		createdAt: raw.CreatedAt,
 	}, nil
@@ -377,6 +412,7 @@ func (r *PGTokenRepo) Lookup(secret string) (Token, error) {
 	raw := struct {
 		ClientID  string    `db:"client_id"`
 		Deleted   bool      `db:"deleted"`
+		Env       env.Env   `db:"environment"`
 		Secret    string    `db:"secret"`
 		CreatedAt time.Time `db:"created_at"`
 	}{}
@@ -400,18 +436,21 @@ func (r *PGTokenRepo) Lookup(secret string) (Token, error) {
 	return Token{
 		clientID:  raw.ClientID,
 		deleted:   raw.Deleted,
+		env:       raw.Env,
 		secret:    raw.Secret,
 		createdAt: raw.CreatedAt,
 	}, nil
 }
 
-// Store persists a new token with the given client id and secret.
-func (r *PGTokenRepo) Store(clientID, secret string) (Token, error) {
+// Store persists a new token with the given client id, secret and
+// environment.
+func (r *PGTokenRepo) Store(clientID, secret string, e env.Env) (Token, error) {
 	_, err := r.db.NamedExec(
 		r.prefixSchema(pgTokenStore),
 		map[string]interface{}{
-			"clientId": clientID,
-			"secret":   secret,
+			"clientId":    clientID,
+			"environment": e,
+			"secret":      secret,
 		},
 	)
 	if err != nil {
@@ -421,7 +460,7 @@ func (r *PGTokenRepo) Store(clientID, secret string) (Token, error) {
 				return Token{}, err
 			}
 
-			return r.Store(clientID, secret)
+			return r.Store(clientID, secret, e)
 		default:
 			return Token{}, errors.Wrap(err, "named exec")
 		}
@@ -430,6 +469,7 @@ func (r *PGTokenRepo) Store(clientID, secret string) (Token, error) {
 	return Token{
 		clientID:  clientID,
 		deleted:   false,
+		env:       e,
 		secret:    secret,
 		createdAt: time.Now(),
 	}, nil
@@ -437,31 +477,17 @@ func (r *PGTokenRepo) Store(clientID, secret string) (Token, error) {
 
 // Setup prepares all dependencies for the Postgres repo.
 func (r *PGTokenRepo) Setup() error {
-	for _, q := range []string{
-		r.prefixSchema(pgCreateSchema),
-		r.prefixSchema(pgTokenCreateTable),
-	} {
-		_, err := r.db.Exec(q)
-		if err != nil {
-			return errors.Wrap(err, "pgTokenRepo.setup()")
-		}
-	}
-
-	return nil
+	return errors.Wrap(r.Migrator().Up(), "pgTokenRepo.setup()")
 }
 
-// Teardown removes all dependencies of the Postgres repo.
+// Teardown deconstructs all dependencies of the repo.
 func (r *PGTokenRepo) Teardown() error {
-	for _, q := range []string{
-		r.prefixSchema(pgTokenDropTable),
-	} {
-		_, err := r.db.Exec(q)
-		if err != nil {
-			return errors.Wrap(err, "pgTokenRepo.teardown()")
-		}
-	}
+	return errors.Wrap(r.Migrator().Reset(), "pgTokenRepo.teardown()")
+}
 
-	return nil
+// Migrator returns the migrator for the tables of the repo.
+func (r *PGTokenRepo) Migrator() *pg.Migrator {
+	return pg.NewMigrator(r.db, r.schema, pgTokenComponent, pgTokenMigrations)
 }
 
 func (r *PGTokenRepo) prefixSchema(query string) string {
@@ -41,6 +41,30 @@ func TestPGTokenRepoLookupNotFound(t *testing.T) {
 	testTokenRepoLookupNotFound(t, preparePGTokenRepo)
 }
 
+func TestPGTokenRepoMigrate(t *testing.T) {
+	m := preparePGTokenRepo(t).(*PGTokenRepo).Migrator()
+
+	for _, step := range []func() error{
+		m.Up,
+		m.Up,
+		func() error { return m.Down(1) },
+		m.Up,
+	} {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := s.Version, s.Latest; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
 func preparePGRepo(t *testing.T) Repo {
 	db, err := sqlx.Connect("postgres", pgURI)
 	if err != nil {
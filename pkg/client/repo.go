@@ -1,6 +1,10 @@
 package client
 
-import "time"
+import (
+	"time"
+
+	"github.com/lifesum/configsum/pkg/env"
+)
 
 // Client represents distinct consumers like mobile apps, SPAs or other web
 // servers.
@@ -30,18 +34,20 @@ type RepoMiddleware func(Repo) Repo
 type TokenRepo interface {
 	lifecycle
 
-	GetLatest(clientID string) (Token, error)
+	GetLatest(clientID string, e env.Env) (Token, error)
 	Lookup(secret string) (Token, error)
-	Store(clientID, secret string) (Token, error)
+	Store(clientID, secret string, e env.Env) (Token, error)
 }
 
 // TokenRepoMiddleware is a chainable behaviour modifier for TokenRepo.
 type TokenRepoMiddleware func(next TokenRepo) TokenRepo
 
-// Token is the relation between a Client secret and id.
+// Token is the relation between a Client secret and id. Every token grants
+// access to the base configs of one environment.
 type Token struct {
 	clientID  string
 	deleted   bool
+	env       env.Env
 	secret    string
 	createdAt time.Time
 }
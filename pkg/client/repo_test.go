@@ -7,6 +7,7 @@ import (
 
 	"github.com/oklog/ulid"
 
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/errors"
 	"github.com/lifesum/configsum/pkg/generate"
 )
@@ -122,12 +123,12 @@ func testTokenRepoGetLatest(t *testing.T, p prepareTokenRepoFunc) {
 		t.Fatal(err)
 	}
 
-	_, err = repo.Store(clientID.String(), secret)
+	_, err = repo.Store(clientID.String(), secret, env.Dev)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	token, err := repo.GetLatest(clientID.String())
+	token, err := repo.GetLatest(clientID.String(), env.Dev)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -140,6 +141,10 @@ func testTokenRepoGetLatest(t *testing.T, p prepareTokenRepoFunc) {
 		t.Errorf("have %v, want %v", have, want)
 	}
 
+	if have, want := token.env, env.Dev; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
 	if have, want := token.secret, secret; have != want {
 		t.Errorf("have %v, want %v", have, want)
 	}
@@ -161,7 +166,7 @@ func testTokenRepoLookup(t *testing.T, p prepareTokenRepoFunc) {
 		t.Fatal(err)
 	}
 
-	_, err = repo.Store(clientID.String(), secret)
+	_, err = repo.Store(clientID.String(), secret, env.Default)
 	if err != nil {
 		t.Fatal(err)
 	}
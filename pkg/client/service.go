@@ -6,15 +6,16 @@ import (
 
 	"github.com/oklog/ulid"
 
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/errors"
 	"github.com/lifesum/configsum/pkg/generate"
 )
 
 // Service provides Clients.
 type Service interface {
-	Create(name string) (Client, string, error)
+	Create(name string) (Client, map[env.Env]string, error)
 	ListWithToken() (clientTokens, error)
-	LookupBySecret(secret string) (Client, error)
+	LookupBySecret(secret string) (Client, env.Env, error)
 }
 
 type service struct {
@@ -32,28 +33,35 @@ func NewService(repo Repo, tokenRepo TokenRepo) Service {
 	}
 }
 
-func (s *service) Create(name string) (Client, string, error) {
+// Create stores a new client and issues a token for every environment.
+func (s *service) Create(name string) (Client, map[env.Env]string, error) {
 	clientID, err := ulid.New(ulid.Timestamp(time.Now()), s.seed)
 	if err != nil {
-		return Client{}, "", err
+		return Client{}, nil, err
 	}
 
 	c, err := s.repo.Store(clientID.String(), name)
 	if err != nil {
-		return Client{}, "", err
+		return Client{}, nil, err
 	}
 
-	secret, err := generate.SecureToken(secretByteLen)
-	if err != nil {
-		return Client{}, "", err
-	}
+	secrets := map[env.Env]string{}
 
-	_, err = s.tokenRepo.Store(clientID.String(), secret)
-	if err != nil {
-		return Client{}, "", err
+	for _, e := range env.All {
+		secret, err := generate.SecureToken(secretByteLen)
+		if err != nil {
+			return Client{}, nil, err
+		}
+
+		_, err = s.tokenRepo.Store(clientID.String(), secret, e)
+		if err != nil {
+			return Client{}, nil, err
+		}
+
+		secrets[e] = secret
 	}
 
-	return c, secret, nil
+	return c, secrets, nil
 }
 
 func (s *service) ListWithToken() (clientTokens, error) {
@@ -65,26 +73,44 @@ func (s *service) ListWithToken() (clientTokens, error) {
 	ct := clientTokens{}
 
 	for _, c := range cs {
-		t, err := s.tokenRepo.GetLatest(c.id)
-		if err != nil {
+		ts := envTokens{}
+
+		for _, e := range env.All {
+			t, err := s.tokenRepo.GetLatest(c.id, e)
+			if err != nil {
+				continue
+			}
+
+			ts[e] = t
+		}
+
+		if len(ts) == 0 {
 			continue
 		}
 
-		ct[c] = t
+		ct[c] = ts
 	}
 
 	return ct, nil
 }
 
-func (s *service) LookupBySecret(secret string) (Client, error) {
+func (s *service) LookupBySecret(secret string) (Client, env.Env, error) {
 	t, err := s.tokenRepo.Lookup(secret)
 	if err != nil {
-		return Client{}, errors.Wrap(err, "service lookup")
+		return Client{}, "", errors.Wrap(err, "service lookup")
 	}
 
-	return s.repo.Lookup(t.clientID)
+	c, err := s.repo.Lookup(t.clientID)
+	if err != nil {
+		return Client{}, "", err
+	}
+
+	return c, t.env, nil
 }
 
-// clientTokens is a mapping of Client to single Token, usually the latest one
-// for the specific Client.
-type clientTokens map[Client]Token
+// clientTokens is a mapping of Client to its tokens, usually the latest one
+// per environment.
+type clientTokens map[Client]envTokens
+
+// envTokens is a mapping of environment to a single Token.
+type envTokens map[env.Env]Token
@@ -7,6 +7,7 @@ import (
 
 	"github.com/oklog/ulid"
 
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/generate"
 )
 
@@ -20,7 +21,7 @@ func TestServiceCreate(t *testing.T) {
 		svc       = NewService(repo, tokenRepo)
 	)
 
-	clientSVC, secret, err := svc.Create(name)
+	clientSVC, secrets, err := svc.Create(name)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -42,17 +43,19 @@ func TestServiceCreate(t *testing.T) {
 		t.Errorf("have %v, want %v", have, want)
 	}
 
-	token, err := tokenRepo.GetLatest(clientSVC.id)
-	if err != nil {
-		t.Fatal(err)
-	}
+	for _, e := range env.All {
+		token, err := tokenRepo.GetLatest(clientSVC.id, e)
+		if err != nil {
+			t.Fatal(err)
+		}
 
-	if have, want := token.clientID, clientSVC.id; have != want {
-		t.Errorf("have %v, want %v", have, want)
-	}
+		if have, want := token.clientID, clientSVC.id; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
 
-	if have, want := token.secret, secret; have != want {
-		t.Errorf("have %v, want %v", have, want)
+		if have, want := token.secret, secrets[e]; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
 	}
 }
 
@@ -105,12 +108,12 @@ func TestServiceLookupBySecret(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = tokenRepo.Store(c.id, secret)
+	_, err = tokenRepo.Store(c.id, secret, env.Staging)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	c, err = svc.LookupBySecret(secret)
+	c, e, err := svc.LookupBySecret(secret)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -118,6 +121,10 @@ func TestServiceLookupBySecret(t *testing.T) {
 	if have, want := c.id, clientID; err != nil {
 		t.Errorf("have %v, want %v", have, want)
 	}
+
+	if have, want := e, env.Staging; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
 }
 
 func testCreateClientWithToken(repo Repo, tokenRepo TokenRepo, t *testing.T) {
@@ -138,7 +145,7 @@ func testCreateClientWithToken(repo Repo, tokenRepo TokenRepo, t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = tokenRepo.Store(c.id, secret)
+	_, err = tokenRepo.Store(c.id, secret, env.Default)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"reflect"
+	"time"
+)
+
+// Compaction reports the outcome of a compaction run.
+type Compaction struct {
+	Users   int
+	Deleted int
+}
+
+// CompactionService prunes the render history of users.
+type CompactionService interface {
+	// Compact deletes user configs which are neither among the latest keep
+	// renders of a base and user nor younger than the maximum age. Renders
+	// holding rule decisions not present in any kept render are retained, so
+	// decisions are never lost.
+	Compact(keep int, maxAge time.Duration, now time.Time) (Compaction, error)
+}
+
+type compactionService struct {
+	userRepo UserRepo
+}
+
+// NewCompactionService provides compaction of the user render history.
+func NewCompactionService(userRepo UserRepo) CompactionService {
+	return &compactionService{
+		userRepo: userRepo,
+	}
+}
+
+func (s *compactionService) Compact(
+	keep int,
+	maxAge time.Duration,
+	now time.Time,
+) (Compaction, error) {
+	if keep < 1 {
+		keep = 1
+	}
+
+	before := now.Add(-maxAge)
+
+	ks, err := s.userRepo.ListCompactable(keep, before)
+	if err != nil {
+		return Compaction{}, err
+	}
+
+	c := Compaction{}
+
+	for _, k := range ks {
+		cs, err := s.userRepo.History(k.BaseID, k.UserID)
+		if err != nil {
+			return c, err
+		}
+
+		ids := compactable(cs, keep, before)
+		if len(ids) == 0 {
+			continue
+		}
+
+		n, err := s.userRepo.Delete(ids...)
+		if err != nil {
+			return c, err
+		}
+
+		c.Deleted += n
+		c.Users++
+	}
+
+	return c, nil
+}
+
+// compactable returns the ids of the user configs, given newest first, which
+// can be removed without losing rule decisions.
+func compactable(cs []UserConfig, keep int, before time.Time) []string {
+	var (
+		ids  = []string{}
+		kept = []UserConfig{}
+	)
+
+	for i, c := range cs {
+		if i < keep || !c.createdAt.Before(before) || !covered(c, kept) {
+			kept = append(kept, c)
+			continue
+		}
+
+		ids = append(ids, c.id)
+	}
+
+	return ids
+}
+
+// covered reports if every decision of the user config is also held by one of
+// the kept user configs.
+func covered(c UserConfig, kept []UserConfig) bool {
+	for id, d := range c.ruleDecisions {
+		found := false
+
+		for _, k := range kept {
+			if kd, ok := k.ruleDecisions[id]; ok && reflect.DeepEqual(kd, d) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
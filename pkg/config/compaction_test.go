@@ -0,0 +1,61 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lifesum/configsum/pkg/rule"
+)
+
+func TestCompactable(t *testing.T) {
+	var (
+		now    = time.Now()
+		before = now.Add(-24 * time.Hour)
+		old    = before.Add(-time.Hour)
+		cs     = []UserConfig{
+			{
+				id:            "latest",
+				ruleDecisions: rule.Decisions{"rollout": []int{42}},
+				createdAt:     old,
+			},
+			{
+				id:            "young",
+				ruleDecisions: rule.Decisions{},
+				createdAt:     now.Add(-time.Hour),
+			},
+			{
+				id:            "covered",
+				ruleDecisions: rule.Decisions{"rollout": []int{42}},
+				createdAt:     old,
+			},
+			{
+				id: "inactive-experiment",
+				ruleDecisions: rule.Decisions{
+					"experiment": []int{1, 7},
+					"rollout":    []int{42},
+				},
+				createdAt: old,
+			},
+			{
+				id:            "covered-by-retained",
+				ruleDecisions: rule.Decisions{"experiment": []int{1, 7}},
+				createdAt:     old,
+			},
+			{
+				id:            "empty",
+				ruleDecisions: rule.Decisions{},
+				createdAt:     old,
+			},
+		}
+		want = []string{"covered", "covered-by-retained", "empty"}
+	)
+
+	if have := compactable(cs, 1, before); !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := len(compactable(cs, len(cs), before)), 0; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
@@ -12,12 +12,14 @@ import (
 
 	"github.com/lifesum/configsum/pkg/auth"
 	"github.com/lifesum/configsum/pkg/client"
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/errors"
 	"github.com/lifesum/configsum/pkg/rule"
 )
 
 type baseCreateRequest struct {
 	clientID string
+	env      env.Env
 	name     string
 }
 
@@ -25,7 +27,7 @@ func baseCreateEndpoint(svc BaseService) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(baseCreateRequest)
 
-		c, err := svc.Create(req.clientID, req.name)
+		c, err := svc.Create(req.clientID, req.env, req.name)
 		if err != nil {
 			return nil, err
 		}
@@ -34,6 +36,71 @@ func baseCreateEndpoint(svc BaseService) endpoint.Endpoint {
 	}
 }
 
+type baseDeprecateRequest struct {
+	id  string
+	key string
+}
+
+func baseDeprecateEndpoint(svc BaseService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(baseDeprecateRequest)
+
+		c, err := svc.Deprecate(req.id, req.key)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseBaseConfig{config: c}, nil
+	}
+}
+
+type baseDeprecationsRequest struct {
+	id string
+}
+
+type baseDeprecationsResponse struct {
+	deprecations []Deprecation
+}
+
+func (r baseDeprecationsResponse) MarshalJSON() ([]byte, error) {
+	type deprecation struct {
+		Key          string    `json:"key"`
+		Rules        []string  `json:"rules"`
+		DeprecatedAt time.Time `json:"deprecated_at"`
+		RemovableAt  time.Time `json:"removable_at"`
+	}
+
+	ds := []deprecation{}
+
+	for _, d := range r.deprecations {
+		ds = append(ds, deprecation{
+			Key:          d.Key,
+			Rules:        d.Rules,
+			DeprecatedAt: d.DeprecatedAt,
+			RemovableAt:  d.RemovableAt,
+		})
+	}
+
+	return json.Marshal(struct {
+		Deprecations []deprecation `json:"deprecations"`
+	}{
+		Deprecations: ds,
+	})
+}
+
+func baseDeprecationsEndpoint(svc BaseService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(baseDeprecationsRequest)
+
+		ds, err := svc.Deprecations(req.id)
+		if err != nil {
+			return nil, err
+		}
+
+		return baseDeprecationsResponse{deprecations: ds}, nil
+	}
+}
+
 type baseGetRequest struct {
 	id string
 }
@@ -79,18 +146,38 @@ func (r responseBaseConfig) MarshalJSON() ([]byte, error) {
 	v := struct {
 		ClientID   string                  `json:"client_id"`
 		Deleted    bool                    `json:"deleted"`
+		Env        env.Env                 `json:"environment"`
 		ID         string                  `json:"id"`
 		Name       string                  `json:"name"`
 		Parameters rule.ResponseParameters `json:"parameters"`
+		Schema     rule.ParameterSchema    `json:"schema"`
+		Deprecated map[string]time.Time    `json:"deprecated"`
+		Platforms  rule.PlatformParameters `json:"platforms"`
 		CreatedAt  time.Time               `json:"created_at"`
 		UpdatedAt  time.Time               `json:"updated_at"`
 	}{
-		ClientID:  r.config.ClientID,
-		Deleted:   r.config.Deleted,
-		ID:        r.config.ID,
-		Name:      r.config.Name,
-		CreatedAt: r.config.CreatedAt,
-		UpdatedAt: r.config.UpdatedAt,
+		ClientID:   r.config.ClientID,
+		Deleted:    r.config.Deleted,
+		Env:        r.config.Env,
+		ID:         r.config.ID,
+		Name:       r.config.Name,
+		Schema:     r.config.Schema,
+		Deprecated: r.config.Deprecated,
+		Platforms:  r.config.Platforms,
+		CreatedAt:  r.config.CreatedAt,
+		UpdatedAt:  r.config.UpdatedAt,
+	}
+
+	if v.Schema == nil {
+		v.Schema = rule.ParameterSchema{}
+	}
+
+	if v.Deprecated == nil {
+		v.Deprecated = map[string]time.Time{}
+	}
+
+	if v.Platforms == nil {
+		v.Platforms = rule.PlatformParameters{}
 	}
 
 	ps := rule.ResponseParameters{}
@@ -120,6 +207,24 @@ func baseListEndpoint(svc BaseService) endpoint.Endpoint {
 	}
 }
 
+type baseUndeprecateRequest struct {
+	id  string
+	key string
+}
+
+func baseUndeprecateEndpoint(svc BaseService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(baseUndeprecateRequest)
+
+		c, err := svc.Undeprecate(req.id, req.key)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseBaseConfig{config: c}, nil
+	}
+}
+
 type baseUpdateRequest struct {
 	id         string
 	parameters rule.Parameters
@@ -138,17 +243,354 @@ func baseUpdateEndpoint(svc BaseService) endpoint.Endpoint {
 	}
 }
 
+type baseUpdatePlatformRequest struct {
+	id         string
+	parameters rule.Parameters
+	platform   string
+}
+
+func baseUpdatePlatformEndpoint(svc BaseService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(baseUpdatePlatformRequest)
+
+		c, err := svc.UpdatePlatform(req.id, req.platform, req.parameters)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseBaseConfig{config: c}, nil
+	}
+}
+
+type baseUpdateSchemaRequest struct {
+	id     string
+	schema rule.ParameterSchema
+}
+
+func baseUpdateSchemaEndpoint(svc BaseService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(baseUpdateSchemaRequest)
+
+		c, err := svc.UpdateSchema(req.id, req.schema)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseBaseConfig{config: c}, nil
+	}
+}
+
+type privacyEraseRequest struct {
+	userID string
+}
+
+func privacyEraseEndpoint(svc PrivacyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(privacyEraseRequest)
+
+		e, err := svc.Erase(req.userID)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseErasure{erasure: e}, nil
+	}
+}
+
+type privacyErasuresRequest struct {
+	userID string
+}
+
+type privacyErasuresResponse struct {
+	erasures []Erasure
+}
+
+func (r privacyErasuresResponse) MarshalJSON() ([]byte, error) {
+	es := []responseErasure{}
+
+	for _, e := range r.erasures {
+		es = append(es, responseErasure{erasure: e})
+	}
+
+	return json.Marshal(struct {
+		Erasures []responseErasure `json:"erasures"`
+	}{
+		Erasures: es,
+	})
+}
+
+func privacyErasuresEndpoint(svc PrivacyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(privacyErasuresRequest)
+
+		es, err := svc.Erasures(req.userID)
+		if err != nil {
+			return nil, err
+		}
+
+		return privacyErasuresResponse{erasures: es}, nil
+	}
+}
+
+type privacyExportRequest struct {
+	userID string
+}
+
+type privacyExportResponse struct {
+	configs   []UserConfig
+	userID    string
+	userLists []rule.UserList
+}
+
+func (r privacyExportResponse) MarshalJSON() ([]byte, error) {
+	type userConfig struct {
+		ID            string          `json:"id"`
+		BaseID        string          `json:"base_id"`
+		Rendered      rule.Parameters `json:"rendered"`
+		RuleDecisions rule.Decisions  `json:"rule_decisions"`
+		CreatedAt     time.Time       `json:"created_at"`
+	}
+
+	cs := []userConfig{}
+
+	for _, c := range r.configs {
+		cs = append(cs, userConfig{
+			ID:            c.id,
+			BaseID:        c.baseID,
+			Rendered:      c.rendered,
+			RuleDecisions: c.ruleDecisions,
+			CreatedAt:     c.createdAt,
+		})
+	}
+
+	type userList struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+
+	ls := []userList{}
+
+	for _, l := range r.userLists {
+		ls = append(ls, userList{
+			ID:   l.ID,
+			Name: l.Name,
+		})
+	}
+
+	return json.Marshal(struct {
+		UserID    string       `json:"user_id"`
+		Configs   []userConfig `json:"configs"`
+		UserLists []userList   `json:"user_lists"`
+	}{
+		UserID:    r.userID,
+		Configs:   cs,
+		UserLists: ls,
+	})
+}
+
+func privacyExportEndpoint(svc PrivacyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(privacyExportRequest)
+
+		cs, ls, err := svc.Export(req.userID)
+		if err != nil {
+			return nil, err
+		}
+
+		return privacyExportResponse{
+			configs:   cs,
+			userID:    req.userID,
+			userLists: ls,
+		}, nil
+	}
+}
+
+type responseErasure struct {
+	erasure Erasure
+}
+
+func (r responseErasure) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID        string    `json:"id"`
+		UserID    string    `json:"user_id"`
+		Deleted   int       `json:"deleted"`
+		CreatedAt time.Time `json:"created_at"`
+	}{
+		ID:        r.erasure.ID,
+		UserID:    r.erasure.UserID,
+		Deleted:   r.erasure.Deleted,
+		CreatedAt: r.erasure.CreatedAt,
+	})
+}
+
+type promotionDiffRequest struct {
+	id  string
+	env env.Env
+}
+
+func promotionDiffEndpoint(svc PromotionService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(promotionDiffRequest)
+
+		p, err := svc.Diff(req.id, req.env)
+		if err != nil {
+			return nil, err
+		}
+
+		return responsePromotion{promotion: p}, nil
+	}
+}
+
+type promotionListRequest struct {
+	id string
+}
+
+type promotionListResponse struct {
+	promotions []Promotion
+}
+
+func (r promotionListResponse) MarshalJSON() ([]byte, error) {
+	ps := []responsePromotion{}
+
+	for _, p := range r.promotions {
+		ps = append(ps, responsePromotion{promotion: p})
+	}
+
+	return json.Marshal(struct {
+		Promotions []responsePromotion `json:"promotions"`
+	}{
+		Promotions: ps,
+	})
+}
+
+func promotionListEndpoint(svc PromotionService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(promotionListRequest)
+
+		ps, err := svc.List(req.id)
+		if err != nil {
+			return nil, err
+		}
+
+		return promotionListResponse{promotions: ps}, nil
+	}
+}
+
+type promotionPromoteRequest struct {
+	id  string
+	env env.Env
+}
+
+func promotionPromoteEndpoint(svc PromotionService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(promotionPromoteRequest)
+
+		p, err := svc.Promote(req.id, req.env)
+		if err != nil {
+			return nil, err
+		}
+
+		return responsePromotion{promotion: p}, nil
+	}
+}
+
+type responsePromotion struct {
+	promotion Promotion
+}
+
+func (r responsePromotion) MarshalJSON() ([]byte, error) {
+	type parameterChange struct {
+		Key  string      `json:"key"`
+		Op   ChangeOp    `json:"op"`
+		From interface{} `json:"from,omitempty"`
+		To   interface{} `json:"to,omitempty"`
+	}
+
+	type ruleChange struct {
+		Name     string   `json:"name"`
+		Op       ChangeOp `json:"op"`
+		SourceID string   `json:"source_id,omitempty"`
+		TargetID string   `json:"target_id,omitempty"`
+	}
+
+	v := struct {
+		ID         string            `json:"id"`
+		SourceID   string            `json:"source_id"`
+		TargetID   string            `json:"target_id"`
+		From       env.Env           `json:"from"`
+		To         env.Env           `json:"to"`
+		Parameters []parameterChange `json:"parameters"`
+		Rules      []ruleChange      `json:"rules"`
+		CreatedAt  time.Time         `json:"created_at"`
+	}{
+		ID:         r.promotion.ID,
+		SourceID:   r.promotion.SourceID,
+		TargetID:   r.promotion.TargetID,
+		From:       r.promotion.From,
+		To:         r.promotion.To,
+		Parameters: []parameterChange{},
+		Rules:      []ruleChange{},
+		CreatedAt:  r.promotion.CreatedAt,
+	}
+
+	for _, c := range r.promotion.Parameters {
+		v.Parameters = append(v.Parameters, parameterChange(c))
+	}
+
+	for _, c := range r.promotion.Rules {
+		v.Rules = append(v.Rules, ruleChange(c))
+	}
+
+	return json.Marshal(v)
+}
+
+type snapshotExportRequest struct {
+	clientID string
+	env      env.Env
+}
+
+type snapshotExportResponse struct {
+	raw []byte
+}
+
+func (r snapshotExportResponse) MarshalJSON() ([]byte, error) {
+	return r.raw, nil
+}
+
+func snapshotExportEndpoint(svc SnapshotService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(snapshotExportRequest)
+
+		raw, err := svc.Export(req.clientID, req.env)
+		if err != nil {
+			return nil, err
+		}
+
+		return snapshotExportResponse{raw: raw}, nil
+	}
+}
+
 type device struct {
+	ID       string   `json:"id"`
 	Location location `json:"location"`
+	OS       deviceOS `json:"os"`
+}
+
+type deviceOS struct {
+	Platform string `json:"platform"`
+	Version  string `json:"version"`
 }
 
 type location struct {
-	locale language.Tag
+	country language.Region
+	locale  language.Tag
+	offset  int
 }
 
 func (l *location) UnmarshalJSON(raw []byte) error {
 	v := struct {
-		Locale string `json:"locale"`
+		Locale         string `json:"locale"`
+		TimezoneOffset int    `json:"timezoneOffset"`
 	}{}
 
 	if err := json.Unmarshal(raw, &v); err != nil {
@@ -161,6 +603,7 @@ func (l *location) UnmarshalJSON(raw []byte) error {
 	}
 
 	l.locale = t
+	l.offset = v.TimezoneOffset
 
 	return nil
 }
@@ -190,8 +633,9 @@ func (u *userInfo) UnmarshalJSON(raw []byte) error {
 }
 
 type userRenderContext struct {
-	Device device   `json:"device"`
-	User   userInfo `json:"user"`
+	Device   device                 `json:"device"`
+	Metadata map[string]interface{} `json:"metadata"`
+	User     userInfo               `json:"user"`
 }
 
 type userRenderRequest struct {
@@ -212,6 +656,31 @@ func (r userRenderResponse) StatusCode() int {
 	return http.StatusCreated
 }
 
+type userAliasRequest struct{}
+
+type userAliasResponse struct{}
+
+func (r userAliasResponse) StatusCode() int {
+	return http.StatusNoContent
+}
+
+func userAliasEndpoint(svc UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		var (
+			clientID = ctx.Value(client.ContextKeyClientID).(string)
+			userID   = ctx.Value(auth.ContextKeyUserID).(string)
+		)
+
+		// Only the device the request is authenticated for can be aliased.
+		deviceID, ok := ctx.Value(auth.ContextKeyDeviceID).(string)
+		if !ok || deviceID == "" {
+			return nil, errors.Wrap(errors.ErrDeviceIDMissing, "request context")
+		}
+
+		return userAliasResponse{}, svc.Alias(clientID, deviceID, userID)
+	}
+}
+
 func userRenderEndpoint(svc UserService) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		var (
@@ -220,7 +689,26 @@ func userRenderEndpoint(svc UserService) endpoint.Endpoint {
 			userID   = ctx.Value(auth.ContextKeyUserID).(string)
 		)
 
-		c, err := svc.Render(clientID, req.baseConfig, userID, req.context)
+		e, ok := ctx.Value(client.ContextKeyEnv).(env.Env)
+		if !ok {
+			e = env.Default
+		}
+
+		if country, ok := ctx.Value(contextKeyCountry).(language.Region); ok {
+			req.context.Device.Location.country = country
+		}
+
+		// An authenticated device id takes precedence over the payload.
+		if deviceID, ok := ctx.Value(auth.ContextKeyDeviceID).(string); ok {
+			req.context.Device.ID = deviceID
+		}
+
+		// A verified registration date takes precedence over the payload.
+		if registered, ok := ctx.Value(auth.ContextKeyRegistered).(time.Time); ok {
+			req.context.User.Registered = registered
+		}
+
+		c, err := svc.Render(clientID, e, req.baseConfig, userID, req.context)
 		if err != nil {
 			return nil, err
 		}
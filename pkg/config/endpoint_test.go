@@ -1,10 +1,13 @@
 package config
 
 import (
+	"context"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/lifesum/configsum/pkg/auth"
+	"github.com/lifesum/configsum/pkg/client"
 	"github.com/lifesum/configsum/pkg/errors"
 )
 
@@ -51,3 +54,14 @@ func TestUnmarshalUserContext(t *testing.T) {
 		t.Errorf("have %v, want %v", have, want)
 	}
 }
+
+func TestUserAliasEndpointDeviceMissing(t *testing.T) {
+	ctx := context.WithValue(context.TODO(), client.ContextKeyClientID, "client-1")
+	ctx = context.WithValue(ctx, auth.ContextKeyUserID, "user-1")
+
+	// The service is never reached without an authenticated device.
+	_, err := userAliasEndpoint(nil)(ctx, userAliasRequest{})
+	if have, want := errors.Cause(err), errors.ErrDeviceIDMissing; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
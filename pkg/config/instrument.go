@@ -3,13 +3,15 @@ package config
 import (
 	"time"
 
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/instrument"
 	"github.com/lifesum/configsum/pkg/rule"
 )
 
 const (
-	labelBaseRepo = "base"
-	labelUserRepo = "user"
+	labelBaseRepo      = "base"
+	labelPromotionRepo = "promotion"
+	labelUserRepo      = "user"
 )
 
 type instrumentBaseRepo struct {
@@ -34,14 +36,16 @@ func NewBaseRepoInstrumentMiddleware(
 }
 
 func (r *instrumentBaseRepo) Create(
-	id, clientID, name string,
+	id, clientID string,
+	e env.Env,
+	name string,
 	parameters rule.Parameters,
 ) (c BaseConfig, err error) {
 	defer func(begin time.Time) {
 		r.opObserve(r.store, labelBaseRepo, "Create", begin, err)
 	}(time.Now())
 
-	return r.next.Create(id, clientID, name, parameters)
+	return r.next.Create(id, clientID, e, name, parameters)
 }
 
 func (r *instrumentBaseRepo) GetByID(id string) (c BaseConfig, err error) {
@@ -52,12 +56,16 @@ func (r *instrumentBaseRepo) GetByID(id string) (c BaseConfig, err error) {
 	return r.next.GetByID(id)
 }
 
-func (r *instrumentBaseRepo) GetByName(clientID, name string) (c BaseConfig, err error) {
+func (r *instrumentBaseRepo) GetByName(
+	clientID string,
+	e env.Env,
+	name string,
+) (c BaseConfig, err error) {
 	defer func(begin time.Time) {
 		r.opObserve(r.store, labelBaseRepo, "GetByName", begin, err)
 	}(time.Now())
 
-	return r.next.GetByName(clientID, name)
+	return r.next.GetByName(clientID, e, name)
 }
 
 func (r *instrumentBaseRepo) List() (l BaseList, err error) {
@@ -135,6 +143,63 @@ func (r *instrumentUserRepo) GetLatest(
 	return r.next.GetLatest(baseID, userID)
 }
 
+func (r *instrumentUserRepo) ListCompactable(
+	keep int,
+	before time.Time,
+) (ks []UserKey, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelUserRepo, "ListCompactable", begin, err)
+	}(time.Now())
+
+	return r.next.ListCompactable(keep, before)
+}
+
+func (r *instrumentUserRepo) History(
+	baseID, userID string,
+) (cs []UserConfig, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelUserRepo, "History", begin, err)
+	}(time.Now())
+
+	return r.next.History(baseID, userID)
+}
+
+func (r *instrumentUserRepo) Delete(ids ...string) (n int, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelUserRepo, "Delete", begin, err)
+	}(time.Now())
+
+	return r.next.Delete(ids...)
+}
+
+func (r *instrumentUserRepo) ListUser(
+	userID string,
+) (cs []UserConfig, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelUserRepo, "ListUser", begin, err)
+	}(time.Now())
+
+	return r.next.ListUser(userID)
+}
+
+func (r *instrumentUserRepo) Erase(id, userID string) (e Erasure, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelUserRepo, "Erase", begin, err)
+	}(time.Now())
+
+	return r.next.Erase(id, userID)
+}
+
+func (r *instrumentUserRepo) ListErasures(
+	userID string,
+) (es []Erasure, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelUserRepo, "ListErasures", begin, err)
+	}(time.Now())
+
+	return r.next.ListErasures(userID)
+}
+
 func (r *instrumentUserRepo) setup() (err error) {
 	defer func(begin time.Time) {
 		r.opObserve(r.store, labelUserRepo, "Setup", begin, err)
@@ -150,3 +215,60 @@ func (r *instrumentUserRepo) teardown() (err error) {
 
 	return r.next.teardown()
 }
+
+type instrumentPromotionRepo struct {
+	next      PromotionRepo
+	opObserve instrument.ObserveRepoFunc
+	store     string
+}
+
+// NewPromotionRepoInstrumentMiddleware wraps the next PromotionRepo and add
+// Prometheus instrumentation capabilities.
+func NewPromotionRepoInstrumentMiddleware(
+	opObserve instrument.ObserveRepoFunc,
+	store string,
+) PromotionRepoMiddleware {
+	return func(next PromotionRepo) PromotionRepo {
+		return &instrumentPromotionRepo{
+			next:      next,
+			opObserve: opObserve,
+			store:     store,
+		}
+	}
+}
+
+func (r *instrumentPromotionRepo) Apply(
+	p Promotion,
+	target BaseConfig,
+	rules []rule.Rule,
+) (res Promotion, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelPromotionRepo, "Apply", begin, err)
+	}(time.Now())
+
+	return r.next.Apply(p, target, rules)
+}
+
+func (r *instrumentPromotionRepo) List(baseID string) (l []Promotion, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelPromotionRepo, "List", begin, err)
+	}(time.Now())
+
+	return r.next.List(baseID)
+}
+
+func (r *instrumentPromotionRepo) setup() (err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelPromotionRepo, "Setup", begin, err)
+	}(time.Now())
+
+	return r.next.setup()
+}
+
+func (r *instrumentPromotionRepo) teardown() (err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelPromotionRepo, "Teardown", begin, err)
+	}(time.Now())
+
+	return r.next.teardown()
+}
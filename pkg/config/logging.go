@@ -5,22 +5,29 @@ import (
 
 	"github.com/go-kit/kit/log"
 
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/rule"
 )
 
 // Log fields.
 const (
 	logBaseID        = "baseId"
+	logBefore        = "before"
 	logClientID      = "clientId"
+	logDeleted       = "deleted"
 	logDuration      = "duration"
 	logElements      = "elements"
+	logEnv           = "env"
+	logEnvTarget     = "envTarget"
 	logErr           = "err"
 	logID            = "id"
+	logKeep          = "keep"
 	logName          = "name"
 	logOp            = "op"
 	logParameters    = "parameters"
 	logPkg           = "pkg"
 	logRendered      = "rendered"
+	logRules         = "rules"
 	logRepo          = "repo"
 	logRuleDecisions = "ruleDecisions"
 	logStore         = "store"
@@ -48,13 +55,16 @@ func NewBaseRepoLogMiddleware(logger log.Logger, store string) BaseRepoMiddlewar
 }
 
 func (r *logBaseRepo) Create(
-	id, clientID, name string,
+	id, clientID string,
+	e env.Env,
+	name string,
 	parameters rule.Parameters,
 ) (c BaseConfig, err error) {
 	defer func(begin time.Time) {
 		ps := []interface{}{
 			logClientID, clientID,
 			logDuration, time.Since(begin).Nanoseconds(),
+			logEnv, e,
 			logID, id,
 			logName, name,
 			logOp, "Create",
@@ -68,7 +78,7 @@ func (r *logBaseRepo) Create(
 		_ = r.logger.Log(ps...)
 	}(time.Now())
 
-	return r.next.Create(id, clientID, name, parameters)
+	return r.next.Create(id, clientID, e, name, parameters)
 }
 
 func (r *logBaseRepo) GetByID(id string) (c BaseConfig, err error) {
@@ -89,11 +99,16 @@ func (r *logBaseRepo) GetByID(id string) (c BaseConfig, err error) {
 	return r.next.GetByID(id)
 }
 
-func (r *logBaseRepo) GetByName(clientID, name string) (c BaseConfig, err error) {
+func (r *logBaseRepo) GetByName(
+	clientID string,
+	e env.Env,
+	name string,
+) (c BaseConfig, err error) {
 	defer func(begin time.Time) {
 		ps := []interface{}{
 			logClientID, clientID,
 			logDuration, time.Since(begin).Nanoseconds(),
+			logEnv, e,
 			logName, name,
 			logOp, "GetByName",
 		}
@@ -105,7 +120,7 @@ func (r *logBaseRepo) GetByName(clientID, name string) (c BaseConfig, err error)
 		_ = r.logger.Log(ps...)
 	}(time.Now())
 
-	return r.next.GetByName(clientID, name)
+	return r.next.GetByName(clientID, e, name)
 }
 
 func (r *logBaseRepo) List() (l BaseList, err error) {
@@ -242,6 +257,128 @@ func (r *logUserRepo) GetLatest(baseID, userID string) (c UserConfig, err error)
 	return r.next.GetLatest(baseID, userID)
 }
 
+func (r *logUserRepo) ListCompactable(
+	keep int,
+	before time.Time,
+) (ks []UserKey, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logBefore, before,
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, len(ks),
+			logKeep, keep,
+			logOp, "ListCompactable",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.ListCompactable(keep, before)
+}
+
+func (r *logUserRepo) History(
+	baseID, userID string,
+) (cs []UserConfig, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logBaseID, baseID,
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, len(cs),
+			logOp, "History",
+			logUserID, userID,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.History(baseID, userID)
+}
+
+func (r *logUserRepo) Delete(ids ...string) (n int, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDeleted, n,
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, len(ids),
+			logOp, "Delete",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Delete(ids...)
+}
+
+func (r *logUserRepo) ListUser(userID string) (cs []UserConfig, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, len(cs),
+			logOp, "ListUser",
+			logUserID, userID,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.ListUser(userID)
+}
+
+func (r *logUserRepo) Erase(id, userID string) (e Erasure, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDeleted, e.Deleted,
+			logDuration, time.Since(begin).Nanoseconds(),
+			logID, id,
+			logOp, "Erase",
+			logUserID, userID,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Erase(id, userID)
+}
+
+func (r *logUserRepo) ListErasures(userID string) (es []Erasure, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, len(es),
+			logOp, "ListErasures",
+			logUserID, userID,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.ListErasures(userID)
+}
+
 func (r *logUserRepo) setup() (err error) {
 	defer func(begin time.Time) {
 		ps := []interface{}{
@@ -275,3 +412,105 @@ func (r *logUserRepo) teardown() (err error) {
 
 	return r.next.teardown()
 }
+
+type logPromotionRepo struct {
+	logger log.Logger
+	next   PromotionRepo
+}
+
+// NewPromotionRepoLogMiddleware wraps the next PromotionRepo with logging
+// capabilities.
+func NewPromotionRepoLogMiddleware(
+	logger log.Logger,
+	store string,
+) PromotionRepoMiddleware {
+	return func(next PromotionRepo) PromotionRepo {
+		return &logPromotionRepo{
+			logger: log.With(
+				logger,
+				logPkg, "config",
+				logRepo, "promotion",
+				logStore, store,
+			),
+			next: next,
+		}
+	}
+}
+
+func (r *logPromotionRepo) Apply(
+	p Promotion,
+	target BaseConfig,
+	rules []rule.Rule,
+) (res Promotion, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logBaseID, p.SourceID,
+			logDuration, time.Since(begin).Nanoseconds(),
+			logEnv, p.From,
+			logEnvTarget, p.To,
+			logID, p.ID,
+			logOp, "Apply",
+			logRules, len(rules),
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Apply(p, target, rules)
+}
+
+func (r *logPromotionRepo) List(baseID string) (l []Promotion, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logBaseID, baseID,
+			logDuration, time.Since(begin).Nanoseconds(),
+			logOp, "List",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.List(baseID)
+}
+
+func (r *logPromotionRepo) setup() (err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logOp, "Setup",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.setup()
+}
+
+func (r *logPromotionRepo) teardown() (err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logOp, "Teardown",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.teardown()
+}
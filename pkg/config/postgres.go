@@ -7,7 +7,9 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/errors"
 	"github.com/lifesum/configsum/pkg/pg"
 	"github.com/lifesum/configsum/pkg/rule"
@@ -16,29 +18,19 @@ import (
 const (
 	pgDefaultSchema = "config"
 
-	pgCreateSchema = `CREATE SCHEMA IF NOT EXISTS %s`
-
-	pgBaseCreateTable = `
-		CREATE TABLE IF NOT EXISTS %s.bases(
-			client_id TEXT NOT NULL,
-			deleted BOOL DEFAULT FALSE,
-			id TEXT NOT NULL PRIMARY KEY,
-			name TEXT NOT NULL UNIQUE,
-			parameters JSONB NOT NULL,
-			created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (now() AT TIME ZONE 'utc'),
-			updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (now() AT TIME ZONE 'utc')
-		)`
-	pgBaseDropTable = `
-		DROP TABLE IF EXISTS %s.bases CASCADE`
+	pgBaseComponent      = "bases"
+	pgPromotionComponent = "promotions"
+	pgUserComponent      = "users"
+
 	pgBaseCreate = `
 		/* pgBaseCreate */
 		INSERT INTO
-			%s.bases(client_id, id, name, parameters)
-			VALUES(:clientId, :id, :name, :parameters)`
+			%s.bases(client_id, environment, id, name, parameters)
+			VALUES(:clientId, :environment, :id, :name, :parameters)`
 	pgBaseGetByID = `
 		/* pgBaseGetByID */
 		SELECT
-			client_id, deleted, id, name, parameters, created_at, updated_at
+			client_id, deleted, environment, id, name, parameters, schema, deprecated, platforms, created_at, updated_at
 		FROM
 			%s.bases
 		WHERE
@@ -50,11 +42,12 @@ const (
 	pgBaseGetByName = `
 		/* pgBaseGetByName */
 		SELECT
-			client_id, deleted, id, name, parameters, created_at, updated_at
+			client_id, deleted, environment, id, name, parameters, schema, deprecated, platforms, created_at, updated_at
 		FROM
 			%s.bases
 		WHERE
 			client_id = :clientId
+			AND environment = :environment
 			AND name = :name
 		ORDER BY
 			created_at DESC
@@ -63,7 +56,7 @@ const (
 	pgBaseList = `
 		/* pgBaseList */
 		SELECT
-			client_id, deleted, id, name, parameters, created_at, updated_at
+			client_id, deleted, environment, id, name, parameters, schema, deprecated, platforms, created_at, updated_at
 		FROM
 			%s.bases
 		WHERE
@@ -78,21 +71,13 @@ const (
 			deleted = :deleted,
 			name = :name,
 			parameters = :parameters,
+			schema = :schema,
+			deprecated = :deprecated,
+			platforms = :platforms,
 			updated_at = :updatedAt
 		WHERE
 			id = :id`
 
-	pgUserCreateTable = `
-		CREATE TABLE IF NOT EXISTS %s.users(
-			id TEXT NOT NULL PRIMARY KEY,
-			user_id TEXT NOT NULL,
-			base_id TEXT NOT NULL,
-			rendered JSONB NOT NULL,
-			rule_decisions JSONB NOT NULL,
-			created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (now() AT TIME ZONE 'utc')
-		)`
-	pgUserDropTable = `DROP TABLE IF EXISTS %s.users CASCADE`
-
 	pgUserInsert = `
 		/* pgUserInsert*/
 		INSERT INTO
@@ -115,14 +100,253 @@ const (
 			created_at DESC
 		LIMIT
 			1`
+	pgUserListCompactable = `
+		/* pgUserListCompactable */
+		SELECT
+			base_id, user_id
+		FROM
+			%s.users
+		GROUP BY
+			base_id, user_id
+		HAVING
+			count(*) > :keep
+			AND min(created_at) < :before`
+	pgUserHistory = `
+		/* pgUserHistory */
+		SELECT
+			id, user_id, base_id, rendered, rule_decisions, created_at
+		FROM
+			%s.users
+		WHERE
+			base_id = :baseId
+			AND user_id = :userId
+		ORDER BY
+			created_at DESC, id DESC`
+	pgUserDelete = `
+		/* pgUserDelete */
+		DELETE FROM
+			%s.users
+		WHERE
+			id = ANY($1)`
+	pgUserListUser = `
+		/* pgUserListUser */
+		SELECT
+			id, user_id, base_id, rendered, rule_decisions, created_at
+		FROM
+			%s.users
+		WHERE
+			user_id = :userId
+		ORDER BY
+			created_at ASC, id ASC`
+	pgUserErase = `
+		/* pgUserErase */
+		DELETE FROM
+			%s.users
+		WHERE
+			user_id = $1`
+	pgUserErasureInsert = `
+		/* pgUserErasureInsert */
+		INSERT INTO
+			%s.erasures(id, user_id, deleted, created_at) VALUES(
+			:id,
+			:userId,
+			:deleted,
+			:createdAt)`
+	pgUserErasureList = `
+		/* pgUserErasureList */
+		SELECT
+			id, user_id, deleted, created_at
+		FROM
+			%s.erasures
+		WHERE
+			user_id = :userId
+		ORDER BY
+			created_at ASC`
+
+	pgPromotionBaseUpsert = `
+		/* pgPromotionBaseUpsert */
+		INSERT INTO
+			%s.bases(client_id, environment, id, name, parameters, schema, deprecated, platforms, created_at, updated_at)
+			VALUES(:clientId, :environment, :id, :name, :parameters, :schema, :deprecated, :platforms, :createdAt, :updatedAt)
+		ON CONFLICT (id) DO UPDATE
+		SET
+			parameters = EXCLUDED.parameters,
+			schema = EXCLUDED.schema,
+			deprecated = EXCLUDED.deprecated,
+			platforms = EXCLUDED.platforms,
+			updated_at = EXCLUDED.updated_at`
+	pgPromotionInsert = `
+		/* pgPromotionInsert */
+		INSERT INTO
+			%s.promotions(id, source_id, target_id, source_environment, target_environment, parameters, rules, created_at)
+			VALUES(:id, :sourceId, :targetId, :sourceEnvironment, :targetEnvironment, :parameters, :rules, :createdAt)`
+	pgPromotionList = `
+		/* pgPromotionList */
+		SELECT
+			id, source_id, target_id, source_environment, target_environment, parameters, rules, created_at
+		FROM
+			%s.promotions
+		WHERE
+			source_id = :baseId
+			OR target_id = :baseId
+		ORDER BY
+			created_at DESC`
+)
+
+var (
+	pgBaseMigrations = []pg.Migration{
+		{
+			Version:     1,
+			Description: "create bases",
+			Up: []string{`
+				CREATE TABLE IF NOT EXISTS %s.bases(
+					client_id TEXT NOT NULL,
+					deleted BOOL DEFAULT FALSE,
+					id TEXT NOT NULL PRIMARY KEY,
+					name TEXT NOT NULL UNIQUE,
+					parameters JSONB NOT NULL,
+					created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (now() AT TIME ZONE 'utc'),
+					updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (now() AT TIME ZONE 'utc')
+				)`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS %s.bases CASCADE`,
+			},
+		},
+		{
+			Version:     2,
+			Description: "add parameter schema to bases",
+			Up: []string{
+				`ALTER TABLE %s.bases ADD COLUMN IF NOT EXISTS schema JSONB NOT NULL DEFAULT '{}'`,
+			},
+			Down: []string{
+				`ALTER TABLE IF EXISTS %s.bases DROP COLUMN IF EXISTS schema`,
+			},
+		},
+		{
+			Version:     3,
+			Description: "add deprecated parameters to bases",
+			Up: []string{
+				`ALTER TABLE %s.bases ADD COLUMN IF NOT EXISTS deprecated JSONB NOT NULL DEFAULT '{}'`,
+			},
+			Down: []string{
+				`ALTER TABLE IF EXISTS %s.bases DROP COLUMN IF EXISTS deprecated`,
+			},
+		},
+		{
+			Version:     4,
+			Description: "scope base names by client and environment",
+			Up: []string{
+				`ALTER TABLE %s.bases ADD COLUMN IF NOT EXISTS environment TEXT NOT NULL DEFAULT 'prod'`,
+				`ALTER TABLE %s.bases DROP CONSTRAINT IF EXISTS bases_name_key`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS bases_client_environment_name
+					ON %s.bases (client_id, environment, name)`,
+			},
+			// Global uniqueness of names isn't reinstated as names may
+			// collide across clients and environments by now.
+			Down: []string{
+				`DROP INDEX IF EXISTS %s.bases_client_environment_name`,
+				`ALTER TABLE IF EXISTS %s.bases DROP COLUMN IF EXISTS environment`,
+			},
+		},
+		{
+			Version:     5,
+			Description: "add platform overrides to bases",
+			Up: []string{
+				`ALTER TABLE %s.bases ADD COLUMN IF NOT EXISTS platforms JSONB NOT NULL DEFAULT '{}'`,
+			},
+			Down: []string{
+				`ALTER TABLE IF EXISTS %s.bases DROP COLUMN IF EXISTS platforms`,
+			},
+		},
+	}
+
+	pgPromotionMigrations = []pg.Migration{
+		{
+			Version:     1,
+			Description: "create promotions",
+			Up: []string{`
+				CREATE TABLE IF NOT EXISTS %s.promotions(
+					id TEXT NOT NULL PRIMARY KEY,
+					source_id TEXT NOT NULL,
+					target_id TEXT NOT NULL,
+					source_environment TEXT NOT NULL,
+					target_environment TEXT NOT NULL,
+					parameters JSONB NOT NULL,
+					rules JSONB NOT NULL,
+					created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (now() AT TIME ZONE 'utc')
+				)`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS %s.promotions CASCADE`,
+			},
+		},
+	}
 
-	pgUserIndexGetLatest = `
-		CREATE INDEX
-			users_get_latest
-		ON
-			%s.users(base_id, user_id, created_at DESC)`
+	pgUserMigrations = []pg.Migration{
+		{
+			Version:     1,
+			Description: "create users",
+			Up: []string{`
+				CREATE TABLE IF NOT EXISTS %s.users(
+					id TEXT NOT NULL PRIMARY KEY,
+					user_id TEXT NOT NULL,
+					base_id TEXT NOT NULL,
+					rendered JSONB NOT NULL,
+					rule_decisions JSONB NOT NULL,
+					created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (now() AT TIME ZONE 'utc')
+				)`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS %s.users CASCADE`,
+			},
+		},
+		{
+			Version:     2,
+			Description: "index latest user config lookup",
+			Up: []string{`
+				CREATE INDEX IF NOT EXISTS
+					users_get_latest
+				ON
+					%s.users(base_id, user_id, created_at DESC)`,
+			},
+			Down: []string{
+				`DROP INDEX IF EXISTS %s.users_get_latest`,
+			},
+		},
+		{
+			Version:     3,
+			Description: "index user lookup and create erasures",
+			Up: []string{`
+				CREATE INDEX IF NOT EXISTS
+					users_user_id
+				ON
+					%s.users(user_id)`, `
+				CREATE TABLE IF NOT EXISTS %s.erasures(
+					id TEXT NOT NULL PRIMARY KEY,
+					user_id TEXT NOT NULL,
+					deleted INT NOT NULL,
+					created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+				)`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS %s.erasures CASCADE`,
+				`DROP INDEX IF EXISTS %s.users_user_id`,
+			},
+		},
+	}
 )
 
+// PGMigrators returns the migrators of all Postgres repos of the package in
+// their default schema.
+func PGMigrators(db *sqlx.DB) []*pg.Migrator {
+	return []*pg.Migrator{
+		NewPostgresBaseRepo(db).Migrator(),
+		(&PGUserRepo{db: db, schema: pgDefaultSchema}).Migrator(),
+		(&PGPromotionRepo{db: db, schema: pgDefaultSchema}).Migrator(),
+	}
+}
+
 // PGBaseRepoOption sets an optional parameter for the base repo.
 type PGBaseRepoOption func(*PGBaseRepo)
 
@@ -154,7 +378,9 @@ func NewPostgresBaseRepo(db *sqlx.DB, options ...PGBaseRepoOption) *PGBaseRepo {
 
 // Create stores a new base config with the given inputs.
 func (r *PGBaseRepo) Create(
-	id, clientID, name string,
+	id, clientID string,
+	e env.Env,
+	name string,
 	parameters rule.Parameters,
 ) (BaseConfig, error) {
 	rawParameters, err := json.Marshal(parameters)
@@ -163,10 +389,11 @@ func (r *PGBaseRepo) Create(
 	}
 
 	_, err = r.db.NamedExec(r.prefixSchema(pgBaseCreate), map[string]interface{}{
-		"clientId":   clientID,
-		"id":         id,
-		"name":       name,
-		"parameters": rawParameters,
+		"clientId":    clientID,
+		"environment": e,
+		"id":          id,
+		"name":        name,
+		"parameters":  rawParameters,
 	})
 	if err != nil {
 		switch errors.Cause(pg.Wrap(err)) {
@@ -177,7 +404,7 @@ func (r *PGBaseRepo) Create(
 				return BaseConfig{}, serr
 			}
 
-			return r.Create(id, clientID, name, parameters)
+			return r.Create(id, clientID, e, name, parameters)
 		default:
 			return BaseConfig{}, fmt.Errorf("named exec: %s", err)
 		}
@@ -185,6 +412,7 @@ func (r *PGBaseRepo) Create(
 
 	return BaseConfig{
 		ClientID:   clientID,
+		Env:        e,
 		ID:         id,
 		Name:       name,
 		Parameters: parameters,
@@ -207,9 +435,13 @@ func (r *PGBaseRepo) GetByID(id string) (BaseConfig, error) {
 	raw := struct {
 		ClientID   string    `db:"client_id"`
 		Deleted    bool      `db:"deleted"`
+		Env        env.Env   `db:"environment"`
 		ID         string    `db:"id"`
 		Name       string    `db:"name"`
 		Parameters []byte    `db:"parameters"`
+		Schema     []byte    `db:"schema"`
+		Deprecated []byte    `db:"deprecated"`
+		Platforms  []byte    `db:"platforms"`
 		CreatedAt  time.Time `db:"created_at"`
 		UpdatedAt  time.Time `db:"updated_at"`
 	}{}
@@ -238,23 +470,50 @@ func (r *PGBaseRepo) GetByID(id string) (BaseConfig, error) {
 		return BaseConfig{}, errors.Wrap(err, "unmarshal parameters")
 	}
 
+	schema := rule.ParameterSchema{}
+
+	if err := json.Unmarshal(raw.Schema, &schema); err != nil {
+		return BaseConfig{}, errors.Wrap(err, "unmarshal schema")
+	}
+
+	deprecated := map[string]time.Time{}
+
+	if err := json.Unmarshal(raw.Deprecated, &deprecated); err != nil {
+		return BaseConfig{}, errors.Wrap(err, "unmarshal deprecated")
+	}
+
+	platforms := rule.PlatformParameters{}
+
+	if err := json.Unmarshal(raw.Platforms, &platforms); err != nil {
+		return BaseConfig{}, errors.Wrap(err, "unmarshal platforms")
+	}
+
 	return BaseConfig{
 		ClientID:   raw.ClientID,
+		Env:        raw.Env,
 		ID:         raw.ID,
 		Name:       raw.Name,
 		Parameters: params,
+		Schema:     schema,
+		Deprecated: deprecated,
+		Platforms:  platforms,
 		CreatedAt:  raw.CreatedAt,
 		UpdatedAt:  raw.UpdatedAt,
 	}, nil
 }
 
-// GetByName retrieves the base config for the given name.
-func (r *PGBaseRepo) GetByName(clientID, name string) (BaseConfig, error) {
+// GetByName retrieves the base config for the given name in the environment.
+func (r *PGBaseRepo) GetByName(
+	clientID string,
+	e env.Env,
+	name string,
+) (BaseConfig, error) {
 	query, args, err := r.db.BindNamed(
 		r.prefixSchema(pgBaseGetByName),
 		map[string]interface{}{
-			"clientId": clientID,
-			"name":     name,
+			"clientId":    clientID,
+			"environment": e,
+			"name":        name,
 		},
 	)
 	if err != nil {
@@ -264,9 +523,13 @@ func (r *PGBaseRepo) GetByName(clientID, name string) (BaseConfig, error) {
 	raw := struct {
 		ClientID   string    `db:"client_id"`
 		Deleted    bool      `db:"deleted"`
+		Env        env.Env   `db:"environment"`
 		ID         string    `db:"id"`
 		Name       string    `db:"name"`
 		Parameters []byte    `db:"parameters"`
+		Schema     []byte    `db:"schema"`
+		Deprecated []byte    `db:"deprecated"`
+		Platforms  []byte    `db:"platforms"`
 		CreatedAt  time.Time `db:"created_at"`
 		UpdatedAt  time.Time `db:"updated_at"`
 	}{}
@@ -279,7 +542,7 @@ func (r *PGBaseRepo) GetByName(clientID, name string) (BaseConfig, error) {
 				return BaseConfig{}, err
 			}
 
-			return r.GetByName(clientID, name)
+			return r.GetByName(clientID, e, name)
 
 		case sql.ErrNoRows:
 			return BaseConfig{}, errors.Wrap(errors.ErrNotFound, "get base config by id")
@@ -295,11 +558,33 @@ func (r *PGBaseRepo) GetByName(clientID, name string) (BaseConfig, error) {
 		return BaseConfig{}, errors.Wrap(err, "unmarshal parameters")
 	}
 
+	schema := rule.ParameterSchema{}
+
+	if err := json.Unmarshal(raw.Schema, &schema); err != nil {
+		return BaseConfig{}, errors.Wrap(err, "unmarshal schema")
+	}
+
+	deprecated := map[string]time.Time{}
+
+	if err := json.Unmarshal(raw.Deprecated, &deprecated); err != nil {
+		return BaseConfig{}, errors.Wrap(err, "unmarshal deprecated")
+	}
+
+	platforms := rule.PlatformParameters{}
+
+	if err := json.Unmarshal(raw.Platforms, &platforms); err != nil {
+		return BaseConfig{}, errors.Wrap(err, "unmarshal platforms")
+	}
+
 	return BaseConfig{
 		ClientID:   raw.ClientID,
+		Env:        raw.Env,
 		ID:         raw.ID,
 		Name:       raw.Name,
 		Parameters: params,
+		Schema:     schema,
+		Deprecated: deprecated,
+		Platforms:  platforms,
 		CreatedAt:  raw.CreatedAt,
 	}, nil
 }
@@ -329,17 +614,25 @@ func (r *PGBaseRepo) List() (BaseList, error) {
 
 	for rows.Next() {
 		var (
-			c         = BaseConfig{}
-			rawParams = []byte{}
+			c             = BaseConfig{}
+			rawParams     = []byte{}
+			rawSchema     = []byte{}
+			rawDeprecated = []byte{}
+			rawPlatforms  = []byte{}
 		)
 
-		// client_id, deleted, id, name, parameters, created_at, updated_at
+		// client_id, deleted, environment, id, name, parameters, schema,
+		// deprecated, platforms, created_at, updated_at
 		err := rows.Scan(
 			&c.ClientID,
 			&c.Deleted,
+			&c.Env,
 			&c.ID,
 			&c.Name,
 			&rawParams,
+			&rawSchema,
+			&rawDeprecated,
+			&rawPlatforms,
 			&c.CreatedAt,
 			&c.UpdatedAt,
 		)
@@ -351,6 +644,18 @@ func (r *PGBaseRepo) List() (BaseList, error) {
 			return nil, errors.Wrap(err, "unmarshal parameters")
 		}
 
+		if err := json.Unmarshal(rawSchema, &c.Schema); err != nil {
+			return nil, errors.Wrap(err, "unmarshal schema")
+		}
+
+		if err := json.Unmarshal(rawDeprecated, &c.Deprecated); err != nil {
+			return nil, errors.Wrap(err, "unmarshal deprecated")
+		}
+
+		if err := json.Unmarshal(rawPlatforms, &c.Platforms); err != nil {
+			return nil, errors.Wrap(err, "unmarshal platforms")
+		}
+
 		cs = append(cs, c)
 	}
 
@@ -368,6 +673,36 @@ func (r *PGBaseRepo) Update(c BaseConfig) (BaseConfig, error) {
 		return BaseConfig{}, errors.Wrap(err, "marshal parameters")
 	}
 
+	schema := c.Schema
+	if schema == nil {
+		schema = rule.ParameterSchema{}
+	}
+
+	rawSchema, err := json.Marshal(schema)
+	if err != nil {
+		return BaseConfig{}, errors.Wrap(err, "marshal schema")
+	}
+
+	deprecated := c.Deprecated
+	if deprecated == nil {
+		deprecated = map[string]time.Time{}
+	}
+
+	rawDeprecated, err := json.Marshal(deprecated)
+	if err != nil {
+		return BaseConfig{}, errors.Wrap(err, "marshal deprecated")
+	}
+
+	platforms := c.Platforms
+	if platforms == nil {
+		platforms = rule.PlatformParameters{}
+	}
+
+	rawPlatforms, err := json.Marshal(platforms)
+	if err != nil {
+		return BaseConfig{}, errors.Wrap(err, "marshal platforms")
+	}
+
 	updatedAt := time.Now().UTC()
 
 	res, err := r.db.NamedExec(
@@ -377,6 +712,9 @@ func (r *PGBaseRepo) Update(c BaseConfig) (BaseConfig, error) {
 			"deleted":    c.Deleted,
 			"name":       c.Name,
 			"parameters": rawParameters,
+			"schema":     rawSchema,
+			"deprecated": rawDeprecated,
+			"platforms":  rawPlatforms,
 			"updatedAt":  updatedAt,
 		},
 	)
@@ -405,39 +743,29 @@ func (r *PGBaseRepo) Update(c BaseConfig) (BaseConfig, error) {
 	return BaseConfig{
 		ClientID:   c.ClientID,
 		Deleted:    c.Deleted,
+		Env:        c.Env,
 		ID:         c.ID,
 		Name:       c.Name,
 		Parameters: c.Parameters,
+		Schema:     c.Schema,
+		Deprecated: c.Deprecated,
+		Platforms:  c.Platforms,
 		CreatedAt:  c.CreatedAt,
 		UpdatedAt:  updatedAt,
 	}, nil
 }
 
 func (r *PGBaseRepo) setup() error {
-	for _, q := range []string{
-		r.prefixSchema(pgCreateSchema),
-		r.prefixSchema(pgBaseCreateTable),
-	} {
-		_, err := r.db.Exec(q)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return r.Migrator().Up()
 }
 
 func (r *PGBaseRepo) teardown() error {
-	for _, q := range []string{
-		r.prefixSchema(pgBaseDropTable),
-	} {
-		_, err := r.db.Exec(q)
-		if err != nil {
-			return err
-		}
-	}
+	return r.Migrator().Reset()
+}
 
-	return nil
+// Migrator returns the migrator for the tables of the repo.
+func (r *PGBaseRepo) Migrator() *pg.Migrator {
+	return pg.NewMigrator(r.db, r.schema, pgBaseComponent, pgBaseMigrations)
 }
 
 func (r *PGBaseRepo) prefixSchema(query string) string {
@@ -538,14 +866,7 @@ func (r *PGUserRepo) GetLatest(baseID, userID string) (UserConfig, error) {
 		return UserConfig{}, fmt.Errorf("named query: %s", err)
 	}
 
-	raw := struct {
-		BaseID        string    `db:"base_id"`
-		ID            string    `db:"id"`
-		Rendered      []byte    `db:"rendered"`
-		RuleDecisions []byte    `db:"rule_decisions"`
-		UserID        string    `db:"user_id"`
-		CreatedAt     time.Time `db:"created_at"`
-	}{}
+	raw := pgUserConfig{}
 
 	err = r.db.Get(&raw, query, args...)
 	if err != nil {
@@ -564,6 +885,304 @@ func (r *PGUserRepo) GetLatest(baseID, userID string) (UserConfig, error) {
 		}
 	}
 
+	return raw.convert()
+}
+
+// ListCompactable returns the base and user pairs with more than keep user
+// configs of which at least one was created before the given time.
+func (r *PGUserRepo) ListCompactable(
+	keep int,
+	before time.Time,
+) ([]UserKey, error) {
+	query, args, err := r.db.BindNamed(
+		r.prefixSchema(pgUserListCompactable),
+		map[string]interface{}{
+			"before": before.UTC(),
+			"keep":   keep,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("named query: %s", err)
+	}
+
+	raws := []struct {
+		BaseID string `db:"base_id"`
+		UserID string `db:"user_id"`
+	}{}
+
+	err = r.db.Select(&raws, query, args...)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.setup(); err != nil {
+				return nil, err
+			}
+
+			return r.ListCompactable(keep, before)
+		default:
+			return nil, fmt.Errorf("select: %s", err)
+		}
+	}
+
+	ks := []UserKey{}
+
+	for _, raw := range raws {
+		ks = append(ks, UserKey{BaseID: raw.BaseID, UserID: raw.UserID})
+	}
+
+	return ks, nil
+}
+
+// History returns all user configs of the base and user, newest first.
+func (r *PGUserRepo) History(baseID, userID string) ([]UserConfig, error) {
+	query, args, err := r.db.BindNamed(
+		r.prefixSchema(pgUserHistory),
+		map[string]interface{}{
+			"baseId": baseID,
+			"userId": userID,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("named query: %s", err)
+	}
+
+	raws := []pgUserConfig{}
+
+	err = r.db.Select(&raws, query, args...)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.setup(); err != nil {
+				return nil, err
+			}
+
+			return r.History(baseID, userID)
+		default:
+			return nil, fmt.Errorf("select: %s", err)
+		}
+	}
+
+	cs := []UserConfig{}
+
+	for _, raw := range raws {
+		c, err := raw.convert()
+		if err != nil {
+			return nil, err
+		}
+
+		cs = append(cs, c)
+	}
+
+	return cs, nil
+}
+
+// Delete removes the user configs with the given ids.
+func (r *PGUserRepo) Delete(ids ...string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	res, err := r.db.Exec(r.prefixSchema(pgUserDelete), pq.Array(ids))
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.setup(); err != nil {
+				return 0, err
+			}
+
+			return r.Delete(ids...)
+		default:
+			return 0, fmt.Errorf("exec: %s", err)
+		}
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "rows affected")
+	}
+
+	return int(n), nil
+}
+
+// ListUser returns all user configs of the user across base configs, oldest
+// first.
+func (r *PGUserRepo) ListUser(userID string) ([]UserConfig, error) {
+	query, args, err := r.db.BindNamed(
+		r.prefixSchema(pgUserListUser),
+		map[string]interface{}{
+			"userId": userID,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("named query: %s", err)
+	}
+
+	raws := []pgUserConfig{}
+
+	err = r.db.Select(&raws, query, args...)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.setup(); err != nil {
+				return nil, err
+			}
+
+			return r.ListUser(userID)
+		default:
+			return nil, fmt.Errorf("select: %s", err)
+		}
+	}
+
+	cs := []UserConfig{}
+
+	for _, raw := range raws {
+		c, err := raw.convert()
+		if err != nil {
+			return nil, err
+		}
+
+		cs = append(cs, c)
+	}
+
+	return cs, nil
+}
+
+// Erase deletes all user configs of the user and records the erasure in the
+// same transaction.
+func (r *PGUserRepo) Erase(id, userID string) (Erasure, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return Erasure{}, errors.Wrap(err, "begin")
+	}
+
+	e, err := r.erase(tx, id, userID)
+	if err != nil {
+		_ = tx.Rollback()
+
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrDuplicateKey:
+			return Erasure{}, errors.Wrap(errors.ErrExists, "erasure")
+		case pg.ErrRelationNotFound:
+			if err := r.setup(); err != nil {
+				return Erasure{}, err
+			}
+
+			return r.Erase(id, userID)
+		default:
+			return Erasure{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Erasure{}, errors.Wrap(err, "commit")
+	}
+
+	return e, nil
+}
+
+func (r *PGUserRepo) erase(tx *sqlx.Tx, id, userID string) (Erasure, error) {
+	res, err := tx.Exec(r.prefixSchema(pgUserErase), userID)
+	if err != nil {
+		return Erasure{}, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return Erasure{}, err
+	}
+
+	e := Erasure{
+		ID:        id,
+		UserID:    userID,
+		Deleted:   int(n),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	_, err = tx.NamedExec(
+		r.prefixSchema(pgUserErasureInsert),
+		map[string]interface{}{
+			"createdAt": e.CreatedAt,
+			"deleted":   e.Deleted,
+			"id":        e.ID,
+			"userId":    e.UserID,
+		},
+	)
+	if err != nil {
+		return Erasure{}, err
+	}
+
+	return e, nil
+}
+
+// ListErasures returns the erasures recorded for the user, oldest first.
+func (r *PGUserRepo) ListErasures(userID string) ([]Erasure, error) {
+	query, args, err := r.db.BindNamed(
+		r.prefixSchema(pgUserErasureList),
+		map[string]interface{}{
+			"userId": userID,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("named query: %s", err)
+	}
+
+	raws := []struct {
+		ID        string    `db:"id"`
+		UserID    string    `db:"user_id"`
+		Deleted   int       `db:"deleted"`
+		CreatedAt time.Time `db:"created_at"`
+	}{}
+
+	err = r.db.Select(&raws, query, args...)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.setup(); err != nil {
+				return nil, err
+			}
+
+			return r.ListErasures(userID)
+		default:
+			return nil, fmt.Errorf("select: %s", err)
+		}
+	}
+
+	es := []Erasure{}
+
+	for _, raw := range raws {
+		es = append(es, Erasure(raw))
+	}
+
+	return es, nil
+}
+
+func (r *PGUserRepo) setup() error {
+	return r.Migrator().Up()
+}
+
+func (r *PGUserRepo) teardown() error {
+	return r.Migrator().Reset()
+}
+
+// Migrator returns the migrator for the tables of the repo.
+func (r *PGUserRepo) Migrator() *pg.Migrator {
+	return pg.NewMigrator(r.db, r.schema, pgUserComponent, pgUserMigrations)
+}
+
+func (r *PGUserRepo) prefixSchema(query string) string {
+	return fmt.Sprintf(query, r.schema)
+}
+
+type pgUserConfig struct {
+	BaseID        string    `db:"base_id"`
+	ID            string    `db:"id"`
+	Rendered      []byte    `db:"rendered"`
+	RuleDecisions []byte    `db:"rule_decisions"`
+	UserID        string    `db:"user_id"`
+	CreatedAt     time.Time `db:"created_at"`
+}
+
+func (raw pgUserConfig) convert() (UserConfig, error) {
 	render := rule.Parameters{}
 
 	if err := json.Unmarshal(raw.Rendered, &render); err != nil {
@@ -586,34 +1205,245 @@ func (r *PGUserRepo) GetLatest(baseID, userID string) (UserConfig, error) {
 	}, nil
 }
 
-func (r *PGUserRepo) setup() error {
-	for _, q := range []string{
-		r.prefixSchema(pgCreateSchema),
-		r.prefixSchema(pgUserCreateTable),
-		r.prefixSchema(pgUserIndexGetLatest),
-	} {
-		_, err := r.db.Exec(q)
+// PGPromotionRepoOption sets an optional parameter for the promotion repo.
+type PGPromotionRepoOption func(*PGPromotionRepo)
+
+// PGPromotionRepoSchema sets the namespacing of the Postgres tables to a
+// non-default schema.
+func PGPromotionRepoSchema(schema string) PGPromotionRepoOption {
+	return func(r *PGPromotionRepo) { r.schema = schema }
+}
+
+// PGPromotionRepoRuleSchema sets the schema of the rules table if it differs
+// from the default one of the rule package.
+func PGPromotionRepoRuleSchema(schema string) PGPromotionRepoOption {
+	return func(r *PGPromotionRepo) { r.ruleSchema = schema }
+}
+
+// PGPromotionRepo is a Postgres backed PromotionRepo implementation. It
+// requires the base configs and rules to share the database.
+type PGPromotionRepo struct {
+	db         *sqlx.DB
+	ruleSchema string
+	schema     string
+}
+
+// NewPostgresPromotionRepo returns a Postgres backed PromotionRepo
+// implementation.
+func NewPostgresPromotionRepo(
+	db *sqlx.DB,
+	options ...PGPromotionRepoOption,
+) PromotionRepo {
+	r := &PGPromotionRepo{
+		db:         db,
+		ruleSchema: rule.PGDefaultSchema,
+		schema:     pgDefaultSchema,
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// Apply upserts the target base config and rules and records the promotion in
+// a single transaction.
+func (r *PGPromotionRepo) Apply(
+	p Promotion,
+	target BaseConfig,
+	rules []rule.Rule,
+) (Promotion, error) {
+	rawParameters, err := json.Marshal(target.Parameters)
+	if err != nil {
+		return Promotion{}, errors.Wrap(err, "marshal parameters")
+	}
+
+	schema := target.Schema
+	if schema == nil {
+		schema = rule.ParameterSchema{}
+	}
+
+	rawSchema, err := json.Marshal(schema)
+	if err != nil {
+		return Promotion{}, errors.Wrap(err, "marshal schema")
+	}
+
+	deprecated := target.Deprecated
+	if deprecated == nil {
+		deprecated = map[string]time.Time{}
+	}
+
+	rawDeprecated, err := json.Marshal(deprecated)
+	if err != nil {
+		return Promotion{}, errors.Wrap(err, "marshal deprecated")
+	}
+
+	platforms := target.Platforms
+	if platforms == nil {
+		platforms = rule.PlatformParameters{}
+	}
+
+	rawPlatforms, err := json.Marshal(platforms)
+	if err != nil {
+		return Promotion{}, errors.Wrap(err, "marshal platforms")
+	}
+
+	rawParameterChanges, err := json.Marshal(p.Parameters)
+	if err != nil {
+		return Promotion{}, errors.Wrap(err, "marshal parameter changes")
+	}
+
+	rawRuleChanges, err := json.Marshal(p.Rules)
+	if err != nil {
+		return Promotion{}, errors.Wrap(err, "marshal rule changes")
+	}
+
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return Promotion{}, errors.Wrap(err, "begin")
+	}
+
+	err = func() error {
+		_, err := tx.NamedExec(
+			r.prefixSchema(pgPromotionBaseUpsert),
+			map[string]interface{}{
+				"clientId":    target.ClientID,
+				"environment": target.Env,
+				"id":          target.ID,
+				"name":        target.Name,
+				"parameters":  rawParameters,
+				"schema":      rawSchema,
+				"deprecated":  rawDeprecated,
+				"platforms":   rawPlatforms,
+				"createdAt":   target.CreatedAt.UTC(),
+				"updatedAt":   p.CreatedAt,
+			},
+		)
 		if err != nil {
 			return err
 		}
+
+		if err := rule.PGUpsertTx(tx, r.ruleSchema, rules...); err != nil {
+			return err
+		}
+
+		_, err = tx.NamedExec(
+			r.prefixSchema(pgPromotionInsert),
+			map[string]interface{}{
+				"id":                p.ID,
+				"sourceId":          p.SourceID,
+				"targetId":          p.TargetID,
+				"sourceEnvironment": p.From,
+				"targetEnvironment": p.To,
+				"parameters":        rawParameterChanges,
+				"rules":             rawRuleChanges,
+				"createdAt":         p.CreatedAt,
+			},
+		)
+
+		return err
+	}()
+	if err != nil {
+		_ = tx.Rollback()
+
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrDuplicateKey:
+			return Promotion{}, errors.Wrap(errors.ErrExists, "promotion")
+		case pg.ErrRelationNotFound:
+			if serr := r.setup(); serr != nil {
+				return Promotion{}, serr
+			}
+
+			return r.Apply(p, target, rules)
+		default:
+			return Promotion{}, fmt.Errorf("apply promotion: %s", err)
+		}
 	}
 
-	return nil
+	if err := tx.Commit(); err != nil {
+		return Promotion{}, errors.Wrap(err, "commit")
+	}
+
+	return p, nil
 }
 
-func (r *PGUserRepo) teardown() error {
-	for _, q := range []string{
-		r.prefixSchema(pgUserDropTable),
-	} {
-		_, err := r.db.Exec(q)
-		if err != nil {
-			return err
+// List returns all promotions from or to the given base config, latest first.
+func (r *PGPromotionRepo) List(baseID string) ([]Promotion, error) {
+	query, args, err := r.db.BindNamed(
+		r.prefixSchema(pgPromotionList),
+		map[string]interface{}{
+			"baseId": baseID,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("named query: %s", err)
+	}
+
+	raws := []struct {
+		ID         string    `db:"id"`
+		SourceID   string    `db:"source_id"`
+		TargetID   string    `db:"target_id"`
+		From       env.Env   `db:"source_environment"`
+		To         env.Env   `db:"target_environment"`
+		Parameters []byte    `db:"parameters"`
+		Rules      []byte    `db:"rules"`
+		CreatedAt  time.Time `db:"created_at"`
+	}{}
+
+	err = r.db.Select(&raws, query, args...)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.setup(); err != nil {
+				return nil, err
+			}
+
+			return r.List(baseID)
+		default:
+			return nil, fmt.Errorf("select: %s", err)
+		}
+	}
+
+	ps := []Promotion{}
+
+	for _, raw := range raws {
+		p := Promotion{
+			ID:        raw.ID,
+			SourceID:  raw.SourceID,
+			TargetID:  raw.TargetID,
+			From:      raw.From,
+			To:        raw.To,
+			CreatedAt: raw.CreatedAt.UTC(),
 		}
+
+		if err := json.Unmarshal(raw.Parameters, &p.Parameters); err != nil {
+			return nil, errors.Wrap(err, "unmarshal parameter changes")
+		}
+
+		if err := json.Unmarshal(raw.Rules, &p.Rules); err != nil {
+			return nil, errors.Wrap(err, "unmarshal rule changes")
+		}
+
+		ps = append(ps, p)
 	}
 
-	return nil
+	return ps, nil
 }
 
-func (r *PGUserRepo) prefixSchema(query string) string {
+func (r *PGPromotionRepo) setup() error {
+	return r.Migrator().Up()
+}
+
+func (r *PGPromotionRepo) teardown() error {
+	return r.Migrator().Reset()
+}
+
+// Migrator returns the migrator for the tables of the repo.
+func (r *PGPromotionRepo) Migrator() *pg.Migrator {
+	return pg.NewMigrator(r.db, r.schema, pgPromotionComponent, pgPromotionMigrations)
+}
+
+func (r *PGPromotionRepo) prefixSchema(query string) string {
 	return fmt.Sprintf(query, r.schema)
 }
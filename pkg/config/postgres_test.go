@@ -75,6 +75,36 @@ func TestPostgresUserRepoAppendDuplicate(t *testing.T) {
 	testUserRepoAppendDuplicate(t, preparePGUserRepo)
 }
 
+func TestPostgresUserRepoCompact(t *testing.T) {
+	t.Parallel()
+
+	testUserRepoCompact(t, preparePGUserRepo)
+}
+
+func TestPostgresUserRepoErase(t *testing.T) {
+	t.Parallel()
+
+	testUserRepoErase(t, preparePGUserRepo)
+}
+
+func TestPostgresBaseRepoMigrate(t *testing.T) {
+	t.Parallel()
+
+	testMigrate(t, preparePGBaseRepo(t).(*PGBaseRepo).Migrator())
+}
+
+func TestPostgresPromotionRepoMigrate(t *testing.T) {
+	t.Parallel()
+
+	testMigrate(t, preparePGPromotionRepo(t).(*PGPromotionRepo).Migrator())
+}
+
+func TestPostgresUserRepoMigrate(t *testing.T) {
+	t.Parallel()
+
+	testMigrate(t, preparePGUserRepo(t).(*PGUserRepo).Migrator())
+}
+
 func preparePGBaseRepo(t *testing.T) BaseRepo {
 	db, err := sqlx.Connect("postgres", pgURI)
 	if err != nil {
@@ -105,6 +135,43 @@ func preparePGUserRepo(t *testing.T) UserRepo {
 	return r
 }
 
+func preparePGPromotionRepo(t *testing.T) PromotionRepo {
+	db, err := sqlx.Connect("postgres", pgURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewPostgresPromotionRepo(db, PGPromotionRepoSchema(t.Name()))
+
+	if err := r.teardown(); err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func testMigrate(t *testing.T, m *pg.Migrator) {
+	for _, step := range []func() error{
+		m.Up,
+		m.Up,
+		func() error { return m.Down(0) },
+		m.Up,
+	} {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := s.Version, s.Latest; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
 func init() {
 	u, err := user.Current()
 	if err != nil {
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/oklog/ulid"
+
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/rule"
+)
+
+// Erasure is the audit record of the removal of all data stored for a user.
+type Erasure struct {
+	ID        string
+	UserID    string
+	Deleted   int
+	CreatedAt time.Time
+}
+
+// PrivacyService answers access and erasure requests for the data stored per
+// user, which are the rendered configs and the memberships in user lists.
+type PrivacyService interface {
+	// Erase removes the user from all user lists and deletes its configs, the
+	// erasure records the number of configs deleted.
+	Erase(userID string) (Erasure, error)
+	Erasures(userID string) ([]Erasure, error)
+	// Export returns the configs of the user and the lists it is a member of.
+	Export(userID string) ([]UserConfig, []rule.UserList, error)
+}
+
+type privacyService struct {
+	seed         *rand.Rand
+	userListRepo rule.UserListRepo
+	userRepo     UserRepo
+}
+
+// NewPrivacyService provides export and erasure of user data.
+func NewPrivacyService(userRepo UserRepo, userListRepo rule.UserListRepo) PrivacyService {
+	return &privacyService{
+		seed:         rand.New(rand.NewSource(time.Now().UnixNano())),
+		userListRepo: userListRepo,
+		userRepo:     userRepo,
+	}
+}
+
+func (s *privacyService) Erase(userID string) (Erasure, error) {
+	if userID == "" {
+		return Erasure{}, errors.Wrap(errors.ErrInvalidPayload, "user id missing")
+	}
+
+	id, err := ulid.New(ulid.Timestamp(time.Now()), s.seed)
+	if err != nil {
+		return Erasure{}, errors.Wrap(errors.ErrID, err.Error())
+	}
+
+	// Memberships go first, so a failed erasure leaves nothing behind once it
+	// is repeated.
+	if _, err := s.userListRepo.RemoveMember(userID); err != nil {
+		return Erasure{}, errors.Wrap(err, "remove from user lists")
+	}
+
+	return s.userRepo.Erase(id.String(), userID)
+}
+
+func (s *privacyService) Erasures(userID string) ([]Erasure, error) {
+	return s.userRepo.ListErasures(userID)
+}
+
+func (s *privacyService) Export(userID string) ([]UserConfig, []rule.UserList, error) {
+	cs, err := s.userRepo.ListUser(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ls, err := s.userListRepo.ListByMember(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cs, ls, nil
+}
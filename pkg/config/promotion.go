@@ -0,0 +1,362 @@
+package config
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/oklog/ulid"
+
+	"github.com/lifesum/configsum/pkg/env"
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/rule"
+)
+
+// Supported change operations.
+const (
+	OpCreate     ChangeOp = "create"
+	OpUpdate     ChangeOp = "update"
+	OpRemove     ChangeOp = "remove"
+	OpDeactivate ChangeOp = "deactivate"
+)
+
+// ChangeOp describes how a parameter or rule is altered by a promotion.
+type ChangeOp string
+
+// ParameterChange is the difference of a single parameter between source and
+// target base config. From is nil for created, To is nil for removed
+// parameters.
+type ParameterChange struct {
+	Key  string
+	Op   ChangeOp
+	From interface{}
+	To   interface{}
+}
+
+// RuleChange is the difference of a single rule, matched by name, between
+// source and target base config.
+type RuleChange struct {
+	Name     string
+	Op       ChangeOp
+	SourceID string
+	TargetID string
+}
+
+// Promotion copies a base config and its rules from one environment to the
+// next. It's stored as audit record once applied.
+type Promotion struct {
+	ID         string
+	SourceID   string
+	TargetID   string
+	From       env.Env
+	To         env.Env
+	Parameters []ParameterChange
+	Rules      []RuleChange
+	CreatedAt  time.Time
+}
+
+// PromotionService diffs and promotes base configs between environments.
+type PromotionService interface {
+	Diff(baseID string, target env.Env) (Promotion, error)
+	List(baseID string) ([]Promotion, error)
+	Promote(baseID string, target env.Env) (Promotion, error)
+}
+
+type promotionService struct {
+	baseRepo      BaseRepo
+	promotionRepo PromotionRepo
+	ruleRepo      rule.Repo
+	seed          *rand.Rand
+}
+
+// NewPromotionService provides promotions of base configs.
+func NewPromotionService(
+	baseRepo BaseRepo,
+	promotionRepo PromotionRepo,
+	ruleRepo rule.Repo,
+) PromotionService {
+	return &promotionService{
+		baseRepo:      baseRepo,
+		promotionRepo: promotionRepo,
+		ruleRepo:      ruleRepo,
+		seed:          rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s *promotionService) Diff(
+	baseID string,
+	target env.Env,
+) (Promotion, error) {
+	p, _, _, err := s.plan(baseID, target)
+
+	return p, err
+}
+
+func (s *promotionService) List(baseID string) ([]Promotion, error) {
+	if _, err := s.baseRepo.GetByID(baseID); err != nil {
+		return nil, err
+	}
+
+	return s.promotionRepo.List(baseID)
+}
+
+func (s *promotionService) Promote(
+	baseID string,
+	target env.Env,
+) (Promotion, error) {
+	p, bc, rs, err := s.plan(baseID, target)
+	if err != nil {
+		return Promotion{}, err
+	}
+
+	all, err := s.ruleRepo.ListAll()
+	if err != nil {
+		return Promotion{}, errors.Wrap(err, "ruleRepo.ListAll")
+	}
+
+	if err := validatePromotedRules(bc, rs, all, time.Now()); err != nil {
+		return Promotion{}, err
+	}
+
+	return s.promotionRepo.Apply(p, bc, rs)
+}
+
+// plan computes the promotion of the base config to the target environment
+// together with the resulting target base config and the rules to store.
+func (s *promotionService) plan(
+	baseID string,
+	target env.Env,
+) (Promotion, BaseConfig, []rule.Rule, error) {
+	source, err := s.baseRepo.GetByID(baseID)
+	if err != nil {
+		return Promotion{}, BaseConfig{}, nil, err
+	}
+
+	if !source.Env.Before(target) {
+		return Promotion{}, BaseConfig{}, nil, errors.Wrapf(
+			errors.ErrEnvInvalid,
+			"can't promote from '%s' to '%s'",
+			source.Env,
+			target,
+		)
+	}
+
+	now := time.Now().UTC()
+
+	bc, err := s.baseRepo.GetByName(source.ClientID, target, source.Name)
+	if err != nil {
+		if errors.Cause(err) != errors.ErrNotFound {
+			return Promotion{}, BaseConfig{}, nil, err
+		}
+
+		id, err := s.newID(now)
+		if err != nil {
+			return Promotion{}, BaseConfig{}, nil, err
+		}
+
+		bc = BaseConfig{
+			ClientID:   source.ClientID,
+			Env:        target,
+			ID:         id,
+			Name:       source.Name,
+			Parameters: rule.Parameters{},
+			CreatedAt:  now,
+		}
+	}
+
+	all, err := s.ruleRepo.ListAll()
+	if err != nil {
+		return Promotion{}, BaseConfig{}, nil, errors.Wrap(err, "ruleRepo.ListAll")
+	}
+
+	sourceRules, targetRules := []rule.Rule{}, []rule.Rule{}
+
+	for _, r := range all {
+		switch r.ConfigID() {
+		case source.ID:
+			sourceRules = append(sourceRules, r)
+		case bc.ID:
+			targetRules = append(targetRules, r)
+		}
+	}
+
+	ruleChanges := diffRules(sourceRules, targetRules, now)
+	rs := []rule.Rule{}
+
+	for i, c := range ruleChanges {
+		switch c.Op {
+		case OpCreate:
+			id, err := s.newID(now)
+			if err != nil {
+				return Promotion{}, BaseConfig{}, nil, err
+			}
+
+			ruleChanges[i].TargetID = id
+			rs = append(rs, ruleByID(sourceRules, c.SourceID).CopyTo(id, bc.ID))
+		case OpUpdate:
+			rs = append(rs, ruleByID(sourceRules, c.SourceID).CopyTo(c.TargetID, bc.ID))
+		case OpDeactivate:
+			rs = append(rs, ruleByID(targetRules, c.TargetID).Deactivated())
+		}
+	}
+
+	id, err := s.newID(now)
+	if err != nil {
+		return Promotion{}, BaseConfig{}, nil, err
+	}
+
+	p := Promotion{
+		ID:         id,
+		SourceID:   source.ID,
+		TargetID:   bc.ID,
+		From:       source.Env,
+		To:         target,
+		Parameters: DiffParameters(source.Parameters, bc.Parameters),
+		Rules:      ruleChanges,
+		CreatedAt:  now,
+	}
+
+	bc.Parameters = source.Parameters
+	bc.Schema = source.Schema
+	bc.Deprecated = source.Deprecated
+	bc.Platforms = source.Platforms
+
+	return p, bc, rs, nil
+}
+
+// validatePromotedRules applies the checks of the rule repo middlewares, which
+// are bypassed when a promotion is applied, to the rules of the target base
+// config as they are after the promotion.
+func validatePromotedRules(bc BaseConfig, rs, all []rule.Rule, now time.Time) error {
+	var (
+		promoted = map[string]struct{}{}
+		target   = []rule.Rule{}
+	)
+
+	for _, r := range rs {
+		promoted[r.ID] = struct{}{}
+		target = append(target, r)
+
+		if !r.Active(now) {
+			continue
+		}
+
+		if err := validateRuleParams(bc, r); err != nil {
+			return errors.Wrapf(err, "rule '%s'", r.Name())
+		}
+	}
+
+	for _, r := range all {
+		if _, ok := promoted[r.ID]; !ok && r.ConfigID() == bc.ID {
+			target = append(target, r)
+		}
+	}
+
+	return rule.ValidateRules(target, now)
+}
+
+func (s *promotionService) newID(now time.Time) (string, error) {
+	id, err := ulid.New(ulid.Timestamp(now), s.seed)
+	if err != nil {
+		return "", errors.Wrap(errors.ErrID, err.Error())
+	}
+
+	return id.String(), nil
+}
+
+// DiffParameters returns the changes which turn the target parameters into the
+// source parameters, sorted by key.
+func DiffParameters(source, target rule.Parameters) []ParameterChange {
+	cs := []ParameterChange{}
+
+	for k, v := range source {
+		old, ok := target[k]
+		if !ok {
+			cs = append(cs, ParameterChange{Key: k, Op: OpCreate, To: v})
+			continue
+		}
+
+		if !reflect.DeepEqual(old, v) {
+			cs = append(cs, ParameterChange{Key: k, Op: OpUpdate, From: old, To: v})
+		}
+	}
+
+	for k, v := range target {
+		if _, ok := source[k]; !ok {
+			cs = append(cs, ParameterChange{Key: k, Op: OpRemove, From: v})
+		}
+	}
+
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Key < cs[j].Key })
+
+	return cs
+}
+
+// diffRules matches source and target rules by name and returns the changes
+// necessary to bring the target in line with the source, sorted by name.
+// Active target rules without counterpart are deactivated rather than deleted.
+// Ids of rules to be created are left empty.
+func diffRules(source, target []rule.Rule, now time.Time) []RuleChange {
+	targets := map[string]rule.Rule{}
+
+	for _, r := range target {
+		targets[r.Name()] = r
+	}
+
+	cs := []RuleChange{}
+	seen := map[string]struct{}{}
+
+	for _, r := range source {
+		seen[r.Name()] = struct{}{}
+
+		t, ok := targets[r.Name()]
+		if !ok {
+			cs = append(cs, RuleChange{
+				Name:     r.Name(),
+				Op:       OpCreate,
+				SourceID: r.ID,
+			})
+			continue
+		}
+
+		if !r.SameAs(t) {
+			cs = append(cs, RuleChange{
+				Name:     r.Name(),
+				Op:       OpUpdate,
+				SourceID: r.ID,
+				TargetID: t.ID,
+			})
+		}
+	}
+
+	for _, r := range target {
+		if _, ok := seen[r.Name()]; ok {
+			continue
+		}
+
+		if !r.Active(now) {
+			continue
+		}
+
+		cs = append(cs, RuleChange{
+			Name:     r.Name(),
+			Op:       OpDeactivate,
+			TargetID: r.ID,
+		})
+	}
+
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Name < cs[j].Name })
+
+	return cs
+}
+
+func ruleByID(rs []rule.Rule, id string) rule.Rule {
+	for _, r := range rs {
+		if r.ID == id {
+			return r
+		}
+	}
+
+	return rule.Rule{}
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/generate"
+	"github.com/lifesum/configsum/pkg/rule"
+)
+
+func TestDiffParameters(t *testing.T) {
+	var (
+		source = rule.Parameters{
+			"feature_decrease_carbs": true,
+			"feature_increase_fat":   true,
+			"tracking_version":       float64(2),
+		}
+		target = rule.Parameters{
+			"feature_decrease_carbs": true,
+			"feature_legacy_diary":   false,
+			"tracking_version":       float64(1),
+		}
+		want = []ParameterChange{
+			{Key: "feature_increase_fat", Op: OpCreate, To: true},
+			{Key: "feature_legacy_diary", Op: OpRemove, From: false},
+			{Key: "tracking_version", Op: OpUpdate, From: float64(1), To: float64(2)},
+		}
+	)
+
+	if have := DiffParameters(source, target); !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := len(DiffParameters(source, source)), 0; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestDiffRules(t *testing.T) {
+	var (
+		sourceID = generate.RandomString(24)
+		targetID = generate.RandomString(24)
+		now      = time.Now()
+	)
+
+	newRule := func(configID, name, description string) rule.Rule {
+		r, err := rule.New(
+			generate.RandomString(12),
+			configID,
+			name,
+			description,
+			rule.KindOverride,
+			true,
+			nil,
+			[]rule.Bucket{
+				{
+					Name: "default",
+					Parameters: rule.Parameters{
+						"feature_decrease_carbs": false,
+					},
+				},
+			},
+			nil,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r
+	}
+
+	var (
+		sourceCreate   = newRule(sourceID, "new_override", "")
+		sourceSame     = newRule(sourceID, "unchanged_override", "")
+		sourceUpdate   = newRule(sourceID, "changed_override", "new description")
+		targetSame     = newRule(targetID, "unchanged_override", "")
+		targetUpdate   = newRule(targetID, "changed_override", "old description")
+		targetRemoved  = newRule(targetID, "removed_override", "")
+		targetInactive = newRule(targetID, "inactive_override", "").Deactivated()
+		source         = []rule.Rule{sourceCreate, sourceSame, sourceUpdate}
+		target         = []rule.Rule{targetSame, targetUpdate, targetRemoved, targetInactive}
+		want           = []RuleChange{
+			{
+				Name:     "changed_override",
+				Op:       OpUpdate,
+				SourceID: sourceUpdate.ID,
+				TargetID: targetUpdate.ID,
+			},
+			{
+				Name:     "new_override",
+				Op:       OpCreate,
+				SourceID: sourceCreate.ID,
+			},
+			{
+				Name:     "removed_override",
+				Op:       OpDeactivate,
+				TargetID: targetRemoved.ID,
+			},
+		}
+	)
+
+	if have := diffRules(source, target, now); !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestValidatePromotedRules(t *testing.T) {
+	var (
+		bc = BaseConfig{
+			ID: generate.RandomString(24),
+			Parameters: rule.Parameters{
+				"feature_decrease_carbs": false,
+			},
+		}
+		now = time.Now()
+	)
+
+	newRule := func(name string, criteria rule.Criteria) rule.Rule {
+		r, err := rule.New(
+			generate.RandomString(12),
+			bc.ID,
+			name,
+			"",
+			rule.KindOverride,
+			true,
+			criteria,
+			[]rule.Bucket{
+				{
+					Name: "default",
+					Parameters: rule.Parameters{
+						"feature_decrease_carbs": true,
+					},
+				},
+			},
+			nil,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r
+	}
+
+	inLayer := func(r rule.Rule, share uint8) rule.Rule {
+		r, err := r.InLayer("checkout", share)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r
+	}
+
+	dependsOn := func(name string) rule.Criteria {
+		return rule.Criteria{
+			{
+				Comparator: rule.ComparatorEQ,
+				Key:        rule.RuleDecision,
+				Value:      name,
+			},
+		}
+	}
+
+	var (
+		layered  = inLayer(newRule("layered", nil), 60)
+		overlap  = inLayer(newRule("overlap", nil), 60)
+		first    = newRule("first", dependsOn("second"))
+		second   = newRule("second", dependsOn("first"))
+		existing = newRule("existing", nil)
+		taken    = newRule("existing", nil)
+	)
+
+	for _, c := range []struct {
+		rs   []rule.Rule
+		all  []rule.Rule
+		want error
+	}{
+		{[]rule.Rule{layered, first}, []rule.Rule{existing}, nil},
+		{[]rule.Rule{layered, overlap}, nil, errors.ErrInvalidRule},
+		{[]rule.Rule{layered}, []rule.Rule{overlap}, errors.ErrInvalidRule},
+		{[]rule.Rule{first, second}, nil, errors.ErrInvalidRule},
+		{[]rule.Rule{taken}, []rule.Rule{existing}, errors.ErrExists},
+		// Stored versions of promoted rules are replaced.
+		{[]rule.Rule{overlap.Deactivated()}, []rule.Rule{layered, overlap}, nil},
+	} {
+		err := validatePromotedRules(bc, c.rs, c.all, now)
+		if have, want := errors.Cause(err), c.want; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
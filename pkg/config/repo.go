@@ -3,6 +3,7 @@ package config
 import (
 	"time"
 
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/rule"
 )
 
@@ -10,9 +11,14 @@ import (
 type BaseRepo interface {
 	lifecycle
 
-	Create(id, clientID, name string, parameters rule.Parameters) (BaseConfig, error)
+	Create(
+		id, clientID string,
+		e env.Env,
+		name string,
+		parameters rule.Parameters,
+	) (BaseConfig, error)
 	GetByID(id string) (BaseConfig, error)
-	GetByName(clientID, name string) (BaseConfig, error)
+	GetByName(clientID string, e env.Env, name string) (BaseConfig, error)
 	List() (BaseList, error)
 	Update(BaseConfig) (BaseConfig, error)
 }
@@ -20,13 +26,20 @@ type BaseRepo interface {
 // BaseRepoMiddleware is chainable behaviour modifier for BaseRepo.
 type BaseRepoMiddleware func(BaseRepo) BaseRepo
 
-// BaseConfig is the entire space of available parameters.
+// BaseConfig is the entire space of available parameters. Deprecated holds
+// the time each deprecated parameter was marked as such. Platforms holds the
+// parameters which replace the defaults for a single platform. The name of a
+// base config is unique per client and environment.
 type BaseConfig struct {
 	ClientID   string
 	Deleted    bool
+	Env        env.Env
 	ID         string
 	Name       string
 	Parameters rule.Parameters
+	Schema     rule.ParameterSchema
+	Deprecated map[string]time.Time
+	Platforms  rule.PlatformParameters
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 }
@@ -46,6 +59,19 @@ func (l BaseList) Swap(i, j int) {
 	l[i], l[j] = l[j], l[i]
 }
 
+// PromotionRepo applies promotions and keeps them as audit trail.
+type PromotionRepo interface {
+	lifecycle
+
+	// Apply stores the target base config, the rules and the promotion
+	// atomically.
+	Apply(p Promotion, target BaseConfig, rules []rule.Rule) (Promotion, error)
+	List(baseID string) ([]Promotion, error)
+}
+
+// PromotionRepoMiddleware is chainable behaviour modifier for PromotionRepo.
+type PromotionRepoMiddleware func(PromotionRepo) PromotionRepo
+
 // UserRepo provides access to user configs.
 type UserRepo interface {
 	lifecycle
@@ -56,6 +82,23 @@ type UserRepo interface {
 		render rule.Parameters,
 	) (UserConfig, error)
 	GetLatest(baseID, userID string) (UserConfig, error)
+
+	// ListCompactable returns the base and user pairs with more than keep
+	// user configs of which at least one was created before the given time.
+	ListCompactable(keep int, before time.Time) ([]UserKey, error)
+	// History returns all user configs of the base and user, newest first.
+	History(baseID, userID string) ([]UserConfig, error)
+	// Delete removes the user configs with the given ids and returns the
+	// number of rows deleted.
+	Delete(ids ...string) (int, error)
+
+	// ListUser returns all user configs of the user across base configs,
+	// oldest first.
+	ListUser(userID string) ([]UserConfig, error)
+	// Erase deletes all user configs of the user and stores the erasure as
+	// audit record atomically.
+	Erase(id, userID string) (Erasure, error)
+	ListErasures(userID string) ([]Erasure, error)
 }
 
 // UserRepoMiddleware is chainable behaviour modifier for UserRepo.
@@ -71,6 +114,12 @@ type UserConfig struct {
 	createdAt     time.Time
 }
 
+// UserKey identifies the render history of a user for a base config.
+type UserKey struct {
+	BaseID string
+	UserID string
+}
+
 type lifecycle interface {
 	setup() error
 	teardown() error
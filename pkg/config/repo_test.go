@@ -9,6 +9,7 @@ import (
 
 	"github.com/oklog/ulid"
 
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/errors"
 	"github.com/lifesum/configsum/pkg/generate"
 	"github.com/lifesum/configsum/pkg/rule"
@@ -34,12 +35,12 @@ func testBaseRepoCreateDuplicate(t *testing.T, p prepareBaseRepoFunc) {
 		t.Fatal(err)
 	}
 
-	_, err = repo.Create(id.String(), clientID, name, parameters)
+	_, err = repo.Create(id.String(), clientID, env.Default, name, parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	_, err = repo.Create(id.String(), clientID, name, parameters)
+	_, err = repo.Create(id.String(), clientID, env.Default, name, parameters)
 	if have, want := errors.Cause(err), errors.ErrExists; have != want {
 		t.Errorf("have %v, want %v", have, want)
 	}
@@ -61,7 +62,7 @@ func testBaseRepoGetByID(t *testing.T, p prepareBaseRepoFunc) {
 		t.Fatal(err)
 	}
 
-	_, err = repo.Create(id.String(), clientID, name, parameters)
+	_, err = repo.Create(id.String(), clientID, env.Default, name, parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -113,12 +114,12 @@ func testBaseRepoGetByName(t *testing.T, p prepareBaseRepoFunc) {
 		t.Fatal(err)
 	}
 
-	_, err = repo.Create(id.String(), clientID, name, parameters)
+	_, err = repo.Create(id.String(), clientID, env.Default, name, parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	c, err := repo.GetByName(clientID, name)
+	c, err := repo.GetByName(clientID, env.Default, name)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -147,7 +148,7 @@ func testBaseRepoGetByNameNotFound(t *testing.T, p prepareBaseRepoFunc) {
 		repo     = p(t)
 	)
 
-	_, err := repo.GetByName(clientID, name)
+	_, err := repo.GetByName(clientID, env.Default, name)
 	if have, want := errors.Cause(err), errors.ErrNotFound; have != want {
 		t.Errorf("have %v, want %v", have, want)
 	}
@@ -178,7 +179,7 @@ func testBaseRepoList(t *testing.T, p prepareBaseRepoFunc) {
 			t.Fatal(err)
 		}
 
-		c, err := repo.Create(id.String(), clientID, name, parameters)
+		c, err := repo.Create(id.String(), clientID, env.Default, name, parameters)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -239,7 +240,7 @@ func testBaseRepoUpdate(t *testing.T, p prepareBaseRepoFunc) {
 		t.Errorf("have %v, want %v", have, want)
 	}
 
-	c, err := repo.Create(id.String(), clientID, name, parameters)
+	c, err := repo.Create(id.String(), clientID, env.Default, name, parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -390,3 +391,160 @@ func testUserRepoAppendDuplicate(t *testing.T, p prepareUserRepoFunc) {
 		t.Errorf("have %v, want %v", have, want)
 	}
 }
+
+func testUserRepoCompact(t *testing.T, p prepareUserRepoFunc) {
+	var (
+		baseID = generate.RandomString(24)
+		userID = generate.RandomString(24)
+		repo   = p(t)
+		seed   = rand.New(rand.NewSource(time.Now().UnixNano()))
+		ids    = []string{}
+	)
+
+	for i := 0; i < 3; i++ {
+		id, err := ulid.New(ulid.Timestamp(time.Now()), seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = repo.Append(
+			id.String(),
+			baseID,
+			userID,
+			rule.Decisions{},
+			rule.Parameters{generate.RandomString(12): i},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, id.String())
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	ks, err := repo.ListCompactable(1, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := ks, []UserKey{{BaseID: baseID, UserID: userID}}; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	cs, err := repo.History(baseID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(cs), len(ids); have != want {
+		t.Fatalf("have %v, want %v", have, want)
+	}
+
+	if have, want := cs[0].id, ids[2]; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	n, err := repo.Delete(ids[0], ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := n, 2; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	c, err := repo.GetLatest(baseID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := c.id, ids[2]; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	ks, err = repo.ListCompactable(1, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(ks), 0; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func testUserRepoErase(t *testing.T, p prepareUserRepoFunc) {
+	var (
+		userID = generate.RandomString(24)
+		other  = generate.RandomString(24)
+		repo   = p(t)
+		seed   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	)
+
+	for _, u := range []string{userID, userID, other} {
+		id, err := ulid.New(ulid.Timestamp(time.Now()), seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = repo.Append(
+			id.String(),
+			generate.RandomString(24),
+			u,
+			rule.Decisions{},
+			rule.Parameters{},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cs, err := repo.ListUser(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(cs), 2; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	e, err := repo.Erase(generate.RandomString(24), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := e.Deleted, 2; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	cs, err = repo.ListUser(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(cs), 0; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	cs, err = repo.ListUser(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(cs), 1; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	es, err := repo.ListErasures(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(es), 1; have != want {
+		t.Fatalf("have %v, want %v", have, want)
+	}
+
+	if have, want := es[0].ID, e.ID; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
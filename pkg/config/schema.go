@@ -15,6 +15,10 @@ const schemaDefBaseCreate = `
     "client_id": {
       "type": "string"
     },
+    "environment": {
+      "type": "string",
+      "enum": ["dev", "staging", "prod"]
+    },
     "name": {
       "type": "string",
       "pattern": "^([0-9a-z-]+)$"
@@ -37,7 +41,46 @@ const schemaDefBaseUpdate = `
       "additionalProperties":false,
       "minProperties":1,
       "patternProperties":{
-        "^feature_([a-z-]+)_([a-z]+)$":{
+        "^[a-zA-Z][a-zA-Z0-9_.-]*$":{
+          "anyOf":[
+            {
+              "type":"boolean"
+            },
+            {
+              "type":"number"
+            },
+            {
+              "type":"string",
+              "minLength": 1
+            },
+            {
+              "type":"object"
+            },
+            {
+              "type":"array"
+            }
+          ]
+        }
+      }
+    }
+  }
+}`
+
+const schemaDefBaseUpdatePlatform = `
+{
+  "$schema":"http://json-schema.org/draft-06/schema#",
+  "title":"Base platform update",
+  "description":"Request data for platform overrides of base config parameters, empty parameters remove the overrides.",
+  "type":"object",
+  "required":[
+    "parameters"
+  ],
+  "properties":{
+    "parameters":{
+      "type":"object",
+      "additionalProperties":false,
+      "patternProperties":{
+        "^[a-zA-Z][a-zA-Z0-9_.-]*$":{
           "anyOf":[
             {
               "type":"boolean"
@@ -48,6 +91,12 @@ const schemaDefBaseUpdate = `
             {
               "type":"string",
               "minLength": 1
+            },
+            {
+              "type":"object"
+            },
+            {
+              "type":"array"
             }
           ]
         }
@@ -56,12 +105,98 @@ const schemaDefBaseUpdate = `
   }
 }`
 
+const schemaDefBaseUpdateSchema = `
+{
+  "$schema":"http://json-schema.org/draft-06/schema#",
+  "title":"Base schema update",
+  "description":"Request data for base config parameter schema updates.",
+  "type":"object",
+  "required":[
+    "schema"
+  ],
+  "properties":{
+    "schema":{
+      "type":"object",
+      "additionalProperties":false,
+      "minProperties":1,
+      "patternProperties":{
+        "^[a-zA-Z][a-zA-Z0-9_.-]*$":{
+          "type":"object",
+          "additionalProperties":false,
+          "required":[
+            "type"
+          ],
+          "properties":{
+            "default":{},
+            "description":{
+              "type":"string"
+            },
+            "enum":{
+              "type":"array",
+              "minItems":1
+            },
+            "max":{
+              "type":"number"
+            },
+            "min":{
+              "type":"number"
+            },
+            "type":{
+              "enum":[
+                "array",
+                "bool",
+                "localized",
+                "number",
+                "object",
+                "string"
+              ]
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
 const schemaDefUserRender = `
 {
   "$schema": "http://json-schema.org/draft-06/schema#",
   "title": "Render context",
   "description": "Common set of information to determine device capabilities and user provided info.",
   "type": "object",
+  "definitions": {
+    "metadataValue": {
+      "anyOf": [
+        {
+          "type": "boolean"
+        },
+        {
+          "type": "string"
+        },
+        {
+          "type": "integer"
+        },
+        {
+          "type": "array",
+          "items": {
+            "type": "string"
+          }
+        },
+        {
+          "type": "array",
+          "items": {
+            "type": "integer"
+          }
+        },
+        {
+          "type": "object",
+          "additionalProperties": {
+            "$ref": "#/definitions/metadataValue"
+          }
+        }
+      ]
+    }
+  },
   "properties": {
     "app": {
       "type": "object",
@@ -76,33 +211,19 @@ const schemaDefUserRender = `
       ]
     },
     "metadata": {
+      "description": "Free-form attributes of the user, nested values are addressed by dot separated paths in criteria.",
       "type": [ "null", "object" ],
       "additionalProperties": {
-        "anyOf": [
-          {
-            "type": "string"
-          },
-          {
-            "type": "integer"
-          },
-          {
-            "type": "array",
-            "items": {
-              "type": "string"
-            }
-          },
-          {
-            "type": "array",
-            "items": {
-              "type": "integer"
-            }
-          }
-        ]
+        "$ref": "#/definitions/metadataValue"
       }
     },
     "device": {
       "type": "object",
       "properties": {
+        "id": {
+          "description": "Stable identifier of the device, rules can bucket by it instead of the user.",
+          "type": "string"
+        },
         "location": {
           "type": "object",
           "properties": {
@@ -153,6 +274,11 @@ const schemaDefUserRender = `
         "age": {
           "description": "Age of the application's logged in user.",
           "type": "integer"
+        },
+        "registered": {
+          "description": "Registration date of the logged in user according to RFC 3339.",
+          "type": "string",
+          "format": "date-time"
         }
       }
     }
@@ -164,9 +290,11 @@ const schemaDefUserRender = `
 }`
 
 var (
-	schemaBaseCreateRequest *gojsonschema.Schema
-	schemaBaseUpdateRequest *gojsonschema.Schema
-	schemaUserRenderRequest *gojsonschema.Schema
+	schemaBaseCreateRequest   *gojsonschema.Schema
+	schemaBaseUpdateRequest   *gojsonschema.Schema
+	schemaBasePlatformRequest *gojsonschema.Schema
+	schemaBaseSchemaRequest   *gojsonschema.Schema
+	schemaUserRenderRequest   *gojsonschema.Schema
 )
 
 func init() {
@@ -186,6 +314,20 @@ func init() {
 		panic(err)
 	}
 
+	schemaBasePlatformRequest, err = gojsonschema.NewSchema(
+		gojsonschema.NewStringLoader(schemaDefBaseUpdatePlatform),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	schemaBaseSchemaRequest, err = gojsonschema.NewSchema(
+		gojsonschema.NewStringLoader(schemaDefBaseUpdateSchema),
+	)
+	if err != nil {
+		panic(err)
+	}
+
 	schemaUserRenderRequest, err = gojsonschema.NewSchema(
 		gojsonschema.NewStringLoader(schemaDefUserRender),
 	)
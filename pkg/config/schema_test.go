@@ -8,7 +8,7 @@ import (
 
 func TestSchemaBaseCreateInvalid(t *testing.T) {
 	cases := []string{
-		`{}`, // Empty.
+		`{}`,                         // Empty.
 		`{"client_id": "clientID"}`,  // Name missing,
 		`{"name": "baseConfigName"}`, // ClientID missing.
 	}
@@ -27,10 +27,8 @@ func TestSchemaBaseCreateInvalid(t *testing.T) {
 
 func TestSchemaBaseUpdateInvalid(t *testing.T) {
 	cases := []string{
-		`{}`,                                                      // Missing parameters.
-		`{"parameters": {}}`,                                      // Empty parameters.
-		`{"parameters": {"feature_array_toggled": {}} }`,          // Array as parameter type.
-		`{"parameters": {"feature_object_toggled": []} }`,         // Object as parameter type.
+		`{}`,                 // Missing parameters.
+		`{"parameters": {}}`, // Empty parameters.
 		`{"parameters": {"feature_inv4l1d$char_toggled": true} }`, // Invalid character in parameter key.
 	}
 
@@ -46,19 +44,68 @@ func TestSchemaBaseUpdateInvalid(t *testing.T) {
 	}
 }
 
+func TestSchemaBaseUpdateValid(t *testing.T) {
+	cases := []struct {
+		schema *gojsonschema.Schema
+		input  string
+	}{
+		{schemaBaseUpdateRequest, `{"parameters": {"paywall_price": 4.99}}`},
+		{schemaBaseUpdateRequest, `{"parameters": {"onboardingFlow": "short"}}`},
+		{schemaBasePlatformRequest, `{"parameters": {"paywall.price": 5.99}}`},
+		{schemaBaseSchemaRequest, `{"schema": {"paywall_price": {"type": "number", "min": 0}}}`},
+	}
+
+	for _, c := range cases {
+		res, err := c.schema.Validate(gojsonschema.NewStringLoader(c.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !res.Valid() {
+			t.Errorf("valid: %s: %v", c.input, res.Errors())
+		}
+	}
+}
+
+func TestSchemaBaseUpdateSchemaInvalid(t *testing.T) {
+	cases := []string{
+		`{}`,             // Missing schema.
+		`{"schema": {}}`, // Empty schema.
+		`{"schema": {"feature_price_amount": {}}}`,                                 // Missing type.
+		`{"schema": {"feature_price_amount": {"type": "integer"}}}`,                // Unsupported type.
+		`{"schema": {"feature_price_amount": {"type": "number", "min": "1"}}}`,     // Min not a number.
+		`{"schema": {"feature_price_amount": {"type": "number", "unit": "euro"}}}`, // Unknown property.
+		`{"schema": {"feature_inv4l1d$char_toggled": {"type": "bool"}}}`,           // Invalid character in parameter key.
+	}
+
+	for _, input := range cases {
+		res, err := schemaBaseSchemaRequest.Validate(gojsonschema.NewStringLoader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Valid() {
+			t.Errorf("invalid: %s", input)
+		}
+	}
+}
+
 func TestSchemaUserRenderInvalid(t *testing.T) {
 	var (
 		want  = "invalid JSON error"
 		cases = []string{
-			`{}`,                                                                                                                                // App missing
-			`{"app": {}}`,                                                                                                                       // Empty App object
-			`{"app": {"version": "6.4.1"}}`,                                                                                                     // Device missing
-			`{"app": {"version": "6.4.1"}, "device": {}, "os": {"platform": "WatchOS", "version": "9.4"}}`,                                      // Empty Device object
-			`{"app": {"version": "6.4.1"}, "device": {"location": {}, "os": {"platform": "WatchOS", "version": "9.4"}}}`,                        // Empty Location object
-			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB"}, "os": {"platform": "WatchOS", "version": "9.4"}}}`,       // TimezoneOffset missing
-			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7200}}}`,                                // OS missing
-			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7200}, "os": {}}}`,                      // Empty Os object
-			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7200}, "os": {"platform": "WatchOS"}}}`, // Version missing
+			`{}`,                            // App missing
+			`{"app": {}}`,                   // Empty App object
+			`{"app": {"version": "6.4.1"}}`, // Device missing
+			`{"app": {"version": "6.4.1"}, "device": {}, "os": {"platform": "WatchOS", "version": "9.4"}}`,                                                                                                    // Empty Device object
+			`{"app": {"version": "6.4.1"}, "device": {"location": {}, "os": {"platform": "WatchOS", "version": "9.4"}}}`,                                                                                      // Empty Location object
+			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB"}, "os": {"platform": "WatchOS", "version": "9.4"}}}`,                                                                     // TimezoneOffset missing
+			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7200}}}`,                                                                                              // OS missing
+			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7200}, "os": {}}}`,                                                                                    // Empty Os object
+			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7200}, "os": {"platform": "WatchOS"}}}`,                                                               // Version missing
+			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7200}, "os": {"platform": "WatchOS", "version": "9.4"}}, "metadata": {"goal": 1.5}}`,                  // Metadata number not an integer
+			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7200}, "os": {"platform": "WatchOS", "version": "9.4"}}, "metadata": {"onboarding": {"goal": [{}]}}}`, // Nested metadata array of objects
+			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7200}, "os": {"platform": "WatchOS", "version": "9.4"}}, "user": {"registered": "04.12.2017"}}`,       // Registration date not RFC 3339
 		}
 	)
 
@@ -78,10 +125,12 @@ func TestSchemaUserRenderValid(t *testing.T) {
 	var (
 		want  = "valid JSON input"
 		cases = []string{
-			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7201}, "os": {"platform": "WatchOS", "version": "9.4"}}, "user": {"age": 23}}`,                   // Working case
-			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7201}, "os": {"platform": "WatchOS", "version": "9.4"}}, "user": {}}`,                            // User age optional
-			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en", "timezoneOffset": 7201}, "os": {"platform": "WatchOS", "version": "9.4"}}, "user": {"age": 27}}`,                      // Only region provided
-			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7201}, "metadata": null, "os": {"platform": "WatchOS", "version": "9.4"}}, "user": {"age": 23}}`, // Metadata value is null
+			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7201}, "os": {"platform": "WatchOS", "version": "9.4"}}, "user": {"age": 23}}`,                                                                // Working case
+			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7201}, "os": {"platform": "WatchOS", "version": "9.4"}}, "user": {}}`,                                                                         // User age optional
+			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en", "timezoneOffset": 7201}, "os": {"platform": "WatchOS", "version": "9.4"}}, "user": {"age": 27}}`,                                                                   // Only region provided
+			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7201}, "metadata": null, "os": {"platform": "WatchOS", "version": "9.4"}}, "user": {"age": 23}}`,                                              // Metadata value is null
+			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7201}, "os": {"platform": "WatchOS", "version": "9.4"}}, "metadata": {"onboarding": {"goal": "lose_weight", "steps": [1, 2]}, "beta": true}}`, // Nested metadata
+			`{"app": {"version": "6.4.1"}, "device": {"location": {"locale": "en_GB", "timezoneOffset": 7201}, "os": {"platform": "WatchOS", "version": "9.4"}}, "user": {"registered": "2017-12-04T23:11:38Z"}}`,                                     // Registration date
 		}
 	)
 
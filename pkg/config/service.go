@@ -1,45 +1,99 @@
 package config
 
 import (
+	"fmt"
 	"math/rand"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/oklog/ulid"
 	"golang.org/x/text/language"
 
+	"github.com/lifesum/configsum/pkg/auth"
 	"github.com/lifesum/configsum/pkg/client"
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/errors"
 	"github.com/lifesum/configsum/pkg/generate"
+	"github.com/lifesum/configsum/pkg/instrument"
 	"github.com/lifesum/configsum/pkg/rule"
 )
 
+// defaultGracePeriod is the time a parameter has to be deprecated before it
+// can be removed from a base config.
+const defaultGracePeriod = 14 * 24 * time.Hour
+
 var regionUS = language.MustParseRegion("US")
 
 // BaseService provides base configs.
 type BaseService interface {
-	Create(clientID, name string) (BaseConfig, error)
+	Create(clientID string, e env.Env, name string) (BaseConfig, error)
+	Deprecate(id, key string) (BaseConfig, error)
+	Deprecations(id string) ([]Deprecation, error)
 	Get(id string) (BaseConfig, error)
 	List() ([]BaseConfig, error)
+	Undeprecate(id, key string) (BaseConfig, error)
 	Update(id string, parameters rule.Parameters) (BaseConfig, error)
+	// UpdatePlatform replaces the overrides of the platform, empty parameters
+	// remove them.
+	UpdatePlatform(id, platform string, params rule.Parameters) (BaseConfig, error)
+	UpdateSchema(id string, schema rule.ParameterSchema) (BaseConfig, error)
+}
+
+// Deprecation reports the state of a deprecated parameter and the active
+// rules which still override it.
+type Deprecation struct {
+	Key          string
+	Rules        []string
+	DeprecatedAt time.Time
+	RemovableAt  time.Time
+}
+
+// BaseServiceOption sets an optional parameter on the base service.
+type BaseServiceOption func(*baseService)
+
+// BaseServiceGracePeriod sets the time a parameter has to be deprecated
+// before it can be removed.
+func BaseServiceGracePeriod(grace time.Duration) BaseServiceOption {
+	return func(s *baseService) { s.grace = grace }
 }
 
 type baseService struct {
 	baseRepo   BaseRepo
 	clientRepo client.Repo
+	grace      time.Duration
+	ruleRepo   rule.Repo
 	seed       *rand.Rand
 }
 
 // NewBaseService provides base configs.
-func NewBaseService(baseRepo BaseRepo, clientRepo client.Repo) BaseService {
-	return &baseService{
+func NewBaseService(
+	baseRepo BaseRepo,
+	clientRepo client.Repo,
+	ruleRepo rule.Repo,
+	options ...BaseServiceOption,
+) BaseService {
+	s := &baseService{
 		baseRepo:   baseRepo,
 		clientRepo: clientRepo,
+		grace:      defaultGracePeriod,
+		ruleRepo:   ruleRepo,
 		seed:       rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
 }
 
-func (s *baseService) Create(clientID, name string) (BaseConfig, error) {
+func (s *baseService) Create(
+	clientID string,
+	e env.Env,
+	name string,
+) (BaseConfig, error) {
 	id, err := ulid.New(ulid.Timestamp(time.Now()), s.seed)
 	if err != nil {
 		return BaseConfig{}, errors.Wrap(errors.ErrID, err.Error())
@@ -50,7 +104,68 @@ func (s *baseService) Create(clientID, name string) (BaseConfig, error) {
 		return BaseConfig{}, err
 	}
 
-	return s.baseRepo.Create(id.String(), clientID, name, nil)
+	return s.baseRepo.Create(id.String(), clientID, e, name, nil)
+}
+
+func (s *baseService) Deprecate(id, key string) (BaseConfig, error) {
+	bc, err := s.baseRepo.GetByID(id)
+	if err != nil {
+		return BaseConfig{}, err
+	}
+
+	if _, ok := bc.Parameters[key]; !ok {
+		return BaseConfig{}, errors.Wrapf(errors.ErrNotFound, "parameter '%s'", key)
+	}
+
+	if _, ok := bc.Deprecated[key]; ok {
+		return bc, nil
+	}
+
+	deprecated := map[string]time.Time{}
+
+	for k, t := range bc.Deprecated {
+		deprecated[k] = t
+	}
+
+	deprecated[key] = time.Now().UTC()
+	bc.Deprecated = deprecated
+
+	return s.baseRepo.Update(bc)
+}
+
+func (s *baseService) Deprecations(id string) ([]Deprecation, error) {
+	bc, err := s.baseRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := s.ruleRepo.ListAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "ruleRepo.ListAll")
+	}
+
+	var (
+		ds   = []Deprecation{}
+		used = activeRuleKeys(bc.ID, rs, time.Now())
+	)
+
+	for key, t := range bc.Deprecated {
+		rules := used[key]
+		if rules == nil {
+			rules = []string{}
+		}
+
+		ds = append(ds, Deprecation{
+			Key:          key,
+			Rules:        rules,
+			DeprecatedAt: t,
+			RemovableAt:  t.Add(s.grace),
+		})
+	}
+
+	sort.Slice(ds, func(i, j int) bool { return ds[i].Key < ds[j].Key })
+
+	return ds, nil
 }
 
 func (s *baseService) Get(id string) (BaseConfig, error) {
@@ -66,6 +181,29 @@ func (s *baseService) List() ([]BaseConfig, error) {
 	return cs, nil
 }
 
+func (s *baseService) Undeprecate(id, key string) (BaseConfig, error) {
+	bc, err := s.baseRepo.GetByID(id)
+	if err != nil {
+		return BaseConfig{}, err
+	}
+
+	if _, ok := bc.Deprecated[key]; !ok {
+		return BaseConfig{}, errors.Wrapf(errors.ErrNotFound, "deprecation '%s'", key)
+	}
+
+	deprecated := map[string]time.Time{}
+
+	for k, t := range bc.Deprecated {
+		if k != key {
+			deprecated[k] = t
+		}
+	}
+
+	bc.Deprecated = deprecated
+
+	return s.baseRepo.Update(bc)
+}
+
 func (s *baseService) Update(id string, params rule.Parameters) (BaseConfig, error) {
 	bc, err := s.baseRepo.GetByID(id)
 	if err != nil {
@@ -77,12 +215,144 @@ func (s *baseService) Update(id string, params rule.Parameters) (BaseConfig, err
 		return BaseConfig{}, err
 	}
 
+	err = validateParams(bc.Schema, params)
+	if err != nil {
+		return BaseConfig{}, err
+	}
+
+	rs, err := s.ruleRepo.ListAll()
+	if err != nil {
+		return BaseConfig{}, errors.Wrap(err, "ruleRepo.ListAll")
+	}
+
+	now := time.Now()
+
+	err = validateRemovals(bc.Parameters, params, bc.Deprecated, s.grace, now)
+	if err != nil {
+		return BaseConfig{}, err
+	}
+
+	err = validateOrphans(bc.ID, bc.Parameters, params, rs, now)
+	if err != nil {
+		return BaseConfig{}, err
+	}
+
+	// Deprecations end with the removal of their parameter.
+	deprecated := map[string]time.Time{}
+
+	for k, t := range bc.Deprecated {
+		if _, ok := params[k]; ok {
+			deprecated[k] = t
+		}
+	}
+
+	// So do the platform overrides of it.
+	platforms := rule.PlatformParameters{}
+
+	for p, ps := range bc.Platforms {
+		overrides := rule.Parameters{}
+
+		for k, v := range ps {
+			if _, ok := params[k]; ok {
+				overrides[k] = v
+			}
+		}
+
+		if len(overrides) > 0 {
+			platforms[p] = overrides
+		}
+	}
+
 	return s.baseRepo.Update(BaseConfig{
 		ClientID:   bc.ClientID,
 		Deleted:    bc.Deleted,
+		Env:        bc.Env,
 		ID:         bc.ID,
 		Name:       bc.Name,
 		Parameters: params,
+		Schema:     bc.Schema,
+		Deprecated: deprecated,
+		Platforms:  platforms,
+		CreatedAt:  bc.CreatedAt,
+		UpdatedAt:  bc.UpdatedAt,
+	})
+}
+
+func (s *baseService) UpdatePlatform(
+	id, platform string,
+	params rule.Parameters,
+) (BaseConfig, error) {
+	if !rule.ValidPlatform(platform) {
+		return BaseConfig{}, errors.Wrapf(errors.ErrParametersInvalid, "platform '%s' unknown", platform)
+	}
+
+	bc, err := s.baseRepo.GetByID(id)
+	if err != nil {
+		return BaseConfig{}, err
+	}
+
+	if err := validatePlatformParams(bc.Parameters, bc.Schema, params); err != nil {
+		return BaseConfig{}, errors.Wrapf(err, "platform '%s'", platform)
+	}
+
+	platforms := rule.PlatformParameters{}
+
+	for p, ps := range bc.Platforms {
+		platforms[p] = ps
+	}
+
+	if len(params) == 0 {
+		delete(platforms, platform)
+	} else {
+		platforms[platform] = params
+	}
+
+	bc.Platforms = platforms
+
+	return s.baseRepo.Update(bc)
+}
+
+func (s *baseService) UpdateSchema(
+	id string,
+	schema rule.ParameterSchema,
+) (BaseConfig, error) {
+	bc, err := s.baseRepo.GetByID(id)
+	if err != nil {
+		return BaseConfig{}, err
+	}
+
+	if err := schema.Validate(); err != nil {
+		return BaseConfig{}, err
+	}
+
+	// Newly declared parameters start out with their default, present ones
+	// keep their value but have to satisfy the new declaration.
+	params := schema.Defaults()
+
+	for k, v := range bc.Parameters {
+		params[k] = v
+	}
+
+	if err := schema.Check(params); err != nil {
+		return BaseConfig{}, err
+	}
+
+	for p, ps := range bc.Platforms {
+		if err := schema.Check(ps); err != nil {
+			return BaseConfig{}, errors.Wrapf(err, "platform '%s'", p)
+		}
+	}
+
+	return s.baseRepo.Update(BaseConfig{
+		ClientID:   bc.ClientID,
+		Deleted:    bc.Deleted,
+		Env:        bc.Env,
+		ID:         bc.ID,
+		Name:       bc.Name,
+		Parameters: params,
+		Schema:     schema,
+		Deprecated: bc.Deprecated,
+		Platforms:  bc.Platforms,
 		CreatedAt:  bc.CreatedAt,
 		UpdatedAt:  bc.UpdatedAt,
 	})
@@ -90,15 +360,63 @@ func (s *baseService) Update(id string, params rule.Parameters) (BaseConfig, err
 
 // UserService provides user specific configs.
 type UserService interface {
-	Render(clientID, baseName, userID string, ctx userRenderContext) (UserConfig, error)
+	// Alias links the device to the user after signup. The rule decisions of
+	// the anonymous configs rendered for the device are carried over, so the
+	// user stays in the same buckets. Decisions the user already holds take
+	// precedence. The device id must stem from the authenticated request.
+	Alias(clientID, deviceID, userID string) error
+	Render(
+		clientID string,
+		e env.Env,
+		baseName, userID string,
+		ctx userRenderContext,
+	) (UserConfig, error)
+}
+
+// UserServiceOption sets an optional parameter on the user service.
+type UserServiceOption func(*userService)
+
+// UserServiceDice sets the source of dice rolls for new percentage based
+// decisions, e.g. rule.HashDice to render the same results as local snapshot
+// evaluation.
+func UserServiceDice(dice rule.DiceFunc) UserServiceOption {
+	return func(s *userService) { s.dice = dice }
+}
+
+// UserServiceClock sets the source of the current time, which decides the
+// active rules and the local time of users.
+func UserServiceClock(now func() time.Time) UserServiceOption {
+	return func(s *userService) { s.now = now }
+}
+
+// UserServiceDeprecatedServed sets the function called for every deprecated
+// parameter included in a rendered config.
+func UserServiceDeprecatedServed(fn instrument.CountServedFunc) UserServiceOption {
+	return func(s *userService) { s.deprecatedServed = fn }
+}
+
+// UserServiceSegments sets the lookup for segments referenced by rules.
+// Rules referencing segments fail to render without it.
+func UserServiceSegments(segments rule.SegmentLookup) UserServiceOption {
+	return func(s *userService) { s.segments = segments }
+}
+
+// UserServiceUserLists sets the lookup for user lists referenced by rules.
+// Rules referencing user lists fail to render without it.
+func UserServiceUserLists(lists rule.UserListLookup) UserServiceOption {
+	return func(s *userService) { s.userLists = lists }
 }
 
 type userService struct {
-	baseRepo BaseRepo
-	userRepo UserRepo
-	randFn   generate.RandPercentageFunc
-	ruleRepo rule.Repo
-	seed     *rand.Rand
+	baseRepo         BaseRepo
+	deprecatedServed instrument.CountServedFunc
+	dice             rule.DiceFunc
+	now              func() time.Time
+	userRepo         UserRepo
+	ruleRepo         rule.Repo
+	seed             *rand.Rand
+	segments         rule.SegmentLookup
+	userLists        rule.UserListLookup
 }
 
 // NewUserService provides user specific configs.
@@ -107,21 +425,32 @@ func NewUserService(
 	userRepo UserRepo,
 	ruleRepo rule.Repo,
 	randFn generate.RandPercentageFunc,
+	options ...UserServiceOption,
 ) UserService {
-	return &userService{
-		baseRepo: baseRepo,
-		userRepo: userRepo,
-		randFn:   randFn,
-		ruleRepo: ruleRepo,
-		seed:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	s := &userService{
+		baseRepo:         baseRepo,
+		deprecatedServed: func(string, string) {},
+		dice:             rule.RandDice(randFn),
+		now:              time.Now,
+		userRepo:         userRepo,
+		ruleRepo:         ruleRepo,
+		seed:             rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
 }
 
 func (s *userService) Render(
-	clientID, baseName, userID string,
+	clientID string,
+	e env.Env,
+	baseName, userID string,
 	ctx userRenderContext,
 ) (UserConfig, error) {
-	bc, err := s.baseRepo.GetByName(clientID, baseName)
+	bc, err := s.baseRepo.GetByName(clientID, e, baseName)
 	if err != nil {
 		return UserConfig{}, errors.Wrap(err, "baseRepo.Get")
 	}
@@ -136,50 +465,42 @@ func (s *userService) Render(
 		}
 	}
 
-	rs, err := s.ruleRepo.ListActive(bc.ID, time.Now())
+	now := s.now()
+
+	rs, err := s.ruleRepo.ListActive(bc.ID, now)
 	if err != nil {
 		return UserConfig{}, err
 	}
 
-	var (
-		decisions = rule.Decisions{}
-		params    = bc.Parameters
+	rctx := ruleContext(userID, ctx)
+	rctx.Now = now
+	rctx.Segments = s.segments
+	rctx.UserLists = s.userLists
+
+	params, decisions, err := rule.Evaluate(
+		bc.Platforms.Apply(bc.Parameters, rctx.Device.Platform),
+		rs,
+		rctx,
+		uc.ruleDecisions,
+		s.dice,
 	)
+	if err != nil {
+		return UserConfig{}, err
+	}
 
-	for _, r := range rs {
-		ctx := rule.Context{
-			User: rule.ContextUser{
-				ID:           userID,
-				Age:          ctx.User.Age,
-				Registered:   ctx.User.Registered,
-				Subscription: ctx.User.Subscription,
-			},
-			Locale: rule.ContextLocale{
-				Locale: ctx.Device.Location.locale,
-			},
-		}
+	params = bc.Schema.Localize(params, rctx.Locale.Locale)
 
-		pm, d, err := r.Run(params, ctx, uc.ruleDecisions[r.ID], s.randFn)
-		if err != nil {
-			switch errors.Cause(err) {
-			case errors.ErrCriterionNotMatch:
-				continue
-			case errors.ErrRuleNotInRollout:
-				decisions[r.ID] = d
-				continue
-			default:
-				return UserConfig{}, err
-			}
+	for key := range bc.Deprecated {
+		if _, ok := params[key]; ok {
+			s.deprecatedServed(bc.Name, key)
 		}
-
-		params = pm
 	}
 
 	if reflect.DeepEqual(params, uc.rendered) && reflect.DeepEqual(uc.ruleDecisions, decisions) {
 		return uc, nil
 	}
 
-	id, err := ulid.New(ulid.Timestamp(time.Now()), s.seed)
+	id, err := ulid.New(ulid.Timestamp(now), s.seed)
 	if err != nil {
 		return UserConfig{}, errors.Wrap(err, "create ulid")
 	}
@@ -187,15 +508,110 @@ func (s *userService) Render(
 	return s.userRepo.Append(id.String(), bc.ID, userID, decisions, params)
 }
 
+func (s *userService) Alias(clientID, deviceID, userID string) error {
+	if auth.IsAnonymous(userID) {
+		return errors.Wrap(errors.ErrUserIDMissing, "alias of anonymous user")
+	}
+
+	cs, err := s.userRepo.ListUser(auth.AnonymousUserID(deviceID))
+	if err != nil {
+		return errors.Wrap(err, "userRepo.ListUser")
+	}
+
+	// User configs are listed oldest first, the last one per base config is
+	// the latest.
+	latest := map[string]UserConfig{}
+
+	for _, c := range cs {
+		latest[c.baseID] = c
+	}
+
+	for baseID, dc := range latest {
+		if len(dc.ruleDecisions) == 0 {
+			continue
+		}
+
+		bc, err := s.baseRepo.GetByID(baseID)
+		if err != nil {
+			if errors.Cause(err) == errors.ErrNotFound {
+				continue
+			}
+
+			return errors.Wrap(err, "baseRepo.GetByID")
+		}
+
+		if bc.ClientID != clientID {
+			continue
+		}
+
+		uc, err := s.userRepo.GetLatest(baseID, userID)
+		if err != nil {
+			switch errors.Cause(err) {
+			case errors.ErrNotFound:
+				uc = UserConfig{rendered: dc.rendered}
+			default:
+				return errors.Wrap(err, "userRepo.GetLatest")
+			}
+		}
+
+		decisions := rule.Decisions{}
+
+		for k, d := range dc.ruleDecisions {
+			decisions[k] = d
+		}
+
+		for k, d := range uc.ruleDecisions {
+			decisions[k] = d
+		}
+
+		if reflect.DeepEqual(decisions, uc.ruleDecisions) {
+			continue
+		}
+
+		id, err := ulid.New(ulid.Timestamp(s.now()), s.seed)
+		if err != nil {
+			return errors.Wrap(err, "create ulid")
+		}
+
+		_, err = s.userRepo.Append(id.String(), baseID, userID, decisions, uc.rendered)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ruleContext(userID string, ctx userRenderContext) rule.Context {
+	return rule.Context{
+		User: rule.ContextUser{
+			ID:           userID,
+			Age:          ctx.User.Age,
+			Registered:   ctx.User.Registered,
+			Subscription: ctx.User.Subscription,
+		},
+		Device: rule.ContextDevice{
+			ID:        ctx.Device.ID,
+			Platform:  ctx.Device.OS.Platform,
+			OSVersion: ctx.Device.OS.Version,
+		},
+		Locale: rule.ContextLocale{
+			Country: ctx.Device.Location.country,
+			Locale:  ctx.Device.Location.locale,
+			Offset:  ctx.Device.Location.offset,
+		},
+		Metadata: ctx.Metadata,
+	}
+}
+
 // validateParamDelta given a base and the new version of the parameters
-// returns an error if:
-// * a key from base is missing in the new version
-// * the type of a key was changed
+// returns an error if the type of a key was changed. Removal of keys is
+// guarded by validateRemovals and validateOrphans.
 func validateParamDelta(base, new rule.Parameters) error {
 	for key, val := range base {
 		v, ok := new[key]
 		if !ok {
-			return errors.Wrapf(errors.ErrParametersInvalid, "key missing '%s'", key)
+			continue
 		}
 
 		if reflect.TypeOf(val).Kind() != reflect.TypeOf(v).Kind() {
@@ -211,3 +627,165 @@ func validateParamDelta(base, new rule.Parameters) error {
 
 	return nil
 }
+
+// validateOrphans returns an error listing the conflicting rules if a key
+// removed from base in the new version is still overridden by an active rule
+// of the base config.
+func validateOrphans(
+	configID string,
+	base, new rule.Parameters,
+	rules []rule.Rule,
+	now time.Time,
+) error {
+	var (
+		conflicts = []string{}
+		used      = activeRuleKeys(configID, rules, now)
+	)
+
+	for key := range base {
+		if _, ok := new[key]; ok {
+			continue
+		}
+
+		if ids, ok := used[key]; ok {
+			conflicts = append(
+				conflicts,
+				fmt.Sprintf("'%s' (%s)", key, strings.Join(ids, ", ")),
+			)
+		}
+	}
+
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	sort.Strings(conflicts)
+
+	return errors.Wrapf(
+		errors.ErrParametersInvalid,
+		"keys used by active rules: %s",
+		strings.Join(conflicts, ", "),
+	)
+}
+
+// validateRemovals returns an error if a key removed from base in the new
+// version hasn't been deprecated for at least the grace period.
+func validateRemovals(
+	base, new rule.Parameters,
+	deprecated map[string]time.Time,
+	grace time.Duration,
+	now time.Time,
+) error {
+	keys := []string{}
+
+	for key := range base {
+		if _, ok := new[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		t, ok := deprecated[key]
+		if !ok {
+			return errors.Wrapf(
+				errors.ErrParametersInvalid,
+				"key '%s' must be deprecated before removal",
+				key,
+			)
+		}
+
+		if removable := t.Add(grace); now.Before(removable) {
+			return errors.Wrapf(
+				errors.ErrParametersInvalid,
+				"key '%s' deprecated, removable after %s",
+				key,
+				removable.Format(time.RFC3339),
+			)
+		}
+	}
+
+	return nil
+}
+
+// activeRuleKeys returns the sorted ids of active rules of the base config
+// indexed by the parameter keys they override.
+func activeRuleKeys(
+	configID string,
+	rules []rule.Rule,
+	now time.Time,
+) map[string][]string {
+	used := map[string][]string{}
+
+	for _, r := range rules {
+		if r.ConfigID() != configID || !r.Active(now) {
+			continue
+		}
+
+		for _, k := range r.Keys() {
+			used[k] = append(used[k], r.ID)
+		}
+	}
+
+	for _, ids := range used {
+		sort.Strings(ids)
+	}
+
+	return used
+}
+
+// validatePlatformParams returns an error if the overrides contain keys which
+// are not present in base or change the type of their value.
+func validatePlatformParams(
+	base rule.Parameters,
+	schema rule.ParameterSchema,
+	params rule.Parameters,
+) error {
+	keys := []string{}
+
+	for k := range params {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v, ok := base[k]
+		if !ok {
+			return errors.Wrapf(errors.ErrParametersInvalid, "'%s' not in base config", k)
+		}
+
+		if have, want := rule.TypeOf(params[k]), rule.TypeOf(v); have != want {
+			return errors.Wrapf(errors.ErrParametersInvalid, "'%s' type '%s' != '%s'", k, have, want)
+		}
+	}
+
+	if len(schema) > 0 {
+		return schema.Check(params)
+	}
+
+	return nil
+}
+
+// validateParams checks the parameters against the declared schema. Base
+// configs without a schema only support scalar values.
+func validateParams(schema rule.ParameterSchema, params rule.Parameters) error {
+	if len(schema) > 0 {
+		return schema.Check(params)
+	}
+
+	for key, val := range params {
+		switch rule.TypeOf(val) {
+		case rule.TypeBool, rule.TypeNumber, rule.TypeString:
+		default:
+			return errors.Wrapf(
+				errors.ErrParametersInvalid,
+				"value for '%s' requires a declared schema",
+				key,
+			)
+		}
+	}
+
+	return nil
+}
@@ -3,9 +3,12 @@ package config
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 
+	"github.com/lifesum/configsum/pkg/auth"
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/errors"
 	"github.com/lifesum/configsum/pkg/generate"
 	"github.com/lifesum/configsum/pkg/rule"
@@ -26,10 +29,11 @@ func TestBaseServiceUpdate(t *testing.T) {
 			generate.RandomString(6): true,
 		}
 		baseRepo = preparePGBaseRepo(t)
-		svc      = NewBaseService(baseRepo, nil)
+		ruleRepo = prepareRuleRepo(t)
+		svc      = NewBaseService(baseRepo, nil, ruleRepo)
 	)
 
-	_, err := baseRepo.Create(baseID, clientID, baseName, nil)
+	_, err := baseRepo.Create(baseID, clientID, env.Default, baseName, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -44,6 +48,53 @@ func TestBaseServiceUpdate(t *testing.T) {
 	}
 }
 
+func TestBaseServiceUpdatePlatform(t *testing.T) {
+	t.Parallel()
+
+	var (
+		clientID   = generate.RandomString(12)
+		baseID     = generate.RandomString(16)
+		baseName   = generate.RandomString(6)
+		featureKey = generate.RandomString(6)
+		baseRepo   = preparePGBaseRepo(t)
+		ruleRepo   = prepareRuleRepo(t)
+		svc        = NewBaseService(baseRepo, nil, ruleRepo)
+		overrides  = rule.Parameters{
+			featureKey: float64(6),
+		}
+	)
+
+	_, err := baseRepo.Create(baseID, clientID, env.Default, baseName, rule.Parameters{
+		featureKey: float64(5),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := svc.UpdatePlatform(baseID, rule.PlatformAndroid, overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := updated.Platforms[rule.PlatformAndroid], overrides; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	_, err = svc.UpdatePlatform(baseID, "Symbian", overrides)
+	if have, want := errors.Cause(err), errors.ErrParametersInvalid; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	updated, err = svc.UpdatePlatform(baseID, rule.PlatformAndroid, rule.Parameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := updated.Platforms[rule.PlatformAndroid]; ok {
+		t.Errorf("have %v, want no overrides", updated.Platforms)
+	}
+}
+
 func TestUserServiceRender(t *testing.T) {
 	t.Parallel()
 
@@ -71,7 +122,7 @@ func TestUserServiceRender(t *testing.T) {
 		}
 	)
 
-	_, err := baseRepo.Create(baseID, clientID, baseName, baseParams)
+	_, err := baseRepo.Create(baseID, clientID, env.Default, baseName, baseParams)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -109,7 +160,7 @@ func TestUserServiceRender(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	uc, err := svc.Render(clientID, baseName, userID, userRenderContext{})
+	uc, err := svc.Render(clientID, env.Default, baseName, userID, userRenderContext{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -131,7 +182,7 @@ func TestUserServiceRender(t *testing.T) {
 		t.Errorf("have %v, want %v", have, want)
 	}
 
-	rc, err := svc.Render(clientID, baseName, userID, userRenderContext{})
+	rc, err := svc.Render(clientID, env.Default, baseName, userID, userRenderContext{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -141,6 +192,197 @@ func TestUserServiceRender(t *testing.T) {
 	}
 }
 
+func TestUserServiceAlias(t *testing.T) {
+	t.Parallel()
+
+	var (
+		clientID   = generate.RandomString(24)
+		baseID     = generate.RandomString(24)
+		baseName   = generate.RandomString(24)
+		featureKey = generate.RandomString(24)
+		baseRepo   = preparePGBaseRepo(t)
+		userRepo   = preparePGUserRepo(t)
+		ruleRepo   = prepareRuleRepo(t)
+		svc        = NewUserService(baseRepo, userRepo, ruleRepo, randIntGenerateTest)
+		deviceID   = generate.RandomString(24)
+		userID     = generate.RandomString(24)
+	)
+
+	_, err := baseRepo.Create(baseID, clientID, env.Default, baseName, rule.Parameters{
+		featureKey: "none",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Devices without decisions leave nothing to carry over.
+	otherDeviceID := generate.RandomString(24)
+
+	_, err = svc.Render(clientID, env.Default, baseName, auth.AnonymousUserID(otherDeviceID), userRenderContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Alias(clientID, otherDeviceID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = userRepo.GetLatest(baseID, userID)
+	if have, want := errors.Cause(err), errors.ErrNotFound; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	r, err := rule.New(
+		generate.RandomString(24),
+		baseID,
+		"onboarding experiment",
+		"",
+		rule.KindExperiment,
+		true,
+		nil,
+		[]rule.Bucket{
+			{
+				Name:       "control",
+				Parameters: rule.Parameters{featureKey: "control"},
+				Percentage: 50,
+			},
+			{
+				Name:       "variant",
+				Parameters: rule.Parameters{featureKey: "variant"},
+				Percentage: 50,
+			},
+		},
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ruleRepo.Create(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dc, err := svc.Render(clientID, env.Default, baseName, auth.AnonymousUserID(deviceID), userRenderContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Alias(clientID, deviceID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uc, err := userRepo.GetLatest(baseID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := uc.ruleDecisions, dc.ruleDecisions; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	rc, err := svc.Render(clientID, env.Default, baseName, userID, userRenderContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := rc.rendered, dc.rendered; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	// Configs of other clients are not carried over.
+	err = svc.Alias(generate.RandomString(24), deviceID, generate.RandomString(24))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Alias(clientID, deviceID, auth.AnonymousUserID(generate.RandomString(24)))
+	if have, want := errors.Cause(err), errors.ErrUserIDMissing; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestUserServiceRenderLocalTime(t *testing.T) {
+	t.Parallel()
+
+	var (
+		clientID   = generate.RandomString(24)
+		baseID     = generate.RandomString(24)
+		baseName   = generate.RandomString(24)
+		featureKey = generate.RandomString(24)
+		baseRepo   = preparePGBaseRepo(t)
+		userRepo   = preparePGUserRepo(t)
+		ruleRepo   = prepareRuleRepo(t)
+		now        = time.Date(2018, 3, 5, 10, 0, 0, 0, time.UTC)
+		svc        = NewUserService(
+			baseRepo,
+			userRepo,
+			ruleRepo,
+			randIntGenerateTest,
+			UserServiceClock(func() time.Time { return now }),
+		)
+	)
+
+	_, err := baseRepo.Create(baseID, clientID, env.Default, baseName, rule.Parameters{
+		featureKey: false,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := rule.New(
+		generate.RandomString(24),
+		baseID,
+		"lunch promo",
+		"",
+		rule.KindOverride,
+		true,
+		rule.Criteria{
+			rule.Criterion{
+				Comparator: rule.ComparatorBetween,
+				Key:        rule.DeviceLocalTime,
+				Value:      []string{"11:30", "14:00"},
+			},
+		},
+		[]rule.Bucket{
+			{
+				Name: "default",
+				Parameters: rule.Parameters{
+					featureKey: true,
+				},
+			},
+		},
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ruleRepo.Create(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for offset, want := range map[int]bool{
+		0:    false,
+		7200: true,
+	} {
+		ctx := userRenderContext{}
+		ctx.Device.Location.offset = offset
+
+		uc, err := svc.Render(clientID, env.Default, baseName, generate.RandomString(24), ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if have := uc.rendered[featureKey]; have != want {
+			t.Errorf("offset %d: have %v, want %v", offset, have, want)
+		}
+	}
+}
+
 func TestUserServiceNoActiveRules(t *testing.T) {
 	t.Parallel()
 
@@ -180,7 +422,7 @@ func TestUserServiceNoActiveRules(t *testing.T) {
 		}
 	)
 
-	_, err := baseRepo.Create(baseID, clientID, baseName, baseParams)
+	_, err := baseRepo.Create(baseID, clientID, env.Default, baseName, baseParams)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -249,7 +491,7 @@ func TestUserServiceNoActiveRules(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	uc1, err := svc.Render(clientID, baseName, userID, userRenderContext{})
+	uc1, err := svc.Render(clientID, env.Default, baseName, userID, userRenderContext{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -304,7 +546,7 @@ func TestUserServiceNotInRollout(t *testing.T) {
 		}
 	)
 
-	_, err := baseRepo.Create(baseID, clientID, baseName, baseParams)
+	_, err := baseRepo.Create(baseID, clientID, env.Default, baseName, baseParams)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -368,7 +610,7 @@ func TestUserServiceNotInRollout(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	uc1, err := svc.Render(clientID, baseName, userID, userRenderContext{})
+	uc1, err := svc.Render(clientID, env.Default, baseName, userID, userRenderContext{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -382,7 +624,7 @@ func TestUserServiceNotInRollout(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	uc2, err := svc.Render(clientID, baseName, userID, userRenderContext{})
+	uc2, err := svc.Render(clientID, env.Default, baseName, userID, userRenderContext{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -419,7 +661,7 @@ func TestUserServiceRenderFailingRule(t *testing.T) {
 		svc      = NewUserService(baseRepo, userRepo, ruleRepo, randIntGenerateTest)
 	)
 
-	_, err := baseRepo.Create(baseID, clientID, baseName, baseParams)
+	_, err := baseRepo.Create(baseID, clientID, env.Default, baseName, baseParams)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -457,7 +699,7 @@ func TestUserServiceRenderFailingRule(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = svc.Render(clientID, baseName, userID, userRenderContext{})
+	_, err = svc.Render(clientID, env.Default, baseName, userID, userRenderContext{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -476,7 +718,7 @@ func TestUserServiceRenderConfigMissingBaseConfig(t *testing.T) {
 		svc      = NewUserService(baseRepo, userRepo, ruleRepo, randIntGenerateTest)
 	)
 
-	_, err := svc.Render(clientID, baseName, userID, userRenderContext{})
+	_, err := svc.Render(clientID, env.Default, baseName, userID, userRenderContext{})
 	if have, want := errors.Cause(err), errors.ErrNotFound; have != want {
 		t.Errorf("have %v, want %v", have, want)
 	}
@@ -491,11 +733,6 @@ func TestValidateParamDelta(t *testing.T) {
 			base rule.Parameters
 			new  rule.Parameters
 		}{
-			{
-				base: rule.Parameters{
-					key: false,
-				},
-			}, // New missing.
 			{
 				base: rule.Parameters{
 					key: false,
@@ -515,6 +752,218 @@ func TestValidateParamDelta(t *testing.T) {
 	}
 }
 
+func TestValidateOrphans(t *testing.T) {
+	t.Parallel()
+
+	var (
+		baseID = generate.RandomString(12)
+		base   = rule.Parameters{
+			"feature_paywall_enabled": false,
+			"feature_paywall_price":   5,
+		}
+		now   = time.Now()
+		rules = []rule.Rule{}
+	)
+
+	for _, r := range []struct {
+		id       string
+		configID string
+		active   bool
+		key      string
+	}{
+		{id: "rule-2", configID: baseID, active: true, key: "feature_paywall_price"},
+		{id: "rule-1", configID: baseID, active: true, key: "feature_paywall_price"},
+		{id: "rule-3", configID: baseID, active: false, key: "feature_paywall_enabled"},
+		{id: "rule-4", configID: "other", active: true, key: "feature_paywall_enabled"},
+	} {
+		rl, err := rule.New(
+			r.id,
+			r.configID,
+			r.id,
+			"",
+			rule.KindOverride,
+			r.active,
+			nil,
+			[]rule.Bucket{
+				{
+					Name:       "default",
+					Parameters: rule.Parameters{r.key: base[r.key]},
+				},
+			},
+			nil,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rules = append(rules, rl)
+	}
+
+	// Only used by an inactive rule and a rule of another base config.
+	err := validateOrphans(baseID, base, rule.Parameters{
+		"feature_paywall_price": 5,
+	}, rules, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = validateOrphans(baseID, base, rule.Parameters{
+		"feature_paywall_enabled": false,
+	}, rules, now)
+	if have, want := errors.Cause(err), errors.ErrParametersInvalid; have != want {
+		t.Fatalf("have %v, want %v", have, want)
+	}
+
+	if have, want := err.Error(), "keys used by active rules: 'feature_paywall_price' (rule-1, rule-2): parameters invalid"; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestValidateRemovals(t *testing.T) {
+	t.Parallel()
+
+	var (
+		grace = 24 * time.Hour
+		now   = time.Now()
+		base  = rule.Parameters{
+			"feature_paywall_enabled": false,
+			"feature_paywall_price":   5,
+		}
+		new = rule.Parameters{
+			"feature_paywall_price": 5,
+		}
+		cases = []struct {
+			deprecated map[string]time.Time
+			valid      bool
+		}{
+			{
+				deprecated: map[string]time.Time{},
+			}, // Not deprecated.
+			{
+				deprecated: map[string]time.Time{
+					"feature_paywall_enabled": now.Add(-time.Hour),
+				},
+			}, // Within grace period.
+			{
+				deprecated: map[string]time.Time{
+					"feature_paywall_enabled": now.Add(-2 * grace),
+				},
+				valid: true,
+			}, // Grace period passed.
+		}
+	)
+
+	for _, c := range cases {
+		err := validateRemovals(base, new, c.deprecated, grace, now)
+		if c.valid {
+			if err != nil {
+				t.Errorf("have %v, want %v", err, nil)
+			}
+
+			continue
+		}
+
+		if have, want := errors.Cause(err), errors.ErrParametersInvalid; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	t.Parallel()
+
+	var (
+		schema = rule.ParameterSchema{
+			"feature_paywall_price": {
+				Type: rule.TypeNumber,
+			},
+		}
+		cases = []struct {
+			schema rule.ParameterSchema
+			params rule.Parameters
+		}{
+			{
+				params: rule.Parameters{
+					"feature_paywall_products": []interface{}{"monthly"},
+				},
+			}, // Array without declared schema.
+			{
+				params: rule.Parameters{
+					"feature_paywall_copy": map[string]interface{}{},
+				},
+			}, // Object without declared schema.
+			{
+				schema: schema,
+				params: rule.Parameters{
+					"feature_paywall_price": "free",
+				},
+			}, // Type missmatch with declared schema.
+			{
+				schema: schema,
+				params: rule.Parameters{
+					"feature_paywall_enabled": true,
+				},
+			}, // Parameter not declared.
+		}
+	)
+
+	for _, c := range cases {
+		err := validateParams(c.schema, c.params)
+		if have, want := errors.Cause(err), errors.ErrParametersInvalid; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
+
+func TestValidatePlatformParams(t *testing.T) {
+	t.Parallel()
+
+	var (
+		base = rule.Parameters{
+			"feature_paywall_enabled": true,
+			"feature_paywall_price":   float64(5),
+		}
+		schema = rule.ParameterSchema{
+			"feature_paywall_enabled": {
+				Type: rule.TypeBool,
+			},
+			"feature_paywall_price": {
+				Max:  func(f float64) *float64 { return &f }(10),
+				Type: rule.TypeNumber,
+			},
+		}
+		cases = []struct {
+			schema rule.ParameterSchema
+			params rule.Parameters
+			err    error
+		}{
+			{
+				params: rule.Parameters{"feature_paywall_price": float64(6)},
+			},
+			{
+				params: rule.Parameters{"feature_paywall_products": "monthly"},
+				err:    errors.ErrParametersInvalid,
+			}, // Not in base.
+			{
+				params: rule.Parameters{"feature_paywall_enabled": "false"},
+				err:    errors.ErrParametersInvalid,
+			}, // Type missmatch with base.
+			{
+				schema: schema,
+				params: rule.Parameters{"feature_paywall_price": float64(11)},
+				err:    errors.ErrParametersInvalid,
+			}, // Above max of declared schema.
+		}
+	)
+
+	for _, c := range cases {
+		err := validatePlatformParams(base, c.schema, c.params)
+		if have, want := errors.Cause(err), c.err; have != want {
+			t.Errorf("%v: have %v, want %v", c.params, have, want)
+		}
+	}
+}
+
 func prepareRuleRepo(t *testing.T) rule.Repo {
 	db, err := sqlx.Connect("postgres", pgURI)
 	if err != nil {
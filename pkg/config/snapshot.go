@@ -0,0 +1,100 @@
+package config
+
+import (
+	"time"
+
+	"github.com/lifesum/configsum/pkg/client"
+	"github.com/lifesum/configsum/pkg/env"
+	"github.com/lifesum/configsum/pkg/rule"
+)
+
+// SnapshotService provides signed snapshots of the base configs of one
+// environment and their active rules for in-process evaluation.
+type SnapshotService interface {
+	Export(clientID string, e env.Env) ([]byte, error)
+}
+
+// SnapshotServiceOption sets an optional parameter on the snapshot service.
+type SnapshotServiceOption func(*snapshotService)
+
+// SnapshotServiceSegments sets the repo segments referenced by rules are read
+// from to include them in snapshots.
+func SnapshotServiceSegments(segmentRepo rule.SegmentRepo) SnapshotServiceOption {
+	return func(s *snapshotService) { s.segmentRepo = segmentRepo }
+}
+
+type snapshotService struct {
+	baseRepo    BaseRepo
+	clientRepo  client.Repo
+	ruleRepo    rule.Repo
+	secret      []byte
+	segmentRepo rule.SegmentRepo
+}
+
+// NewSnapshotService provides snapshots signed with the given secret.
+func NewSnapshotService(
+	baseRepo BaseRepo,
+	clientRepo client.Repo,
+	ruleRepo rule.Repo,
+	secret []byte,
+	options ...SnapshotServiceOption,
+) SnapshotService {
+	s := &snapshotService{
+		baseRepo:   baseRepo,
+		clientRepo: clientRepo,
+		ruleRepo:   ruleRepo,
+		secret:     secret,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+func (s *snapshotService) Export(clientID string, e env.Env) ([]byte, error) {
+	_, err := s.clientRepo.Lookup(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	bcs, err := s.baseRepo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	bs := []rule.SnapshotBase{}
+
+	for _, bc := range bcs {
+		if bc.ClientID != clientID || bc.Env != e || bc.Deleted {
+			continue
+		}
+
+		bs = append(bs, rule.SnapshotBase{
+			ID:         bc.ID,
+			Name:       bc.Name,
+			Parameters: bc.Parameters,
+			Platforms:  bc.Platforms,
+			Schema:     bc.Schema,
+		})
+	}
+
+	rs, err := s.ruleRepo.ListAll()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := rule.NewSnapshot(clientID, bs, rs, time.Now())
+
+	if s.segmentRepo != nil {
+		segs, err := s.segmentRepo.List()
+		if err != nil {
+			return nil, err
+		}
+
+		snapshot = snapshot.WithSegments(segs)
+	}
+
+	return rule.SignSnapshot(snapshot, s.secret)
+}
@@ -9,7 +9,9 @@ import (
 	"github.com/go-kit/kit/endpoint"
 	kithttp "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/mux"
+	"golang.org/x/text/language"
 
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/errors"
 	"github.com/lifesum/configsum/pkg/rule"
 	confhttp "github.com/lifesum/configsum/pkg/transport/http"
@@ -25,14 +27,28 @@ const (
 	headerCreatedAt   = "X-Configsum-Created"
 )
 
+// Query parameters.
+const (
+	queryEnv = "environment"
+)
+
 // URL fragments.
 const (
 	varBaseConfig muxVar = "baseConfig"
+	varClientID   muxVar = "clientID"
+	varEnv        muxVar = "environment"
 	varID         muxVar = "id"
+	varKey        muxVar = "key"
+	varPlatform   muxVar = "platform"
+	varUserID     muxVar = "userID"
 )
 
 type muxVar string
 
+type contextKey string
+
+const contextKeyCountry contextKey = "country"
+
 // MakeBaseHandler returns an http.Handler for the base config service.
 func MakeBaseHandler(
 	svc BaseService,
@@ -83,6 +99,88 @@ func MakeBaseHandler(
 		),
 	)
 
+	r.Methods("PUT").Path(`/{id:[a-zA-Z0-9]+}/platforms/{platform:[a-zA-Z]+}`).Name("configBaseUpdatePlatform").Handler(
+		kithttp.NewServer(
+			baseUpdatePlatformEndpoint(svc),
+			confhttp.DecodeJSONSchema(decodeBaseUpdatePlatformRequest, schemaBasePlatformRequest),
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID, varPlatform)),
+			)...,
+		),
+	)
+
+	r.Methods("PUT").Path(`/{id:[a-zA-Z0-9]+}/schema`).Name("configBaseUpdateSchema").Handler(
+		kithttp.NewServer(
+			baseUpdateSchemaEndpoint(svc),
+			confhttp.DecodeJSONSchema(decodeBaseUpdateSchemaRequest, schemaBaseSchemaRequest),
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{id:[a-zA-Z0-9]+}/deprecations`).Name("configBaseDeprecations").Handler(
+		kithttp.NewServer(
+			baseDeprecationsEndpoint(svc),
+			decodeBaseDeprecationsRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	r.Methods("PUT").Path(`/{id:[a-zA-Z0-9]+}/deprecations/{key:[a-z0-9_\-]+}`).Name("configBaseDeprecate").Handler(
+		kithttp.NewServer(
+			baseDeprecateEndpoint(svc),
+			decodeBaseDeprecateRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID, varKey)),
+			)...,
+		),
+	)
+
+	r.Methods("DELETE").Path(`/{id:[a-zA-Z0-9]+}/deprecations/{key:[a-z0-9_\-]+}`).Name("configBaseUndeprecate").Handler(
+		kithttp.NewServer(
+			baseUndeprecateEndpoint(svc),
+			decodeBaseUndeprecateRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID, varKey)),
+			)...,
+		),
+	)
+
+	return r
+}
+
+// MakeAliasHandler returns an http.Handler to alias anonymous devices of the
+// user config service, auth is expected to authenticate the device.
+func MakeAliasHandler(
+	svc UserService,
+	auth endpoint.Middleware,
+	opts ...kithttp.ServerOption,
+) http.Handler {
+	r := mux.NewRouter()
+	r.StrictSlash(true)
+
+	r.Methods("POST").Path(`/alias`).Name("configUserAlias").Handler(
+		kithttp.NewServer(
+			auth(userAliasEndpoint(svc)),
+			decodeUserAliasRequest,
+			kithttp.EncodeJSONResponse,
+			opts...,
+		),
+	)
+
 	return r
 }
 
@@ -110,6 +208,137 @@ func MakeHandler(
 	return r
 }
 
+// MakePrivacyHandler returns an http.Handler for the privacy service.
+func MakePrivacyHandler(
+	svc PrivacyService,
+	opts ...kithttp.ServerOption,
+) http.Handler {
+	r := mux.NewRouter()
+	r.StrictSlash(true)
+
+	r.Methods("GET").Path(`/{userID}`).Name("configPrivacyExport").Handler(
+		kithttp.NewServer(
+			privacyExportEndpoint(svc),
+			decodePrivacyExportRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varUserID)),
+			)...,
+		),
+	)
+
+	r.Methods("DELETE").Path(`/{userID}`).Name("configPrivacyErase").Handler(
+		kithttp.NewServer(
+			privacyEraseEndpoint(svc),
+			decodePrivacyEraseRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varUserID)),
+			)...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{userID}/erasures`).Name("configPrivacyErasures").Handler(
+		kithttp.NewServer(
+			privacyErasuresEndpoint(svc),
+			decodePrivacyErasuresRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varUserID)),
+			)...,
+		),
+	)
+
+	return r
+}
+
+// MakePromotionHandler returns an http.Handler for the promotion service.
+func MakePromotionHandler(
+	svc PromotionService,
+	opts ...kithttp.ServerOption,
+) http.Handler {
+	r := mux.NewRouter()
+	r.StrictSlash(true)
+
+	r.Methods("GET").Path(`/{id:[a-zA-Z0-9]+}`).Name("configPromotionList").Handler(
+		kithttp.NewServer(
+			promotionListEndpoint(svc),
+			decodePromotionListRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{id:[a-zA-Z0-9]+}/{environment:[a-z]+}`).Name("configPromotionDiff").Handler(
+		kithttp.NewServer(
+			promotionDiffEndpoint(svc),
+			decodePromotionDiffRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID, varEnv)),
+			)...,
+		),
+	)
+
+	r.Methods("POST").Path(`/{id:[a-zA-Z0-9]+}/{environment:[a-z]+}`).Name("configPromotionPromote").Handler(
+		kithttp.NewServer(
+			promotionPromoteEndpoint(svc),
+			decodePromotionPromoteRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID, varEnv)),
+			)...,
+		),
+	)
+
+	return r
+}
+
+// MakeSnapshotHandler returns an http.Handler for the snapshot service.
+func MakeSnapshotHandler(
+	svc SnapshotService,
+	opts ...kithttp.ServerOption,
+) http.Handler {
+	r := mux.NewRouter()
+	r.StrictSlash(true)
+
+	r.Methods("GET").Path(`/{clientID:[a-zA-Z0-9]+}`).Name("configSnapshotExport").Handler(
+		kithttp.NewServer(
+			snapshotExportEndpoint(svc),
+			decodeSnapshotExportRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varClientID)),
+			)...,
+		),
+	)
+
+	return r
+}
+
+// HTTPCountryToContext returns a kithttp.RequestFunc which moves the country
+// a trusted proxy sets in the given header, e.g. CF-IPCountry, to the context.
+// Values which are not ISO 3166-1 alpha-2 country codes are ignored.
+func HTTPCountryToContext(header string) kithttp.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		country, err := language.ParseRegion(r.Header.Get(header))
+		if err != nil || !country.IsCountry() {
+			return ctx
+		}
+
+		return context.WithValue(ctx, contextKeyCountry, country)
+	}
+}
+
 func extractMuxVars(keys ...muxVar) kithttp.RequestFunc {
 	return func(ctx context.Context, r *http.Request) context.Context {
 		for _, k := range keys {
@@ -125,6 +354,7 @@ func extractMuxVars(keys ...muxVar) kithttp.RequestFunc {
 func decodeBaseCreateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	v := struct {
 		ClientID string `json:"client_id"`
+		Env      string `json:"environment"`
 		Name     string `json:"name"`
 	}{}
 
@@ -133,7 +363,35 @@ func decodeBaseCreateRequest(ctx context.Context, r *http.Request) (interface{},
 		return nil, errors.Wrapf(errors.ErrInvalidPayload, "%s", err)
 	}
 
-	return baseCreateRequest{clientID: v.ClientID, name: v.Name}, nil
+	e, err := env.Parse(v.Env)
+	if err != nil {
+		return nil, err
+	}
+
+	return baseCreateRequest{clientID: v.ClientID, env: e, name: v.Name}, nil
+}
+
+func decodeBaseDeprecateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id missing")
+	}
+
+	key, ok := ctx.Value(varKey).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "key missing")
+	}
+
+	return baseDeprecateRequest{id: id, key: key}, nil
+}
+
+func decodeBaseDeprecationsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id missing")
+	}
+
+	return baseDeprecationsRequest{id: id}, nil
 }
 
 func decodeBaseGetRequest(ctx context.Context, r *http.Request) (interface{}, error) {
@@ -149,6 +407,20 @@ func decodeBaseListRequest(ctx context.Context, r *http.Request) (interface{}, e
 	return baseListRequest{}, nil
 }
 
+func decodeBaseUndeprecateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id missing")
+	}
+
+	key, ok := ctx.Value(varKey).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "key missing")
+	}
+
+	return baseUndeprecateRequest{id: id, key: key}, nil
+}
+
 func decodeBaseUpdateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	id, ok := ctx.Value(varID).(string)
 	if !ok {
@@ -170,6 +442,145 @@ func decodeBaseUpdateRequest(ctx context.Context, r *http.Request) (interface{},
 	}, nil
 }
 
+func decodeBaseUpdatePlatformRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id missing")
+	}
+
+	platform, ok := ctx.Value(varPlatform).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "platform missing")
+	}
+
+	v := struct {
+		Parameters rule.Parameters `json:"parameters"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&v)
+	if err != nil {
+		return nil, errors.Wrapf(errors.ErrInvalidPayload, "%s", err)
+	}
+
+	return baseUpdatePlatformRequest{
+		id:         id,
+		parameters: v.Parameters,
+		platform:   platform,
+	}, nil
+}
+
+func decodeBaseUpdateSchemaRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id missing")
+	}
+
+	v := struct {
+		Schema rule.ParameterSchema `json:"schema"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&v)
+	if err != nil {
+		return nil, errors.Wrapf(errors.ErrInvalidPayload, "%s", err)
+	}
+
+	return baseUpdateSchemaRequest{
+		id:     id,
+		schema: v.Schema,
+	}, nil
+}
+
+func decodePrivacyEraseRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	userID, ok := ctx.Value(varUserID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "user id missing")
+	}
+
+	return privacyEraseRequest{userID: userID}, nil
+}
+
+func decodePrivacyErasuresRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	userID, ok := ctx.Value(varUserID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "user id missing")
+	}
+
+	return privacyErasuresRequest{userID: userID}, nil
+}
+
+func decodePrivacyExportRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	userID, ok := ctx.Value(varUserID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "user id missing")
+	}
+
+	return privacyExportRequest{userID: userID}, nil
+}
+
+func decodePromotionDiffRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, e, err := extractPromotionVars(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return promotionDiffRequest{id: id, env: e}, nil
+}
+
+func decodePromotionListRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id missing")
+	}
+
+	return promotionListRequest{id: id}, nil
+}
+
+func decodePromotionPromoteRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, e, err := extractPromotionVars(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return promotionPromoteRequest{id: id, env: e}, nil
+}
+
+func extractPromotionVars(ctx context.Context) (string, env.Env, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return "", "", errors.Wrap(errors.ErrVarMissing, "id missing")
+	}
+
+	name, ok := ctx.Value(varEnv).(string)
+	if !ok {
+		return "", "", errors.Wrap(errors.ErrVarMissing, "environment missing")
+	}
+
+	e, err := env.Parse(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	return id, e, nil
+}
+
+func decodeSnapshotExportRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	clientID, ok := ctx.Value(varClientID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "clientID missing")
+	}
+
+	e, err := env.Parse(r.URL.Query().Get(queryEnv))
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshotExportRequest{clientID: clientID, env: e}, nil
+}
+
+func decodeUserAliasRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return userAliasRequest{}, nil
+}
+
 func decodeUserRenderRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	baseConfig, ok := ctx.Value(varBaseConfig).(string)
 	if !ok {
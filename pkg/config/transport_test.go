@@ -18,6 +18,7 @@ import (
 
 	"github.com/lifesum/configsum/pkg/auth"
 	"github.com/lifesum/configsum/pkg/client"
+	"github.com/lifesum/configsum/pkg/env"
 	"github.com/lifesum/configsum/pkg/generate"
 	"github.com/lifesum/configsum/pkg/rule"
 )
@@ -99,6 +100,29 @@ func TestExtractMuxVars(t *testing.T) {
 	r.ServeHTTP(httptest.NewRecorder(), req)
 }
 
+func TestHTTPCountryToContext(t *testing.T) {
+	header := "CF-IPCountry"
+
+	cases := map[string]interface{}{
+		"":   nil,
+		"DE": language.MustParseRegion("DE"),
+		"se": language.MustParseRegion("SE"),
+		"XX": nil,
+		"T1": nil,
+	}
+
+	for input, want := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(header, input)
+
+		ctx := HTTPCountryToContext(header)(context.Background(), r)
+
+		if have := ctx.Value(contextKeyCountry); have != want {
+			t.Errorf("%s: have %v, want %v", input, have, want)
+		}
+	}
+}
+
 func TestUserRender(t *testing.T) {
 	var (
 		baseID     = generate.RandomString(16)
@@ -122,7 +146,7 @@ func TestUserRender(t *testing.T) {
 		router    = MakeHandler(svc, injectAuth(clientID, userID))
 	)
 
-	_, err := baseRepo.Create(baseID, clientID, baseName, parameters)
+	_, err := baseRepo.Create(baseID, clientID, env.Default, baseName, parameters)
 	if err != nil {
 		t.Fatal(err)
 	}
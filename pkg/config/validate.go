@@ -0,0 +1,84 @@
+package config
+
+import (
+	"time"
+
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/rule"
+)
+
+type validateRuleRepo struct {
+	baseRepo BaseRepo
+	next     rule.Repo
+}
+
+// NewRuleRepoValidateMiddleware wraps the next rule.Repo and rejects rules
+// whose bucket parameters don't satisfy the base config they apply to. Bases
+// with a parameter schema are checked against it, all others against their
+// present parameters. Updates of inactive rules are not validated, so a rule
+// can always be turned off.
+func NewRuleRepoValidateMiddleware(baseRepo BaseRepo) rule.RepoMiddleware {
+	return func(next rule.Repo) rule.Repo {
+		return &validateRuleRepo{
+			baseRepo: baseRepo,
+			next:     next,
+		}
+	}
+}
+
+func (r *validateRuleRepo) Create(input rule.Rule) (rule.Rule, error) {
+	if err := r.validate(input); err != nil {
+		return rule.Rule{}, err
+	}
+
+	return r.next.Create(input)
+}
+
+func (r *validateRuleRepo) GetByID(id string) (rule.Rule, error) {
+	return r.next.GetByID(id)
+}
+
+func (r *validateRuleRepo) UpdateWith(input rule.Rule) (rule.Rule, error) {
+	if !input.Active(time.Now()) {
+		return r.next.UpdateWith(input)
+	}
+
+	if err := r.validate(input); err != nil {
+		return rule.Rule{}, err
+	}
+
+	return r.next.UpdateWith(input)
+}
+
+func (r *validateRuleRepo) ListAll() ([]rule.Rule, error) {
+	return r.next.ListAll()
+}
+
+func (r *validateRuleRepo) ListActive(configID string, now time.Time) ([]rule.Rule, error) {
+	return r.next.ListActive(configID, now)
+}
+
+func (r *validateRuleRepo) Setup() error {
+	return r.next.Setup()
+}
+
+func (r *validateRuleRepo) Teardown() error {
+	return r.next.Teardown()
+}
+
+func (r *validateRuleRepo) validate(input rule.Rule) error {
+	bc, err := r.baseRepo.GetByID(input.ConfigID())
+	if err != nil {
+		return errors.Wrap(err, "base config of rule")
+	}
+
+	return validateRuleParams(bc, input)
+}
+
+func validateRuleParams(bc BaseConfig, r rule.Rule) error {
+	if len(bc.Schema) > 0 {
+		return r.ValidateParameters(bc.Schema)
+	}
+
+	return r.ValidateOverrides(bc.Parameters)
+}
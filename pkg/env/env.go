@@ -0,0 +1,58 @@
+package env
+
+import (
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// Supported environments in order of promotion.
+const (
+	Dev     Env = "dev"
+	Staging Env = "staging"
+	Prod    Env = "prod"
+)
+
+// Default is the environment of entities which predate environments and of
+// requests which don't specify one.
+const Default = Prod
+
+// All lists the supported environments in order of promotion.
+var All = []Env{Dev, Staging, Prod}
+
+// Env is a distinct stage base configs and rules live in, which are promoted
+// from one to the next.
+type Env string
+
+// Parse returns the Env for the given name, an empty name yields Default.
+func Parse(name string) (Env, error) {
+	if name == "" {
+		return Default, nil
+	}
+
+	e := Env(name)
+
+	if !e.Valid() {
+		return "", errors.Wrapf(errors.ErrEnvInvalid, "'%s'", name)
+	}
+
+	return e, nil
+}
+
+// Valid reports if the environment is supported.
+func (e Env) Valid() bool {
+	return e.index() < len(All)
+}
+
+// Before reports if e precedes o in the order of promotion.
+func (e Env) Before(o Env) bool {
+	return e.index() < o.index()
+}
+
+func (e Env) index() int {
+	for i, v := range All {
+		if e == v {
+			return i
+		}
+	}
+
+	return len(All)
+}
@@ -0,0 +1,52 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]Env{
+		"":        Prod,
+		"dev":     Dev,
+		"staging": Staging,
+		"prod":    Prod,
+	}
+
+	for name, want := range cases {
+		have, err := Parse(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+
+	_, err := Parse("qa")
+	if have, want := errors.Cause(err), errors.ErrEnvInvalid; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestEnvBefore(t *testing.T) {
+	cases := []struct {
+		e, o Env
+		want bool
+	}{
+		{Dev, Staging, true},
+		{Dev, Prod, true},
+		{Staging, Prod, true},
+		{Prod, Dev, false},
+		{Staging, Staging, false},
+		{Env("qa"), Prod, false},
+	}
+
+	for _, c := range cases {
+		if have, want := c.e.Before(c.o), c.want; have != want {
+			t.Errorf("%s before %s: have %v, want %v", c.e, c.o, have, want)
+		}
+	}
+}
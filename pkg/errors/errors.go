@@ -8,15 +8,18 @@ import (
 var (
 	ErrID       = errors.New("id creation")
 	ErrExists   = errors.New("entity exists")
+	ErrInUse    = errors.New("entity in use")
 	ErrNotFound = errors.New("entity not found")
 )
 
 // Auth errors.
 var (
 	ErrClientNotFound     = errors.New("client not found")
+	ErrDeviceIDMissing    = errors.New("deviceID missing")
 	ErrSecretMissing      = errors.New("secret missing")
 	ErrSignatureMissing   = errors.New("signature missing")
 	ErrSignatureMissmatch = errors.New("signature missmatch")
+	ErrUserIDInvalid      = errors.New("userID invalid")
 	ErrUserIDMissing      = errors.New("userID missing")
 )
 
@@ -40,6 +43,26 @@ var (
 	ErrCriterionNotMatch         = errors.New("no criterion match")
 	ErrRuleNotInRollout          = errors.New("not in rollout")
 	ErrParsingInvalidLanguageTag = errors.New("invalid language to parse")
+	ErrScheduleChanged           = errors.New("schedule changed")
+	ErrScheduleInvalid           = errors.New("schedule invalid")
+	ErrGuardrailInvalid          = errors.New("guardrail invalid")
+	ErrSegmentInvalid            = errors.New("segment invalid")
+	ErrUserListInvalid           = errors.New("user list invalid")
+)
+
+// Environment errors.
+var (
+	ErrEnvInvalid = errors.New("environment invalid")
+)
+
+// Manifest errors.
+var (
+	ErrManifestInvalid = errors.New("manifest invalid")
+)
+
+// Snapshot errors.
+var (
+	ErrSnapshotVersion = errors.New("snapshot version unsupported")
 )
 
 // Cause is a wraper over github.com/pkg/errors.Cause.
@@ -3,6 +3,7 @@ package generate
 import (
 	crand "crypto/rand"
 	"encoding/base64"
+	"hash/fnv"
 	"math/rand"
 	"time"
 
@@ -68,13 +69,38 @@ func randomBytes(src rand.Source, bytes string, n int) []byte {
 // RandPercentageFunc is the integer returned by RandomInt() which represents the rollout percentage
 type RandPercentageFunc func() int
 
-// RandPercentage returns a RandPercentage func that generates int in the range [min, max]
+// RandPercentage returns a RandPercentage func that generates int in the range
+// [1, 100], so percentages of rollouts and buckets map to as many dice values.
 func RandPercentage(r *rand.Rand) RandPercentageFunc {
 	if r == nil {
 		r = rand.New(rand.NewSource(time.Now().UnixNano()))
 	}
 
 	return func() int {
-		return r.Intn(99) + 1
+		return r.Intn(100) + 1
+	}
+}
+
+// HashPercentage returns a RandPercentageFunc that always yields the same int
+// in the range [1, 100] for the given keys, which allows stable bucketing
+// without storing the dice roll.
+func HashPercentage(keys ...string) RandPercentageFunc {
+	return HashRange(100, keys...)
+}
+
+// HashRange returns a RandPercentageFunc that always yields the same int in the
+// range [1, n] for the given keys.
+func HashRange(n int, keys ...string) RandPercentageFunc {
+	h := fnv.New32a()
+
+	for _, k := range keys {
+		_, _ = h.Write([]byte(k))
+		_, _ = h.Write([]byte{0})
+	}
+
+	p := int(h.Sum32()%uint32(n)) + 1
+
+	return func() int {
+		return p
 	}
 }
@@ -13,8 +13,10 @@ import (
 
 // Labels.
 const (
+	labelBase       = "base"
 	labelErr        = "err"
 	labelHost       = "host"
+	labelKey        = "key"
 	labelMethod     = "method"
 	labelOp         = "op"
 	labelProto      = "proto"
@@ -25,10 +27,45 @@ const (
 )
 
 var (
+	deprecatedServed = map[string]*kitprom.Counter{}
 	repoLatencies    = map[string]*kitprom.Histogram{}
 	requestLatencies = map[string]*kitprom.Histogram{}
 )
 
+// CountServedFunc wraps a counter to track parameters served by base config.
+type CountServedFunc func(base, key string)
+
+// CountDeprecatedServed wraps a counter to track how often deprecated
+// parameters are still included in rendered configs.
+func CountDeprecatedServed(namespace, subsystem string) CountServedFunc {
+	key := fmt.Sprintf("%s-%s", namespace, subsystem)
+
+	_, ok := deprecatedServed[key]
+	if !ok {
+		deprecatedServed[key] = kitprom.NewCounterFrom(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "deprecated_parameters_served_total",
+				Help:      "Number of deprecated parameters included in rendered configs.",
+			},
+			[]string{
+				labelBase,
+				labelKey,
+			},
+		)
+	}
+
+	counter := deprecatedServed[key]
+
+	return func(base, param string) {
+		counter.With(
+			labelBase, base,
+			labelKey, param,
+		).Add(1)
+	}
+}
+
 // ObserveRepoFunc wraps a histogram to track repo op latencies.
 type ObserveRepoFunc func(store, repo, op string, begin time.Time, err error)
 
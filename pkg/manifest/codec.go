@@ -0,0 +1,118 @@
+package manifest
+
+import (
+	"encoding/json"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// Supported file extensions of declarations.
+const (
+	ExtJSON = ".json"
+	ExtYAML = ".yaml"
+
+	extYML = ".yml"
+)
+
+// marshal encodes the base in the format of the file extension. YAML goes
+// through the JSON form so both formats share the same field names.
+func marshal(ext string, b Base) ([]byte, error) {
+	raw, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	switch ext {
+	case ExtJSON:
+		return append(raw, '\n'), nil
+	case ExtYAML, extYML:
+		var v interface{}
+
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+
+		return yaml.Marshal(v)
+	default:
+		return nil, errors.Wrapf(errors.ErrManifestInvalid, "unsupported extension '%s'", ext)
+	}
+}
+
+// toJSON returns the JSON form of a declaration in the format of the file
+// extension.
+func toJSON(ext string, raw []byte) ([]byte, error) {
+	switch ext {
+	case ExtJSON:
+		return raw, nil
+	case ExtYAML, extYML:
+		var v interface{}
+
+		if err := yaml.Unmarshal(raw, &v); err != nil {
+			return nil, errors.Wrapf(errors.ErrManifestInvalid, "%s", err)
+		}
+
+		v, err := jsonValue(v)
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(v)
+	default:
+		return nil, errors.Wrapf(errors.ErrManifestInvalid, "unsupported extension '%s'", ext)
+	}
+}
+
+func supported(ext string) bool {
+	switch ext {
+	case ExtJSON, ExtYAML, extYML:
+		return true
+	default:
+		return false
+	}
+}
+
+// jsonValue converts the mappings of decoded YAML, which allows keys of any
+// type, to objects with string keys.
+func jsonValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+
+		for key, val := range v {
+			k, ok := key.(string)
+			if !ok {
+				return nil, errors.Wrapf(
+					errors.ErrManifestInvalid,
+					"key '%v' is not a string",
+					key,
+				)
+			}
+
+			val, err := jsonValue(val)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s", k)
+			}
+
+			m[k] = val
+		}
+
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(v))
+
+		for i, val := range v {
+			val, err := jsonValue(val)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%d", i)
+			}
+
+			s[i] = val
+		}
+
+		return s, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,211 @@
+// Package manifest keeps base configs and their rules as declarative files,
+// one JSON or YAML document per base config, laid out as
+// <dir>/<client id>/<environment>/<base name>.<json|yaml|yml>. The format is
+// picked by the file extension, files with other extensions are ignored.
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/lifesum/configsum/pkg/config"
+	"github.com/lifesum/configsum/pkg/env"
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/rule"
+)
+
+// Base is the declarative form of a base config and all its rules.
+type Base struct {
+	ClientID   string               `json:"client_id"`
+	Env        env.Env              `json:"environment"`
+	Name       string               `json:"name"`
+	Parameters rule.Parameters      `json:"parameters"`
+	Schema     rule.ParameterSchema `json:"schema,omitempty"`
+	Rules      []rule.Declaration   `json:"rules"`
+}
+
+// String returns the identity of the base.
+func (b Base) String() string {
+	return fmt.Sprintf("%s/%s/%s", b.ClientID, b.Env, b.Name)
+}
+
+func (b Base) path(ext string) string {
+	return filepath.Join(b.ClientID, string(b.Env), b.Name+ext)
+}
+
+// Export returns the declarative form of the given base configs and the rules
+// which apply to them, sorted by identity. Deleted base configs are skipped.
+func Export(bcs []config.BaseConfig, rules []rule.Rule) []Base {
+	byConfig := map[string][]rule.Rule{}
+
+	for _, r := range rules {
+		byConfig[r.ConfigID()] = append(byConfig[r.ConfigID()], r)
+	}
+
+	bs := []Base{}
+
+	for _, bc := range bcs {
+		if bc.Deleted {
+			continue
+		}
+
+		b := Base{
+			ClientID:   bc.ClientID,
+			Env:        bc.Env,
+			Name:       bc.Name,
+			Parameters: bc.Parameters,
+			Schema:     bc.Schema,
+			Rules:      []rule.Declaration{},
+		}
+
+		if b.Parameters == nil {
+			b.Parameters = rule.Parameters{}
+		}
+
+		for _, r := range byConfig[bc.ID] {
+			b.Rules = append(b.Rules, r.Declaration())
+		}
+
+		sort.Slice(b.Rules, func(i, j int) bool {
+			return b.Rules[i].Name < b.Rules[j].Name
+		})
+
+		bs = append(bs, b)
+	}
+
+	sort.Slice(bs, func(i, j int) bool { return bs[i].String() < bs[j].String() })
+
+	return bs
+}
+
+// Write stores every base as its own file under dir in the format of the
+// given extension, ExtJSON or ExtYAML.
+func Write(dir, ext string, bs []Base) error {
+	if !supported(ext) {
+		return errors.Wrapf(errors.ErrManifestInvalid, "unsupported extension '%s'", ext)
+	}
+
+	for _, b := range bs {
+		path := filepath.Join(dir, b.path(ext))
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		raw, err := marshal(ext, b)
+		if err != nil {
+			return errors.Wrapf(err, "marshal %s", b)
+		}
+
+		if err := ioutil.WriteFile(path, raw, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read loads all bases declared in files under dir.
+func Read(dir string) ([]Base, error) {
+	bs := []Base{}
+	seen := map[string]string{}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || !supported(filepath.Ext(path)) {
+			return nil
+		}
+
+		b, err := readFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "%s", path)
+		}
+
+		if other, ok := seen[b.String()]; ok {
+			return errors.Wrapf(
+				errors.ErrManifestInvalid,
+				"%s: '%s' already declared in %s",
+				path,
+				b,
+				other,
+			)
+		}
+
+		seen[b.String()] = path
+		bs = append(bs, b)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bs, nil
+}
+
+func readFile(path string) (Base, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Base{}, err
+	}
+
+	raw, err = toJSON(filepath.Ext(path), raw)
+	if err != nil {
+		return Base{}, err
+	}
+
+	v := struct {
+		Base
+		Env string `json:"environment"`
+	}{}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&v); err != nil {
+		return Base{}, errors.Wrapf(errors.ErrManifestInvalid, "%s", err)
+	}
+
+	b := v.Base
+
+	b.Env, err = env.Parse(v.Env)
+	if err != nil {
+		return Base{}, err
+	}
+
+	if b.ClientID == "" {
+		return Base{}, errors.Wrap(errors.ErrManifestInvalid, "missing client_id")
+	}
+
+	if b.Name == "" {
+		return Base{}, errors.Wrap(errors.ErrManifestInvalid, "missing name")
+	}
+
+	if b.Parameters == nil {
+		b.Parameters = rule.Parameters{}
+	}
+
+	names := map[string]struct{}{}
+
+	for _, d := range b.Rules {
+		if _, ok := names[d.Name]; ok {
+			return Base{}, errors.Wrapf(
+				errors.ErrManifestInvalid,
+				"rule '%s' declared twice",
+				d.Name,
+			)
+		}
+
+		names[d.Name] = struct{}{}
+	}
+
+	return b, nil
+}
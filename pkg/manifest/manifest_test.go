@@ -0,0 +1,218 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lifesum/configsum/pkg/config"
+	"github.com/lifesum/configsum/pkg/env"
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/generate"
+	"github.com/lifesum/configsum/pkg/rule"
+)
+
+func TestWriteRead(t *testing.T) {
+	for _, ext := range []string{ExtJSON, ExtYAML} {
+		dir, err := ioutil.TempDir("", "manifest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		var (
+			bcs   = testBaseConfigs()
+			rules = testRules(t, bcs[0].ID)
+			want  = Export(bcs, rules)
+		)
+
+		if err := Write(dir, ext, want); err != nil {
+			t.Fatal(err)
+		}
+
+		have, err := Read(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("%s: have %v, want %v", ext, have, want)
+		}
+	}
+}
+
+func TestReadYAML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		raw = `client_id: lifesum
+environment: prod
+name: diary
+parameters:
+  carbs_limit: 0.4
+  feature_decrease_carbs: true
+  title: "yes"
+rules:
+- active: true
+  buckets:
+  - name: default
+    parameters:
+      feature_decrease_carbs: false
+  description: ""
+  kind: 1
+  name: no_carbs
+  rollout: 0
+`
+		want = []Base{
+			{
+				ClientID: "lifesum",
+				Env:      env.Prod,
+				Name:     "diary",
+				Parameters: rule.Parameters{
+					"carbs_limit":            0.4,
+					"feature_decrease_carbs": true,
+					"title":                  "yes",
+				},
+				Rules: []rule.Declaration{
+					{
+						Active: true,
+						Buckets: []rule.DeclarationBucket{
+							{
+								Name: "default",
+								Parameters: rule.Parameters{
+									"feature_decrease_carbs": false,
+								},
+							},
+						},
+						Kind: rule.KindOverride,
+						Name: "no_carbs",
+					},
+				},
+			},
+		}
+	)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "diary.yml"), []byte(raw), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	have, err := Read(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	// Unknown fields are rejected the same as in JSON declarations.
+	raw = strings.Replace(raw, "name: diary", "name: diary\nschedule: daily", 1)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "diary.yml"), []byte(raw), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Read(dir)
+	if have, want := errors.Cause(err), errors.ErrManifestInvalid; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestReadDuplicate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b := Base{
+		ClientID:   generate.RandomString(12),
+		Env:        env.Dev,
+		Name:       "diary",
+		Parameters: rule.Parameters{},
+		Rules: []rule.Declaration{
+			{Name: "override"},
+			{Name: "override"},
+		},
+	}
+
+	if err := Write(dir, ExtJSON, []Base{b}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Read(dir)
+	if have, want := errors.Cause(err), errors.ErrManifestInvalid; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func testBaseConfigs() []config.BaseConfig {
+	clientID := generate.RandomString(12)
+
+	return []config.BaseConfig{
+		{
+			ClientID: clientID,
+			Env:      env.Prod,
+			ID:       generate.RandomString(24),
+			Name:     "diary",
+			Parameters: rule.Parameters{
+				"feature_decrease_carbs": true,
+				"feature_increase_fat":   false,
+			},
+		},
+		{
+			ClientID:   clientID,
+			Env:        env.Dev,
+			ID:         generate.RandomString(24),
+			Name:       "diary",
+			Parameters: rule.Parameters{},
+		},
+		{
+			ClientID:   clientID,
+			Deleted:    true,
+			Env:        env.Prod,
+			ID:         generate.RandomString(24),
+			Name:       "legacy",
+			Parameters: rule.Parameters{},
+		},
+	}
+}
+
+func testRules(t *testing.T, configID string) []rule.Rule {
+	rs := []rule.Rule{}
+
+	for _, name := range []string{"increase_fat", "decrease_carbs"} {
+		r, err := rule.New(
+			generate.RandomString(24),
+			configID,
+			name,
+			"",
+			rule.KindOverride,
+			true,
+			nil,
+			[]rule.Bucket{
+				{
+					Name: "default",
+					Parameters: rule.Parameters{
+						"feature_" + name: true,
+					},
+				},
+			},
+			nil,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rs = append(rs, r)
+	}
+
+	return rs
+}
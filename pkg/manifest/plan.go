@@ -0,0 +1,328 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/oklog/ulid"
+
+	"github.com/lifesum/configsum/pkg/config"
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/rule"
+)
+
+// Plan is the set of changes necessary to bring the stored base configs and
+// rules in line with their declarations. Base configs which are not declared
+// are left untouched.
+type Plan struct {
+	Bases []BaseChange
+	Rules []RuleChange
+}
+
+// BaseChange creates or updates a base config. The ID is empty for base
+// configs which don't exist yet.
+type BaseChange struct {
+	ID         string
+	Op         config.ChangeOp
+	Base       Base
+	Parameters []config.ParameterChange
+	Schema     bool
+}
+
+// RuleChange creates, updates or deactivates a rule of the base. Current is
+// the stored rule for updates and deactivations.
+type RuleChange struct {
+	Op          config.ChangeOp
+	Base        Base
+	Current     rule.Rule
+	Declaration rule.Declaration
+}
+
+// Empty reports if the plan has no changes.
+func (p Plan) Empty() bool {
+	return len(p.Bases) == 0 && len(p.Rules) == 0
+}
+
+// String renders the plan as human readable diff.
+func (p Plan) String() string {
+	buf := &bytes.Buffer{}
+
+	for _, c := range p.Bases {
+		fmt.Fprintf(buf, "%s base %s\n", opSymbol(c.Op), c.Base)
+
+		if c.Schema {
+			fmt.Fprintf(buf, "    ~ schema\n")
+		}
+
+		for _, pc := range c.Parameters {
+			switch pc.Op {
+			case config.OpCreate:
+				fmt.Fprintf(buf, "    + %s: %s\n", pc.Key, value(pc.To))
+			case config.OpUpdate:
+				fmt.Fprintf(buf, "    ~ %s: %s -> %s\n", pc.Key, value(pc.From), value(pc.To))
+			case config.OpRemove:
+				fmt.Fprintf(buf, "    - %s: %s\n", pc.Key, value(pc.From))
+			}
+		}
+	}
+
+	for _, c := range p.Rules {
+		fmt.Fprintf(buf, "%s rule %s/%s\n", opSymbol(c.Op), c.Base, c.Declaration.Name)
+	}
+
+	return buf.String()
+}
+
+// Applier computes plans against the stored state and applies them.
+type Applier struct {
+	baseSVC  config.BaseService
+	ruleRepo rule.Repo
+	seed     *rand.Rand
+}
+
+// NewApplier returns an Applier which changes base configs through the
+// service, to be subject to its validation, and rules through the repo.
+func NewApplier(baseSVC config.BaseService, ruleRepo rule.Repo) *Applier {
+	return &Applier{
+		baseSVC:  baseSVC,
+		ruleRepo: ruleRepo,
+		seed:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Plan returns the changes necessary to apply the declared bases.
+func (a *Applier) Plan(declared []Base) (Plan, error) {
+	bcs, err := a.baseSVC.List()
+	if err != nil {
+		return Plan{}, err
+	}
+
+	rs, err := a.ruleRepo.ListAll()
+	if err != nil {
+		return Plan{}, err
+	}
+
+	return plan(declared, bcs, rs, time.Now())
+}
+
+// Apply executes the plan. Rules are deactivated before base configs are
+// updated, so parameters can be removed together with the rules overriding
+// them, and created or updated after, so they can override new parameters.
+// Applying the same plan twice has no effect as long as nothing else changed
+// in between.
+func (a *Applier) Apply(p Plan) error {
+	ids := map[string]string{}
+
+	for _, c := range p.Bases {
+		if c.Op != config.OpCreate {
+			ids[c.Base.String()] = c.ID
+			continue
+		}
+
+		bc, err := a.baseSVC.Create(c.Base.ClientID, c.Base.Env, c.Base.Name)
+		if err != nil {
+			return errors.Wrapf(err, "create %s", c.Base)
+		}
+
+		ids[c.Base.String()] = bc.ID
+	}
+
+	for _, c := range p.Rules {
+		if c.Op != config.OpDeactivate {
+			continue
+		}
+
+		if _, err := a.ruleRepo.UpdateWith(c.Current.Deactivated()); err != nil {
+			return errors.Wrapf(err, "deactivate %s/%s", c.Base, c.Declaration.Name)
+		}
+	}
+
+	for _, c := range p.Bases {
+		id := ids[c.Base.String()]
+
+		if c.Schema {
+			if _, err := a.baseSVC.UpdateSchema(id, c.Base.Schema); err != nil {
+				return errors.Wrapf(err, "update schema %s", c.Base)
+			}
+		}
+
+		if len(c.Parameters) > 0 {
+			if _, err := a.baseSVC.Update(id, c.Base.Parameters); err != nil {
+				return errors.Wrapf(err, "update parameters %s", c.Base)
+			}
+		}
+	}
+
+	for _, c := range p.Rules {
+		switch c.Op {
+		case config.OpCreate:
+			id, err := ulid.New(ulid.Timestamp(time.Now()), a.seed)
+			if err != nil {
+				return errors.Wrap(errors.ErrID, err.Error())
+			}
+
+			r, err := c.Declaration.Rule(id.String(), ids[c.Base.String()])
+			if err != nil {
+				return errors.Wrapf(err, "%s/%s", c.Base, c.Declaration.Name)
+			}
+
+			if _, err := a.ruleRepo.Create(r); err != nil {
+				return errors.Wrapf(err, "create %s/%s", c.Base, c.Declaration.Name)
+			}
+		case config.OpUpdate:
+			r, err := c.Declaration.Apply(c.Current)
+			if err != nil {
+				return errors.Wrapf(err, "%s/%s", c.Base, c.Declaration.Name)
+			}
+
+			if _, err := a.ruleRepo.UpdateWith(r); err != nil {
+				return errors.Wrapf(err, "update %s/%s", c.Base, c.Declaration.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func plan(
+	declared []Base,
+	bcs []config.BaseConfig,
+	rules []rule.Rule,
+	now time.Time,
+) (Plan, error) {
+	current := map[string]config.BaseConfig{}
+
+	for _, bc := range bcs {
+		if bc.Deleted {
+			continue
+		}
+
+		current[Base{ClientID: bc.ClientID, Env: bc.Env, Name: bc.Name}.String()] = bc
+	}
+
+	byConfig := map[string]map[string]rule.Rule{}
+
+	for _, r := range rules {
+		if _, ok := byConfig[r.ConfigID()]; !ok {
+			byConfig[r.ConfigID()] = map[string]rule.Rule{}
+		}
+
+		byConfig[r.ConfigID()][r.Name()] = r
+	}
+
+	p := Plan{
+		Bases: []BaseChange{},
+		Rules: []RuleChange{},
+	}
+
+	for _, b := range declared {
+		bc, ok := current[b.String()]
+		if !ok {
+			bc = config.BaseConfig{Parameters: rule.Parameters{}}
+		}
+
+		c := BaseChange{
+			ID:         bc.ID,
+			Op:         config.OpUpdate,
+			Base:       b,
+			Parameters: config.DiffParameters(b.Parameters, bc.Parameters),
+			Schema:     !sameSchema(b.Schema, bc.Schema),
+		}
+
+		if !ok {
+			c.Op = config.OpCreate
+		}
+
+		if c.Op == config.OpCreate || c.Schema || len(c.Parameters) > 0 {
+			p.Bases = append(p.Bases, c)
+		}
+
+		stored := byConfig[bc.ID]
+		names := map[string]struct{}{}
+
+		for _, d := range b.Rules {
+			names[d.Name] = struct{}{}
+
+			r, ok := stored[d.Name]
+			if !ok {
+				p.Rules = append(p.Rules, RuleChange{
+					Op:          config.OpCreate,
+					Base:        b,
+					Declaration: d,
+				})
+				continue
+			}
+
+			next, err := d.Apply(r)
+			if err != nil {
+				return Plan{}, errors.Wrapf(err, "%s/%s", b, d.Name)
+			}
+
+			if !next.SameAs(r) {
+				p.Rules = append(p.Rules, RuleChange{
+					Op:          config.OpUpdate,
+					Base:        b,
+					Current:     r,
+					Declaration: d,
+				})
+			}
+		}
+
+		for name, r := range stored {
+			if _, ok := names[name]; ok || !r.Active(now) {
+				continue
+			}
+
+			p.Rules = append(p.Rules, RuleChange{
+				Op:          config.OpDeactivate,
+				Base:        b,
+				Current:     r,
+				Declaration: r.Declaration(),
+			})
+		}
+	}
+
+	sort.Slice(p.Bases, func(i, j int) bool {
+		return p.Bases[i].Base.String() < p.Bases[j].Base.String()
+	})
+	sort.Slice(p.Rules, func(i, j int) bool {
+		a, b := p.Rules[i], p.Rules[j]
+
+		if a.Base.String() != b.Base.String() {
+			return a.Base.String() < b.Base.String()
+		}
+
+		return a.Declaration.Name < b.Declaration.Name
+	})
+
+	return p, nil
+}
+
+func sameSchema(a, b rule.ParameterSchema) bool {
+	return len(a) == 0 && len(b) == 0 || reflect.DeepEqual(a, b)
+}
+
+func opSymbol(op config.ChangeOp) string {
+	switch op {
+	case config.OpCreate:
+		return "+"
+	case config.OpUpdate:
+		return "~"
+	default:
+		return "-"
+	}
+}
+
+func value(v interface{}) string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+
+	return string(raw)
+}
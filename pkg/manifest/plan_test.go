@@ -0,0 +1,93 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lifesum/configsum/pkg/config"
+	"github.com/lifesum/configsum/pkg/env"
+	"github.com/lifesum/configsum/pkg/rule"
+)
+
+func TestPlanUnchanged(t *testing.T) {
+	var (
+		bcs   = testBaseConfigs()
+		rules = testRules(t, bcs[0].ID)
+	)
+
+	p, err := plan(Export(bcs, rules), bcs, rules, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.Empty() {
+		t.Errorf("want empty plan, have:\n%s", p)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	var (
+		bcs      = testBaseConfigs()
+		rules    = testRules(t, bcs[0].ID)
+		declared = Export(bcs, rules)
+	)
+
+	// Prod diary: change a parameter, drop one rule and change the other.
+	declared[1].Parameters = rule.Parameters{
+		"feature_decrease_carbs": false,
+		"feature_increase_fat":   false,
+	}
+	declared[1].Rules = declared[1].Rules[:1]
+	declared[1].Rules[0].Description = "carbs are bad"
+
+	// Staging diary doesn't exist yet.
+	declared = append(declared, Base{
+		ClientID:   bcs[0].ClientID,
+		Env:        env.Staging,
+		Name:       "diary",
+		Parameters: rule.Parameters{"feature_decrease_carbs": true},
+		Rules:      []rule.Declaration{declared[1].Rules[0]},
+	})
+
+	p, err := plan(declared, bcs, rules, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type change struct {
+		op   config.ChangeOp
+		base string
+	}
+
+	bases := []change{}
+
+	for _, c := range p.Bases {
+		bases = append(bases, change{op: c.Op, base: c.Base.String()})
+	}
+
+	wantBases := []change{
+		{op: config.OpUpdate, base: declared[1].String()},
+		{op: config.OpCreate, base: declared[2].String()},
+	}
+
+	if have, want := bases, wantBases; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	rs := []change{}
+
+	for _, c := range p.Rules {
+		rs = append(rs, change{op: c.Op, base: c.Base.String() + "/" + c.Declaration.Name})
+	}
+
+	wantRules := []change{
+		{op: config.OpUpdate, base: declared[1].String() + "/decrease_carbs"},
+		{op: config.OpDeactivate, base: declared[1].String() + "/increase_fat"},
+		{op: config.OpCreate, base: declared[2].String() + "/decrease_carbs"},
+	}
+
+	if have, want := rs, wantRules; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
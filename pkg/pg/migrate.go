@@ -0,0 +1,272 @@
+package pg
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const (
+	pgMigrationCreateSchema = `CREATE SCHEMA IF NOT EXISTS %s`
+	pgMigrationCreateTable  = `
+		CREATE TABLE IF NOT EXISTS %s.schema_migrations(
+			component TEXT NOT NULL,
+			version INT8 NOT NULL,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (now() AT TIME ZONE 'utc'),
+			PRIMARY KEY (component, version)
+		)`
+	pgMigrationList = `
+		/* pgMigrationList */
+		SELECT
+			version
+		FROM
+			%s.schema_migrations
+		WHERE
+			component = $1
+		ORDER BY
+			version ASC`
+	pgMigrationInsert = `
+		/* pgMigrationInsert */
+		INSERT INTO
+			%s.schema_migrations(component, version, description, applied_at)
+			VALUES($1, $2, $3, $4)`
+	pgMigrationDelete = `
+		/* pgMigrationDelete */
+		DELETE FROM
+			%s.schema_migrations
+		WHERE
+			component = $1
+			AND version = $2`
+	pgMigrationDeleteAll = `
+		/* pgMigrationDeleteAll */
+		DELETE FROM
+			%s.schema_migrations
+		WHERE
+			component = $1`
+)
+
+// Migration is a versioned and reversible change of the tables of one
+// component. Statements are formatted with the schema, so tables are referred
+// to as %s.table. Down statements have to succeed on a partially or not at all
+// migrated schema, e.g. by using IF EXISTS.
+type Migration struct {
+	Version     int
+	Description string
+	Up          []string
+	Down        []string
+}
+
+// MigrationStatus reports the applied and latest known version of a component.
+type MigrationStatus struct {
+	Component string
+	Schema    string
+	Version   int
+	Latest    int
+}
+
+// Migrator applies the migrations of one component in order of their version
+// and records every applied one in the schema_migrations table of the schema.
+// Each migration runs in its own transaction.
+type Migrator struct {
+	component  string
+	db         *sqlx.DB
+	migrations []Migration
+	schema     string
+}
+
+// NewMigrator returns a Migrator for the component with its tables in schema.
+func NewMigrator(
+	db *sqlx.DB,
+	schema, component string,
+	migrations []Migration,
+) *Migrator {
+	ms := make([]Migration, len(migrations))
+	copy(ms, migrations)
+
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+
+	return &Migrator{
+		component:  component,
+		db:         db,
+		migrations: ms,
+		schema:     schema,
+	}
+}
+
+// Component returns the name of the component migrated.
+func (m *Migrator) Component() string {
+	return m.component
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	for _, mi := range m.migrations {
+		if _, ok := applied[mi.Version]; ok {
+			continue
+		}
+
+		err := m.run(
+			mi.Up,
+			m.prefixSchema(pgMigrationInsert),
+			m.component, mi.Version, mi.Description, time.Now().UTC(),
+		)
+		if Wrap(err) == ErrDuplicateKey {
+			// Applied concurrently by another process.
+			continue
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "migrate %s up to %d", m.component, mi.Version)
+		}
+	}
+
+	return nil
+}
+
+// Down reverts the given number of most recently applied migrations, all of
+// them if steps is zero or less.
+func (m *Migrator) Down(steps int) error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	reverted := 0
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mi := m.migrations[i]
+
+		if _, ok := applied[mi.Version]; !ok {
+			continue
+		}
+
+		if steps > 0 && reverted == steps {
+			break
+		}
+
+		err := m.run(
+			mi.Down,
+			m.prefixSchema(pgMigrationDelete),
+			m.component, mi.Version,
+		)
+		if err != nil {
+			return errors.Wrapf(err, "migrate %s down from %d", m.component, mi.Version)
+		}
+
+		reverted++
+	}
+
+	return nil
+}
+
+// Reset reverts all migrations independent of the recorded versions and
+// forgets about them. It relies on down statements to tolerate missing
+// relations and is meant to tear down throwaway schemas.
+func (m *Migrator) Reset() error {
+	if err := m.ensure(); err != nil {
+		return err
+	}
+
+	stmts := []string{}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		stmts = append(stmts, m.migrations[i].Down...)
+	}
+
+	return m.run(stmts, m.prefixSchema(pgMigrationDeleteAll), m.component)
+}
+
+// Status returns the applied and latest version of the component.
+func (m *Migrator) Status() (MigrationStatus, error) {
+	s := MigrationStatus{
+		Component: m.component,
+		Schema:    m.schema,
+	}
+
+	if len(m.migrations) > 0 {
+		s.Latest = m.migrations[len(m.migrations)-1].Version
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	for v := range applied {
+		if v > s.Version {
+			s.Version = v
+		}
+	}
+
+	return s, nil
+}
+
+func (m *Migrator) applied() (map[int]struct{}, error) {
+	if err := m.ensure(); err != nil {
+		return nil, err
+	}
+
+	vs := []int{}
+
+	err := m.db.Select(&vs, m.prefixSchema(pgMigrationList), m.component)
+	if err != nil {
+		return nil, errors.Wrap(err, "list migrations")
+	}
+
+	applied := map[int]struct{}{}
+
+	for _, v := range vs {
+		applied[v] = struct{}{}
+	}
+
+	return applied, nil
+}
+
+func (m *Migrator) ensure() error {
+	for _, q := range []string{
+		m.prefixSchema(pgMigrationCreateSchema),
+		m.prefixSchema(pgMigrationCreateTable),
+	} {
+		if _, err := m.db.Exec(q); err != nil {
+			return errors.Wrap(err, "setup schema_migrations")
+		}
+	}
+
+	return nil
+}
+
+// run executes the statements followed by the bookkeeping query in a single
+// transaction.
+func (m *Migrator) run(stmts []string, record string, args ...interface{}) error {
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(m.prefixSchema(stmt)); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(record, args...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) prefixSchema(query string) string {
+	return fmt.Sprintf(query, m.schema)
+}
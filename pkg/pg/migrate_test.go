@@ -0,0 +1,138 @@
+package pg
+
+import (
+	"flag"
+	"fmt"
+	"os/user"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	// Blank import for Postgres capabilities.
+	_ "github.com/lib/pq"
+)
+
+var pgURI string
+
+var testMigrations = []Migration{
+	{
+		Version:     2,
+		Description: "add name",
+		Up:          []string{`ALTER TABLE %s.things ADD COLUMN IF NOT EXISTS name TEXT`},
+		Down:        []string{`ALTER TABLE IF EXISTS %s.things DROP COLUMN IF EXISTS name`},
+	},
+	{
+		Version:     1,
+		Description: "create things",
+		Up:          []string{`CREATE TABLE IF NOT EXISTS %s.things(id TEXT NOT NULL PRIMARY KEY)`},
+		Down:        []string{`DROP TABLE IF EXISTS %s.things CASCADE`},
+	},
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	t.Parallel()
+
+	m := prepareMigrator(t)
+
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	testMigratorVersion(t, m, 2)
+
+	// Applying again is a no-op.
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.db.Exec(m.prefixSchema(`INSERT INTO %s.things(id, name) VALUES('a', 'b')`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Down(1); err != nil {
+		t.Fatal(err)
+	}
+
+	testMigratorVersion(t, m, 1)
+
+	_, err := m.db.Exec(m.prefixSchema(`INSERT INTO %s.things(id, name) VALUES('c', 'd')`))
+	if err == nil {
+		t.Fatal("want error for dropped column")
+	}
+
+	if err := m.Down(0); err != nil {
+		t.Fatal(err)
+	}
+
+	testMigratorVersion(t, m, 0)
+
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	testMigratorVersion(t, m, 2)
+}
+
+func TestMigratorReset(t *testing.T) {
+	t.Parallel()
+
+	m := prepareMigrator(t)
+
+	// Tables created before migrations were recorded.
+	if _, err := m.db.Exec(m.prefixSchema(testMigrations[1].Up[0])); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	testMigratorVersion(t, m, 2)
+}
+
+func testMigratorVersion(t *testing.T, m *Migrator, want int) {
+	s, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have := s.Version; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := s.Latest, 2; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func prepareMigrator(t *testing.T) *Migrator {
+	db, err := sqlx.Connect("postgres", pgURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMigrator(db, strings.ToLower(t.Name()), "things", testMigrations)
+
+	if err := m.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func init() {
+	u, err := user.Current()
+	if err != nil {
+		panic(err)
+	}
+
+	uri := flag.String("postgres.uri", fmt.Sprintf(DefaultTestURI, u.Username), "Postgres connection URL")
+
+	flag.Parse()
+
+	pgURI = *uri
+}
@@ -0,0 +1,104 @@
+package rule
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// Supported keys of bucketing.
+const (
+	BucketByUser     BucketingKey = "user"
+	BucketByMetadata BucketingKey = "metadata"
+	BucketByDevice   BucketingKey = "device"
+)
+
+// BucketingKey defines which attribute percentage based decisions are taken
+// for.
+type BucketingKey string
+
+// Bucketing defines what percentage based decisions of a rule are keyed by.
+// Everybody sharing the key, e.g. all members of a household, lands in the same
+// bucket as dice rolls are derived from the key with HashDice, regardless of
+// the dice used for other rules. If the attribute is missing decisions fall
+// back to the user id. The zero value buckets by user id.
+type Bucketing struct {
+	Key BucketingKey `json:"key"`
+	// Path of the metadata attribute for BucketByMetadata.
+	Path string `json:"path,omitempty"`
+}
+
+func (b Bucketing) validate() error {
+	switch b.Key {
+	case "", BucketByUser, BucketByDevice:
+		if b.Path != "" {
+			return errors.Wrapf(errors.ErrInvalidRule, "bucketing by '%s' takes no path", b.Key)
+		}
+	case BucketByMetadata:
+		if b.Path == "" {
+			return errors.Wrap(errors.ErrInvalidRule, "bucketing by metadata path missing")
+		}
+	default:
+		return errors.Wrapf(errors.ErrInvalidRule, "bucketing key '%s' not supported", b.Key)
+	}
+
+	return nil
+}
+
+// id returns the value decisions are keyed by for the context and if it was
+// found, otherwise the user id is returned.
+func (b Bucketing) id(ctx Context) (string, bool) {
+	var id string
+
+	switch b.Key {
+	case BucketByDevice:
+		id = ctx.Device.ID
+	case BucketByMetadata:
+		v, _ := lookupMetadata(ctx.Metadata, b.Path)
+
+		switch v := v.(type) {
+		case string:
+			id = v
+		case float64:
+			id = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+
+	if id == "" {
+		return ctx.User.ID, false
+	}
+
+	return id, true
+}
+
+// decisionKey returns the key dice rolls are derived from and the key the
+// decision is kept under among the decisions of the user. Decisions keyed by
+// another attribute than the user id carry its value, so they are taken anew
+// once the value changes, e.g. if the user moves to another household.
+func (r Rule) decisionKey(ctx Context) (id, key string) {
+	id, ok := r.bucketing.id(ctx)
+	if !ok {
+		return id, r.ID
+	}
+
+	return id, fmt.Sprintf("%s:%s:%s", r.ID, r.bucketing.Key, id)
+}
+
+// WithBucketing returns a copy of the rule which keys its percentage based
+// decisions as given. Changing the bucketing of a running rule distributes
+// users anew.
+func (r Rule) WithBucketing(b Bucketing) (Rule, error) {
+	if b.Key == BucketByUser {
+		b = Bucketing{}
+	}
+
+	c := r
+	c.bucketing = b
+
+	if err := c.validate(); err != nil {
+		return Rule{}, err
+	}
+
+	return c, nil
+}
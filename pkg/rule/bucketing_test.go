@@ -0,0 +1,324 @@
+package rule
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/generate"
+)
+
+func TestRuleBucketingValidate(t *testing.T) {
+	t.Parallel()
+
+	rp := uint8(50)
+
+	r, err := New(
+		generate.RandomString(12),
+		generate.RandomString(16),
+		generate.RandomString(12),
+		generate.RandomString(12),
+		KindRollout,
+		true,
+		nil,
+		[]Bucket{
+			{
+				Parameters: Parameters{"feature_family": true},
+			},
+		},
+		&rp,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, b := range []Bucketing{
+		{Key: BucketByMetadata},
+		{Key: BucketByDevice, Path: "household_id"},
+		{Key: "household"},
+	} {
+		_, err := r.WithBucketing(b)
+		if have, want := errors.Cause(err), errors.ErrInvalidRule; have != want {
+			t.Errorf("%v: have %v, want %v", b, have, want)
+		}
+	}
+
+	r, err = r.WithBucketing(Bucketing{Key: BucketByUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := r.bucketing, (Bucketing{}); have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestRuleDecisionKey(t *testing.T) {
+	t.Parallel()
+
+	household := Rule{
+		ID:        "rule-1",
+		bucketing: Bucketing{Key: BucketByMetadata, Path: "family.id"},
+	}
+	device := Rule{
+		ID:        "rule-2",
+		bucketing: Bucketing{Key: BucketByDevice},
+	}
+
+	cases := []struct {
+		rule Rule
+		ctx  Context
+		id   string
+		key  string
+	}{
+		{
+			household,
+			Context{
+				Metadata: map[string]interface{}{
+					"family": map[string]interface{}{"id": "family-1"},
+				},
+				User: ContextUser{ID: "user-1"},
+			},
+			"family-1",
+			"rule-1:metadata:family-1",
+		},
+		{
+			household,
+			Context{
+				Metadata: map[string]interface{}{
+					"family": map[string]interface{}{"id": float64(42)},
+				},
+				User: ContextUser{ID: "user-1"},
+			},
+			"42",
+			"rule-1:metadata:42",
+		},
+		{
+			household,
+			Context{User: ContextUser{ID: "user-1"}},
+			"user-1",
+			"rule-1",
+		},
+		{
+			device,
+			Context{Device: ContextDevice{ID: "device-1"}, User: ContextUser{ID: "user-1"}},
+			"device-1",
+			"rule-2:device:device-1",
+		},
+		{
+			device,
+			Context{User: ContextUser{ID: "user-1"}},
+			"user-1",
+			"rule-2",
+		},
+	}
+
+	for _, c := range cases {
+		id, key := c.rule.decisionKey(c.ctx)
+
+		if have, want := id, c.id; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		if have, want := key, c.key; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
+
+func TestEvaluateBucketing(t *testing.T) {
+	t.Parallel()
+
+	r := Rule{
+		active:    true,
+		bucketing: Bucketing{Key: BucketByMetadata, Path: "household_id"},
+		buckets: []Bucket{
+			{Name: "control", Parameters: Parameters{"family_plan": "control"}, Percentage: 50},
+			{Name: "variant", Parameters: Parameters{"family_plan": "variant"}, Percentage: 50},
+		},
+		configID: "base-1",
+		ID:       "rule-1",
+		kind:     KindExperiment,
+		name:     "family plan",
+	}
+
+	for i := 0; i < 20; i++ {
+		var (
+			household = fmt.Sprintf("household-%d", i)
+			want      Parameters
+			wantDs    Decisions
+		)
+
+		for j := 0; j < 3; j++ {
+			ctx := Context{
+				Metadata: map[string]interface{}{"household_id": household},
+				User:     ContextUser{ID: fmt.Sprintf("user-%d-%d", i, j)},
+			}
+
+			params, ds, err := Evaluate(Parameters{}, []Rule{r}, ctx, nil, HashDice)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok := ds["rule-1:metadata:"+household]; !ok {
+				t.Fatalf("decision missing in %v", ds)
+			}
+
+			if j == 0 {
+				want, wantDs = params, ds
+				continue
+			}
+
+			if have := params; !reflect.DeepEqual(have, want) {
+				t.Errorf("%s: have %v, want %v", household, have, want)
+			}
+
+			if have := ds; !reflect.DeepEqual(have, wantDs) {
+				t.Errorf("%s: have %v, want %v", household, have, wantDs)
+			}
+		}
+	}
+
+	// Previous decisions are looked up by the bucketing key.
+	ctx := Context{
+		Metadata: map[string]interface{}{"household_id": "household-x"},
+		User:     ContextUser{ID: "user-1"},
+	}
+	previous := Decisions{"rule-1:metadata:household-x": []int{80}}
+
+	params, _, err := Evaluate(Parameters{}, []Rule{r}, ctx, previous, HashDice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := params, (Parameters{"family_plan": "variant"}); !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestEvaluateBucketingRandDice(t *testing.T) {
+	t.Parallel()
+
+	r := Rule{
+		active:    true,
+		bucketing: Bucketing{Key: BucketByMetadata, Path: "household_id"},
+		buckets: []Bucket{
+			{Name: "control", Parameters: Parameters{"family_plan": "control"}, Percentage: 50},
+			{Name: "variant", Parameters: Parameters{"family_plan": "variant"}, Percentage: 50},
+		},
+		configID: "base-1",
+		ID:       "rule-1",
+		kind:     KindExperiment,
+		name:     "family plan",
+	}
+
+	var (
+		rolls = 0
+		dice  = RandDice(func() int {
+			rolls++
+			return rolls%99 + 1
+		})
+	)
+
+	for i := 0; i < 20; i++ {
+		var (
+			household = fmt.Sprintf("household-%d", i)
+			want      Parameters
+		)
+
+		for j := 0; j < 3; j++ {
+			ctx := Context{
+				Metadata: map[string]interface{}{"household_id": household},
+				User:     ContextUser{ID: fmt.Sprintf("user-%d-%d", i, j)},
+			}
+
+			params, _, err := Evaluate(Parameters{}, []Rule{r}, ctx, nil, dice)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if j == 0 {
+				want = params
+				continue
+			}
+
+			if have := params; !reflect.DeepEqual(have, want) {
+				t.Errorf("%s: have %v, want %v", household, have, want)
+			}
+		}
+	}
+
+	if rolls != 0 {
+		t.Errorf("dice rolled %d times for bucketed rule", rolls)
+	}
+
+	// Without the attribute the user falls back to the given dice.
+	_, _, err := Evaluate(Parameters{}, []Rule{r}, Context{User: ContextUser{ID: "user-1"}}, nil, dice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := rolls, 1; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestRuleBucketDistribution(t *testing.T) {
+	t.Parallel()
+
+	r := Rule{
+		buckets: []Bucket{
+			{Name: "control", Percentage: 50},
+			{Name: "variant", Percentage: 49},
+			{Name: "holdout", Percentage: 1},
+		},
+	}
+
+	var (
+		have     = map[string]int{}
+		min, max = 100, 0
+		dice     = generate.RandPercentage(nil)
+	)
+
+	for i := 0; i < 20000; i++ {
+		d := dice()
+
+		if d < min {
+			min = d
+		}
+
+		if d > max {
+			max = d
+		}
+	}
+
+	if min != 1 || max != 100 {
+		t.Errorf("dice range [%d, %d], want [1, 100]", min, max)
+	}
+
+	// Every dice value lands in exactly one bucket, so each bucket is served
+	// its percentage.
+	for d := 1; d <= 100; d++ {
+		have[r.bucket(d).Name]++
+	}
+
+	want := map[string]int{"control": 50, "variant": 49, "holdout": 1}
+
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	// Hashed dice reach the last bucket as well.
+	hashed := map[string]int{}
+
+	for i := 0; i < 20000; i++ {
+		hashed[r.bucket(HashDice("rule-1", fmt.Sprintf("user-%d", i))()).Name]++
+	}
+
+	for name, share := range want {
+		if n := hashed[name]; n < share*20000/100/2 || n > share*20000/100*2 {
+			t.Errorf("%s: %d of 20000 for %d%%", name, n, share)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package rule
+
+import (
+	"strings"
+
+	"golang.org/x/text/language"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// Region groups which can be used in place of single countries in country
+// criteria, given as ISO 3166-1 alpha-2 codes.
+var regionGroups = map[string][]string{
+	"EEA": {
+		"AT", "BE", "BG", "CY", "CZ", "DE", "DK", "EE", "ES", "FI", "FR", "GR",
+		"HR", "HU", "IE", "IS", "IT", "LI", "LT", "LU", "LV", "MT", "NL", "NO",
+		"PL", "PT", "RO", "SE", "SI", "SK",
+	},
+	"EU": {
+		"AT", "BE", "BG", "CY", "CZ", "DE", "DK", "EE", "ES", "FI", "FR", "GR",
+		"HR", "HU", "IE", "IT", "LT", "LU", "LV", "MT", "NL", "PL", "PT", "RO",
+		"SE", "SI", "SK",
+	},
+	"LATAM": {
+		"AR", "BO", "BR", "CL", "CO", "CR", "CU", "DO", "EC", "GT", "HN", "MX",
+		"NI", "PA", "PE", "PY", "SV", "UY", "VE",
+	},
+	"NORDICS": {
+		"DK", "FI", "IS", "NO", "SE",
+	},
+}
+
+// countryValue converts the decoded JSON value of a country criterion to the
+// type it is matched with. Codes are upper-cased.
+func countryValue(v interface{}) interface{} {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+
+	ss := []string{}
+
+	for _, e := range vs {
+		s, ok := e.(string)
+		if !ok {
+			return v
+		}
+
+		ss = append(ss, strings.ToUpper(s))
+	}
+
+	return ss
+}
+
+func validateCountryCriterion(c Criterion) error {
+	switch c.Comparator {
+	case ComparatorIN, ComparatorNotIN:
+	default:
+		return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+	}
+
+	codes, ok := c.Value.([]string)
+	if !ok {
+		return errors.Wrapf(
+			errors.ErrInvalidTypeToMatch,
+			"%s: value %v invalid for '%s'",
+			c.Key,
+			c.Value,
+			c.Comparator,
+		)
+	}
+
+	if len(codes) == 0 {
+		return errors.Wrapf(errors.ErrInvalidRule, "%s: countries missing", c.Key)
+	}
+
+	for _, code := range codes {
+		if _, ok := regionGroups[code]; ok {
+			continue
+		}
+
+		r, err := language.ParseRegion(code)
+		if err != nil || !r.IsCountry() || r.String() != code {
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: country '%s' unknown", c.Key, code)
+		}
+	}
+
+	return nil
+}
+
+// matchCountry matches the detected country, or the explicit region of the
+// locale if none was detected, against the listed countries and region groups. Requests
+// without a known country never match.
+func matchCountry(c Criterion, locale ContextLocale) error {
+	if err := validateCountryCriterion(c); err != nil {
+		return err
+	}
+
+	country := locale.Country
+	if !country.IsCountry() {
+		if r, confidence := locale.Locale.Region(); confidence == language.Exact {
+			country = r
+		}
+	}
+
+	if !country.IsCountry() {
+		return errors.Wrap(errors.ErrCriterionNotMatch, "country unknown")
+	}
+
+	ok := containsCountry(c.Value.([]string), country.String())
+
+	switch c.Comparator {
+	case ComparatorIN:
+		if !ok {
+			return errors.Wrapf(errors.ErrCriterionNotMatch, "country %s not in %v", country, c.Value)
+		}
+	case ComparatorNotIN:
+		if ok {
+			return errors.Wrapf(errors.ErrCriterionNotMatch, "country %s in %v", country, c.Value)
+		}
+	}
+
+	return nil
+}
+
+func containsCountry(codes []string, country string) bool {
+	for _, code := range codes {
+		if code == country {
+			return true
+		}
+
+		for _, member := range regionGroups[code] {
+			if member == country {
+				return true
+			}
+		}
+	}
+
+	return false
+}
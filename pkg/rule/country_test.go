@@ -0,0 +1,113 @@
+package rule
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/language"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+func TestCriterionCountryMarshal(t *testing.T) {
+	want := Criterion{
+		Comparator: ComparatorNotIN,
+		Key:        DeviceLocationCountry,
+		Value:      []string{"EU", "CH", "GB"},
+	}
+
+	raw, err := json.Marshal(&want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var have Criterion
+
+	err = json.Unmarshal(raw, &have)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestCriterionCountryUnmarshal(t *testing.T) {
+	var have Criterion
+
+	err := json.Unmarshal([]byte(`{"comparator": 3, "key": 107, "value": ["nordics", "de"]}`), &have)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := have.Value, []string{"NORDICS", "DE"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestCriterionCountryInvalid(t *testing.T) {
+	cases := map[string]error{
+		`{"comparator": 1, "key": 107, "value": ["DE"]}`:      errors.ErrInvalidRule,
+		`{"comparator": 3, "key": 107, "value": []}`:          errors.ErrInvalidRule,
+		`{"comparator": 3, "key": 107, "value": ["Germany"]}`: errors.ErrInvalidRule,
+		`{"comparator": 3, "key": 107, "value": ["419"]}`:     errors.ErrInvalidRule,
+		`{"comparator": 8, "key": 107, "value": "DE"}`:        errors.ErrInvalidTypeToMatch,
+	}
+
+	for input, want := range cases {
+		err := json.Unmarshal([]byte(input), &Criterion{})
+		if have := errors.Cause(err); have != want {
+			t.Errorf("%s: have %v, want %v", input, have, want)
+		}
+	}
+}
+
+func TestCriterionCountryMatch(t *testing.T) {
+	var (
+		eu    = Criterion{ComparatorIN, DeviceLocationCountry, []string{"EU"}, ""}
+		notEU = Criterion{ComparatorNotIN, DeviceLocationCountry, []string{"EU", "CH"}, ""}
+		latam = Criterion{ComparatorIN, DeviceLocationCountry, []string{"LATAM", "ES"}, ""}
+	)
+
+	cases := []struct {
+		criterion Criterion
+		country   string
+		locale    string
+		match     bool
+	}{
+		{eu, "", "de_DE", true},
+		{eu, "", "en_US", false},
+		{eu, "", "en", false},
+		{eu, "SE", "en_US", true},
+		{eu, "US", "de_DE", false},
+		{notEU, "", "de_CH", false},
+		{notEU, "NO", "de_DE", true},
+		{notEU, "", "en", false},
+		{latam, "", "es_MX", true},
+		{latam, "", "es_ES", true},
+		{latam, "", "pt_PT", false},
+	}
+
+	for _, c := range cases {
+		ctx := Context{
+			Locale: ContextLocale{
+				Locale: language.MustParse(c.locale),
+			},
+		}
+
+		if c.country != "" {
+			ctx.Locale.Country = language.MustParseRegion(c.country)
+		}
+
+		err := c.criterion.match(ctx)
+
+		switch {
+		case c.match && err != nil:
+			t.Errorf("%v %s %s: have %v, want match", c.criterion, c.country, c.locale, err)
+		case !c.match && errors.Cause(err) != errors.ErrCriterionNotMatch:
+			t.Errorf("%v %s %s: have %v, want %v", c.criterion, c.country, c.locale, err, errors.ErrCriterionNotMatch)
+		}
+	}
+}
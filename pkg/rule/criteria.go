@@ -14,6 +14,11 @@ const (
 	ComparatorEQ
 	ComparatorNQ
 	ComparatorIN
+	ComparatorLT
+	ComparatorContains
+	ComparatorRegex
+	ComparatorBetween
+	ComparatorNotIN
 )
 
 // Comparator defines the type of comparison for a Criterion.
@@ -29,6 +34,16 @@ func (c Comparator) String() string {
 		return "ComparatorGT"
 	case ComparatorIN:
 		return "ComparatorIN"
+	case ComparatorLT:
+		return "ComparatorLT"
+	case ComparatorContains:
+		return "ComparatorContains"
+	case ComparatorRegex:
+		return "ComparatorRegex"
+	case ComparatorBetween:
+		return "ComparatorBetween"
+	case ComparatorNotIN:
+		return "ComparatorNotIN"
 	default:
 		return "unknown comparator"
 	}
@@ -45,6 +60,10 @@ const (
 	DeviceLocationOffset
 	DeviceOSPlatform
 	DeviceOSVersion
+	DeviceLocalTime
+	DeviceLocalWeekday
+	DeviceLocationCountry
+	DeviceLocationLanguage
 )
 
 // Metadata context keys.
@@ -60,6 +79,8 @@ const (
 	UserRegistered
 	UserID
 	UserSubscription
+	UserListID
+	UserAccountAge
 )
 
 // Date comparison key
@@ -67,6 +88,17 @@ const (
 	ValidDate CriterionKey = iota + 401
 )
 
+// Segment keys.
+const (
+	SegmentID CriterionKey = iota + 501
+)
+
+// Prerequisite keys match on the state of the current render.
+const (
+	ParameterValue CriterionKey = iota + 601
+	RuleDecision
+)
+
 // CriterionKey is the set of possible input to match on.
 type CriterionKey int
 
@@ -74,10 +106,42 @@ func (k CriterionKey) String() string {
 	switch k {
 	case AppVersion:
 		return "AppVersion"
+	case DeviceLocationOffset:
+		return "DeviceLocationOffset"
+	case DeviceLocationCountry:
+		return "DeviceLocationCountry"
+	case DeviceLocationLanguage:
+		return "DeviceLocationLanguage"
+	case DeviceOSPlatform:
+		return "DeviceOSPlatform"
+	case DeviceOSVersion:
+		return "DeviceOSVersion"
+	case DeviceLocalTime:
+		return "DeviceLocalTime"
+	case DeviceLocalWeekday:
+		return "DeviceLocalWeekday"
+	case MetadataBool:
+		return "MetadataBool"
+	case MetadataNumber:
+		return "MetadataNumber"
+	case MetadataString:
+		return "MetadataString"
 	case UserID:
 		return "UserID"
 	case UserSubscription:
 		return "UserSubscription"
+	case UserListID:
+		return "UserListID"
+	case UserRegistered:
+		return "UserRegistered"
+	case UserAccountAge:
+		return "UserAccountAge"
+	case SegmentID:
+		return "SegmentID"
+	case ParameterValue:
+		return "ParameterValue"
+	case RuleDecision:
+		return "RuleDecision"
 	default:
 		return "unknown"
 	}
@@ -107,6 +171,54 @@ func (c Criterion) MarshalJSON() ([]byte, error) {
 		}
 
 		value = t.String()
+	case DeviceLocationCountry:
+		if err := validateCountryCriterion(c); err != nil {
+			return nil, err
+		}
+
+		value = c.Value
+	case DeviceLocationLanguage:
+		if err := validateLanguageCriterion(c); err != nil {
+			return nil, err
+		}
+
+		ss := []string{}
+
+		for _, t := range c.Value.([]language.Tag) {
+			ss = append(ss, t.String())
+		}
+
+		value = ss
+	case DeviceOSPlatform, DeviceOSVersion:
+		if err := validateDeviceCriterion(c); err != nil {
+			return nil, err
+		}
+
+		value = c.Value
+	case DeviceLocalTime, DeviceLocalWeekday, DeviceLocationOffset:
+		if err := validateLocalTimeCriterion(c); err != nil {
+			return nil, err
+		}
+
+		value = c.Value
+	case UserAccountAge, UserRegistered:
+		if err := validateRegistrationCriterion(c); err != nil {
+			return nil, err
+		}
+
+		value = c.Value
+	case MetadataBool, MetadataNumber, MetadataString:
+		if err := validateMetadataCriterion(c); err != nil {
+			return nil, err
+		}
+
+		value = c.Value
+	case ParameterValue, RuleDecision:
+		if err := validatePrerequisiteCriterion(c); err != nil {
+			return nil, err
+		}
+
+		value = c.Value
 	case UserSubscription:
 		t, ok := c.Value.(int)
 		if !ok {
@@ -122,6 +234,13 @@ func (c Criterion) MarshalJSON() ([]byte, error) {
 
 		value = t
 
+	case SegmentID, UserListID:
+		t, ok := c.Value.(string)
+		if !ok {
+			return nil, errors.Wrapf(errors.ErrInvalidTypeToMatch, "%s: value not a string", c.Key)
+		}
+
+		value = t
 	case ValidDate:
 		t, ok := c.Value.(int)
 		if !ok {
@@ -176,6 +295,53 @@ func (c *Criterion) UnmarshalJSON(raw []byte) error {
 		}
 
 		c.Value = t
+	case DeviceLocationCountry:
+		c.Value = countryValue(v.Value)
+
+		if err := validateCountryCriterion(*c); err != nil {
+			return err
+		}
+	case DeviceLocationLanguage:
+		value, err := languageValue(v.Value)
+		if err != nil {
+			return err
+		}
+
+		c.Value = value
+
+		if err := validateLanguageCriterion(*c); err != nil {
+			return err
+		}
+	case DeviceOSPlatform, DeviceOSVersion:
+		c.Value = deviceValue(v.Value)
+
+		if err := validateDeviceCriterion(*c); err != nil {
+			return err
+		}
+	case DeviceLocalTime, DeviceLocalWeekday, DeviceLocationOffset:
+		c.Value = localTimeValue(c.Key, v.Value)
+
+		if err := validateLocalTimeCriterion(*c); err != nil {
+			return err
+		}
+	case UserAccountAge, UserRegistered:
+		c.Value = registrationValue(c.Key, v.Value)
+
+		if err := validateRegistrationCriterion(*c); err != nil {
+			return err
+		}
+	case MetadataBool, MetadataNumber, MetadataString:
+		c.Value = metadataValue(c.Key, v.Value)
+
+		if err := validateMetadataCriterion(*c); err != nil {
+			return err
+		}
+	case ParameterValue, RuleDecision:
+		c.Value = v.Value
+
+		if err := validatePrerequisiteCriterion(*c); err != nil {
+			return err
+		}
 	case UserSubscription:
 		s, ok := v.Value.(float64)
 		if !ok {
@@ -191,6 +357,13 @@ func (c *Criterion) UnmarshalJSON(raw []byte) error {
 
 		c.Value = s
 
+	case SegmentID, UserListID:
+		s, ok := v.Value.(string)
+		if !ok {
+			return errors.Wrapf(errors.ErrInvalidTypeToMatch, "%s: value not a string", c.Key)
+		}
+
+		c.Value = s
 	case ValidDate:
 		s, ok := v.Value.(float64)
 		if !ok {
@@ -214,6 +387,20 @@ func (c Criterion) match(ctx Context) error {
 		}
 
 		return matchLocationLocale(c.Comparator, expected, ctx.Locale.Locale)
+	case DeviceLocationCountry:
+		return matchCountry(c, ctx.Locale)
+	case DeviceLocationLanguage:
+		return matchLanguage(c, ctx.Locale.Locale)
+	case DeviceOSPlatform, DeviceOSVersion:
+		return matchDevice(c, ctx.Device)
+	case DeviceLocalTime, DeviceLocalWeekday, DeviceLocationOffset:
+		return matchLocalTime(c, ctx)
+	case UserAccountAge, UserRegistered:
+		return matchRegistration(c, ctx)
+	case MetadataBool, MetadataNumber, MetadataString:
+		return matchMetadata(c, ctx.Metadata)
+	case ParameterValue, RuleDecision:
+		return matchPrerequisite(c, ctx)
 	case UserSubscription:
 		expected, ok := c.Value.(int)
 		if !ok {
@@ -227,6 +414,33 @@ func (c Criterion) match(ctx Context) error {
 		}
 
 		return matchUserID(c.Comparator, expected, ctx.User.ID)
+	case UserListID:
+		id, ok := c.Value.(string)
+		if !ok {
+			return errors.Wrapf(errors.ErrInvalidTypeToMatch, "%s: value not a string", c.Key)
+		}
+
+		if ctx.UserLists == nil {
+			return errors.Errorf("user list '%s': no user list lookup", id)
+		}
+
+		return matchUserList(c.Comparator, id, ctx.UserLists, ctx.User.ID)
+	case SegmentID:
+		id, ok := c.Value.(string)
+		if !ok {
+			return errors.Wrapf(errors.ErrInvalidTypeToMatch, "%s: value not a string", c.Key)
+		}
+
+		if ctx.Segments == nil {
+			return errors.Errorf("segment '%s': no segment lookup", id)
+		}
+
+		s, err := ctx.Segments.Lookup(id)
+		if err != nil {
+			return errors.Wrapf(err, "segment '%s'", id)
+		}
+
+		return matchSegment(c.Comparator, s, ctx)
 	default:
 		errors.Errorf("unsupported Key '%d'", c.Key)
 	}
@@ -0,0 +1,179 @@
+package rule
+
+import (
+	"time"
+)
+
+// Declaration is the declarative form of a rule, suitable to be kept in
+// version control. It carries no identity besides the name, which is unique
+// per base config.
+type Declaration struct {
+	Active      bool                `json:"active"`
+	Bucketing   *Bucketing          `json:"bucketing,omitempty"`
+	Buckets     []DeclarationBucket `json:"buckets"`
+	Criteria    Criteria            `json:"criteria,omitempty"`
+	Description string              `json:"description"`
+	EndTime     *time.Time          `json:"end_time,omitempty"`
+	Kind        Kind                `json:"kind"`
+	Layer       *DeclarationLayer   `json:"layer,omitempty"`
+	Name        string              `json:"name"`
+	Reshuffle   bool                `json:"reshuffle,omitempty"`
+	Rollout     uint8               `json:"rollout"`
+	StartTime   *time.Time          `json:"start_time,omitempty"`
+	Sticky      bool                `json:"sticky,omitempty"`
+}
+
+// DeclarationBucket is the declarative form of a Bucket.
+type DeclarationBucket struct {
+	Name       string     `json:"name"`
+	Parameters Parameters `json:"parameters"`
+	Percentage int        `json:"percentage,omitempty"`
+}
+
+// DeclarationLayer is the declarative form of a Layer. The offset is assigned
+// on storage and not part of the declaration.
+type DeclarationLayer struct {
+	Name  string `json:"name"`
+	Share uint8  `json:"share"`
+}
+
+// Declaration returns the declarative form of the rule.
+func (r Rule) Declaration() Declaration {
+	d := Declaration{
+		Active:      r.active,
+		Buckets:     []DeclarationBucket{},
+		Criteria:    r.criteria,
+		Description: r.description,
+		Kind:        r.kind,
+		Name:        r.name,
+		Reshuffle:   r.reshuffle,
+		Rollout:     r.rollout,
+		Sticky:      r.sticky,
+	}
+
+	if len(d.Criteria) == 0 {
+		d.Criteria = nil
+	}
+
+	if r.bucketing.Key != "" {
+		b := r.bucketing
+		d.Bucketing = &b
+	}
+
+	for _, b := range r.buckets {
+		d.Buckets = append(d.Buckets, DeclarationBucket{
+			Name:       b.Name,
+			Parameters: b.Parameters,
+			Percentage: b.Percentage,
+		})
+	}
+
+	if !r.endTime.IsZero() {
+		t := r.endTime.UTC()
+		d.EndTime = &t
+	}
+
+	if r.layer.Name != "" {
+		d.Layer = &DeclarationLayer{
+			Name:  r.layer.Name,
+			Share: r.layer.Share,
+		}
+	}
+
+	if !r.startTime.IsZero() {
+		t := r.startTime.UTC()
+		d.StartTime = &t
+	}
+
+	return d
+}
+
+// Rule returns a valid rule for the declaration with the given identity.
+func (d Declaration) Rule(id, configID string) (Rule, error) {
+	bs := []Bucket{}
+
+	for _, b := range d.Buckets {
+		bs = append(bs, Bucket{
+			Name:       b.Name,
+			Parameters: b.Parameters,
+			Percentage: b.Percentage,
+		})
+	}
+
+	r, err := New(
+		id,
+		configID,
+		d.Name,
+		d.Description,
+		d.Kind,
+		d.Active,
+		d.Criteria,
+		bs,
+		&d.Rollout,
+	)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	if d.EndTime != nil {
+		r.endTime = d.EndTime.UTC()
+	}
+
+	if d.Layer != nil {
+		r, err = r.InLayer(d.Layer.Name, d.Layer.Share)
+		if err != nil {
+			return Rule{}, err
+		}
+	}
+
+	if d.StartTime != nil {
+		r.startTime = d.StartTime.UTC()
+	}
+
+	r, err = r.WithStickiness(d.Sticky, d.Reshuffle)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	if d.Bucketing != nil {
+		r, err = r.WithBucketing(*d.Bucketing)
+		if err != nil {
+			return Rule{}, err
+		}
+	}
+
+	if r.active {
+		r.activatedAt = time.Now().UTC()
+	}
+
+	return r, nil
+}
+
+// Apply returns the given rule overridden by the declaration. Identity and
+// creation time are kept, as is the activation time unless the declaration
+// changes the activation and the layer offset unless the layer changes. The
+// rollout restarts if the declaration activates it or raises it from zero.
+func (d Declaration) Apply(r Rule) (Rule, error) {
+	n, err := d.Rule(r.ID, r.configID)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	n.createdAt = r.createdAt
+
+	if n.active == r.active {
+		n.activatedAt = r.activatedAt
+	}
+
+	if n.layer.Name == r.layer.Name {
+		n.layer.Offset = r.layer.Offset
+	}
+
+	n.generation = r.generation
+
+	if n.active && !r.active || n.rollout > 0 && r.rollout == 0 {
+		n = n.restarted()
+	}
+
+	return n, nil
+}
@@ -0,0 +1,278 @@
+package rule
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// Platforms clients render configs on.
+const (
+	PlatformAndroid = "Android"
+	PlatformIOS     = "iOS"
+	PlatformWatchOS = "WatchOS"
+)
+
+// ValidPlatform reports if the platform is one clients render configs on.
+func ValidPlatform(platform string) bool {
+	switch platform {
+	case PlatformAndroid, PlatformIOS, PlatformWatchOS:
+		return true
+	default:
+		return false
+	}
+}
+
+// deviceValue converts the decoded JSON value of a device criterion to the
+// type it is matched with.
+func deviceValue(v interface{}) interface{} {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+
+	ss := []string{}
+
+	for _, e := range vs {
+		s, ok := e.(string)
+		if !ok {
+			return v
+		}
+
+		ss = append(ss, s)
+	}
+
+	return ss
+}
+
+func validateDeviceCriterion(c Criterion) error {
+	var (
+		ok     bool
+		values []string
+	)
+
+	switch c.Comparator {
+	case ComparatorEQ, ComparatorNQ:
+		var s string
+
+		s, ok = c.Value.(string)
+		values = []string{s}
+	case ComparatorGT, ComparatorLT:
+		if c.Key != DeviceOSVersion {
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+		}
+
+		var s string
+
+		s, ok = c.Value.(string)
+		values = []string{s}
+	case ComparatorIN, ComparatorNotIN:
+		if c.Key != DeviceOSPlatform {
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+		}
+
+		if values, ok = c.Value.([]string); ok && len(values) == 0 {
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: platforms missing", c.Key)
+		}
+	case ComparatorBetween:
+		if c.Key != DeviceOSVersion {
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+		}
+
+		if values, ok = c.Value.([]string); ok && len(values) != 2 {
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: range %v invalid", c.Key, values)
+		}
+	default:
+		return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+	}
+
+	if !ok {
+		return errors.Wrapf(
+			errors.ErrInvalidTypeToMatch,
+			"%s: value %v invalid for '%s'",
+			c.Key,
+			c.Value,
+			c.Comparator,
+		)
+	}
+
+	for _, v := range values {
+		switch c.Key {
+		case DeviceOSPlatform:
+			if !ValidPlatform(v) {
+				return errors.Wrapf(errors.ErrInvalidRule, "%s: platform '%s' unknown", c.Key, v)
+			}
+		case DeviceOSVersion:
+			if _, err := parseVersion(v); err != nil {
+				return errors.Wrapf(errors.ErrInvalidRule, "%s: %s", c.Key, err)
+			}
+		}
+	}
+
+	if c.Comparator == ComparatorBetween {
+		from, _ := parseVersion(values[0])
+		to, _ := parseVersion(values[1])
+
+		if compareVersions(from, to) > 0 {
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: range %v invalid", c.Key, values)
+		}
+	}
+
+	return nil
+}
+
+// matchDevice matches the platform or the os version of the device. Devices
+// with an unknown platform or a version which can't be parsed never match.
+func matchDevice(c Criterion, device ContextDevice) error {
+	if err := validateDeviceCriterion(c); err != nil {
+		return err
+	}
+
+	var ok bool
+
+	switch c.Key {
+	case DeviceOSPlatform:
+		if !ValidPlatform(device.Platform) {
+			return errors.Wrapf(errors.ErrCriterionNotMatch, "platform '%s' unknown", device.Platform)
+		}
+
+		ok = matchPlatform(c, device.Platform)
+	case DeviceOSVersion:
+		version, err := parseVersion(device.OSVersion)
+		if err != nil {
+			return errors.Wrapf(errors.ErrCriterionNotMatch, "os version: %s", err)
+		}
+
+		ok = matchVersion(c, version)
+	}
+
+	if !ok {
+		return errors.Wrapf(
+			errors.ErrCriterionNotMatch,
+			"device %s %s doesn't match %s %s %v",
+			device.Platform,
+			device.OSVersion,
+			c.Key,
+			c.Comparator,
+			c.Value,
+		)
+	}
+
+	return nil
+}
+
+func matchPlatform(c Criterion, platform string) bool {
+	switch c.Comparator {
+	case ComparatorEQ:
+		return platform == c.Value.(string)
+	case ComparatorNQ:
+		return platform != c.Value.(string)
+	}
+
+	in := false
+
+	for _, p := range c.Value.([]string) {
+		if p == platform {
+			in = true
+			break
+		}
+	}
+
+	return in == (c.Comparator == ComparatorIN)
+}
+
+// matchVersion includes both ends of a range.
+func matchVersion(c Criterion, version []int) bool {
+	if c.Comparator == ComparatorBetween {
+		var (
+			r       = c.Value.([]string)
+			from, _ = parseVersion(r[0])
+			to, _   = parseVersion(r[1])
+		)
+
+		return compareVersions(version, from) >= 0 && compareVersions(version, to) <= 0
+	}
+
+	expected, _ := parseVersion(c.Value.(string))
+	cmp := compareVersions(version, expected)
+
+	switch c.Comparator {
+	case ComparatorEQ:
+		return cmp == 0
+	case ComparatorNQ:
+		return cmp != 0
+	case ComparatorGT:
+		return cmp > 0
+	case ComparatorLT:
+		return cmp < 0
+	default:
+		return false
+	}
+}
+
+// parseVersion splits a dot separated version like "11.4.1" into its numeric
+// components.
+func parseVersion(s string) ([]int, error) {
+	if s == "" {
+		return nil, errors.New("version missing")
+	}
+
+	parts := strings.Split(s, ".")
+	version := make([]int, 0, len(parts))
+
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, errors.Errorf("version '%s' invalid", s)
+		}
+
+		version = append(version, n)
+	}
+
+	return version, nil
+}
+
+// compareVersions returns -1, 0 or 1 if a is lower, equal or greater than b.
+// Missing components count as zero, so "9" equals "9.0".
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+
+		if i < len(a) {
+			x = a[i]
+		}
+
+		if i < len(b) {
+			y = b[i]
+		}
+
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// PlatformParameters are parameter overrides keyed by platform.
+type PlatformParameters map[string]Parameters
+
+// Apply returns a copy of the parameters with the overrides of the platform
+// applied.
+func (p PlatformParameters) Apply(params Parameters, platform string) Parameters {
+	ps := Parameters{}
+
+	for k, v := range params {
+		ps[k] = v
+	}
+
+	for k, v := range p[platform] {
+		ps[k] = v
+	}
+
+	return ps
+}
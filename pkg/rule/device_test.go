@@ -0,0 +1,141 @@
+package rule
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+func TestCriterionDeviceMarshal(t *testing.T) {
+	for _, want := range []Criterion{
+		{
+			Comparator: ComparatorIN,
+			Key:        DeviceOSPlatform,
+			Value:      []string{PlatformIOS, PlatformWatchOS},
+		},
+		{
+			Comparator: ComparatorEQ,
+			Key:        DeviceOSPlatform,
+			Value:      PlatformAndroid,
+		},
+		{
+			Comparator: ComparatorBetween,
+			Key:        DeviceOSVersion,
+			Value:      []string{"11", "12.1"},
+		},
+	} {
+		raw, err := json.Marshal(&want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var have Criterion
+
+		err = json.Unmarshal(raw, &have)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
+
+func TestCriterionDeviceInvalid(t *testing.T) {
+	cases := map[string]error{
+		`{"comparator": 1, "key": 103, "value": "Symbian"}`:          errors.ErrInvalidRule,
+		`{"comparator": 0, "key": 103, "value": "iOS"}`:              errors.ErrInvalidRule,
+		`{"comparator": 3, "key": 103, "value": []}`:                 errors.ErrInvalidRule,
+		`{"comparator": 3, "key": 103, "value": "iOS"}`:              errors.ErrInvalidTypeToMatch,
+		`{"comparator": 0, "key": 104, "value": "11.beta"}`:          errors.ErrInvalidRule,
+		`{"comparator": 7, "key": 104, "value": ["12", "11.4"]}`:     errors.ErrInvalidRule,
+		`{"comparator": 3, "key": 104, "value": ["11", "12"]}`:       errors.ErrInvalidRule,
+		`{"comparator": 0, "key": 104, "value": 11}`:                 errors.ErrInvalidTypeToMatch,
+		`{"comparator": 7, "key": 104, "value": ["11", "12", "13"]}`: errors.ErrInvalidRule,
+	}
+
+	for input, want := range cases {
+		err := json.Unmarshal([]byte(input), &Criterion{})
+		if have := errors.Cause(err); have != want {
+			t.Errorf("%s: have %v, want %v", input, have, want)
+		}
+	}
+}
+
+func TestCriterionDeviceMatch(t *testing.T) {
+	var (
+		apple   = Criterion{ComparatorIN, DeviceOSPlatform, []string{PlatformIOS, PlatformWatchOS}, ""}
+		android = Criterion{ComparatorEQ, DeviceOSPlatform, PlatformAndroid, ""}
+		notIOS  = Criterion{ComparatorNotIN, DeviceOSPlatform, []string{PlatformIOS}, ""}
+		modern  = Criterion{ComparatorGT, DeviceOSVersion, "11.2", ""}
+		range11 = Criterion{ComparatorBetween, DeviceOSVersion, []string{"11", "11.4"}, ""}
+		exact   = Criterion{ComparatorEQ, DeviceOSVersion, "9", ""}
+	)
+
+	cases := []struct {
+		criterion Criterion
+		device    ContextDevice
+		match     bool
+	}{
+		{apple, ContextDevice{Platform: PlatformIOS, OSVersion: "11.2"}, true},
+		{apple, ContextDevice{Platform: PlatformAndroid, OSVersion: "8.1"}, false},
+		{apple, ContextDevice{}, false},
+		{android, ContextDevice{Platform: PlatformAndroid, OSVersion: "8.1"}, true},
+		{notIOS, ContextDevice{Platform: PlatformWatchOS, OSVersion: "4.0"}, true},
+		{notIOS, ContextDevice{Platform: "", OSVersion: ""}, false},
+		{modern, ContextDevice{Platform: PlatformIOS, OSVersion: "11.2.1"}, true},
+		{modern, ContextDevice{Platform: PlatformIOS, OSVersion: "11.2"}, false},
+		{modern, ContextDevice{Platform: PlatformIOS, OSVersion: "11.10"}, true},
+		{modern, ContextDevice{Platform: PlatformIOS, OSVersion: "beta"}, false},
+		{range11, ContextDevice{Platform: PlatformIOS, OSVersion: "11.4"}, true},
+		{range11, ContextDevice{Platform: PlatformIOS, OSVersion: "11.4.1"}, false},
+		{range11, ContextDevice{Platform: PlatformIOS, OSVersion: "10.3"}, false},
+		{exact, ContextDevice{Platform: PlatformIOS, OSVersion: "9.0.0"}, true},
+	}
+
+	for _, c := range cases {
+		err := c.criterion.match(Context{Device: c.device})
+
+		switch {
+		case c.match && err != nil:
+			t.Errorf("%v %v: have %v, want match", c.criterion, c.device, err)
+		case !c.match && errors.Cause(err) != errors.ErrCriterionNotMatch:
+			t.Errorf("%v %v: have %v, want %v", c.criterion, c.device, err, errors.ErrCriterionNotMatch)
+		}
+	}
+}
+
+func TestPlatformParametersApply(t *testing.T) {
+	var (
+		base = Parameters{
+			"feature_paywall_enabled": true,
+			"feature_paywall_price":   float64(5),
+		}
+		platforms = PlatformParameters{
+			PlatformAndroid: {
+				"feature_paywall_price": float64(6),
+			},
+		}
+	)
+
+	have := platforms.Apply(base, PlatformAndroid)
+	want := Parameters{
+		"feature_paywall_enabled": true,
+		"feature_paywall_price":   float64(6),
+	}
+
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := platforms.Apply(base, PlatformIOS), base; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := base["feature_paywall_price"], float64(5); have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
@@ -3,6 +3,7 @@ package rule
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"sort"
 	"time"
@@ -134,15 +135,11 @@ func (r ResponseParameter) MarshalJSON() ([]byte, error) {
 		Value: r.Value,
 	}
 
-	switch r.Value.(type) {
-	case bool:
-		v.Type = "bool"
-	case float64:
-		v.Type = "number"
-	case string:
-		v.Type = "string"
-	default:
+	switch t := TypeOf(r.Value); t {
+	case "":
 		v.Type = "unknown"
+	default:
+		v.Type = string(t)
 	}
 
 	return json.Marshal(v)
@@ -163,6 +160,12 @@ func (r ResponseParameters) Swap(i, j int) {
 	r[i], r[j] = r[j], r[i]
 }
 
+type responseLayer struct {
+	Name   string `json:"name"`
+	Offset uint8  `json:"offset"`
+	Share  uint8  `json:"share"`
+}
+
 type responseRule struct {
 	rule Rule
 }
@@ -174,9 +177,23 @@ func (r *responseRule) MarshalJSON() ([]byte, error) {
 		bs = append(bs, responseBucket{bucket: b})
 	}
 
+	var l *responseLayer
+
+	if r.rule.layer.Name != "" {
+		rl := responseLayer(r.rule.layer)
+		l = &rl
+	}
+
+	var b *Bucketing
+
+	if r.rule.bucketing.Key != "" {
+		b = &r.rule.bucketing
+	}
+
 	return json.Marshal(struct {
 		Active      bool             `json:"active"`
 		ActivatedAt time.Time        `json:"activated_at"`
+		Bucketing   *Bucketing       `json:"bucketing,omitempty"`
 		Buckets     []responseBucket `json:"buckets"`
 		ConfigID    string           `json:"config_id"`
 		CreatedAt   string           `json:"created_at"`
@@ -184,15 +201,20 @@ func (r *responseRule) MarshalJSON() ([]byte, error) {
 		Description string           `json:"description"`
 		Deleted     bool             `json:"deleted"`
 		EndTime     time.Time        `json:"end_time"`
+		Generation  int              `json:"generation"`
 		ID          string           `json:"id"`
 		Kind        Kind             `json:"kind"`
+		Layer       *responseLayer   `json:"layer,omitempty"`
 		Name        string           `json:"name"`
+		Reshuffle   bool             `json:"reshuffle"`
 		Rollout     uint8            `json:"rollout"`
 		StartTime   time.Time        `json:"start_time"`
+		Sticky      bool             `json:"sticky"`
 		UpdatedAt   time.Time        `json:"updated_at"`
 	}{
 		Active:      r.rule.active,
 		ActivatedAt: r.rule.activatedAt,
+		Bucketing:   b,
 		Buckets:     bs,
 		ConfigID:    r.rule.configID,
 		CreatedAt:   r.rule.createdAt.Format(time.RFC3339Nano),
@@ -200,11 +222,15 @@ func (r *responseRule) MarshalJSON() ([]byte, error) {
 		Description: r.rule.description,
 		Deleted:     r.rule.deleted,
 		EndTime:     r.rule.endTime,
+		Generation:  r.rule.generation,
 		ID:          r.rule.ID,
 		Kind:        r.rule.kind,
+		Layer:       l,
 		Name:        r.rule.name,
+		Reshuffle:   r.rule.reshuffle,
 		Rollout:     r.rule.rollout,
 		StartTime:   r.rule.startTime,
+		Sticky:      r.rule.sticky,
 		UpdatedAt:   r.rule.updatedAt,
 	})
 }
@@ -213,6 +239,7 @@ func (r *responseRule) UnmarshalJSON(raw []byte) error {
 	v := struct {
 		Active      bool             `json:"active"`
 		ActivatedAt time.Time        `json:"activated_at"`
+		Bucketing   *Bucketing       `json:"bucketing,omitempty"`
 		Buckets     []responseBucket `json:"buckets"`
 		ConfigID    string           `json:"config_id"`
 		CreatedAt   string           `json:"created_at"`
@@ -220,11 +247,15 @@ func (r *responseRule) UnmarshalJSON(raw []byte) error {
 		Description string           `json:"description"`
 		Deleted     bool             `json:"deleted"`
 		EndTime     time.Time        `json:"end_time"`
+		Generation  int              `json:"generation"`
 		ID          string           `json:"id"`
 		Kind        Kind             `json:"kind"`
+		Layer       *responseLayer   `json:"layer,omitempty"`
 		Name        string           `json:"name"`
+		Reshuffle   bool             `json:"reshuffle"`
 		Rollout     uint8            `json:"rollout"`
 		StartTime   time.Time        `json:"start_time"`
+		Sticky      bool             `json:"sticky"`
 		UpdatedAt   time.Time        `json:"updated_at"`
 	}{}
 
@@ -238,20 +269,37 @@ func (r *responseRule) UnmarshalJSON(raw []byte) error {
 		bs = append(bs, rb.bucket)
 	}
 
+	l := Layer{}
+
+	if v.Layer != nil {
+		l = Layer(*v.Layer)
+	}
+
+	b := Bucketing{}
+
+	if v.Bucketing != nil {
+		b = *v.Bucketing
+	}
+
 	r.rule = Rule{
 		active:      v.Active,
 		activatedAt: v.ActivatedAt,
+		bucketing:   b,
 		buckets:     bs,
 		configID:    v.ConfigID,
 		criteria:    v.Criteria,
 		description: v.Description,
 		deleted:     v.Deleted,
 		endTime:     v.EndTime,
+		generation:  v.Generation,
 		ID:          v.ID,
 		kind:        v.Kind,
+		layer:       l,
 		name:        v.Name,
+		reshuffle:   v.Reshuffle,
 		rollout:     v.Rollout,
 		startTime:   v.StartTime,
+		sticky:      v.Sticky,
 		updatedAt:   v.UpdatedAt,
 	}
 
@@ -313,6 +361,590 @@ func listEndpoint(svc Service) endpoint.Endpoint {
 	}
 }
 
+type guardrailJSON struct {
+	RuleID     string              `json:"rule_id"`
+	Metric     string              `json:"metric"`
+	Threshold  float64             `json:"threshold"`
+	Comparison GuardrailComparison `json:"comparison"`
+	MinSamples int                 `json:"min_samples"`
+	Armed      bool                `json:"armed"`
+	BreachedAt *time.Time          `json:"breached_at,omitempty"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+}
+
+func guardrailToJSON(g Guardrail) guardrailJSON {
+	v := guardrailJSON{
+		RuleID:     g.RuleID,
+		Metric:     g.Metric,
+		Threshold:  g.Threshold,
+		Comparison: g.Comparison,
+		MinSamples: g.minSamples(),
+		Armed:      g.Armed(),
+		CreatedAt:  g.CreatedAt,
+		UpdatedAt:  g.UpdatedAt,
+	}
+
+	if !g.Armed() {
+		v.BreachedAt = &g.BreachedAt
+	}
+
+	return v
+}
+
+type guardrailMetricJSON struct {
+	Metric    string    `json:"metric"`
+	Group     Group     `json:"group"`
+	Count     int       `json:"count"`
+	Sum       float64   `json:"sum"`
+	Mean      float64   `json:"mean"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func guardrailMetricToJSON(m GuardrailMetric) guardrailMetricJSON {
+	return guardrailMetricJSON{
+		Metric:    m.Metric,
+		Group:     m.Group,
+		Count:     m.Count,
+		Sum:       m.Sum,
+		Mean:      m.Mean(),
+		UpdatedAt: m.UpdatedAt,
+	}
+}
+
+type responseBreach struct {
+	breach GuardrailBreach
+}
+
+func (r responseBreach) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Guardrail  guardrailJSON       `json:"guardrail"`
+		Control    guardrailMetricJSON `json:"control"`
+		Rollout    guardrailMetricJSON `json:"rollout"`
+		Difference float64             `json:"difference"`
+		CreatedAt  time.Time           `json:"created_at"`
+	}{
+		Guardrail:  guardrailToJSON(r.breach.Guardrail),
+		Control:    guardrailMetricToJSON(r.breach.Control),
+		Rollout:    guardrailMetricToJSON(r.breach.Rollout),
+		Difference: r.breach.Difference,
+		CreatedAt:  r.breach.CreatedAt,
+	})
+}
+
+type responseGuardrail struct {
+	guardrail Guardrail
+	metrics   []GuardrailMetric
+}
+
+func (r responseGuardrail) MarshalJSON() ([]byte, error) {
+	ms := []guardrailMetricJSON{}
+
+	for _, m := range r.metrics {
+		ms = append(ms, guardrailMetricToJSON(m))
+	}
+
+	return json.Marshal(struct {
+		guardrailJSON
+		Metrics []guardrailMetricJSON `json:"metrics"`
+	}{
+		guardrailJSON: guardrailToJSON(r.guardrail),
+		Metrics:       ms,
+	})
+}
+
+type guardrailGetRequest struct {
+	ruleID string
+}
+
+func guardrailGetEndpoint(svc GuardrailService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(guardrailGetRequest)
+
+		g, ms, err := svc.Get(req.ruleID)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseGuardrail{guardrail: g, metrics: ms}, nil
+	}
+}
+
+type guardrailIngestRequest struct {
+	events []GuardrailEvent
+	ruleID string
+}
+
+type guardrailIngestResponse struct{}
+
+func (r guardrailIngestResponse) StatusCode() int {
+	return http.StatusNoContent
+}
+
+func guardrailIngestEndpoint(svc GuardrailService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(guardrailIngestRequest)
+
+		return guardrailIngestResponse{}, svc.Ingest(req.ruleID, req.events)
+	}
+}
+
+type guardrailSetRequest struct {
+	comparison GuardrailComparison
+	metric     string
+	minSamples int
+	ruleID     string
+	threshold  float64
+}
+
+func guardrailSetEndpoint(svc GuardrailService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(guardrailSetRequest)
+
+		g, err := svc.Set(req.ruleID, req.metric, req.threshold, req.comparison, req.minSamples)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseGuardrail{guardrail: g, metrics: []GuardrailMetric{}}, nil
+	}
+}
+
+type scheduleGetRequest struct {
+	ruleID string
+}
+
+type responseSchedule struct {
+	records  []ScheduleRecord
+	schedule Schedule
+}
+
+func (r responseSchedule) MarshalJSON() ([]byte, error) {
+	type step struct {
+		Percentage uint8     `json:"percentage"`
+		At         time.Time `json:"at"`
+	}
+
+	type record struct {
+		ID        string        `json:"id"`
+		Event     ScheduleEvent `json:"event"`
+		Step      int           `json:"step"`
+		Rollout   uint8         `json:"rollout"`
+		CreatedAt time.Time     `json:"created_at"`
+	}
+
+	v := struct {
+		RuleID    string        `json:"rule_id"`
+		Steps     []step        `json:"steps"`
+		Applied   int           `json:"applied"`
+		State     ScheduleState `json:"state"`
+		Records   []record      `json:"records"`
+		CreatedAt time.Time     `json:"created_at"`
+		UpdatedAt time.Time     `json:"updated_at"`
+	}{
+		RuleID:    r.schedule.RuleID,
+		Steps:     []step{},
+		Applied:   r.schedule.Applied,
+		State:     r.schedule.State,
+		Records:   []record{},
+		CreatedAt: r.schedule.CreatedAt,
+		UpdatedAt: r.schedule.UpdatedAt,
+	}
+
+	for _, s := range r.schedule.Steps {
+		v.Steps = append(v.Steps, step(s))
+	}
+
+	for _, rec := range r.records {
+		v.Records = append(v.Records, record{
+			ID:        rec.ID,
+			Event:     rec.Event,
+			Step:      rec.Step,
+			Rollout:   rec.Rollout,
+			CreatedAt: rec.CreatedAt,
+		})
+	}
+
+	return json.Marshal(v)
+}
+
+func scheduleGetEndpoint(svc ScheduleService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(scheduleGetRequest)
+
+		s, recs, err := svc.Get(req.ruleID)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseSchedule{records: recs, schedule: s}, nil
+	}
+}
+
+type scheduleSetRequest struct {
+	ruleID string
+	steps  []ScheduleStep
+}
+
+func scheduleSetEndpoint(svc ScheduleService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(scheduleSetRequest)
+
+		s, err := svc.Set(req.ruleID, req.steps)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseSchedule{records: []ScheduleRecord{}, schedule: s}, nil
+	}
+}
+
+type scheduleStateRequest struct {
+	ruleID string
+	state  ScheduleState
+}
+
+type scheduleStateResponse struct{}
+
+func (r scheduleStateResponse) StatusCode() int {
+	return http.StatusNoContent
+}
+
+func scheduleStateEndpoint(svc ScheduleService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(scheduleStateRequest)
+
+		var err error
+
+		switch req.state {
+		case ScheduleAborted:
+			err = svc.Abort(req.ruleID)
+		case SchedulePaused:
+			err = svc.Pause(req.ruleID)
+		default:
+			err = svc.Resume(req.ruleID)
+		}
+
+		return scheduleStateResponse{}, err
+	}
+}
+
+type segmentJSON struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Criteria    Criteria  `json:"criteria"`
+	Version     int       `json:"version"`
+	Deleted     bool      `json:"deleted"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func segmentToJSON(s Segment) segmentJSON {
+	return segmentJSON{
+		ID:          s.ID,
+		Name:        s.Name,
+		Description: s.Description,
+		Criteria:    s.Criteria,
+		Version:     s.Version,
+		Deleted:     s.Deleted,
+		CreatedAt:   s.CreatedAt,
+	}
+}
+
+type responseSegment struct {
+	segment Segment
+	status  int
+}
+
+func (r responseSegment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(segmentToJSON(r.segment))
+}
+
+func (r responseSegment) StatusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+
+	return r.status
+}
+
+type responseSegmentList struct {
+	segments []Segment
+}
+
+func (r responseSegmentList) MarshalJSON() ([]byte, error) {
+	ss := []segmentJSON{}
+
+	for _, s := range r.segments {
+		ss = append(ss, segmentToJSON(s))
+	}
+
+	return json.Marshal(struct {
+		Segments []segmentJSON `json:"segments"`
+	}{
+		Segments: ss,
+	})
+}
+
+type segmentCreateRequest struct {
+	criteria    Criteria
+	description string
+	name        string
+}
+
+func segmentCreateEndpoint(svc SegmentService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(segmentCreateRequest)
+
+		s, err := svc.Create(req.name, req.description, req.criteria)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseSegment{segment: s, status: http.StatusCreated}, nil
+	}
+}
+
+type segmentDeleteRequest struct {
+	id string
+}
+
+type segmentDeleteResponse struct{}
+
+func (r segmentDeleteResponse) StatusCode() int {
+	return http.StatusNoContent
+}
+
+func segmentDeleteEndpoint(svc SegmentService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(segmentDeleteRequest)
+
+		return segmentDeleteResponse{}, svc.Delete(req.id)
+	}
+}
+
+type segmentGetRequest struct {
+	id string
+}
+
+func segmentGetEndpoint(svc SegmentService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(segmentGetRequest)
+
+		s, err := svc.Get(req.id)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseSegment{segment: s}, nil
+	}
+}
+
+func segmentListEndpoint(svc SegmentService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		ss, err := svc.List()
+		if err != nil {
+			return nil, err
+		}
+
+		return responseSegmentList{segments: ss}, nil
+	}
+}
+
+type segmentUpdateRequest struct {
+	criteria    Criteria
+	description string
+	id          string
+	name        string
+}
+
+func segmentUpdateEndpoint(svc SegmentService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(segmentUpdateRequest)
+
+		s, err := svc.Update(req.id, req.name, req.description, req.criteria)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseSegment{segment: s}, nil
+	}
+}
+
+func segmentUsageEndpoint(svc SegmentService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(segmentGetRequest)
+
+		rs, err := svc.Usage(req.id)
+		if err != nil {
+			return nil, err
+		}
+
+		return &responseList{rules: rs}, nil
+	}
+}
+
+func segmentVersionsEndpoint(svc SegmentService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(segmentGetRequest)
+
+		ss, err := svc.Versions(req.id)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseSegmentList{segments: ss}, nil
+	}
+}
+
+type userListJSON struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Size        int       `json:"size"`
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type responseUserList struct {
+	list   UserList
+	status int
+}
+
+func (r responseUserList) MarshalJSON() ([]byte, error) {
+	return json.Marshal(userListJSON(r.list))
+}
+
+func (r responseUserList) StatusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+
+	return r.status
+}
+
+type responseUserLists struct {
+	lists []UserList
+}
+
+func (r responseUserLists) MarshalJSON() ([]byte, error) {
+	ls := []userListJSON{}
+
+	for _, l := range r.lists {
+		ls = append(ls, userListJSON(l))
+	}
+
+	return json.Marshal(struct {
+		Lists []userListJSON `json:"lists"`
+	}{
+		Lists: ls,
+	})
+}
+
+type userListCreateRequest struct {
+	description string
+	name        string
+}
+
+func userListCreateEndpoint(svc UserListService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(userListCreateRequest)
+
+		l, err := svc.Create(req.name, req.description)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseUserList{list: l, status: http.StatusCreated}, nil
+	}
+}
+
+type userListGetRequest struct {
+	id string
+}
+
+func userListGetEndpoint(svc UserListService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(userListGetRequest)
+
+		l, err := svc.Get(req.id)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseUserList{list: l}, nil
+	}
+}
+
+func userListListEndpoint(svc UserListService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		ls, err := svc.List()
+		if err != nil {
+			return nil, err
+		}
+
+		return responseUserLists{lists: ls}, nil
+	}
+}
+
+type userListUploadRequest struct {
+	id      string
+	members io.Reader
+}
+
+func userListUploadEndpoint(svc UserListService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(userListUploadRequest)
+
+		l, err := svc.Upload(req.id, req.members)
+		if err != nil {
+			return nil, err
+		}
+
+		return responseUserList{list: l}, nil
+	}
+}
+
+type updateBucketingRequest struct {
+	id        string
+	bucketing Bucketing
+}
+
+type updateBucketingResponse struct{}
+
+func (r updateBucketingResponse) StatusCode() int {
+	return http.StatusNoContent
+}
+
+func updateBucketingEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(updateBucketingRequest)
+
+		return updateBucketingResponse{}, svc.UpdateBucketing(req.id, req.bucketing)
+	}
+}
+
+type updateLayerRequest struct {
+	id    string
+	layer string
+	share uint8
+}
+
+type updateLayerResponse struct{}
+
+func (r updateLayerResponse) StatusCode() int {
+	return http.StatusNoContent
+}
+
+func updateLayerEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(updateLayerRequest)
+
+		return updateLayerResponse{}, svc.UpdateLayer(req.id, req.layer, req.share)
+	}
+}
+
 type updateRolloutRequest struct {
 	id      string
 	rollout uint8
@@ -331,3 +963,23 @@ func updateRolloutEndpoint(svc Service) endpoint.Endpoint {
 		return updateRolloutResponse{}, svc.UpdateRollout(req.id, req.rollout)
 	}
 }
+
+type updateStickinessRequest struct {
+	id        string
+	reshuffle bool
+	sticky    bool
+}
+
+type updateStickinessResponse struct{}
+
+func (r updateStickinessResponse) StatusCode() int {
+	return http.StatusNoContent
+}
+
+func updateStickinessEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(updateStickinessRequest)
+
+		return updateStickinessResponse{}, svc.UpdateStickiness(req.id, req.sticky, req.reshuffle)
+	}
+}
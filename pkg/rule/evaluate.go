@@ -0,0 +1,115 @@
+package rule
+
+import (
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/generate"
+)
+
+// DiceFunc returns the source of dice rolls for percentage based decisions of
+// the given rule and user.
+type DiceFunc func(ruleID, userID string) generate.RandPercentageFunc
+
+// HashDice derives the dice roll from rule and user id, so the same user
+// always lands in the same bucket without the need to store the decision.
+func HashDice(ruleID, userID string) generate.RandPercentageFunc {
+	return generate.HashPercentage(ruleID, userID)
+}
+
+// RandDice uses the given random function for all dice rolls.
+func RandDice(randFn generate.RandPercentageFunc) DiceFunc {
+	return func(string, string) generate.RandPercentageFunc {
+		return randFn
+	}
+}
+
+// Evaluate applies the given rules in order of creation to a copy of the base
+// parameters, rules with prerequisites follow the rules they depend on.
+// Previous decisions are reused for percentage based rules unless the rule was
+// reshuffled since, new dice rolls are obtained from dice for the bucketing key
+// of the rule. Rules in a layer only apply if the position of the user in the
+// layer falls into their share, the position is kept among the decisions. It
+// returns the rendered parameters and the decisions taken.
+func Evaluate(
+	base Parameters,
+	rules []Rule,
+	ctx Context,
+	previous Decisions,
+	dice DiceFunc,
+) (Parameters, Decisions, error) {
+	var (
+		decisions = Decisions{}
+		params    = Parameters{}
+		applied   = map[string]string{}
+	)
+
+	for k, v := range base {
+		params[k] = v
+	}
+
+	// Cycles are rejected when rules are stored, should one slip through its
+	// rules are still evaluated in order of creation.
+	rs, _ := orderRules(rules)
+
+	for _, r := range rs {
+		if r.layer.Name != "" {
+			key := r.layer.decisionKey()
+
+			if _, ok := decisions[key]; !ok {
+				position := previous[key]
+
+				if len(position) == 0 {
+					position = []int{r.layer.position(r.configID, ctx.User.ID)}
+				}
+
+				decisions[key] = position
+			}
+
+			if !r.layer.contains(decisions[key][0]) {
+				continue
+			}
+		}
+
+		ctx.params = params
+		ctx.applied = applied
+
+		id, key := r.decisionKey(ctx)
+
+		roll := dice
+		if key != r.ID {
+			// Decisions are kept per user, members sharing the bucketing key
+			// only land in the same bucket if their dice are derived from it.
+			roll = HashDice
+		}
+
+		pm, d, err := r.Run(params, ctx, previous[key], roll(r.diceKey(), id))
+		if err != nil {
+			switch errors.Cause(err) {
+			case errors.ErrCriterionNotMatch:
+				continue
+			case errors.ErrRuleNotInRollout:
+				decisions[key] = d
+				continue
+			default:
+				return nil, nil, errors.Wrapf(err, "rule '%s'", r.ID)
+			}
+		}
+
+		if len(d) > 0 {
+			decisions[key] = d
+		}
+
+		applied[r.name] = r.appliedBucket(d)
+		params = pm
+	}
+
+	// Sticky decisions outlive renders in which their rule is skipped, e.g.
+	// because its criteria don't match or it is paused, so users who were in
+	// are not rolled again once the rule applies to them anew.
+	for key, d := range previous {
+		if _, ok := decisions[key]; !ok && parseDecision(d).sticky {
+			decisions[key] = d
+		}
+	}
+
+	return params, decisions, nil
+}
@@ -0,0 +1,570 @@
+package rule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// Supported comparisons of a guardrail.
+const (
+	// GuardrailIncrease is breached when the mean of the rollout group
+	// exceeds the mean of the control group by more than the threshold, e.g.
+	// for crash or error rates.
+	GuardrailIncrease GuardrailComparison = "increase"
+	// GuardrailDecrease is breached when the mean of the rollout group falls
+	// below the mean of the control group by more than the threshold, e.g. for
+	// conversion rates.
+	GuardrailDecrease GuardrailComparison = "decrease"
+)
+
+// Groups a guardrail metric is collected for.
+const (
+	GroupControl Group = "control"
+	GroupRollout Group = "rollout"
+)
+
+// DefaultGuardrailMinSamples is the number of samples both groups need before
+// a guardrail without an explicit minimum is evaluated.
+const DefaultGuardrailMinSamples = 100
+
+// Labels identifying the rule and group of metrics scraped from the
+// Prometheus text format.
+const (
+	labelGroup  = "group"
+	labelRuleID = "rule"
+)
+
+// GuardrailComparison defines in which direction a metric must not move.
+type GuardrailComparison string
+
+// Group distinguishes users in the rollout of a rule from the ones outside of
+// it.
+type Group string
+
+func (g Group) validate() error {
+	switch g {
+	case GroupControl, GroupRollout:
+		return nil
+	default:
+		return errors.Wrapf(errors.ErrInvalidPayload, "group '%s' not supported", g)
+	}
+}
+
+// Guardrail protects a rollout rule from degrading a metric. It is armed as
+// long as it has not been breached.
+type Guardrail struct {
+	RuleID     string
+	Metric     string
+	Threshold  float64
+	Comparison GuardrailComparison
+	// MinSamples is the number of samples required in each group before the
+	// guardrail is evaluated, DefaultGuardrailMinSamples if zero. Scraped
+	// counters and gauges carry a single value per group and are only taken
+	// for guardrails with an explicit minimum.
+	MinSamples int
+	BreachedAt time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Armed reports if the guardrail is still watching its rule.
+func (g Guardrail) Armed() bool {
+	return g.BreachedAt.IsZero()
+}
+
+func (g Guardrail) minSamples() int {
+	if g.MinSamples == 0 {
+		return DefaultGuardrailMinSamples
+	}
+
+	return g.MinSamples
+}
+
+// evaluate compares the metric of the rollout and control group and returns
+// the breach if the difference exceeds the threshold. Guardrails are not
+// breached until both groups reached the minimum number of samples.
+func (g Guardrail) evaluate(ms []GuardrailMetric) (GuardrailBreach, bool) {
+	var control, rollout GuardrailMetric
+
+	for _, m := range ms {
+		if m.Metric != g.Metric {
+			continue
+		}
+
+		switch m.Group {
+		case GroupControl:
+			control = m
+		case GroupRollout:
+			rollout = m
+		}
+	}
+
+	if min := g.minSamples(); control.Count < min || rollout.Count < min {
+		return GuardrailBreach{}, false
+	}
+
+	diff := rollout.Mean() - control.Mean()
+
+	if g.Comparison == GuardrailDecrease {
+		diff = -diff
+	}
+
+	if diff <= g.Threshold {
+		return GuardrailBreach{}, false
+	}
+
+	return GuardrailBreach{
+		Guardrail:  g,
+		Control:    control,
+		Difference: diff,
+		Rollout:    rollout,
+	}, true
+}
+
+func (g Guardrail) validate() error {
+	if g.Metric == "" {
+		return errors.Wrap(errors.ErrGuardrailInvalid, "metric missing")
+	}
+
+	if g.Threshold < 0 {
+		return errors.Wrap(errors.ErrGuardrailInvalid, "threshold negative")
+	}
+
+	if g.MinSamples < 0 {
+		return errors.Wrap(errors.ErrGuardrailInvalid, "min samples negative")
+	}
+
+	switch g.Comparison {
+	case GuardrailDecrease, GuardrailIncrease:
+		return nil
+	default:
+		return errors.Wrapf(
+			errors.ErrGuardrailInvalid,
+			"comparison '%s' not supported",
+			g.Comparison,
+		)
+	}
+}
+
+// GuardrailMetric is the aggregate of all values collected for a metric of a
+// rule in one group.
+type GuardrailMetric struct {
+	RuleID    string
+	Metric    string
+	Group     Group
+	Count     int
+	Sum       float64
+	UpdatedAt time.Time
+
+	// point is set for scraped counters, gauges and untyped metrics, whose
+	// count is not a number of samples.
+	point bool
+}
+
+// Mean returns the average of the collected values.
+func (m GuardrailMetric) Mean() float64 {
+	if m.Count == 0 {
+		return 0
+	}
+
+	return m.Sum / float64(m.Count)
+}
+
+// GuardrailEvent is a single value of a metric observed in a group.
+type GuardrailEvent struct {
+	Metric string
+	Group  Group
+	Value  float64
+}
+
+// GuardrailBreach is the evidence for the automatic deactivation of a rule.
+type GuardrailBreach struct {
+	Guardrail  Guardrail
+	Control    GuardrailMetric
+	Difference float64
+	Rollout    GuardrailMetric
+	CreatedAt  time.Time
+}
+
+// GuardrailNotifier emits notifications about breached guardrails.
+type GuardrailNotifier interface {
+	Notify(b GuardrailBreach) error
+}
+
+type webhookNotifier struct {
+	client *http.Client
+	url    string
+}
+
+// NewGuardrailWebhookNotifier posts the evidence of breaches as JSON to the
+// given URL.
+func NewGuardrailWebhookNotifier(client *http.Client, url string) GuardrailNotifier {
+	return &webhookNotifier{
+		client: client,
+		url:    url,
+	}
+}
+
+func (n *webhookNotifier) Notify(b GuardrailBreach) error {
+	raw, err := json.Marshal(responseBreach{breach: b})
+	if err != nil {
+		return errors.Wrap(err, "marshal breach")
+	}
+
+	res, err := n.client.Post(n.url, "application/json", bytes.NewReader(raw))
+	if err != nil {
+		return errors.Wrap(err, "post breach")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("post breach: unexpected status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// GuardrailService manages guardrails of rollout rules and deactivates the
+// rules once their guardrail is breached.
+type GuardrailService interface {
+	// Check evaluates all armed guardrails and deactivates the rules of the
+	// breached ones.
+	Check(now time.Time) ([]GuardrailBreach, error)
+	Get(ruleID string) (Guardrail, []GuardrailMetric, error)
+	// Ingest adds the events to the metric of the guardrail of the rule.
+	Ingest(ruleID string, events []GuardrailEvent) error
+	// Scrape replaces the metrics of armed guardrails with the samples found
+	// in the Prometheus text format and returns the number of samples taken.
+	// Single value samples of guardrails without an explicit minimum number
+	// of samples are skipped and reported with ErrGuardrailInvalid.
+	Scrape(r io.Reader) (int, error)
+	Set(
+		ruleID, metric string,
+		threshold float64,
+		comparison GuardrailComparison,
+		minSamples int,
+	) (Guardrail, error)
+}
+
+type guardrailService struct {
+	guardrailRepo GuardrailRepo
+	notifier      GuardrailNotifier
+	svc           Service
+}
+
+// NewGuardrailService provides guardrails for rules of kind rollout. Rules are
+// deactivated through the given Service.
+func NewGuardrailService(
+	svc Service,
+	guardrailRepo GuardrailRepo,
+	notifier GuardrailNotifier,
+) GuardrailService {
+	return &guardrailService{
+		guardrailRepo: guardrailRepo,
+		notifier:      notifier,
+		svc:           svc,
+	}
+}
+
+func (s *guardrailService) Check(now time.Time) ([]GuardrailBreach, error) {
+	gs, err := s.guardrailRepo.ListArmed()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		bs       = []GuardrailBreach{}
+		firstErr error
+	)
+
+	for _, g := range gs {
+		b, ok, err := s.check(g, now)
+		if ok {
+			bs = append(bs, b)
+		}
+
+		if err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "check guardrail of rule '%s'", g.RuleID)
+		}
+	}
+
+	return bs, firstErr
+}
+
+func (s *guardrailService) Get(ruleID string) (Guardrail, []GuardrailMetric, error) {
+	g, err := s.guardrailRepo.Get(ruleID)
+	if err != nil {
+		return Guardrail{}, nil, err
+	}
+
+	ms, err := s.guardrailRepo.ListMetrics(ruleID)
+	if err != nil {
+		return Guardrail{}, nil, err
+	}
+
+	return g, ms, nil
+}
+
+func (s *guardrailService) Ingest(ruleID string, events []GuardrailEvent) error {
+	g, err := s.guardrailRepo.Get(ruleID)
+	if err != nil {
+		return err
+	}
+
+	ms := map[Group]GuardrailMetric{}
+
+	for _, e := range events {
+		if e.Metric != g.Metric {
+			return errors.Wrapf(
+				errors.ErrInvalidPayload,
+				"metric '%s' not guarded",
+				e.Metric,
+			)
+		}
+
+		if err := e.Group.validate(); err != nil {
+			return err
+		}
+
+		m := ms[e.Group]
+		m.Count++
+		m.Sum += e.Value
+		ms[e.Group] = m
+	}
+
+	now := time.Now().UTC()
+
+	for _, group := range []Group{GroupControl, GroupRollout} {
+		m, ok := ms[group]
+		if !ok {
+			continue
+		}
+
+		m.RuleID = ruleID
+		m.Metric = g.Metric
+		m.Group = group
+		m.UpdatedAt = now
+
+		if err := s.guardrailRepo.AddMetric(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *guardrailService) Scrape(r io.Reader) (int, error) {
+	ms, err := parseGuardrailMetrics(r)
+	if err != nil {
+		return 0, err
+	}
+
+	gs, err := s.guardrailRepo.ListArmed()
+	if err != nil {
+		return 0, err
+	}
+
+	guarded := map[string]Guardrail{}
+
+	for _, g := range gs {
+		guarded[g.RuleID] = g
+	}
+
+	var (
+		n       = 0
+		now     = time.Now().UTC()
+		skipped = []string{}
+	)
+
+	for _, m := range ms {
+		g, ok := guarded[m.RuleID]
+		if !ok || g.Metric != m.Metric {
+			continue
+		}
+
+		// A single value never reaches the default minimum, the guardrail
+		// would silently never be evaluated.
+		if m.point && g.MinSamples == 0 {
+			if !contains(skipped, m.RuleID) {
+				skipped = append(skipped, m.RuleID)
+			}
+
+			continue
+		}
+
+		m.UpdatedAt = now
+
+		if err := s.guardrailRepo.SetMetric(m); err != nil {
+			return n, err
+		}
+
+		n++
+	}
+
+	if len(skipped) > 0 {
+		return n, errors.Wrapf(
+			errors.ErrGuardrailInvalid,
+			"single value metrics need an explicit min_samples, skipped rules '%s'",
+			strings.Join(skipped, "', '"),
+		)
+	}
+
+	return n, nil
+}
+
+func (s *guardrailService) Set(
+	ruleID, metric string,
+	threshold float64,
+	comparison GuardrailComparison,
+	minSamples int,
+) (Guardrail, error) {
+	r, err := s.svc.GetByID(ruleID)
+	if err != nil {
+		return Guardrail{}, err
+	}
+
+	if r.kind != KindRollout {
+		return Guardrail{}, errors.Wrap(errors.ErrGuardrailInvalid, "rule is not of kind rollout")
+	}
+
+	now := time.Now().UTC()
+
+	g := Guardrail{
+		RuleID:     ruleID,
+		Metric:     metric,
+		Threshold:  threshold,
+		Comparison: comparison,
+		MinSamples: minSamples,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if err := g.validate(); err != nil {
+		return Guardrail{}, err
+	}
+
+	return s.guardrailRepo.Put(g)
+}
+
+func (s *guardrailService) check(g Guardrail, now time.Time) (GuardrailBreach, bool, error) {
+	r, err := s.svc.GetByID(g.RuleID)
+	if err != nil {
+		return GuardrailBreach{}, false, err
+	}
+
+	if !r.active {
+		return GuardrailBreach{}, false, nil
+	}
+
+	ms, err := s.guardrailRepo.ListMetrics(g.RuleID)
+	if err != nil {
+		return GuardrailBreach{}, false, err
+	}
+
+	b, ok := g.evaluate(ms)
+	if !ok {
+		return GuardrailBreach{}, false, nil
+	}
+
+	b.CreatedAt = now.UTC()
+	b.Guardrail.BreachedAt = b.CreatedAt
+
+	if err := s.svc.Deactivate(g.RuleID); err != nil {
+		return GuardrailBreach{}, false, err
+	}
+
+	if err := s.guardrailRepo.Breach(g.RuleID, b.CreatedAt); err != nil {
+		return b, true, err
+	}
+
+	if err := s.notifier.Notify(b); err != nil {
+		return b, true, errors.Wrap(err, "notify")
+	}
+
+	return b, true, nil
+}
+
+// parseGuardrailMetrics reads samples labeled with rule and group from the
+// Prometheus text format. Summaries and histograms contribute their count and
+// sum, all other types a single value.
+func parseGuardrailMetrics(r io.Reader) ([]GuardrailMetric, error) {
+	p := expfmt.TextParser{}
+
+	mfs, err := p.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, errors.Wrapf(errors.ErrInvalidPayload, "parse metrics: %s", err)
+	}
+
+	ms := []GuardrailMetric{}
+
+	for name, mf := range mfs {
+		for _, metric := range mf.GetMetric() {
+			m := GuardrailMetric{
+				Metric: name,
+			}
+
+			for _, l := range metric.GetLabel() {
+				switch l.GetName() {
+				case labelGroup:
+					m.Group = Group(l.GetValue())
+				case labelRuleID:
+					m.RuleID = l.GetValue()
+				}
+			}
+
+			if m.RuleID == "" || m.Group.validate() != nil {
+				continue
+			}
+
+			m.Count, m.Sum = sampleOf(mf.GetType(), metric)
+
+			switch mf.GetType() {
+			case dto.MetricType_HISTOGRAM, dto.MetricType_SUMMARY:
+			default:
+				m.point = true
+			}
+
+			ms = append(ms, m)
+		}
+	}
+
+	sort.Slice(ms, func(i, j int) bool {
+		if ms[i].RuleID != ms[j].RuleID {
+			return ms[i].RuleID < ms[j].RuleID
+		}
+
+		if ms[i].Metric != ms[j].Metric {
+			return ms[i].Metric < ms[j].Metric
+		}
+
+		return ms[i].Group < ms[j].Group
+	})
+
+	return ms, nil
+}
+
+func sampleOf(t dto.MetricType, m *dto.Metric) (int, float64) {
+	switch t {
+	case dto.MetricType_COUNTER:
+		return 1, m.GetCounter().GetValue()
+	case dto.MetricType_GAUGE:
+		return 1, m.GetGauge().GetValue()
+	case dto.MetricType_HISTOGRAM:
+		return int(m.GetHistogram().GetSampleCount()), m.GetHistogram().GetSampleSum()
+	case dto.MetricType_SUMMARY:
+		return int(m.GetSummary().GetSampleCount()), m.GetSummary().GetSampleSum()
+	default:
+		return 1, m.GetUntyped().GetValue()
+	}
+}
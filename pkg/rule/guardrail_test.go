@@ -0,0 +1,263 @@
+package rule
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid"
+
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/generate"
+)
+
+func TestGuardrailEvaluate(t *testing.T) {
+	var (
+		control = GuardrailMetric{Metric: "crashes", Group: GroupControl, Count: 100, Sum: 2}
+		rollout = GuardrailMetric{Metric: "crashes", Group: GroupRollout, Count: 100, Sum: 5}
+	)
+
+	for _, c := range []struct {
+		comparison GuardrailComparison
+		metrics    []GuardrailMetric
+		threshold  float64
+		want       bool
+	}{
+		{
+			comparison: GuardrailIncrease,
+			metrics:    []GuardrailMetric{control, rollout},
+			threshold:  0.01,
+			want:       true,
+		},
+		{
+			comparison: GuardrailIncrease,
+			metrics:    []GuardrailMetric{control, rollout},
+			threshold:  0.05,
+			want:       false,
+		},
+		{
+			comparison: GuardrailDecrease,
+			metrics:    []GuardrailMetric{control, rollout},
+			threshold:  0.01,
+			want:       false,
+		},
+		{
+			comparison: GuardrailIncrease,
+			metrics:    []GuardrailMetric{rollout},
+			threshold:  0,
+			want:       false,
+		},
+		{
+			comparison: GuardrailIncrease,
+			metrics: []GuardrailMetric{
+				control,
+				{Metric: "errors", Group: GroupRollout, Count: 1, Sum: 100},
+			},
+			threshold: 0,
+			want:      false,
+		},
+	} {
+		g := Guardrail{
+			Comparison: c.comparison,
+			Metric:     "crashes",
+			Threshold:  c.threshold,
+		}
+
+		b, have := g.evaluate(c.metrics)
+		if want := c.want; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		if !have {
+			continue
+		}
+
+		if have, want := b.Control, control; !reflect.DeepEqual(have, want) {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		if have, want := b.Rollout, rollout; !reflect.DeepEqual(have, want) {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
+
+func TestGuardrailEvaluateMinSamples(t *testing.T) {
+	var (
+		control = GuardrailMetric{Metric: "crashes", Group: GroupControl, Count: 1, Sum: 0}
+		rollout = GuardrailMetric{Metric: "crashes", Group: GroupRollout, Count: 1, Sum: 1}
+		g       = Guardrail{
+			Comparison: GuardrailIncrease,
+			Metric:     "crashes",
+		}
+	)
+
+	// A single sample per group is not enough evidence with the default.
+	if _, have := g.evaluate([]GuardrailMetric{control, rollout}); have {
+		t.Errorf("have %v, want %v", have, false)
+	}
+
+	control.Count, rollout.Count = 99, DefaultGuardrailMinSamples
+
+	if _, have := g.evaluate([]GuardrailMetric{control, rollout}); have {
+		t.Errorf("have %v, want %v", have, false)
+	}
+
+	control.Count = DefaultGuardrailMinSamples
+
+	if _, have := g.evaluate([]GuardrailMetric{control, rollout}); !have {
+		t.Errorf("have %v, want %v", have, true)
+	}
+
+	// An explicit minimum replaces the default.
+	g.MinSamples = 1
+	control.Count, rollout.Count = 1, 1
+
+	if _, have := g.evaluate([]GuardrailMetric{control, rollout}); !have {
+		t.Errorf("have %v, want %v", have, true)
+	}
+
+	g.MinSamples = -1
+
+	if have, want := errors.Cause(g.validate()), errors.ErrGuardrailInvalid; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestParseGuardrailMetrics(t *testing.T) {
+	var (
+		text = `# TYPE crashes gauge
+crashes{rule="r1",group="control"} 0.02
+crashes{rule="r1",group="rollout"} 0.05
+crashes{rule="r1",group="unknown"} 1
+crashes{group="rollout"} 1
+# TYPE latency summary
+latency_sum{rule="r2",group="rollout"} 12.5
+latency_count{rule="r2",group="rollout"} 5
+`
+		want = []GuardrailMetric{
+			{RuleID: "r1", Metric: "crashes", Group: GroupControl, Count: 1, Sum: 0.02, point: true},
+			{RuleID: "r1", Metric: "crashes", Group: GroupRollout, Count: 1, Sum: 0.05, point: true},
+			{RuleID: "r2", Metric: "latency", Group: GroupRollout, Count: 5, Sum: 12.5},
+		}
+	)
+
+	have, err := parseGuardrailMetrics(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestGuardrailServiceScrapeCounter(t *testing.T) {
+	var (
+		repo     = preparePGRepo(t)
+		notifier = &recordNotifier{}
+		svc      = NewGuardrailService(
+			NewService(repo),
+			preparePGGuardrailRepo(t),
+			notifier,
+		)
+		id, _   = ulid.New(ulid.Timestamp(time.Now()), seed)
+		rollout = uint8(50)
+		text    = fmt.Sprintf(`# TYPE crashes counter
+crashes{rule="%s",group="control"} 2
+crashes{rule="%s",group="rollout"} 9
+`, id.String(), id.String())
+	)
+
+	rule, err := New(
+		id.String(),
+		generate.RandomString(12),
+		generate.RandomString(12),
+		generate.RandomString(42),
+		KindRollout,
+		true,
+		nil,
+		[]Bucket{
+			{
+				Name: "default",
+				Parameters: Parameters{
+					"feature_funky_toggle": true,
+				},
+			},
+		},
+		&rollout,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Create(rule); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Set(id.String(), "crashes", 1, GuardrailIncrease, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := svc.Scrape(strings.NewReader(text))
+	if have, want := errors.Cause(err), errors.ErrGuardrailInvalid; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := n, 0; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	_, err = svc.Set(id.String(), "crashes", 1, GuardrailIncrease, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err = svc.Scrape(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := n, 2; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	bs, err := svc.Check(time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(bs), 1; have != want {
+		t.Fatalf("have %v, want %v", have, want)
+	}
+
+	if have, want := bs[0].Difference, 7.0; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := len(notifier.breaches), 1; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	r, err := repo.GetByID(id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := r.active, false; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+type recordNotifier struct {
+	breaches []GuardrailBreach
+}
+
+func (n *recordNotifier) Notify(b GuardrailBreach) error {
+	n.breaches = append(n.breaches, b)
+
+	return nil
+}
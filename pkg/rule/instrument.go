@@ -7,7 +7,11 @@ import (
 )
 
 const (
-	labelRuleRepo = "rule"
+	labelGuardrailRepo = "guardrail"
+	labelRuleRepo      = "rule"
+	labelScheduleRepo  = "schedule"
+	labelSegmentRepo   = "segment"
+	labelUserListRepo  = "userlist"
 )
 
 type instrumentRuleRepo struct {
@@ -89,3 +93,335 @@ func (r *instrumentRuleRepo) Teardown() (err error) {
 
 	return r.next.Teardown()
 }
+
+type instrumentScheduleRepo struct {
+	next      ScheduleRepo
+	opObserve instrument.ObserveRepoFunc
+	store     string
+}
+
+// NewScheduleRepoInstrumentMiddleware wraps the next ScheduleRepo and adds
+// Prometheus instrumentation capabilities.
+func NewScheduleRepoInstrumentMiddleware(
+	opObserve instrument.ObserveRepoFunc,
+	store string,
+) ScheduleRepoMiddleware {
+	return func(next ScheduleRepo) ScheduleRepo {
+		return &instrumentScheduleRepo{
+			next:      next,
+			opObserve: opObserve,
+			store:     store,
+		}
+	}
+}
+
+func (r *instrumentScheduleRepo) Get(ruleID string) (sc Schedule, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelScheduleRepo, "Get", begin, err)
+	}(time.Now())
+
+	return r.next.Get(ruleID)
+}
+
+func (r *instrumentScheduleRepo) ListRecords(ruleID string) (recs []ScheduleRecord, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelScheduleRepo, "ListRecords", begin, err)
+	}(time.Now())
+
+	return r.next.ListRecords(ruleID)
+}
+
+func (r *instrumentScheduleRepo) ListRunning() (ss []Schedule, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelScheduleRepo, "ListRunning", begin, err)
+	}(time.Now())
+
+	return r.next.ListRunning()
+}
+
+func (r *instrumentScheduleRepo) Put(s Schedule) (sc Schedule, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelScheduleRepo, "Put", begin, err)
+	}(time.Now())
+
+	return r.next.Put(s)
+}
+
+func (r *instrumentScheduleRepo) Record(prev, s Schedule, rec ScheduleRecord) (err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelScheduleRepo, "Record", begin, err)
+	}(time.Now())
+
+	return r.next.Record(prev, s, rec)
+}
+
+func (r *instrumentScheduleRepo) Setup() (err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelScheduleRepo, "Setup", begin, err)
+	}(time.Now())
+
+	return r.next.Setup()
+}
+
+func (r *instrumentScheduleRepo) Teardown() (err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelScheduleRepo, "Teardown", begin, err)
+	}(time.Now())
+
+	return r.next.Teardown()
+}
+
+type instrumentGuardrailRepo struct {
+	next      GuardrailRepo
+	opObserve instrument.ObserveRepoFunc
+	store     string
+}
+
+// NewGuardrailRepoInstrumentMiddleware wraps the next GuardrailRepo and adds
+// Prometheus instrumentation capabilities.
+func NewGuardrailRepoInstrumentMiddleware(
+	opObserve instrument.ObserveRepoFunc,
+	store string,
+) GuardrailRepoMiddleware {
+	return func(next GuardrailRepo) GuardrailRepo {
+		return &instrumentGuardrailRepo{
+			next:      next,
+			opObserve: opObserve,
+			store:     store,
+		}
+	}
+}
+
+func (r *instrumentGuardrailRepo) AddMetric(m GuardrailMetric) (err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelGuardrailRepo, "AddMetric", begin, err)
+	}(time.Now())
+
+	return r.next.AddMetric(m)
+}
+
+func (r *instrumentGuardrailRepo) Breach(ruleID string, at time.Time) (err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelGuardrailRepo, "Breach", begin, err)
+	}(time.Now())
+
+	return r.next.Breach(ruleID, at)
+}
+
+func (r *instrumentGuardrailRepo) Get(ruleID string) (g Guardrail, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelGuardrailRepo, "Get", begin, err)
+	}(time.Now())
+
+	return r.next.Get(ruleID)
+}
+
+func (r *instrumentGuardrailRepo) ListArmed() (gs []Guardrail, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelGuardrailRepo, "ListArmed", begin, err)
+	}(time.Now())
+
+	return r.next.ListArmed()
+}
+
+func (r *instrumentGuardrailRepo) ListMetrics(ruleID string) (ms []GuardrailMetric, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelGuardrailRepo, "ListMetrics", begin, err)
+	}(time.Now())
+
+	return r.next.ListMetrics(ruleID)
+}
+
+func (r *instrumentGuardrailRepo) Put(g Guardrail) (gr Guardrail, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelGuardrailRepo, "Put", begin, err)
+	}(time.Now())
+
+	return r.next.Put(g)
+}
+
+func (r *instrumentGuardrailRepo) SetMetric(m GuardrailMetric) (err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelGuardrailRepo, "SetMetric", begin, err)
+	}(time.Now())
+
+	return r.next.SetMetric(m)
+}
+
+func (r *instrumentGuardrailRepo) Setup() (err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelGuardrailRepo, "Setup", begin, err)
+	}(time.Now())
+
+	return r.next.Setup()
+}
+
+func (r *instrumentGuardrailRepo) Teardown() (err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelGuardrailRepo, "Teardown", begin, err)
+	}(time.Now())
+
+	return r.next.Teardown()
+}
+
+type instrumentSegmentRepo struct {
+	next      SegmentRepo
+	opObserve instrument.ObserveRepoFunc
+	store     string
+}
+
+// NewSegmentRepoInstrumentMiddleware wraps the next SegmentRepo and adds
+// Prometheus instrumentation capabilities.
+func NewSegmentRepoInstrumentMiddleware(
+	opObserve instrument.ObserveRepoFunc,
+	store string,
+) SegmentRepoMiddleware {
+	return func(next SegmentRepo) SegmentRepo {
+		return &instrumentSegmentRepo{
+			next:      next,
+			opObserve: opObserve,
+			store:     store,
+		}
+	}
+}
+
+func (r *instrumentSegmentRepo) Append(s Segment) (seg Segment, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelSegmentRepo, "Append", begin, err)
+	}(time.Now())
+
+	return r.next.Append(s)
+}
+
+func (r *instrumentSegmentRepo) Get(id string) (s Segment, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelSegmentRepo, "Get", begin, err)
+	}(time.Now())
+
+	return r.next.Get(id)
+}
+
+func (r *instrumentSegmentRepo) List() (ss []Segment, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelSegmentRepo, "List", begin, err)
+	}(time.Now())
+
+	return r.next.List()
+}
+
+func (r *instrumentSegmentRepo) ListVersions(id string) (ss []Segment, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelSegmentRepo, "ListVersions", begin, err)
+	}(time.Now())
+
+	return r.next.ListVersions(id)
+}
+
+func (r *instrumentSegmentRepo) Setup() (err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelSegmentRepo, "Setup", begin, err)
+	}(time.Now())
+
+	return r.next.Setup()
+}
+
+func (r *instrumentSegmentRepo) Teardown() (err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelSegmentRepo, "Teardown", begin, err)
+	}(time.Now())
+
+	return r.next.Teardown()
+}
+
+type instrumentUserListRepo struct {
+	next      UserListRepo
+	opObserve instrument.ObserveRepoFunc
+	store     string
+}
+
+// NewUserListRepoInstrumentMiddleware wraps the next UserListRepo and adds
+// Prometheus instrumentation capabilities.
+func NewUserListRepoInstrumentMiddleware(
+	opObserve instrument.ObserveRepoFunc,
+	store string,
+) UserListRepoMiddleware {
+	return func(next UserListRepo) UserListRepo {
+		return &instrumentUserListRepo{
+			next:      next,
+			opObserve: opObserve,
+			store:     store,
+		}
+	}
+}
+
+func (r *instrumentUserListRepo) Create(l UserList) (ul UserList, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelUserListRepo, "Create", begin, err)
+	}(time.Now())
+
+	return r.next.Create(l)
+}
+
+func (r *instrumentUserListRepo) Get(id string) (l UserList, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelUserListRepo, "Get", begin, err)
+	}(time.Now())
+
+	return r.next.Get(id)
+}
+
+func (r *instrumentUserListRepo) List() (ls []UserList, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelUserListRepo, "List", begin, err)
+	}(time.Now())
+
+	return r.next.List()
+}
+
+func (r *instrumentUserListRepo) ListByMember(userID string) (ls []UserList, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelUserListRepo, "ListByMember", begin, err)
+	}(time.Now())
+
+	return r.next.ListByMember(userID)
+}
+
+func (r *instrumentUserListRepo) Members(id string) (ids []string, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelUserListRepo, "Members", begin, err)
+	}(time.Now())
+
+	return r.next.Members(id)
+}
+
+func (r *instrumentUserListRepo) RemoveMember(userID string) (n int, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelUserListRepo, "RemoveMember", begin, err)
+	}(time.Now())
+
+	return r.next.RemoveMember(userID)
+}
+
+func (r *instrumentUserListRepo) Replace(l UserList, ids []string) (ul UserList, err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelUserListRepo, "Replace", begin, err)
+	}(time.Now())
+
+	return r.next.Replace(l, ids)
+}
+
+func (r *instrumentUserListRepo) Setup() (err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelUserListRepo, "Setup", begin, err)
+	}(time.Now())
+
+	return r.next.Setup()
+}
+
+func (r *instrumentUserListRepo) Teardown() (err error) {
+	defer func(begin time.Time) {
+		r.opObserve(r.store, labelUserListRepo, "Teardown", begin, err)
+	}(time.Now())
+
+	return r.next.Teardown()
+}
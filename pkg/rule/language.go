@@ -0,0 +1,194 @@
+package rule
+
+import (
+	"sort"
+
+	"golang.org/x/text/language"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// LocalizedFallback is the variant of a localized parameter used if none of
+// the other variants matches the locale of the device.
+const LocalizedFallback = "und"
+
+// languageValue converts the decoded JSON value of a language criterion to
+// the tags it is matched with.
+func languageValue(v interface{}) (interface{}, error) {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return v, nil
+	}
+
+	ts := []language.Tag{}
+
+	for _, e := range vs {
+		s, ok := e.(string)
+		if !ok {
+			return v, nil
+		}
+
+		t, err := language.Parse(s)
+		if err != nil {
+			return nil, errors.Wrapf(errors.ErrParsingInvalidLanguageTag, "%s: language tag invalid", s)
+		}
+
+		ts = append(ts, t)
+	}
+
+	return ts, nil
+}
+
+func validateLanguageCriterion(c Criterion) error {
+	switch c.Comparator {
+	case ComparatorIN, ComparatorNotIN:
+	default:
+		return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+	}
+
+	ts, ok := c.Value.([]language.Tag)
+	if !ok {
+		return errors.Wrapf(
+			errors.ErrInvalidTypeToMatch,
+			"%s: value %v invalid for '%s'",
+			c.Key,
+			c.Value,
+			c.Comparator,
+		)
+	}
+
+	if len(ts) == 0 {
+		return errors.Wrapf(errors.ErrInvalidRule, "%s: languages missing", c.Key)
+	}
+
+	return nil
+}
+
+// matchLanguage matches the locale of the device against the listed tags. A
+// tag matches if the language.Matcher is at least highly confident that
+// language and script are the same, a region given in the tag must also be
+// the explicit region of the locale. So "pt" matches "pt-BR" and "pt-PT",
+// while "pt-BR" only matches the former.
+func matchLanguage(c Criterion, locale language.Tag) error {
+	if err := validateLanguageCriterion(c); err != nil {
+		return err
+	}
+
+	ok := false
+
+	for _, t := range c.Value.([]language.Tag) {
+		if languageMatches(t, locale) {
+			ok = true
+			break
+		}
+	}
+
+	switch c.Comparator {
+	case ComparatorIN:
+		if !ok {
+			return errors.Wrapf(errors.ErrCriterionNotMatch, "locale %s not in %v", locale, c.Value)
+		}
+	case ComparatorNotIN:
+		if ok {
+			return errors.Wrapf(errors.ErrCriterionNotMatch, "locale %s in %v", locale, c.Value)
+		}
+	}
+
+	return nil
+}
+
+func languageMatches(expected, input language.Tag) bool {
+	if input == language.Und {
+		return false
+	}
+
+	_, _, confidence := language.NewMatcher([]language.Tag{expected}).Match(input)
+	if confidence < language.High {
+		return false
+	}
+
+	er, confidence := expected.Region()
+	if confidence != language.Exact {
+		return true
+	}
+
+	ir, confidence := input.Region()
+
+	return confidence == language.Exact && er.Contains(ir)
+}
+
+// checkLocalized returns an error if the value is not an object of BCP 47
+// tags to strings which includes the fallback variant.
+func checkLocalized(v interface{}) (map[string]interface{}, error) {
+	variants, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("type '%s' != '%s'", TypeOf(v), TypeLocalized)
+	}
+
+	if _, ok := variants[LocalizedFallback]; !ok {
+		return nil, errors.Errorf("variant '%s' missing", LocalizedFallback)
+	}
+
+	for k, s := range variants {
+		if _, err := language.Parse(k); err != nil {
+			return nil, errors.Errorf("variant '%s' not a language tag", k)
+		}
+
+		if TypeOf(s) != TypeString {
+			return nil, errors.Errorf("variant '%s' type '%s' != '%s'", k, TypeOf(s), TypeString)
+		}
+	}
+
+	return variants, nil
+}
+
+// localize returns the variant which best matches the locale, or the fallback
+// variant if the matcher is not at least highly confident about any.
+func localize(variants map[string]interface{}, locale language.Tag) interface{} {
+	keys := []string{}
+
+	for k := range variants {
+		if k != LocalizedFallback {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	// The fallback goes first as the matcher returns the first supported tag
+	// if nothing matches.
+	keys = append([]string{LocalizedFallback}, keys...)
+	tags := []language.Tag{}
+
+	for _, k := range keys {
+		tags = append(tags, language.Make(k))
+	}
+
+	_, i, confidence := language.NewMatcher(tags).Match(locale)
+	if confidence < language.High {
+		i = 0
+	}
+
+	return variants[keys[i]]
+}
+
+// Localize replaces the variants of all localized parameters with the one
+// matching the locale. Parameters which are not declared as localized are
+// returned as is.
+func (s ParameterSchema) Localize(ps Parameters, locale language.Tag) Parameters {
+	localized := Parameters{}
+
+	for k, v := range ps {
+		localized[k] = v
+
+		if spec, ok := s[k]; !ok || spec.Type != TypeLocalized {
+			continue
+		}
+
+		if variants, ok := v.(map[string]interface{}); ok {
+			localized[k] = localize(variants, locale)
+		}
+	}
+
+	return localized
+}
@@ -0,0 +1,146 @@
+package rule
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/language"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+func TestCriterionLanguageMarshal(t *testing.T) {
+	want := Criterion{
+		Comparator: ComparatorIN,
+		Key:        DeviceLocationLanguage,
+		Value:      []language.Tag{language.MustParse("pt-BR"), language.MustParse("zh-Hant")},
+	}
+
+	raw, err := json.Marshal(&want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var have Criterion
+
+	err = json.Unmarshal(raw, &have)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestCriterionLanguageInvalid(t *testing.T) {
+	cases := map[string]error{
+		`{"comparator": 1, "key": 108, "value": ["pt"]}`:   errors.ErrInvalidRule,
+		`{"comparator": 3, "key": 108, "value": []}`:       errors.ErrInvalidRule,
+		`{"comparator": 3, "key": 108, "value": ["xx_1"]}`: errors.ErrParsingInvalidLanguageTag,
+		`{"comparator": 8, "key": 108, "value": "pt"}`:     errors.ErrInvalidTypeToMatch,
+	}
+
+	for input, want := range cases {
+		err := json.Unmarshal([]byte(input), &Criterion{})
+		if have := errors.Cause(err); have != want {
+			t.Errorf("%s: have %v, want %v", input, have, want)
+		}
+	}
+}
+
+func TestCriterionLanguageMatch(t *testing.T) {
+	tags := func(ss ...string) []language.Tag {
+		ts := []language.Tag{}
+
+		for _, s := range ss {
+			ts = append(ts, language.MustParse(s))
+		}
+
+		return ts
+	}
+
+	var (
+		portuguese = Criterion{ComparatorIN, DeviceLocationLanguage, tags("pt"), ""}
+		brazil     = Criterion{ComparatorIN, DeviceLocationLanguage, tags("pt-BR"), ""}
+		latam      = Criterion{ComparatorIN, DeviceLocationLanguage, tags("es-419"), ""}
+		hant       = Criterion{ComparatorIN, DeviceLocationLanguage, tags("zh-Hant"), ""}
+		notEnglish = Criterion{ComparatorNotIN, DeviceLocationLanguage, tags("en"), ""}
+	)
+
+	cases := []struct {
+		criterion Criterion
+		locale    string
+		match     bool
+	}{
+		{portuguese, "pt_BR", true},
+		{portuguese, "pt_PT", true},
+		{portuguese, "pt", true},
+		{portuguese, "es_ES", false},
+		{brazil, "pt_BR", true},
+		{brazil, "pt_PT", false},
+		{brazil, "pt", false},
+		{latam, "es_MX", true},
+		{latam, "es_ES", false},
+		{hant, "zh_TW", true},
+		{hant, "zh_CN", false},
+		{notEnglish, "en_GB", false},
+		{notEnglish, "de_DE", true},
+	}
+
+	for _, c := range cases {
+		err := c.criterion.match(Context{
+			Locale: ContextLocale{
+				Locale: language.MustParse(c.locale),
+			},
+		})
+
+		switch {
+		case c.match && err != nil:
+			t.Errorf("%v %s: have %v, want match", c.criterion, c.locale, err)
+		case !c.match && errors.Cause(err) != errors.ErrCriterionNotMatch:
+			t.Errorf("%v %s: have %v, want %v", c.criterion, c.locale, err, errors.ErrCriterionNotMatch)
+		}
+	}
+}
+
+func TestParameterSchemaLocalize(t *testing.T) {
+	var (
+		schema = ParameterSchema{
+			"feature_paywall_title": {
+				Type: TypeLocalized,
+			},
+		}
+		variants = map[string]interface{}{
+			LocalizedFallback: "Go premium",
+			"de":              "Premium holen",
+			"pt-BR":           "Seja premium",
+			"pt-PT":           "Torne-se premium",
+		}
+	)
+
+	cases := map[string]string{
+		"de_AT":   "Premium holen",
+		"en_GB":   "Go premium",
+		"pt_BR":   "Seja premium",
+		"pt_PT":   "Torne-se premium",
+		"sv_SE":   "Go premium",
+		"zh_Hant": "Go premium",
+	}
+
+	for locale, want := range cases {
+		ps := schema.Localize(Parameters{
+			"feature_paywall_enabled": true,
+			"feature_paywall_title":   variants,
+		}, language.MustParse(locale))
+
+		if have := ps["feature_paywall_title"]; have != want {
+			t.Errorf("%s: have %v, want %v", locale, have, want)
+		}
+
+		if have, want := ps["feature_paywall_enabled"], true; have != want {
+			t.Errorf("%s: have %v, want %v", locale, have, want)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package rule
+
+import (
+	"sort"
+	"time"
+
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/generate"
+)
+
+const layerDecisionPrefix = "layer:"
+
+// layerPositions is the number of positions in a layer.
+const layerPositions = 100
+
+// Layer is a traffic partition shared by rules of the same base config. Every
+// rule in a layer takes a share of it, starting at its offset, and users are
+// placed in exactly one position of the layer, so they are subject to at most
+// one of its rules. Positions are keyed by user id, so rules in a layer can't
+// bucket by any other attribute.
+type Layer struct {
+	Name   string
+	Offset uint8
+	Share  uint8
+}
+
+// Layer returns the layer the rule is assigned to. The name is empty for
+// rules outside of any layer.
+func (r Rule) Layer() Layer {
+	return r.layer
+}
+
+// InLayer returns a copy of the rule which takes share of the named layer.
+// The offset is assigned on storage, see NewRuleRepoLayerMiddleware. An
+// empty name removes the rule from its layer.
+func (r Rule) InLayer(name string, share uint8) (Rule, error) {
+	c := r
+
+	if name == "" {
+		c.layer = Layer{}
+		return c, nil
+	}
+
+	if c.layer.Name != name {
+		c.layer.Offset = 0
+	}
+
+	c.layer.Name = name
+	c.layer.Share = share
+
+	if err := c.validate(); err != nil {
+		return Rule{}, err
+	}
+
+	return c, nil
+}
+
+// position returns the position of the user in the layer. Positions range from
+// 1 to 100, so every share covers as many positions as its size, including the
+// last one. They are derived from the user id regardless of the dice used for
+// other decisions, so positions are stable without being stored.
+func (l Layer) position(configID, userID string) int {
+	return generate.HashRange(layerPositions, configID+"/"+l.decisionKey(), userID)()
+}
+
+// decisionKey is the key under which the position of a user in the layer is
+// kept among the decisions.
+func (l Layer) decisionKey() string {
+	return layerDecisionPrefix + l.Name
+}
+
+// contains reports if the position falls into the share of the layer.
+func (l Layer) contains(position int) bool {
+	return position > int(l.Offset) && position <= int(l.Offset)+int(l.Share)
+}
+
+func (l Layer) overlaps(o Layer) bool {
+	return int(l.Offset) < int(o.Offset)+int(o.Share) &&
+		int(o.Offset) < int(l.Offset)+int(l.Share)
+}
+
+func (l Layer) validate() error {
+	if l.Name == "" {
+		return nil
+	}
+
+	if l.Share == 0 {
+		return errors.Wrap(errors.ErrInvalidRule, "layer share missing")
+	}
+
+	if int(l.Offset)+int(l.Share) > 100 {
+		return errors.Wrap(errors.ErrInvalidRule, "layer share exceeds layer")
+	}
+
+	return nil
+}
+
+// allocateLayer places the share of the rule in its layer without overlapping
+// the other active rules in the same layer. A rule keeps its offset as long as
+// it doesn't overlap, otherwise the lowest free offset is taken. Inactive
+// rules don't hold a share and are returned as is.
+func allocateLayer(r Rule, others []Rule, now time.Time) (Rule, error) {
+	if r.layer.Name == "" || !r.Active(now) {
+		return r, nil
+	}
+
+	taken := []Layer{}
+
+	for _, o := range others {
+		if o.ID == r.ID ||
+			o.configID != r.configID ||
+			o.layer.Name != r.layer.Name ||
+			!o.Active(now) {
+			continue
+		}
+
+		taken = append(taken, o.layer)
+	}
+
+	sort.Slice(taken, func(i, j int) bool { return taken[i].Offset < taken[j].Offset })
+
+	fits := func(l Layer) bool {
+		if int(l.Offset)+int(l.Share) > 100 {
+			return false
+		}
+
+		for _, t := range taken {
+			if l.overlaps(t) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if fits(r.layer) {
+		return r, nil
+	}
+
+	candidates := []uint8{0}
+
+	for _, t := range taken {
+		candidates = append(candidates, t.Offset+t.Share)
+	}
+
+	for _, offset := range candidates {
+		l := r.layer
+		l.Offset = offset
+
+		if fits(l) {
+			r.layer = l
+			return r, nil
+		}
+	}
+
+	return Rule{}, errors.Wrapf(
+		errors.ErrInvalidRule,
+		"layer '%s' has no room for share %d",
+		r.layer.Name,
+		r.layer.Share,
+	)
+}
+
+// checkLayer returns an error if the share of the active rule overlaps the
+// share of another active rule in the same layer.
+func checkLayer(r Rule, others []Rule, now time.Time) error {
+	if r.layer.Name == "" || !r.Active(now) {
+		return nil
+	}
+
+	for _, o := range others {
+		if o.ID == r.ID ||
+			o.configID != r.configID ||
+			o.layer.Name != r.layer.Name ||
+			!o.Active(now) {
+			continue
+		}
+
+		if r.layer.overlaps(o.layer) {
+			return errors.Wrapf(
+				errors.ErrInvalidRule,
+				"layer '%s' shares of '%s' and '%s' overlap",
+				r.layer.Name,
+				r.name,
+				o.name,
+			)
+		}
+	}
+
+	return nil
+}
+
+type layerRuleRepo struct {
+	next Repo
+}
+
+// NewRuleRepoLayerMiddleware wraps the next Repo and assigns offsets to rules
+// in a layer on storage, so the shares of active rules in the same layer never
+// overlap. Rules which don't fit in their layer are rejected.
+func NewRuleRepoLayerMiddleware() RepoMiddleware {
+	return func(next Repo) Repo {
+		return &layerRuleRepo{
+			next: next,
+		}
+	}
+}
+
+func (r *layerRuleRepo) Create(input Rule) (Rule, error) {
+	input, err := r.allocate(input)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	return r.next.Create(input)
+}
+
+func (r *layerRuleRepo) GetByID(id string) (Rule, error) {
+	return r.next.GetByID(id)
+}
+
+func (r *layerRuleRepo) UpdateWith(input Rule) (Rule, error) {
+	input, err := r.allocate(input)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	return r.next.UpdateWith(input)
+}
+
+func (r *layerRuleRepo) ListAll() ([]Rule, error) {
+	return r.next.ListAll()
+}
+
+func (r *layerRuleRepo) ListActive(configID string, now time.Time) ([]Rule, error) {
+	return r.next.ListActive(configID, now)
+}
+
+func (r *layerRuleRepo) Setup() error {
+	return r.next.Setup()
+}
+
+func (r *layerRuleRepo) Teardown() error {
+	return r.next.Teardown()
+}
+
+func (r *layerRuleRepo) allocate(input Rule) (Rule, error) {
+	if input.layer.Name == "" {
+		return input, nil
+	}
+
+	rs, err := r.next.ListAll()
+	if err != nil {
+		return Rule{}, err
+	}
+
+	return allocateLayer(input, rs, time.Now())
+}
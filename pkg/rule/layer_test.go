@@ -0,0 +1,203 @@
+package rule
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+func TestAllocateLayer(t *testing.T) {
+	var (
+		now     = time.Now()
+		newRule = func(id, layer string, offset, share uint8, active bool) Rule {
+			return Rule{
+				active:   active,
+				configID: "base-1",
+				ID:       id,
+				layer: Layer{
+					Name:   layer,
+					Offset: offset,
+					Share:  share,
+				},
+			}
+		}
+		others = []Rule{
+			newRule("a", "paywall", 0, 30, true),
+			newRule("b", "paywall", 50, 20, true),
+			newRule("c", "paywall", 30, 20, false),
+			newRule("d", "onboarding", 30, 20, true),
+		}
+	)
+
+	for i, c := range []struct {
+		rule Rule
+		want uint8
+		err  error
+	}{
+		{newRule("new", "paywall", 0, 20, true), 30, nil},
+		{newRule("new", "paywall", 0, 30, true), 70, nil},
+		{newRule("new", "paywall", 0, 31, true), 0, errors.ErrInvalidRule},
+		{newRule("b", "paywall", 50, 20, true), 50, nil},
+		{newRule("b", "paywall", 50, 60, true), 30, nil},
+		{newRule("new", "onboarding", 30, 20, true), 0, nil},
+		{newRule("new", "paywall", 10, 90, false), 10, nil},
+	} {
+		r, err := allocateLayer(c.rule, others, now)
+		if have, want := errors.Cause(err), c.err; have != want {
+			t.Errorf("%d: have %v, want %v", i, have, want)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if have, want := r.layer.Offset, c.want; have != want {
+			t.Errorf("%d: have %v, want %v", i, have, want)
+		}
+	}
+}
+
+func TestLayerBucketing(t *testing.T) {
+	r := Rule{
+		buckets:   []Bucket{{Parameters: Parameters{"paywall": "annual"}}},
+		configID:  "base-1",
+		createdAt: time.Now(),
+		ID:        "rule-1",
+		kind:      KindOverride,
+		name:      "paywall",
+	}
+
+	r, err := r.InLayer("paywall", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.WithBucketing(Bucketing{Key: BucketByDevice})
+	if have, want := errors.Cause(err), errors.ErrInvalidRule; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if _, err := r.WithBucketing(Bucketing{Key: BucketByUser}); err != nil {
+		t.Error(err)
+	}
+
+	household, err := r.InLayer("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	household, err = household.WithBucketing(Bucketing{Key: BucketByMetadata, Path: "household_id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = household.InLayer("paywall", 20)
+	if have, want := errors.Cause(err), errors.ErrInvalidRule; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestEvaluateLayer(t *testing.T) {
+	newRule := func(id, value string, offset uint8, createdAt time.Time) Rule {
+		return Rule{
+			active: true,
+			buckets: []Bucket{
+				{
+					Name:       "default",
+					Parameters: Parameters{"onboarding_" + id: value},
+				},
+			},
+			configID:  "base-1",
+			createdAt: createdAt,
+			ID:        id,
+			kind:      KindOverride,
+			layer: Layer{
+				Name:   "onboarding",
+				Offset: offset,
+				Share:  50,
+			},
+		}
+	}
+
+	var (
+		now   = time.Now()
+		rules = []Rule{
+			newRule("short", "on", 0, now),
+			newRule("video", "on", 50, now.Add(time.Second)),
+		}
+		seen = map[string]int{}
+	)
+
+	for i := 0; i < 200; i++ {
+		ctx := Context{User: ContextUser{ID: fmt.Sprintf("user-%d", i)}}
+
+		params, ds, err := Evaluate(Parameters{}, rules, ctx, nil, HashDice)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if have, want := len(params), 1; have != want {
+			t.Fatalf("have %v, want %v: %v", have, want, params)
+		}
+
+		for k := range params {
+			seen[k]++
+		}
+
+		if _, ok := ds[layerDecisionPrefix+"onboarding"]; !ok {
+			t.Errorf("layer position missing in decisions %v", ds)
+		}
+
+		again, _, err := Evaluate(Parameters{}, rules, ctx, ds, RandDice(randIntGenerateTest))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(again, params) {
+			t.Errorf("have %v, want %v", again, params)
+		}
+	}
+
+	if have, want := len(seen), 2; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestLayerPositionLastSlot(t *testing.T) {
+	var (
+		l        = Layer{Name: "onboarding", Offset: 99, Share: 1}
+		min, max = layerPositions, 0
+	)
+
+	for i := 0; i < 5000; i++ {
+		p := l.position("base-1", fmt.Sprintf("user-%d", i))
+
+		if p < min {
+			min = p
+		}
+
+		if p > max {
+			max = p
+		}
+	}
+
+	if have, want := min, 1; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := max, layerPositions; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if !l.contains(layerPositions) {
+		t.Errorf("last position not in share %v", l)
+	}
+
+	if l.contains(layerPositions - 1) {
+		t.Errorf("position %d in share %v", layerPositions-1, l)
+	}
+}
@@ -0,0 +1,242 @@
+package rule
+
+import (
+	"strings"
+	"time"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// localTimeLayout is the format of times of day in DeviceLocalTime criteria.
+const localTimeLayout = "15:04"
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// localTimeValue converts the decoded JSON value of a local time criterion to
+// the type it is matched with.
+func localTimeValue(key CriterionKey, v interface{}) interface{} {
+	switch key {
+	case DeviceLocationOffset:
+		return intsValue(v)
+	case DeviceLocalTime, DeviceLocalWeekday:
+		if vs, ok := v.([]interface{}); ok {
+			ss := []string{}
+
+			for _, e := range vs {
+				s, ok := e.(string)
+				if !ok {
+					return v
+				}
+
+				ss = append(ss, s)
+			}
+
+			return ss
+		}
+	}
+
+	return v
+}
+
+func validateLocalTimeCriterion(c Criterion) error {
+	var ok bool
+
+	switch c.Key {
+	case DeviceLocationOffset:
+		switch c.Comparator {
+		case ComparatorEQ, ComparatorNQ, ComparatorGT, ComparatorLT:
+			_, ok = c.Value.(int)
+		case ComparatorBetween:
+			var r []int
+
+			if r, ok = c.Value.([]int); ok && (len(r) != 2 || r[0] > r[1]) {
+				return errors.Wrapf(errors.ErrInvalidRule, "%s: range %v invalid", c.Key, r)
+			}
+		default:
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+		}
+	case DeviceLocalTime:
+		var ts []string
+
+		switch c.Comparator {
+		case ComparatorGT, ComparatorLT:
+			var t string
+
+			t, ok = c.Value.(string)
+			ts = []string{t}
+		case ComparatorBetween:
+			if ts, ok = c.Value.([]string); ok && len(ts) != 2 {
+				return errors.Wrapf(errors.ErrInvalidRule, "%s: window %v invalid", c.Key, ts)
+			}
+		default:
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+		}
+
+		for _, t := range ts {
+			if _, err := parseLocalTime(t); ok && err != nil {
+				return errors.Wrapf(errors.ErrInvalidRule, "%s: %s", c.Key, err)
+			}
+		}
+	case DeviceLocalWeekday:
+		var ds []string
+
+		switch c.Comparator {
+		case ComparatorEQ, ComparatorNQ:
+			var d string
+
+			d, ok = c.Value.(string)
+			ds = []string{d}
+		case ComparatorIN:
+			ds, ok = c.Value.([]string)
+		default:
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+		}
+
+		for _, d := range ds {
+			if _, valid := weekdays[d]; ok && !valid {
+				return errors.Wrapf(errors.ErrInvalidRule, "%s: weekday '%s' unknown", c.Key, d)
+			}
+		}
+	}
+
+	if !ok {
+		return errors.Wrapf(
+			errors.ErrInvalidTypeToMatch,
+			"%s: value %v invalid for '%s'",
+			c.Key,
+			c.Value,
+			c.Comparator,
+		)
+	}
+
+	return nil
+}
+
+// parseLocalTime returns the minutes since midnight of a time of day.
+func parseLocalTime(s string) (int, error) {
+	t, err := time.Parse(localTimeLayout, s)
+	if err != nil {
+		return 0, err
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// matchLocalTime matches the criterion against the local time of the device,
+// derived from the time of the context and the timezone offset of the device.
+func matchLocalTime(c Criterion, ctx Context) error {
+	if err := validateLocalTimeCriterion(c); err != nil {
+		return err
+	}
+
+	now := ctx.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	local := now.UTC().Add(time.Duration(ctx.Locale.Offset) * time.Second)
+
+	var ok bool
+
+	switch c.Key {
+	case DeviceLocationOffset:
+		ok = matchOffset(c, ctx.Locale.Offset)
+	case DeviceLocalTime:
+		ok = matchTimeOfDay(c, local.Hour()*60+local.Minute())
+	case DeviceLocalWeekday:
+		ok = matchWeekday(c, local.Weekday())
+	}
+
+	if !ok {
+		return errors.Wrapf(
+			errors.ErrCriterionNotMatch,
+			"local time %s doesn't match %s %s %v",
+			local.Format("Mon 15:04 -0700"),
+			c.Key,
+			c.Comparator,
+			c.Value,
+		)
+	}
+
+	return nil
+}
+
+func matchOffset(c Criterion, offset int) bool {
+	if c.Comparator == ComparatorBetween {
+		r := c.Value.([]int)
+
+		return offset >= r[0] && offset <= r[1]
+	}
+
+	expected := c.Value.(int)
+
+	switch c.Comparator {
+	case ComparatorEQ:
+		return offset == expected
+	case ComparatorNQ:
+		return offset != expected
+	case ComparatorGT:
+		return offset > expected
+	case ComparatorLT:
+		return offset < expected
+	default:
+		return false
+	}
+}
+
+// matchTimeOfDay treats windows whose start is after their end as spanning
+// midnight, e.g. 22:00 to 02:00. The start of a window is included, its end
+// is not.
+func matchTimeOfDay(c Criterion, minutes int) bool {
+	if c.Comparator == ComparatorBetween {
+		var (
+			w        = c.Value.([]string)
+			from, _  = parseLocalTime(w[0])
+			until, _ = parseLocalTime(w[1])
+		)
+
+		if from <= until {
+			return minutes >= from && minutes < until
+		}
+
+		return minutes >= from || minutes < until
+	}
+
+	expected, _ := parseLocalTime(c.Value.(string))
+
+	switch c.Comparator {
+	case ComparatorGT:
+		return minutes > expected
+	case ComparatorLT:
+		return minutes < expected
+	default:
+		return false
+	}
+}
+
+func matchWeekday(c Criterion, day time.Weekday) bool {
+	name := strings.ToLower(day.String())
+
+	switch c.Comparator {
+	case ComparatorEQ:
+		return name == c.Value.(string)
+	case ComparatorNQ:
+		return name != c.Value.(string)
+	case ComparatorIN:
+		for _, d := range c.Value.([]string) {
+			if d == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,105 @@
+package rule
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+func TestCriterionLocalTimeMarshal(t *testing.T) {
+	for _, want := range []Criterion{
+		{
+			Comparator: ComparatorBetween,
+			Key:        DeviceLocalTime,
+			Value:      []string{"11:30", "14:00"},
+		},
+		{
+			Comparator: ComparatorIN,
+			Key:        DeviceLocalWeekday,
+			Value:      []string{"saturday", "sunday"},
+		},
+		{
+			Comparator: ComparatorBetween,
+			Key:        DeviceLocationOffset,
+			Value:      []int{0, 7200},
+		},
+	} {
+		raw, err := json.Marshal(&want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var have Criterion
+
+		err = json.Unmarshal(raw, &have)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
+
+func TestCriterionLocalTimeInvalid(t *testing.T) {
+	cases := map[string]error{
+		`{"comparator": 7, "key": 105, "value": ["11:30", "25:00"]}`: errors.ErrInvalidRule,
+		`{"comparator": 7, "key": 105, "value": ["11:30"]}`:          errors.ErrInvalidRule,
+		`{"comparator": 1, "key": 105, "value": "11:30"}`:            errors.ErrInvalidRule,
+		`{"comparator": 3, "key": 106, "value": ["someday"]}`:        errors.ErrInvalidRule,
+		`{"comparator": 7, "key": 102, "value": [3600, 0]}`:          errors.ErrInvalidRule,
+		`{"comparator": 0, "key": 102, "value": "3600"}`:             errors.ErrInvalidTypeToMatch,
+	}
+
+	for input, want := range cases {
+		err := json.Unmarshal([]byte(input), &Criterion{})
+		if have := errors.Cause(err); have != want {
+			t.Errorf("%s: have %v, want %v", input, have, want)
+		}
+	}
+}
+
+func TestCriterionLocalTimeMatch(t *testing.T) {
+	// Monday 10:00 UTC.
+	now := time.Date(2018, 3, 5, 10, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		criterion Criterion
+		offset    int
+		match     bool
+	}{
+		{Criterion{ComparatorBetween, DeviceLocalTime, []string{"11:30", "14:00"}, ""}, 0, false},
+		{Criterion{ComparatorBetween, DeviceLocalTime, []string{"11:30", "14:00"}, ""}, 7200, true},
+		{Criterion{ComparatorBetween, DeviceLocalTime, []string{"11:30", "14:00"}, ""}, 14400, false},
+		{Criterion{ComparatorBetween, DeviceLocalTime, []string{"22:00", "02:00"}, ""}, -36000, true},
+		{Criterion{ComparatorGT, DeviceLocalTime, "09:00", ""}, 0, true},
+		{Criterion{ComparatorLT, DeviceLocalTime, "09:00", ""}, 0, false},
+		{Criterion{ComparatorEQ, DeviceLocalWeekday, "monday", ""}, 0, true},
+		{Criterion{ComparatorEQ, DeviceLocalWeekday, "monday", ""}, -39600, false},
+		{Criterion{ComparatorIN, DeviceLocalWeekday, []string{"saturday", "sunday"}, ""}, -39600, true},
+		{Criterion{ComparatorNQ, DeviceLocalWeekday, "sunday", ""}, 0, true},
+		{Criterion{ComparatorBetween, DeviceLocationOffset, []int{0, 7200}, ""}, 3600, true},
+		{Criterion{ComparatorBetween, DeviceLocationOffset, []int{0, 7200}, ""}, -3600, false},
+		{Criterion{ComparatorLT, DeviceLocationOffset, 0, ""}, -3600, true},
+	}
+
+	for _, c := range cases {
+		err := c.criterion.match(Context{
+			Locale: ContextLocale{
+				Offset: c.offset,
+			},
+			Now: now,
+		})
+
+		switch {
+		case c.match && err != nil:
+			t.Errorf("%v at %d: have %v, want match", c.criterion, c.offset, err)
+		case !c.match && errors.Cause(err) != errors.ErrCriterionNotMatch:
+			t.Errorf("%v at %d: have %v, want %v", c.criterion, c.offset, err, errors.ErrCriterionNotMatch)
+		}
+	}
+}
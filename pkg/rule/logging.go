@@ -8,22 +8,38 @@ import (
 
 // Log fields.
 const (
-	logBuckets   = "buckets"
-	logConfigID  = "configID"
-	logCreatedAt = "createdAt"
-	logDuration  = "duration"
-	logElements  = "elements"
-	logEndTime   = "endTime"
-	logErr       = "err"
-	logID        = "id"
-	logKind      = "kind"
-	logName      = "name"
-	logOp        = "op"
-	logPkg       = "pkg"
-	logRepo      = "repo"
-	logStartTime = "startTime"
-	logStore     = "store"
-	logUpdatedAt = "updatedAt"
+	logBreachedAt = "breachedAt"
+	logBuckets    = "buckets"
+	logComparison = "comparison"
+	logConfigID   = "configID"
+	logControl    = "control"
+	logCount      = "count"
+	logCreatedAt  = "createdAt"
+	logDeleted    = "deleted"
+	logDifference = "difference"
+	logDuration   = "duration"
+	logElements   = "elements"
+	logEndTime    = "endTime"
+	logErr        = "err"
+	logEvent      = "event"
+	logGroup      = "group"
+	logID         = "id"
+	logKind       = "kind"
+	logMetric     = "metric"
+	logName       = "name"
+	logOp         = "op"
+	logPkg        = "pkg"
+	logRepo       = "repo"
+	logRollout    = "rollout"
+	logRuleID     = "ruleID"
+	logSize       = "size"
+	logStartTime  = "startTime"
+	logState      = "state"
+	logStep       = "step"
+	logStore      = "store"
+	logThreshold  = "threshold"
+	logUpdatedAt  = "updatedAt"
+	logVersion    = "version"
 )
 
 type logRuleRepo struct {
@@ -177,3 +193,700 @@ func (r *logRuleRepo) Teardown() (err error) {
 
 	return r.next.Teardown()
 }
+
+type logScheduleRepo struct {
+	logger log.Logger
+	next   ScheduleRepo
+}
+
+// NewScheduleRepoLogMiddleware wraps the next ScheduleRepo with logging
+// capabilities.
+func NewScheduleRepoLogMiddleware(
+	logger log.Logger,
+	store string,
+) ScheduleRepoMiddleware {
+	return func(next ScheduleRepo) ScheduleRepo {
+		return &logScheduleRepo{
+			logger: log.With(
+				logger,
+				logPkg, "rule",
+				logRepo, "schedule",
+				logStore, store,
+			),
+			next: next,
+		}
+	}
+}
+
+func (r *logScheduleRepo) Get(ruleID string) (sc Schedule, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logOp, "Get",
+			logRuleID, ruleID,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Get(ruleID)
+}
+
+func (r *logScheduleRepo) ListRecords(ruleID string) (recs []ScheduleRecord, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, len(recs),
+			logOp, "ListRecords",
+			logRuleID, ruleID,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.ListRecords(ruleID)
+}
+
+func (r *logScheduleRepo) ListRunning() (ss []Schedule, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, len(ss),
+			logOp, "ListRunning",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.ListRunning()
+}
+
+func (r *logScheduleRepo) Put(s Schedule) (sc Schedule, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, len(s.Steps),
+			logOp, "Put",
+			logRuleID, s.RuleID,
+			logState, s.State,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Put(s)
+}
+
+func (r *logScheduleRepo) Record(prev, s Schedule, rec ScheduleRecord) (err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logEvent, rec.Event,
+			logID, rec.ID,
+			logOp, "Record",
+			logRollout, rec.Rollout,
+			logRuleID, s.RuleID,
+			logState, s.State,
+			logStep, rec.Step,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Record(prev, s, rec)
+}
+
+func (r *logScheduleRepo) Setup() (err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logOp, "Setup",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Setup()
+}
+
+func (r *logScheduleRepo) Teardown() (err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logOp, "Teardown",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Teardown()
+}
+
+type logGuardrailRepo struct {
+	logger log.Logger
+	next   GuardrailRepo
+}
+
+// NewGuardrailRepoLogMiddleware wraps the next GuardrailRepo with logging
+// capabilities.
+func NewGuardrailRepoLogMiddleware(
+	logger log.Logger,
+	store string,
+) GuardrailRepoMiddleware {
+	return func(next GuardrailRepo) GuardrailRepo {
+		return &logGuardrailRepo{
+			logger: log.With(
+				logger,
+				logPkg, "rule",
+				logRepo, "guardrail",
+				logStore, store,
+			),
+			next: next,
+		}
+	}
+}
+
+func (r *logGuardrailRepo) AddMetric(m GuardrailMetric) (err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logCount, m.Count,
+			logGroup, m.Group,
+			logMetric, m.Metric,
+			logOp, "AddMetric",
+			logRuleID, m.RuleID,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.AddMetric(m)
+}
+
+func (r *logGuardrailRepo) Breach(ruleID string, at time.Time) (err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logBreachedAt, at,
+			logOp, "Breach",
+			logRuleID, ruleID,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Breach(ruleID, at)
+}
+
+func (r *logGuardrailRepo) Get(ruleID string) (g Guardrail, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logOp, "Get",
+			logRuleID, ruleID,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Get(ruleID)
+}
+
+func (r *logGuardrailRepo) ListArmed() (gs []Guardrail, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, len(gs),
+			logOp, "ListArmed",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.ListArmed()
+}
+
+func (r *logGuardrailRepo) ListMetrics(ruleID string) (ms []GuardrailMetric, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, len(ms),
+			logOp, "ListMetrics",
+			logRuleID, ruleID,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.ListMetrics(ruleID)
+}
+
+func (r *logGuardrailRepo) Put(g Guardrail) (gr Guardrail, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logComparison, g.Comparison,
+			logMetric, g.Metric,
+			logOp, "Put",
+			logRuleID, g.RuleID,
+			logThreshold, g.Threshold,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Put(g)
+}
+
+func (r *logGuardrailRepo) SetMetric(m GuardrailMetric) (err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logCount, m.Count,
+			logGroup, m.Group,
+			logMetric, m.Metric,
+			logOp, "SetMetric",
+			logRuleID, m.RuleID,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.SetMetric(m)
+}
+
+func (r *logGuardrailRepo) Setup() (err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logOp, "Setup",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Setup()
+}
+
+func (r *logGuardrailRepo) Teardown() (err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logOp, "Teardown",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Teardown()
+}
+
+type logNotifier struct {
+	logger log.Logger
+}
+
+// NewGuardrailLogNotifier emits the evidence of breaches as log lines.
+func NewGuardrailLogNotifier(logger log.Logger) GuardrailNotifier {
+	return &logNotifier{
+		logger: log.With(logger, logPkg, "rule"),
+	}
+}
+
+func (n *logNotifier) Notify(b GuardrailBreach) error {
+	return n.logger.Log(
+		logBreachedAt, b.CreatedAt,
+		logComparison, b.Guardrail.Comparison,
+		logControl, b.Control.Mean(),
+		logDifference, b.Difference,
+		logMetric, b.Guardrail.Metric,
+		logRollout, b.Rollout.Mean(),
+		logRuleID, b.Guardrail.RuleID,
+		logThreshold, b.Guardrail.Threshold,
+	)
+}
+
+type logSegmentRepo struct {
+	logger log.Logger
+	next   SegmentRepo
+}
+
+// NewSegmentRepoLogMiddleware wraps the next SegmentRepo with logging
+// capabilities.
+func NewSegmentRepoLogMiddleware(
+	logger log.Logger,
+	store string,
+) SegmentRepoMiddleware {
+	return func(next SegmentRepo) SegmentRepo {
+		return &logSegmentRepo{
+			logger: log.With(
+				logger,
+				logPkg, "rule",
+				logRepo, "segment",
+				logStore, store,
+			),
+			next: next,
+		}
+	}
+}
+
+func (r *logSegmentRepo) Append(s Segment) (seg Segment, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logDeleted, s.Deleted,
+			logID, s.ID,
+			logName, s.Name,
+			logOp, "Append",
+			logVersion, s.Version,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Append(s)
+}
+
+func (r *logSegmentRepo) Get(id string) (s Segment, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logID, id,
+			logOp, "Get",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Get(id)
+}
+
+func (r *logSegmentRepo) List() (ss []Segment, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, len(ss),
+			logOp, "List",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.List()
+}
+
+func (r *logSegmentRepo) ListVersions(id string) (ss []Segment, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, len(ss),
+			logID, id,
+			logOp, "ListVersions",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.ListVersions(id)
+}
+
+func (r *logSegmentRepo) Setup() (err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logOp, "Setup",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Setup()
+}
+
+func (r *logSegmentRepo) Teardown() (err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logOp, "Teardown",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Teardown()
+}
+
+type logUserListRepo struct {
+	logger log.Logger
+	next   UserListRepo
+}
+
+// NewUserListRepoLogMiddleware wraps the next UserListRepo with logging
+// capabilities.
+func NewUserListRepoLogMiddleware(
+	logger log.Logger,
+	store string,
+) UserListRepoMiddleware {
+	return func(next UserListRepo) UserListRepo {
+		return &logUserListRepo{
+			logger: log.With(
+				logger,
+				logPkg, "rule",
+				logRepo, "userlist",
+				logStore, store,
+			),
+			next: next,
+		}
+	}
+}
+
+func (r *logUserListRepo) Create(l UserList) (ul UserList, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logID, l.ID,
+			logName, l.Name,
+			logOp, "Create",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Create(l)
+}
+
+func (r *logUserListRepo) Get(id string) (l UserList, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logID, id,
+			logOp, "Get",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Get(id)
+}
+
+func (r *logUserListRepo) List() (ls []UserList, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, len(ls),
+			logOp, "List",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.List()
+}
+
+func (r *logUserListRepo) ListByMember(userID string) (ls []UserList, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, len(ls),
+			logOp, "ListByMember",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.ListByMember(userID)
+}
+
+func (r *logUserListRepo) Members(id string) (ids []string, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, len(ids),
+			logID, id,
+			logOp, "Members",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Members(id)
+}
+
+func (r *logUserListRepo) RemoveMember(userID string) (n int, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logElements, n,
+			logOp, "RemoveMember",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.RemoveMember(userID)
+}
+
+func (r *logUserListRepo) Replace(l UserList, ids []string) (ul UserList, err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logID, l.ID,
+			logOp, "Replace",
+			logSize, len(ids),
+			logVersion, l.Version,
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Replace(l, ids)
+}
+
+func (r *logUserListRepo) Setup() (err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logOp, "Setup",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Setup()
+}
+
+func (r *logUserListRepo) Teardown() (err error) {
+	defer func(begin time.Time) {
+		ps := []interface{}{
+			logDuration, time.Since(begin).Nanoseconds(),
+			logOp, "Teardown",
+		}
+
+		if err != nil {
+			ps = append(ps, logErr, err)
+		}
+
+		_ = r.logger.Log(ps...)
+	}(time.Now())
+
+	return r.next.Teardown()
+}
@@ -0,0 +1,393 @@
+package rule
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// metadataPathSeparator separates the keys of nested metadata in criterion
+// paths, e.g. "onboarding.goal".
+const metadataPathSeparator = "."
+
+// metadataRegexps caches compiled patterns of regex criteria, as criteria are
+// matched on every render.
+var metadataRegexps = struct {
+	sync.RWMutex
+	m map[string]*regexp.Regexp
+}{
+	m: map[string]*regexp.Regexp{},
+}
+
+// metadataValue converts the decoded JSON value of a metadata criterion to the
+// type it is matched with.
+func metadataValue(key CriterionKey, v interface{}) interface{} {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+
+	switch key {
+	case MetadataNumber:
+		fs := []float64{}
+
+		for _, v := range vs {
+			f, ok := v.(float64)
+			if !ok {
+				return vs
+			}
+
+			fs = append(fs, f)
+		}
+
+		return fs
+	case MetadataString:
+		ss := []string{}
+
+		for _, v := range vs {
+			s, ok := v.(string)
+			if !ok {
+				return vs
+			}
+
+			ss = append(ss, s)
+		}
+
+		return ss
+	default:
+		return vs
+	}
+}
+
+func validateMetadataCriterion(c Criterion) error {
+	if c.Path == "" {
+		return errors.Wrapf(errors.ErrInvalidRule, "%s: path missing", c.Key)
+	}
+
+	var ok bool
+
+	switch c.Key {
+	case MetadataBool:
+		switch c.Comparator {
+		case ComparatorEQ, ComparatorNQ:
+			_, ok = c.Value.(bool)
+		default:
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+		}
+	case MetadataNumber:
+		switch c.Comparator {
+		case ComparatorEQ, ComparatorNQ, ComparatorGT, ComparatorLT, ComparatorContains:
+			_, ok = c.Value.(float64)
+		case ComparatorIN:
+			_, ok = c.Value.([]float64)
+		default:
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+		}
+	case MetadataString:
+		switch c.Comparator {
+		case ComparatorEQ, ComparatorNQ, ComparatorContains:
+			_, ok = c.Value.(string)
+		case ComparatorIN:
+			_, ok = c.Value.([]string)
+		case ComparatorRegex:
+			pattern, isString := c.Value.(string)
+			if !isString {
+				break
+			}
+
+			if _, err := metadataRegexp(pattern); err != nil {
+				return errors.Wrapf(errors.ErrInvalidRule, "%s: %s", c.Key, err)
+			}
+
+			ok = true
+		default:
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+		}
+	}
+
+	if !ok {
+		return errors.Wrapf(
+			errors.ErrInvalidTypeToMatch,
+			"%s: value %v invalid for '%s'",
+			c.Key,
+			c.Value,
+			c.Comparator,
+		)
+	}
+
+	return nil
+}
+
+func metadataRegexp(pattern string) (*regexp.Regexp, error) {
+	metadataRegexps.RLock()
+	re, ok := metadataRegexps.m[pattern]
+	metadataRegexps.RUnlock()
+
+	if ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataRegexps.Lock()
+	metadataRegexps.m[pattern] = re
+	metadataRegexps.Unlock()
+
+	return re, nil
+}
+
+// lookupMetadata returns the value addressed by the path in the metadata.
+func lookupMetadata(metadata map[string]interface{}, path string) (interface{}, bool) {
+	var (
+		keys    = strings.Split(path, metadataPathSeparator)
+		current = metadata
+	)
+
+	for i, key := range keys {
+		v, ok := current[key]
+		if !ok {
+			return nil, false
+		}
+
+		if i == len(keys)-1 {
+			return v, true
+		}
+
+		current, ok = v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return nil, false
+}
+
+// matchMetadata matches the value at the path of the criterion. Values which
+// are absent or of another type than the criterion expects never match.
+func matchMetadata(c Criterion, metadata map[string]interface{}) error {
+	input, ok := lookupMetadata(metadata, c.Path)
+	if !ok {
+		return errors.Wrapf(errors.ErrCriterionNotMatch, "metadata '%s' missing", c.Path)
+	}
+
+	var err error
+
+	switch c.Key {
+	case MetadataBool:
+		ok, err = matchMetadataBool(c, input)
+	case MetadataNumber:
+		ok, err = matchMetadataNumber(c, input)
+	case MetadataString:
+		ok, err = matchMetadataString(c, input)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errors.Wrapf(
+			errors.ErrCriterionNotMatch,
+			"metadata '%s' %v doesn't match %s %v",
+			c.Path,
+			input,
+			c.Comparator,
+			c.Value,
+		)
+	}
+
+	return nil
+}
+
+func matchMetadataBool(c Criterion, input interface{}) (bool, error) {
+	expected, ok := c.Value.(bool)
+	if !ok {
+		return false, errors.Wrapf(errors.ErrInvalidTypeToMatch, "%s: value not a bool", c.Key)
+	}
+
+	in, ok := input.(bool)
+	if !ok {
+		return false, nil
+	}
+
+	switch c.Comparator {
+	case ComparatorEQ:
+		return in == expected, nil
+	case ComparatorNQ:
+		return in != expected, nil
+	default:
+		return false, errors.Errorf("comparator '%s' not supported", c.Comparator)
+	}
+}
+
+func matchMetadataNumber(c Criterion, input interface{}) (bool, error) {
+	if c.Comparator == ComparatorIN {
+		expected, ok := c.Value.([]float64)
+		if !ok {
+			return false, errors.Wrapf(errors.ErrInvalidTypeToMatch, "%s: value not a number slice", c.Key)
+		}
+
+		in, ok := toFloat(input)
+		if !ok {
+			return false, nil
+		}
+
+		for _, e := range expected {
+			if e == in {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	expected, ok := c.Value.(float64)
+	if !ok {
+		return false, errors.Wrapf(errors.ErrInvalidTypeToMatch, "%s: value not a number", c.Key)
+	}
+
+	if c.Comparator == ComparatorContains {
+		for _, v := range toSlice(input) {
+			if in, ok := toFloat(v); ok && in == expected {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	in, ok := toFloat(input)
+	if !ok {
+		return false, nil
+	}
+
+	switch c.Comparator {
+	case ComparatorEQ:
+		return in == expected, nil
+	case ComparatorNQ:
+		return in != expected, nil
+	case ComparatorGT:
+		return in > expected, nil
+	case ComparatorLT:
+		return in < expected, nil
+	default:
+		return false, errors.Errorf("comparator '%s' not supported", c.Comparator)
+	}
+}
+
+func matchMetadataString(c Criterion, input interface{}) (bool, error) {
+	if c.Comparator == ComparatorIN {
+		expected, ok := c.Value.([]string)
+		if !ok {
+			return false, errors.Wrapf(errors.ErrInvalidTypeToMatch, "%s: value not a string slice", c.Key)
+		}
+
+		in, ok := input.(string)
+		if !ok {
+			return false, nil
+		}
+
+		for _, e := range expected {
+			if e == in {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	expected, ok := c.Value.(string)
+	if !ok {
+		return false, errors.Wrapf(errors.ErrInvalidTypeToMatch, "%s: value not a string", c.Key)
+	}
+
+	if c.Comparator == ComparatorContains {
+		if in, ok := input.(string); ok {
+			return strings.Contains(in, expected), nil
+		}
+
+		for _, v := range toSlice(input) {
+			if in, ok := v.(string); ok && in == expected {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	in, ok := input.(string)
+	if !ok {
+		return false, nil
+	}
+
+	switch c.Comparator {
+	case ComparatorEQ:
+		return in == expected, nil
+	case ComparatorNQ:
+		return in != expected, nil
+	case ComparatorRegex:
+		re, err := metadataRegexp(expected)
+		if err != nil {
+			return false, errors.Wrapf(errors.ErrInvalidRule, "%s: %s", c.Key, err)
+		}
+
+		return re.MatchString(in), nil
+	default:
+		return false, errors.Errorf("comparator '%s' not supported", c.Comparator)
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+func toSlice(v interface{}) []interface{} {
+	switch s := v.(type) {
+	case []interface{}:
+		return s
+	case []string:
+		vs := []interface{}{}
+
+		for _, e := range s {
+			vs = append(vs, e)
+		}
+
+		return vs
+	case []float64:
+		vs := []interface{}{}
+
+		for _, e := range s {
+			vs = append(vs, e)
+		}
+
+		return vs
+	case []int:
+		vs := []interface{}{}
+
+		for _, e := range s {
+			vs = append(vs, e)
+		}
+
+		return vs
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,115 @@
+package rule
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+func TestCriterionMetadataMarshal(t *testing.T) {
+	for _, want := range []Criterion{
+		{
+			Comparator: ComparatorNQ,
+			Key:        MetadataBool,
+			Value:      true,
+			Path:       "beta",
+		},
+		{
+			Comparator: ComparatorIN,
+			Key:        MetadataNumber,
+			Value:      []float64{1, 2},
+			Path:       "onboarding.step",
+		},
+		{
+			Comparator: ComparatorRegex,
+			Key:        MetadataString,
+			Value:      "^lose_",
+			Path:       "onboarding.goal",
+		},
+	} {
+		raw, err := json.Marshal(&want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var have Criterion
+
+		err = json.Unmarshal(raw, &have)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
+
+func TestCriterionMetadataInvalid(t *testing.T) {
+	cases := map[string]error{
+		`{"comparator": 1, "key": 203, "value": "lose_weight", "path": ""}`:          errors.ErrInvalidRule,
+		`{"comparator": 6, "key": 203, "value": "(", "path": "goal"}`:                errors.ErrInvalidRule,
+		`{"comparator": 6, "key": 202, "value": 1, "path": "goal"}`:                  errors.ErrInvalidRule,
+		`{"comparator": 3, "key": 202, "value": 1, "path": "goal"}`:                  errors.ErrInvalidTypeToMatch,
+		`{"comparator": 1, "key": 201, "value": "true", "path": "goal"}`:             errors.ErrInvalidTypeToMatch,
+		`{"comparator": 3, "key": 203, "value": ["lose_weight", 1], "path": "goal"}`: errors.ErrInvalidTypeToMatch,
+	}
+
+	for input, want := range cases {
+		err := json.Unmarshal([]byte(input), &Criterion{})
+		if have := errors.Cause(err); have != want {
+			t.Errorf("%s: have %v, want %v", input, have, want)
+		}
+	}
+}
+
+func TestCriterionMetadataMatch(t *testing.T) {
+	metadata := map[string]interface{}{
+		"beta": true,
+		"onboarding": map[string]interface{}{
+			"goal":  "lose_weight",
+			"step":  float64(3),
+			"diets": []interface{}{"keto", "vegan"},
+		},
+		"weeks": []interface{}{float64(1), float64(2)},
+	}
+
+	cases := []struct {
+		criterion Criterion
+		match     bool
+	}{
+		{Criterion{ComparatorEQ, MetadataBool, true, "beta"}, true},
+		{Criterion{ComparatorNQ, MetadataBool, true, "beta"}, false},
+		{Criterion{ComparatorEQ, MetadataNumber, float64(3), "onboarding.step"}, true},
+		{Criterion{ComparatorNQ, MetadataNumber, float64(3), "onboarding.step"}, false},
+		{Criterion{ComparatorGT, MetadataNumber, float64(2), "onboarding.step"}, true},
+		{Criterion{ComparatorLT, MetadataNumber, float64(2), "onboarding.step"}, false},
+		{Criterion{ComparatorIN, MetadataNumber, []float64{1, 3}, "onboarding.step"}, true},
+		{Criterion{ComparatorContains, MetadataNumber, float64(2), "weeks"}, true},
+		{Criterion{ComparatorContains, MetadataNumber, float64(3), "weeks"}, false},
+		{Criterion{ComparatorEQ, MetadataString, "lose_weight", "onboarding.goal"}, true},
+		{Criterion{ComparatorNQ, MetadataString, "lose_weight", "onboarding.goal"}, false},
+		{Criterion{ComparatorIN, MetadataString, []string{"gain_weight", "lose_weight"}, "onboarding.goal"}, true},
+		{Criterion{ComparatorContains, MetadataString, "vegan", "onboarding.diets"}, true},
+		{Criterion{ComparatorContains, MetadataString, "weight", "onboarding.goal"}, true},
+		{Criterion{ComparatorRegex, MetadataString, "^lose_", "onboarding.goal"}, true},
+		{Criterion{ComparatorRegex, MetadataString, "^gain_", "onboarding.goal"}, false},
+		// Values of another type or absent values never match.
+		{Criterion{ComparatorEQ, MetadataString, "3", "onboarding.step"}, false},
+		{Criterion{ComparatorNQ, MetadataString, "keto", "onboarding.missing"}, false},
+		{Criterion{ComparatorEQ, MetadataString, "keto", "beta.diet"}, false},
+	}
+
+	for _, c := range cases {
+		err := c.criterion.match(Context{Metadata: metadata})
+
+		switch {
+		case c.match && err != nil:
+			t.Errorf("%v: have %v, want match", c.criterion, err)
+		case !c.match && errors.Cause(err) != errors.ErrCriterionNotMatch:
+			t.Errorf("%v: have %v, want %v", c.criterion, err, errors.ErrCriterionNotMatch)
+		}
+	}
+}
@@ -14,58 +14,55 @@ import (
 )
 
 const (
-	pgDefaultSchmea = "rule"
-
-	pgRuleCreateSchema = `CREATE SCHEMA IF NOT EXISTS %s`
-	pgRuleCreateTable  = `
-		CREATE TABLE IF NOT EXISTS %s.rules(
-			id TEXT NOT NULL PRIMARY KEY,
-			active BOOLEAN NOT NULL DEFAULT FALSE,
-			buckets JSONB NOT NULL,
-			config_id TEXT NOT NULL,
-			criteria JSONB NOT NULL,
-			description TEXT NOT NULL,
-			deleted BOOLEAN NOT NULL DEFAULT FALSE,
-			kind INT8 NOT NULL,
-			name TEXT NOT NULL,
-			rollout INT8 NOT NULL,
-			activated_at TIMESTAMP WITHOUT TIME ZONE,
-			created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (now() AT TIME ZONE 'utc'),
-			end_time TIMESTAMP WITHOUT TIME ZONE,
-			start_time TIMESTAMP WITHOUT TIME ZONE,
-			updated_at TIMESTAMP WITHOUT TIME ZONE
-		)`
-	pgRuleDropTable = `DROP TABLE IF EXISTS %s.rules CASCADE`
+	// PGDefaultSchema is the Postgres schema rules are stored in unless
+	// configured otherwise.
+	PGDefaultSchema = "rule"
+
+	pgGuardrailComponent = "guardrails"
+	pgRuleComponent      = "rules"
+	pgScheduleComponent  = "schedules"
+	pgSegmentComponent   = "segments"
+	pgUserListComponent  = "user_lists"
 
 	pgRuleInsert = `
 		INSERT INTO
 		%s.rules(
 			id,
 			active,
+			bucketing,
 			buckets,
 			config_id,
 			created_at,
 			criteria,
 			description,
 			end_time,
+			generation,
 			kind,
+			layer,
 			name,
+			reshuffle,
 			rollout,
 			start_time,
+			sticky,
 			updated_at)
 			VALUES(
 				:id,
 				:active,
+				:bucketing,
 				:buckets,
 				:configId,
 				:createdAt,
 				:criteria,
 				:description,
 				:endTime,
+				:generation,
 				:kind,
+				:layer,
 				:name,
+				:reshuffle,
 				:rollout,
 				:startTime,
+				:sticky,
 				:updatedAt
 		)`
 
@@ -74,6 +71,7 @@ const (
 			id,
 			active,
 			activated_at,
+			bucketing,
 			buckets,
 			config_id,
 			created_at,
@@ -81,10 +79,14 @@ const (
 			description,
 			deleted,
 			end_time,
+			generation,
 			kind,
+			layer,
 			name,
+			reshuffle,
 			rollout,
 			start_time,
+			sticky,
 			updated_at
 		FROM
 			%s.rules
@@ -96,20 +98,92 @@ const (
 		LIMIT
 			1`
 
+	pgRuleUpsert = `
+		INSERT INTO
+		%s.rules(
+			id,
+			active,
+			activated_at,
+			bucketing,
+			buckets,
+			config_id,
+			created_at,
+			criteria,
+			description,
+			deleted,
+			end_time,
+			generation,
+			kind,
+			layer,
+			name,
+			reshuffle,
+			rollout,
+			start_time,
+			sticky,
+			updated_at)
+			VALUES(
+				:id,
+				:active,
+				:activatedAt,
+				:bucketing,
+				:buckets,
+				:configId,
+				:createdAt,
+				:criteria,
+				:description,
+				:deleted,
+				:endTime,
+				:generation,
+				:kind,
+				:layer,
+				:name,
+				:reshuffle,
+				:rollout,
+				:startTime,
+				:sticky,
+				:updatedAt
+		)
+		ON CONFLICT (id) DO UPDATE
+		SET
+			active = EXCLUDED.active,
+			activated_at = EXCLUDED.activated_at,
+			bucketing = EXCLUDED.bucketing,
+			buckets = EXCLUDED.buckets,
+			config_id = EXCLUDED.config_id,
+			created_at = EXCLUDED.created_at,
+			criteria = EXCLUDED.criteria,
+			description = EXCLUDED.description,
+			deleted = EXCLUDED.deleted,
+			end_time = EXCLUDED.end_time,
+			generation = EXCLUDED.generation,
+			kind = EXCLUDED.kind,
+			layer = EXCLUDED.layer,
+			name = EXCLUDED.name,
+			reshuffle = EXCLUDED.reshuffle,
+			rollout = EXCLUDED.rollout,
+			start_time = EXCLUDED.start_time,
+			sticky = EXCLUDED.sticky,
+			updated_at = EXCLUDED.updated_at`
+
 	pgRuleUpdate = `
 		UPDATE %s.rules
 		SET
 			active = :active,
 			activated_at = :activatedAt,
+			bucketing = :bucketing,
 			buckets = :buckets,
 			criteria = :criteria,
 			description = :description,
 			deleted = :deleted,
 			end_time = :endTime,
+			generation = :generation,
 			kind = :kind,
+			layer = :layer,
 			name = :name,
+			reshuffle = :reshuffle,
 			rollout = :rollout,
 			start_time = :startTime,
+			sticky = :sticky,
 			updated_at = :updatedAt
 		WHERE
 			config_id = :configId
@@ -121,16 +195,21 @@ const (
 			id,
 			active,
 			activated_at,
+			bucketing,
 			buckets,
 			config_id,
 			created_at,
 			criteria,
 			description,
 			end_time,
+			generation,
 			kind,
+			layer,
 			name,
+			reshuffle,
 			rollout,
 			start_time,
+			sticky,
 			updated_at
 		FROM
 			%s.rules
@@ -142,16 +221,21 @@ const (
 			id,
 			active,
 			activated_at,
+			bucketing,
 			buckets,
 			config_id,
 			created_at,
 			criteria,
 			description,
 			end_time,
+			generation,
 			kind,
+			layer,
 			name,
+			reshuffle,
 			rollout,
 			start_time,
+			sticky,
 			updated_at
 		FROM
 			%s.rules
@@ -167,8 +251,533 @@ const (
 				start_time IS NULL
 				OR start_time <= :now
 			)`
+
+	pgScheduleGet = `
+		/* pgScheduleGet */
+		SELECT
+			rule_id, steps, applied, state, created_at, updated_at
+		FROM
+			%s.schedules
+		WHERE
+			rule_id = :ruleId`
+
+	pgScheduleListRunning = `
+		/* pgScheduleListRunning */
+		SELECT
+			rule_id, steps, applied, state, created_at, updated_at
+		FROM
+			%s.schedules
+		WHERE
+			state = 'running'
+		ORDER BY
+			rule_id ASC`
+
+	pgScheduleUpsert = `
+		/* pgScheduleUpsert */
+		INSERT INTO
+			%s.schedules(rule_id, steps, applied, state, created_at, updated_at)
+			VALUES(:ruleId, :steps, :applied, :state, :createdAt, :updatedAt)
+		ON CONFLICT (rule_id) DO UPDATE
+		SET
+			steps = EXCLUDED.steps,
+			applied = EXCLUDED.applied,
+			state = EXCLUDED.state,
+			created_at = EXCLUDED.created_at,
+			updated_at = EXCLUDED.updated_at`
+
+	pgScheduleLock = `
+		/* pgScheduleLock */
+		SELECT
+			updated_at
+		FROM
+			%s.schedules
+		WHERE
+			rule_id = :ruleId
+		FOR UPDATE`
+
+	pgScheduleUpdate = `
+		/* pgScheduleUpdate */
+		UPDATE
+			%s.schedules
+		SET
+			applied = :applied,
+			state = :state,
+			updated_at = :updatedAt
+		WHERE
+			rule_id = :ruleId`
+
+	pgScheduleRecordInsert = `
+		/* pgScheduleRecordInsert */
+		INSERT INTO
+			%s.schedule_records(id, rule_id, event, step, rollout, created_at)
+			VALUES(:id, :ruleId, :event, :step, :rollout, :createdAt)`
+
+	pgScheduleRecordList = `
+		/* pgScheduleRecordList */
+		SELECT
+			id, rule_id, event, step, rollout, created_at
+		FROM
+			%s.schedule_records
+		WHERE
+			rule_id = :ruleId
+		ORDER BY
+			created_at ASC, id ASC`
+
+	pgGuardrailGet = `
+		/* pgGuardrailGet */
+		SELECT
+			rule_id, metric, threshold, comparison, min_samples, breached_at, created_at, updated_at
+		FROM
+			%s.guardrails
+		WHERE
+			rule_id = :ruleId`
+
+	pgGuardrailListArmed = `
+		/* pgGuardrailListArmed */
+		SELECT
+			rule_id, metric, threshold, comparison, min_samples, breached_at, created_at, updated_at
+		FROM
+			%s.guardrails
+		WHERE
+			breached_at IS NULL
+		ORDER BY
+			rule_id ASC`
+
+	pgGuardrailUpsert = `
+		/* pgGuardrailUpsert */
+		INSERT INTO
+			%s.guardrails(rule_id, metric, threshold, comparison, min_samples, breached_at, created_at, updated_at)
+			VALUES(:ruleId, :metric, :threshold, :comparison, :minSamples, NULL, :createdAt, :updatedAt)
+		ON CONFLICT (rule_id) DO UPDATE
+		SET
+			metric = EXCLUDED.metric,
+			threshold = EXCLUDED.threshold,
+			comparison = EXCLUDED.comparison,
+			min_samples = EXCLUDED.min_samples,
+			breached_at = NULL,
+			created_at = EXCLUDED.created_at,
+			updated_at = EXCLUDED.updated_at`
+
+	pgGuardrailBreach = `
+		/* pgGuardrailBreach */
+		UPDATE
+			%s.guardrails
+		SET
+			breached_at = :breachedAt,
+			updated_at = :breachedAt
+		WHERE
+			rule_id = :ruleId`
+
+	pgGuardrailMetricAdd = `
+		/* pgGuardrailMetricAdd */
+		INSERT INTO
+			%s.guardrail_metrics AS m(rule_id, metric, grp, count, sum, updated_at)
+			VALUES(:ruleId, :metric, :group, :count, :sum, :updatedAt)
+		ON CONFLICT (rule_id, metric, grp) DO UPDATE
+		SET
+			count = m.count + EXCLUDED.count,
+			sum = m.sum + EXCLUDED.sum,
+			updated_at = EXCLUDED.updated_at`
+
+	pgGuardrailMetricSet = `
+		/* pgGuardrailMetricSet */
+		INSERT INTO
+			%s.guardrail_metrics(rule_id, metric, grp, count, sum, updated_at)
+			VALUES(:ruleId, :metric, :group, :count, :sum, :updatedAt)
+		ON CONFLICT (rule_id, metric, grp) DO UPDATE
+		SET
+			count = EXCLUDED.count,
+			sum = EXCLUDED.sum,
+			updated_at = EXCLUDED.updated_at`
+
+	pgGuardrailMetricDelete = `
+		/* pgGuardrailMetricDelete */
+		DELETE FROM
+			%s.guardrail_metrics
+		WHERE
+			rule_id = :ruleId`
+
+	pgGuardrailMetricList = `
+		/* pgGuardrailMetricList */
+		SELECT
+			rule_id, metric, grp, count, sum, updated_at
+		FROM
+			%s.guardrail_metrics
+		WHERE
+			rule_id = :ruleId
+		ORDER BY
+			metric ASC, grp ASC`
+
+	pgSegmentInsert = `
+		/* pgSegmentInsert */
+		INSERT INTO
+			%s.segments(id, version, name, description, criteria, deleted, created_at)
+			VALUES(:id, :version, :name, :description, :criteria, :deleted, :createdAt)`
+
+	pgSegmentGet = `
+		/* pgSegmentGet */
+		SELECT
+			id, version, name, description, criteria, deleted, created_at
+		FROM
+			%s.segments
+		WHERE
+			id = :id
+		ORDER BY
+			version DESC
+		LIMIT
+			1`
+
+	pgSegmentList = `
+		/* pgSegmentList */
+		SELECT
+			id, version, name, description, criteria, deleted, created_at
+		FROM (
+			SELECT DISTINCT ON (id)
+				id, version, name, description, criteria, deleted, created_at
+			FROM
+				%s.segments
+			ORDER BY
+				id ASC, version DESC
+		) AS latest
+		WHERE
+			deleted = false
+		ORDER BY
+			name ASC`
+
+	pgSegmentListVersions = `
+		/* pgSegmentListVersions */
+		SELECT
+			id, version, name, description, criteria, deleted, created_at
+		FROM
+			%s.segments
+		WHERE
+			id = :id
+		ORDER BY
+			version ASC`
+
+	pgUserListInsert = `
+		/* pgUserListInsert */
+		INSERT INTO
+			%s.user_lists(id, name, description, size, version, created_at, updated_at)
+			VALUES(:id, :name, :description, :size, :version, :createdAt, :updatedAt)`
+
+	pgUserListGet = `
+		/* pgUserListGet */
+		SELECT
+			id, name, description, size, version, created_at, updated_at
+		FROM
+			%s.user_lists
+		WHERE
+			id = :id
+		LIMIT
+			1`
+
+	pgUserListList = `
+		/* pgUserListList */
+		SELECT
+			id, name, description, size, version, created_at, updated_at
+		FROM
+			%s.user_lists
+		ORDER BY
+			name ASC`
+
+	pgUserListUpdate = `
+		/* pgUserListUpdate */
+		UPDATE
+			%s.user_lists
+		SET
+			name = :name,
+			description = :description,
+			size = :size,
+			version = version + 1,
+			updated_at = :updatedAt
+		WHERE
+			id = :id
+		RETURNING
+			version`
+
+	pgUserListListByMember = `
+		/* pgUserListListByMember */
+		SELECT
+			l.id, l.name, l.description, l.size, l.version, l.created_at, l.updated_at
+		FROM
+			%[1]s.user_lists l
+			JOIN %[1]s.user_list_members m ON m.list_id = l.id
+		WHERE
+			m.user_id = :userId
+		ORDER BY
+			l.name ASC`
+
+	pgUserListMemberRemove = `
+		/* pgUserListMemberRemove */
+		WITH removed AS (
+			DELETE FROM
+				%[1]s.user_list_members
+			WHERE
+				user_id = :userId
+			RETURNING
+				list_id
+		)
+		UPDATE
+			%[1]s.user_lists
+		SET
+			size = size - 1,
+			version = version + 1,
+			updated_at = :updatedAt
+		WHERE
+			id IN (SELECT list_id FROM removed)`
+
+	pgUserListMembers = `
+		/* pgUserListMembers */
+		SELECT
+			user_id
+		FROM
+			%s.user_list_members
+		WHERE
+			list_id = :id`
+
+	pgUserListMembersDelete = `
+		/* pgUserListMembersDelete */
+		DELETE FROM
+			%s.user_list_members
+		WHERE
+			list_id = :id`
 )
 
+var pgRuleMigrations = []pg.Migration{
+	{
+		Version:     1,
+		Description: "create rules",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS %s.rules(
+				id TEXT NOT NULL PRIMARY KEY,
+				active BOOLEAN NOT NULL DEFAULT FALSE,
+				buckets JSONB NOT NULL,
+				config_id TEXT NOT NULL,
+				criteria JSONB NOT NULL,
+				description TEXT NOT NULL,
+				deleted BOOLEAN NOT NULL DEFAULT FALSE,
+				kind INT8 NOT NULL,
+				name TEXT NOT NULL,
+				rollout INT8 NOT NULL,
+				activated_at TIMESTAMP WITHOUT TIME ZONE,
+				created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT (now() AT TIME ZONE 'utc'),
+				end_time TIMESTAMP WITHOUT TIME ZONE,
+				start_time TIMESTAMP WITHOUT TIME ZONE,
+				updated_at TIMESTAMP WITHOUT TIME ZONE
+			)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS %s.rules CASCADE`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "add layer to rules",
+		Up: []string{
+			`ALTER TABLE %s.rules ADD COLUMN IF NOT EXISTS layer JSONB`,
+		},
+		Down: []string{
+			`ALTER TABLE IF EXISTS %s.rules DROP COLUMN IF EXISTS layer`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "add stickiness to rules",
+		Up: []string{
+			`ALTER TABLE %s.rules ADD COLUMN IF NOT EXISTS sticky BOOLEAN NOT NULL DEFAULT FALSE`,
+			`ALTER TABLE %s.rules ADD COLUMN IF NOT EXISTS reshuffle BOOLEAN NOT NULL DEFAULT FALSE`,
+			`ALTER TABLE %s.rules ADD COLUMN IF NOT EXISTS generation INT8 NOT NULL DEFAULT 0`,
+		},
+		Down: []string{
+			`ALTER TABLE IF EXISTS %s.rules DROP COLUMN IF EXISTS generation`,
+			`ALTER TABLE IF EXISTS %s.rules DROP COLUMN IF EXISTS reshuffle`,
+			`ALTER TABLE IF EXISTS %s.rules DROP COLUMN IF EXISTS sticky`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "add bucketing to rules",
+		Up: []string{
+			`ALTER TABLE %s.rules ADD COLUMN IF NOT EXISTS bucketing JSONB`,
+		},
+		Down: []string{
+			`ALTER TABLE IF EXISTS %s.rules DROP COLUMN IF EXISTS bucketing`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "make rule names unique per base config",
+		Up: []string{`
+			UPDATE
+				%[1]s.rules AS r
+			SET
+				name = r.name || ' (' || r.id || ')'
+			FROM
+				%[1]s.rules AS o
+			WHERE
+				o.config_id = r.config_id
+				AND o.name = r.name
+				AND o.id <> r.id
+				AND NOT o.deleted
+				AND NOT r.deleted
+				AND (o.created_at, o.id) < (r.created_at, r.id)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS rules_config_id_name ON %s.rules(config_id, name) WHERE NOT deleted`,
+		},
+		Down: []string{
+			`DROP INDEX IF EXISTS %s.rules_config_id_name`,
+		},
+	},
+}
+
+var pgScheduleMigrations = []pg.Migration{
+	{
+		Version:     1,
+		Description: "create schedules and schedule records",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS %s.schedules(
+				rule_id TEXT NOT NULL PRIMARY KEY,
+				steps JSONB NOT NULL,
+				applied INT NOT NULL,
+				state TEXT NOT NULL,
+				created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+				updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+			)`, `
+			CREATE TABLE IF NOT EXISTS %s.schedule_records(
+				id TEXT NOT NULL PRIMARY KEY,
+				rule_id TEXT NOT NULL,
+				event TEXT NOT NULL,
+				step INT NOT NULL,
+				rollout INT8 NOT NULL,
+				created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+			)`, `
+			CREATE INDEX IF NOT EXISTS
+				schedule_records_rule_id
+			ON
+				%s.schedule_records(rule_id, created_at)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS %s.schedule_records CASCADE`,
+			`DROP TABLE IF EXISTS %s.schedules CASCADE`,
+		},
+	},
+}
+
+var pgGuardrailMigrations = []pg.Migration{
+	{
+		Version:     1,
+		Description: "create guardrails and guardrail metrics",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS %s.guardrails(
+				rule_id TEXT NOT NULL PRIMARY KEY,
+				metric TEXT NOT NULL,
+				threshold DOUBLE PRECISION NOT NULL,
+				comparison TEXT NOT NULL,
+				breached_at TIMESTAMP WITHOUT TIME ZONE,
+				created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+				updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+			)`, `
+			CREATE TABLE IF NOT EXISTS %s.guardrail_metrics(
+				rule_id TEXT NOT NULL,
+				metric TEXT NOT NULL,
+				grp TEXT NOT NULL,
+				count INT8 NOT NULL,
+				sum DOUBLE PRECISION NOT NULL,
+				updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+				PRIMARY KEY (rule_id, metric, grp)
+			)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS %s.guardrail_metrics CASCADE`,
+			`DROP TABLE IF EXISTS %s.guardrails CASCADE`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "add minimum number of samples to guardrails",
+		Up: []string{`
+			ALTER TABLE %s.guardrails ADD COLUMN IF NOT EXISTS min_samples INT NOT NULL DEFAULT 0`,
+		},
+		Down: []string{
+			`ALTER TABLE %s.guardrails DROP COLUMN IF EXISTS min_samples`,
+		},
+	},
+}
+
+var pgSegmentMigrations = []pg.Migration{
+	{
+		Version:     1,
+		Description: "create segments",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS %s.segments(
+				id TEXT NOT NULL,
+				version INT NOT NULL,
+				name TEXT NOT NULL,
+				description TEXT NOT NULL,
+				criteria JSONB NOT NULL,
+				deleted BOOLEAN NOT NULL DEFAULT FALSE,
+				created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+				PRIMARY KEY (id, version)
+			)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS %s.segments CASCADE`,
+		},
+	},
+}
+
+var pgUserListMigrations = []pg.Migration{
+	{
+		Version:     1,
+		Description: "create user lists",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS %s.user_lists(
+				id TEXT NOT NULL PRIMARY KEY,
+				name TEXT NOT NULL UNIQUE,
+				description TEXT NOT NULL,
+				size INT NOT NULL DEFAULT 0,
+				version INT NOT NULL DEFAULT 0,
+				created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+				updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+			)`, `
+			CREATE TABLE IF NOT EXISTS %s.user_list_members(
+				list_id TEXT NOT NULL REFERENCES %[1]s.user_lists(id) ON DELETE CASCADE,
+				user_id TEXT NOT NULL,
+				PRIMARY KEY (list_id, user_id)
+			)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS %s.user_list_members CASCADE`,
+			`DROP TABLE IF EXISTS %s.user_lists CASCADE`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "index member lookup",
+		Up: []string{`
+			CREATE INDEX IF NOT EXISTS
+				user_list_members_user_id
+			ON
+				%s.user_list_members(user_id)`,
+		},
+		Down: []string{
+			`DROP INDEX IF EXISTS %s.user_list_members_user_id`,
+		},
+	},
+}
+
+// PGMigrators returns the migrators of all Postgres repos of the package in
+// their default schema.
+func PGMigrators(db *sqlx.DB) []*pg.Migrator {
+	return []*pg.Migrator{
+		(&PGRepo{db: db, schema: PGDefaultSchema}).Migrator(),
+		(&PGScheduleRepo{db: db, schema: PGDefaultSchema}).Migrator(),
+		(&PGGuardrailRepo{db: db, schema: PGDefaultSchema}).Migrator(),
+		(&PGSegmentRepo{db: db, schema: PGDefaultSchema}).Migrator(),
+		(&PGUserListRepo{db: db, schema: PGDefaultSchema}).Migrator(),
+	}
+}
+
 // PGRepoOption sets an optional parameter on the repo.
 type PGRepoOption func(*PGRepo)
 
@@ -188,7 +797,7 @@ type PGRepo struct {
 func NewPostgresRepo(db *sqlx.DB, options ...PGRepoOption) Repo {
 	r := &PGRepo{
 		db:     db,
-		schema: pgDefaultSchmea,
+		schema: PGDefaultSchema,
 	}
 
 	for _, option := range options {
@@ -210,22 +819,37 @@ func (r *PGRepo) Create(input Rule) (Rule, error) {
 		return Rule{}, errors.Wrap(err, "marshal criteria")
 	}
 
+	rawLayer, err := marshalLayer(input.layer)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	rawBucketing, err := marshalBucketing(input.bucketing)
+	if err != nil {
+		return Rule{}, err
+	}
+
 	input.createdAt = input.createdAt.UTC()
 	input.updatedAt = time.Now().UTC()
 
 	args := map[string]interface{}{
 		"id":          input.ID,
 		"active":      input.active,
+		"bucketing":   rawBucketing,
 		"buckets":     rawBuckets,
 		"configId":    input.configID,
 		"createdAt":   input.createdAt,
 		"criteria":    rawCriteria,
 		"description": input.description,
 		"endTime":     input.endTime,
+		"generation":  input.generation,
 		"kind":        input.kind,
+		"layer":       rawLayer,
 		"name":        input.name,
+		"reshuffle":   input.reshuffle,
 		"rollout":     input.rollout,
 		"startTime":   input.startTime,
+		"sticky":      input.sticky,
 		"updatedAt":   input.updatedAt,
 	}
 
@@ -272,6 +896,7 @@ func (r *PGRepo) GetByID(id string) (Rule, error) {
 		ID          string      `db:"id"`
 		Active      bool        `db:"active"`
 		ActivatedAt pq.NullTime `db:"activated_at"`
+		Bucketing   []byte      `db:"bucketing"`
 		Buckets     []byte      `db:"buckets"`
 		ConfigID    string      `db:"config_id"`
 		CreatedAt   time.Time   `db:"created_at"`
@@ -279,10 +904,14 @@ func (r *PGRepo) GetByID(id string) (Rule, error) {
 		Description string      `db:"description"`
 		Deleted     bool        `db:"deleted"`
 		EndTime     pq.NullTime `db:"end_time"`
+		Generation  int         `db:"generation"`
 		Kind        Kind        `db:"kind"`
+		Layer       []byte      `db:"layer"`
 		Name        string      `db:"name"`
+		Reshuffle   bool        `db:"reshuffle"`
 		Rollout     uint8       `db:"rollout"`
 		StartTime   pq.NullTime `db:"start_time"`
+		Sticky      bool        `db:"sticky"`
 		UpdatedAt   time.Time   `db:"updated_at"`
 	}{}
 
@@ -321,6 +950,16 @@ func (r *PGRepo) GetByID(id string) (Rule, error) {
 		}
 	}
 
+	layer, err := unmarshalLayer(raw.Layer)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	bucketing, err := unmarshalBucketing(raw.Bucketing)
+	if err != nil {
+		return Rule{}, err
+	}
+
 	var activatedAt time.Time
 	if raw.ActivatedAt.Valid {
 		activatedAt = (raw.ActivatedAt).Time
@@ -340,6 +979,7 @@ func (r *PGRepo) GetByID(id string) (Rule, error) {
 		ID:          raw.ID,
 		active:      raw.Active,
 		activatedAt: activatedAt,
+		bucketing:   bucketing,
 		buckets:     buckets,
 		configID:    raw.ConfigID,
 		createdAt:   raw.CreatedAt.UTC(),
@@ -347,10 +987,14 @@ func (r *PGRepo) GetByID(id string) (Rule, error) {
 		description: raw.Description,
 		deleted:     raw.Deleted,
 		endTime:     endTime,
+		generation:  raw.Generation,
 		kind:        raw.Kind,
+		layer:       layer,
 		name:        raw.Name,
+		reshuffle:   raw.Reshuffle,
 		rollout:     raw.Rollout,
 		startTime:   startTime,
+		sticky:      raw.Sticky,
 		updatedAt:   raw.UpdatedAt.UTC(),
 	}, nil
 }
@@ -368,6 +1012,16 @@ func (r *PGRepo) UpdateWith(input Rule) (Rule, error) {
 		return Rule{}, errors.Wrap(err, "marshal criteria")
 	}
 
+	rawLayer, err := marshalLayer(input.layer)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	rawBucketing, err := marshalBucketing(input.bucketing)
+	if err != nil {
+		return Rule{}, err
+	}
+
 	_, err = r.db.NamedExec(
 		r.prefixSchema(pgRuleUpdate),
 		map[string]interface{}{
@@ -375,16 +1029,21 @@ func (r *PGRepo) UpdateWith(input Rule) (Rule, error) {
 			"active":      input.active,
 			"activatedAt": input.activatedAt,
 			"configId":    input.configID,
+			"bucketing":   rawBucketing,
 			"buckets":     rawBuckets,
 			"createdAt":   input.createdAt,
 			"criteria":    rawCriteria,
 			"description": input.description,
 			"deleted":     input.deleted,
 			"endTime":     input.endTime,
+			"generation":  input.generation,
 			"kind":        input.kind,
+			"layer":       rawLayer,
 			"name":        input.name,
+			"reshuffle":   input.reshuffle,
 			"rollout":     input.rollout,
 			"startTime":   input.startTime,
+			"sticky":      input.sticky,
 			"updatedAt":   time.Now().UTC(),
 		},
 	)
@@ -396,6 +1055,8 @@ func (r *PGRepo) UpdateWith(input Rule) (Rule, error) {
 			}
 
 			return r.UpdateWith(input)
+		case pg.ErrDuplicateKey:
+			return Rule{}, errors.Wrap(errors.ErrExists, "rule name")
 		case sql.ErrNoRows:
 			return Rule{}, errors.Wrap(errors.ErrNotFound, "update rule")
 
@@ -407,6 +1068,76 @@ func (r *PGRepo) UpdateWith(input Rule) (Rule, error) {
 	return input, nil
 }
 
+// PGUpsertTx creates or overrides the given rules as part of the transaction.
+// It's used by other repos which need to change rules atomically with their
+// own tables, e.g. promotions of base configs between environments.
+func PGUpsertTx(tx *sqlx.Tx, schema string, rules ...Rule) error {
+	query := fmt.Sprintf(pgRuleUpsert, schema)
+
+	for _, input := range rules {
+		rawBuckets, err := json.Marshal(input.buckets)
+		if err != nil {
+			return errors.Wrap(err, "marshal buckets")
+		}
+
+		rawCriteria, err := json.Marshal(input.criteria)
+		if err != nil {
+			return errors.Wrap(err, "marshal criteria")
+		}
+
+		rawLayer, err := marshalLayer(input.layer)
+		if err != nil {
+			return err
+		}
+
+		rawBucketing, err := marshalBucketing(input.bucketing)
+		if err != nil {
+			return err
+		}
+
+		args := map[string]interface{}{
+			"id":          input.ID,
+			"active":      input.active,
+			"activatedAt": input.activatedAt,
+			"bucketing":   rawBucketing,
+			"buckets":     rawBuckets,
+			"configId":    input.configID,
+			"createdAt":   input.createdAt.UTC(),
+			"criteria":    rawCriteria,
+			"description": input.description,
+			"deleted":     input.deleted,
+			"endTime":     input.endTime,
+			"generation":  input.generation,
+			"kind":        input.kind,
+			"layer":       rawLayer,
+			"name":        input.name,
+			"reshuffle":   input.reshuffle,
+			"rollout":     input.rollout,
+			"startTime":   input.startTime,
+			"sticky":      input.sticky,
+			"updatedAt":   time.Now().UTC(),
+		}
+
+		if input.activatedAt.IsZero() {
+			args["activatedAt"] = nil
+		}
+
+		if input.endTime.IsZero() {
+			args["endTime"] = nil
+		}
+
+		if input.startTime.IsZero() {
+			args["startTime"] = nil
+		}
+
+		if _, err := tx.NamedExec(query, args); err != nil {
+			return fmt.Errorf("upsert named exec: %s", err)
+		}
+	}
+
+	return nil
+}
+
 // ListAll returns all rules.
 func (r *PGRepo) ListAll() ([]Rule, error) {
 	rows, err := r.db.Queryx(r.prefixSchema(pgRuleListAll))
@@ -464,59 +1195,1346 @@ func (r *PGRepo) ListActive(configID string, now time.Time) ([]Rule, error) {
 
 // Setup prepares the database by setting up schemas and tables.
 func (r *PGRepo) Setup() error {
-	for _, q := range []string{
-		r.prefixSchema(pgRuleCreateSchema),
-		r.prefixSchema(pgRuleCreateTable),
-	} {
-		_, err := r.db.Exec(q)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return r.Migrator().Up()
 }
 
 // Teardown cascadingly removes all database dependencies.
 func (r *PGRepo) Teardown() error {
-	for _, q := range []string{
-		r.prefixSchema(pgRuleDropTable),
-	} {
-		_, err := r.db.Exec(q)
-		if err != nil {
-			return err
-		}
-	}
+	return r.Migrator().Reset()
+}
 
-	return nil
+// Migrator returns the migrator for the tables of the repo.
+func (r *PGRepo) Migrator() *pg.Migrator {
+	return pg.NewMigrator(r.db, r.schema, pgRuleComponent, pgRuleMigrations)
 }
 
 func (r *PGRepo) prefixSchema(query string) string {
 	return fmt.Sprintf(query, r.schema)
 }
 
-func buildList(rows *sqlx.Rows) ([]Rule, error) {
-	defer func() {
-		_ = rows.Close()
-	}()
+// PGScheduleRepoOption sets an optional parameter on the schedule repo.
+type PGScheduleRepoOption func(*PGScheduleRepo)
 
-	rules := []Rule{}
+// PGScheduleRepoSchema sets the namespacing of the Postgres tables to a
+// non-default schema.
+func PGScheduleRepoSchema(schema string) PGScheduleRepoOption {
+	return func(r *PGScheduleRepo) { r.schema = schema }
+}
+
+// PGScheduleRepo is a Postgres backed ScheduleRepo implementation.
+type PGScheduleRepo struct {
+	db     *sqlx.DB
+	schema string
+}
+
+// NewPostgresScheduleRepo returns a Postgres backed ScheduleRepo
+// implementation.
+func NewPostgresScheduleRepo(
+	db *sqlx.DB,
+	options ...PGScheduleRepoOption,
+) ScheduleRepo {
+	r := &PGScheduleRepo{
+		db:     db,
+		schema: PGDefaultSchema,
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// Get returns the schedule of the rule.
+func (r *PGScheduleRepo) Get(ruleID string) (Schedule, error) {
+	query, args, err := r.db.BindNamed(
+		r.prefixSchema(pgScheduleGet),
+		map[string]interface{}{
+			"ruleId": ruleID,
+		},
+	)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("named query: %s", err)
+	}
+
+	raw := pgSchedule{}
+
+	err = r.db.Get(&raw, query, args...)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return Schedule{}, err
+			}
+
+			return r.Get(ruleID)
+		case sql.ErrNoRows:
+			return Schedule{}, errors.Wrap(errors.ErrNotFound, "get schedule")
+		default:
+			return Schedule{}, fmt.Errorf("get: %s", err)
+		}
+	}
+
+	return raw.convert()
+}
+
+// ListRecords returns the records of the schedule of the rule in order.
+func (r *PGScheduleRepo) ListRecords(ruleID string) ([]ScheduleRecord, error) {
+	query, args, err := r.db.BindNamed(
+		r.prefixSchema(pgScheduleRecordList),
+		map[string]interface{}{
+			"ruleId": ruleID,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("named query: %s", err)
+	}
+
+	raws := []struct {
+		ID        string        `db:"id"`
+		RuleID    string        `db:"rule_id"`
+		Event     ScheduleEvent `db:"event"`
+		Step      int           `db:"step"`
+		Rollout   uint8         `db:"rollout"`
+		CreatedAt time.Time     `db:"created_at"`
+	}{}
+
+	err = r.db.Select(&raws, query, args...)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return nil, err
+			}
+
+			return r.ListRecords(ruleID)
+		default:
+			return nil, fmt.Errorf("select: %s", err)
+		}
+	}
+
+	recs := []ScheduleRecord{}
+
+	for _, raw := range raws {
+		recs = append(recs, ScheduleRecord(raw))
+	}
+
+	return recs, nil
+}
+
+// ListRunning returns all schedules in the running state.
+func (r *PGScheduleRepo) ListRunning() ([]Schedule, error) {
+	raws := []pgSchedule{}
+
+	err := r.db.Select(&raws, r.prefixSchema(pgScheduleListRunning))
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return nil, err
+			}
+
+			return r.ListRunning()
+		default:
+			return nil, fmt.Errorf("select: %s", err)
+		}
+	}
+
+	ss := []Schedule{}
+
+	for _, raw := range raws {
+		s, err := raw.convert()
+		if err != nil {
+			return nil, err
+		}
+
+		ss = append(ss, s)
+	}
+
+	return ss, nil
+}
+
+// Put creates or replaces the schedule of the rule.
+func (r *PGScheduleRepo) Put(s Schedule) (Schedule, error) {
+	rawSteps, err := json.Marshal(s.Steps)
+	if err != nil {
+		return Schedule{}, errors.Wrap(err, "marshal steps")
+	}
+
+	_, err = r.db.NamedExec(
+		r.prefixSchema(pgScheduleUpsert),
+		map[string]interface{}{
+			"applied":   s.Applied,
+			"createdAt": s.CreatedAt.UTC(),
+			"ruleId":    s.RuleID,
+			"state":     s.State,
+			"steps":     rawSteps,
+			"updatedAt": s.UpdatedAt.UTC(),
+		},
+	)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return Schedule{}, err
+			}
+
+			return r.Put(s)
+		default:
+			return Schedule{}, fmt.Errorf("named exec: %s", err)
+		}
+	}
+
+	return s, nil
+}
+
+// Record updates the progress and state of the schedule and stores the record
+// in the same transaction, unless the schedule was updated since prev was
+// read.
+func (r *PGScheduleRepo) Record(prev, s Schedule, rec ScheduleRecord) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "begin")
+	}
+
+	err = r.record(tx, prev, s, rec)
+	if err != nil {
+		_ = tx.Rollback()
+
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrDuplicateKey:
+			return errors.Wrap(errors.ErrExists, "schedule record")
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return err
+			}
+
+			return r.Record(prev, s, rec)
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Setup prepares the database by setting up schemas and tables.
+func (r *PGScheduleRepo) Setup() error {
+	return r.Migrator().Up()
+}
+
+// Teardown cascadingly removes all database dependencies.
+func (r *PGScheduleRepo) Teardown() error {
+	return r.Migrator().Reset()
+}
+
+// Migrator returns the migrator for the tables of the repo.
+func (r *PGScheduleRepo) Migrator() *pg.Migrator {
+	return pg.NewMigrator(r.db, r.schema, pgScheduleComponent, pgScheduleMigrations)
+}
+
+// record locks the row of the schedule for the transaction, so concurrent
+// records of the same schedule are serialised and only the first one applies.
+func (r *PGScheduleRepo) record(tx *sqlx.Tx, prev, s Schedule, rec ScheduleRecord) error {
+	query, args, err := tx.BindNamed(
+		r.prefixSchema(pgScheduleLock),
+		map[string]interface{}{
+			"ruleId": s.RuleID,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	var updatedAt time.Time
+
+	if err := tx.Get(&updatedAt, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.Wrap(errors.ErrNotFound, "lock schedule")
+		}
+
+		return err
+	}
+
+	if !updatedAt.Equal(prev.UpdatedAt) {
+		return errors.Wrapf(
+			errors.ErrScheduleChanged,
+			"schedule of rule '%s' updated at %s",
+			s.RuleID,
+			updatedAt.UTC(),
+		)
+	}
+
+	res, err := tx.NamedExec(
+		r.prefixSchema(pgScheduleUpdate),
+		map[string]interface{}{
+			"applied":   s.Applied,
+			"ruleId":    s.RuleID,
+			"state":     s.State,
+			"updatedAt": s.UpdatedAt.UTC(),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return errors.Wrap(errors.ErrNotFound, "update schedule")
+	}
+
+	_, err = tx.NamedExec(
+		r.prefixSchema(pgScheduleRecordInsert),
+		map[string]interface{}{
+			"createdAt": rec.CreatedAt.UTC(),
+			"event":     rec.Event,
+			"id":        rec.ID,
+			"rollout":   rec.Rollout,
+			"ruleId":    rec.RuleID,
+			"step":      rec.Step,
+		},
+	)
+
+	return err
+}
+
+func (r *PGScheduleRepo) prefixSchema(query string) string {
+	return fmt.Sprintf(query, r.schema)
+}
+
+// PGGuardrailRepoOption sets an optional parameter on the guardrail repo.
+type PGGuardrailRepoOption func(*PGGuardrailRepo)
+
+// PGGuardrailRepoSchema sets the namespacing of the Postgres tables to a
+// non-default schema.
+func PGGuardrailRepoSchema(schema string) PGGuardrailRepoOption {
+	return func(r *PGGuardrailRepo) { r.schema = schema }
+}
+
+// PGGuardrailRepo is a Postgres backed GuardrailRepo implementation.
+type PGGuardrailRepo struct {
+	db     *sqlx.DB
+	schema string
+}
+
+// NewPostgresGuardrailRepo returns a Postgres backed GuardrailRepo
+// implementation.
+func NewPostgresGuardrailRepo(
+	db *sqlx.DB,
+	options ...PGGuardrailRepoOption,
+) GuardrailRepo {
+	r := &PGGuardrailRepo{
+		db:     db,
+		schema: PGDefaultSchema,
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// AddMetric adds count and sum of the metric to the stored aggregate.
+func (r *PGGuardrailRepo) AddMetric(m GuardrailMetric) error {
+	err := r.putMetric(pgGuardrailMetricAdd, m)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return err
+			}
+
+			return r.AddMetric(m)
+		default:
+			return fmt.Errorf("named exec: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// Breach disarms the guardrail of the rule.
+func (r *PGGuardrailRepo) Breach(ruleID string, at time.Time) error {
+	res, err := r.db.NamedExec(
+		r.prefixSchema(pgGuardrailBreach),
+		map[string]interface{}{
+			"breachedAt": at.UTC(),
+			"ruleId":     ruleID,
+		},
+	)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return err
+			}
+
+			return r.Breach(ruleID, at)
+		default:
+			return fmt.Errorf("named exec: %s", err)
+		}
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return errors.Wrap(errors.ErrNotFound, "breach guardrail")
+	}
+
+	return nil
+}
+
+// Get returns the guardrail of the rule.
+func (r *PGGuardrailRepo) Get(ruleID string) (Guardrail, error) {
+	query, args, err := r.db.BindNamed(
+		r.prefixSchema(pgGuardrailGet),
+		map[string]interface{}{
+			"ruleId": ruleID,
+		},
+	)
+	if err != nil {
+		return Guardrail{}, fmt.Errorf("named query: %s", err)
+	}
+
+	raw := pgGuardrail{}
+
+	err = r.db.Get(&raw, query, args...)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return Guardrail{}, err
+			}
+
+			return r.Get(ruleID)
+		case sql.ErrNoRows:
+			return Guardrail{}, errors.Wrap(errors.ErrNotFound, "get guardrail")
+		default:
+			return Guardrail{}, fmt.Errorf("get: %s", err)
+		}
+	}
+
+	return raw.convert(), nil
+}
+
+// ListArmed returns all guardrails which have not been breached.
+func (r *PGGuardrailRepo) ListArmed() ([]Guardrail, error) {
+	raws := []pgGuardrail{}
+
+	err := r.db.Select(&raws, r.prefixSchema(pgGuardrailListArmed))
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return nil, err
+			}
+
+			return r.ListArmed()
+		default:
+			return nil, fmt.Errorf("select: %s", err)
+		}
+	}
+
+	gs := []Guardrail{}
+
+	for _, raw := range raws {
+		gs = append(gs, raw.convert())
+	}
+
+	return gs, nil
+}
+
+// ListMetrics returns the aggregates of all metrics collected for the rule.
+func (r *PGGuardrailRepo) ListMetrics(ruleID string) ([]GuardrailMetric, error) {
+	query, args, err := r.db.BindNamed(
+		r.prefixSchema(pgGuardrailMetricList),
+		map[string]interface{}{
+			"ruleId": ruleID,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("named query: %s", err)
+	}
+
+	raws := []struct {
+		RuleID    string    `db:"rule_id"`
+		Metric    string    `db:"metric"`
+		Group     Group     `db:"grp"`
+		Count     int       `db:"count"`
+		Sum       float64   `db:"sum"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}{}
+
+	err = r.db.Select(&raws, query, args...)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return nil, err
+			}
+
+			return r.ListMetrics(ruleID)
+		default:
+			return nil, fmt.Errorf("select: %s", err)
+		}
+	}
+
+	ms := []GuardrailMetric{}
+
+	for _, raw := range raws {
+		ms = append(ms, GuardrailMetric{
+			RuleID:    raw.RuleID,
+			Metric:    raw.Metric,
+			Group:     raw.Group,
+			Count:     raw.Count,
+			Sum:       raw.Sum,
+			UpdatedAt: raw.UpdatedAt.UTC(),
+		})
+	}
+
+	return ms, nil
+}
+
+// Put creates or replaces the guardrail of the rule and discards the metrics
+// collected so far in the same transaction.
+func (r *PGGuardrailRepo) Put(g Guardrail) (Guardrail, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return Guardrail{}, errors.Wrap(err, "begin")
+	}
+
+	err = r.put(tx, g)
+	if err != nil {
+		_ = tx.Rollback()
+
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return Guardrail{}, err
+			}
+
+			return r.Put(g)
+		default:
+			return Guardrail{}, fmt.Errorf("named exec: %s", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Guardrail{}, errors.Wrap(err, "commit")
+	}
+
+	g.BreachedAt = time.Time{}
+
+	return g, nil
+}
+
+// SetMetric replaces the stored aggregate of the metric.
+func (r *PGGuardrailRepo) SetMetric(m GuardrailMetric) error {
+	err := r.putMetric(pgGuardrailMetricSet, m)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return err
+			}
+
+			return r.SetMetric(m)
+		default:
+			return fmt.Errorf("named exec: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// Setup prepares the database by setting up schemas and tables.
+func (r *PGGuardrailRepo) Setup() error {
+	return r.Migrator().Up()
+}
+
+// Teardown cascadingly removes all database dependencies.
+func (r *PGGuardrailRepo) Teardown() error {
+	return r.Migrator().Reset()
+}
+
+// Migrator returns the migrator for the tables of the repo.
+func (r *PGGuardrailRepo) Migrator() *pg.Migrator {
+	return pg.NewMigrator(r.db, r.schema, pgGuardrailComponent, pgGuardrailMigrations)
+}
+
+func (r *PGGuardrailRepo) put(tx *sqlx.Tx, g Guardrail) error {
+	_, err := tx.NamedExec(
+		r.prefixSchema(pgGuardrailUpsert),
+		map[string]interface{}{
+			"comparison": g.Comparison,
+			"createdAt":  g.CreatedAt.UTC(),
+			"metric":     g.Metric,
+			"minSamples": g.MinSamples,
+			"ruleId":     g.RuleID,
+			"threshold":  g.Threshold,
+			"updatedAt":  g.UpdatedAt.UTC(),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NamedExec(
+		r.prefixSchema(pgGuardrailMetricDelete),
+		map[string]interface{}{
+			"ruleId": g.RuleID,
+		},
+	)
+
+	return err
+}
+
+func (r *PGGuardrailRepo) putMetric(query string, m GuardrailMetric) error {
+	_, err := r.db.NamedExec(
+		r.prefixSchema(query),
+		map[string]interface{}{
+			"count":     m.Count,
+			"group":     m.Group,
+			"metric":    m.Metric,
+			"ruleId":    m.RuleID,
+			"sum":       m.Sum,
+			"updatedAt": m.UpdatedAt.UTC(),
+		},
+	)
+
+	return err
+}
+
+func (r *PGGuardrailRepo) prefixSchema(query string) string {
+	return fmt.Sprintf(query, r.schema)
+}
+
+type pgGuardrail struct {
+	RuleID     string              `db:"rule_id"`
+	Metric     string              `db:"metric"`
+	Threshold  float64             `db:"threshold"`
+	Comparison GuardrailComparison `db:"comparison"`
+	MinSamples int                 `db:"min_samples"`
+	BreachedAt pq.NullTime         `db:"breached_at"`
+	CreatedAt  time.Time           `db:"created_at"`
+	UpdatedAt  time.Time           `db:"updated_at"`
+}
+
+func (raw pgGuardrail) convert() Guardrail {
+	g := Guardrail{
+		RuleID:     raw.RuleID,
+		Metric:     raw.Metric,
+		Threshold:  raw.Threshold,
+		Comparison: raw.Comparison,
+		MinSamples: raw.MinSamples,
+		CreatedAt:  raw.CreatedAt.UTC(),
+		UpdatedAt:  raw.UpdatedAt.UTC(),
+	}
+
+	if raw.BreachedAt.Valid {
+		g.BreachedAt = raw.BreachedAt.Time.UTC()
+	}
+
+	return g
+}
+
+// PGSegmentRepoOption sets an optional parameter on the segment repo.
+type PGSegmentRepoOption func(*PGSegmentRepo)
+
+// PGSegmentRepoSchema sets the namespacing of the Postgres tables to a
+// non-default schema.
+func PGSegmentRepoSchema(schema string) PGSegmentRepoOption {
+	return func(r *PGSegmentRepo) { r.schema = schema }
+}
+
+// PGSegmentRepo is a Postgres backed SegmentRepo implementation.
+type PGSegmentRepo struct {
+	db     *sqlx.DB
+	schema string
+}
+
+// NewPostgresSegmentRepo returns a Postgres backed SegmentRepo implementation.
+func NewPostgresSegmentRepo(
+	db *sqlx.DB,
+	options ...PGSegmentRepoOption,
+) SegmentRepo {
+	r := &PGSegmentRepo{
+		db:     db,
+		schema: PGDefaultSchema,
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// Append stores the segment as a new version.
+func (r *PGSegmentRepo) Append(s Segment) (Segment, error) {
+	rawCriteria, err := json.Marshal(s.Criteria)
+	if err != nil {
+		return Segment{}, errors.Wrap(err, "marshal criteria")
+	}
+
+	s.CreatedAt = s.CreatedAt.UTC()
+
+	_, err = r.db.NamedExec(
+		r.prefixSchema(pgSegmentInsert),
+		map[string]interface{}{
+			"createdAt":   s.CreatedAt,
+			"criteria":    rawCriteria,
+			"deleted":     s.Deleted,
+			"description": s.Description,
+			"id":          s.ID,
+			"name":        s.Name,
+			"version":     s.Version,
+		},
+	)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrDuplicateKey:
+			return Segment{}, errors.Wrapf(
+				errors.ErrExists,
+				"segment '%s' version %d",
+				s.ID,
+				s.Version,
+			)
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return Segment{}, err
+			}
+
+			return r.Append(s)
+		default:
+			return Segment{}, fmt.Errorf("named exec: %s", err)
+		}
+	}
+
+	return s, nil
+}
+
+// Get returns the latest version of the segment unless it is deleted.
+func (r *PGSegmentRepo) Get(id string) (Segment, error) {
+	query, args, err := r.db.BindNamed(
+		r.prefixSchema(pgSegmentGet),
+		map[string]interface{}{
+			"id": id,
+		},
+	)
+	if err != nil {
+		return Segment{}, fmt.Errorf("named query: %s", err)
+	}
+
+	raw := pgSegment{}
+
+	err = r.db.Get(&raw, query, args...)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return Segment{}, err
+			}
+
+			return r.Get(id)
+		case sql.ErrNoRows:
+			return Segment{}, errors.Wrap(errors.ErrNotFound, "get segment")
+		default:
+			return Segment{}, fmt.Errorf("get: %s", err)
+		}
+	}
+
+	if raw.Deleted {
+		return Segment{}, errors.Wrap(errors.ErrNotFound, "get segment")
+	}
+
+	return raw.convert()
+}
+
+// List returns the latest version of all segments which are not deleted.
+func (r *PGSegmentRepo) List() ([]Segment, error) {
+	raws := []pgSegment{}
+
+	err := r.db.Select(&raws, r.prefixSchema(pgSegmentList))
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return nil, err
+			}
+
+			return r.List()
+		default:
+			return nil, fmt.Errorf("select: %s", err)
+		}
+	}
+
+	return convertSegments(raws)
+}
+
+// ListVersions returns all versions of the segment in order.
+func (r *PGSegmentRepo) ListVersions(id string) ([]Segment, error) {
+	query, args, err := r.db.BindNamed(
+		r.prefixSchema(pgSegmentListVersions),
+		map[string]interface{}{
+			"id": id,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("named query: %s", err)
+	}
+
+	raws := []pgSegment{}
+
+	err = r.db.Select(&raws, query, args...)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return nil, err
+			}
+
+			return r.ListVersions(id)
+		default:
+			return nil, fmt.Errorf("select: %s", err)
+		}
+	}
+
+	if len(raws) == 0 {
+		return nil, errors.Wrap(errors.ErrNotFound, "list segment versions")
+	}
+
+	return convertSegments(raws)
+}
+
+// Setup prepares the database by setting up schemas and tables.
+func (r *PGSegmentRepo) Setup() error {
+	return r.Migrator().Up()
+}
+
+// Teardown cascadingly removes all database dependencies.
+func (r *PGSegmentRepo) Teardown() error {
+	return r.Migrator().Reset()
+}
+
+// Migrator returns the migrator for the tables of the repo.
+func (r *PGSegmentRepo) Migrator() *pg.Migrator {
+	return pg.NewMigrator(r.db, r.schema, pgSegmentComponent, pgSegmentMigrations)
+}
+
+func (r *PGSegmentRepo) prefixSchema(query string) string {
+	return fmt.Sprintf(query, r.schema)
+}
+
+type pgSegment struct {
+	ID          string    `db:"id"`
+	Version     int       `db:"version"`
+	Name        string    `db:"name"`
+	Description string    `db:"description"`
+	Criteria    []byte    `db:"criteria"`
+	Deleted     bool      `db:"deleted"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+func (raw pgSegment) convert() (Segment, error) {
+	criteria := Criteria{}
+
+	if err := json.Unmarshal(raw.Criteria, &criteria); err != nil {
+		return Segment{}, errors.Wrap(err, "unmarshal criteria")
+	}
+
+	return Segment{
+		ID:          raw.ID,
+		Name:        raw.Name,
+		Description: raw.Description,
+		Criteria:    criteria,
+		Version:     raw.Version,
+		Deleted:     raw.Deleted,
+		CreatedAt:   raw.CreatedAt.UTC(),
+	}, nil
+}
+
+func convertSegments(raws []pgSegment) ([]Segment, error) {
+	ss := []Segment{}
+
+	for _, raw := range raws {
+		s, err := raw.convert()
+		if err != nil {
+			return nil, err
+		}
+
+		ss = append(ss, s)
+	}
+
+	return ss, nil
+}
+
+// PGUserListRepoOption sets an optional parameter on the user list repo.
+type PGUserListRepoOption func(*PGUserListRepo)
+
+// PGUserListRepoSchema sets the namespacing of the Postgres tables to a
+// non-default schema.
+func PGUserListRepoSchema(schema string) PGUserListRepoOption {
+	return func(r *PGUserListRepo) { r.schema = schema }
+}
+
+// PGUserListRepo is a Postgres backed UserListRepo implementation.
+type PGUserListRepo struct {
+	db     *sqlx.DB
+	schema string
+}
+
+// NewPostgresUserListRepo returns a Postgres backed UserListRepo
+// implementation.
+func NewPostgresUserListRepo(
+	db *sqlx.DB,
+	options ...PGUserListRepoOption,
+) UserListRepo {
+	r := &PGUserListRepo{
+		db:     db,
+		schema: PGDefaultSchema,
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// Create stores a new user list without members.
+func (r *PGUserListRepo) Create(l UserList) (UserList, error) {
+	l.CreatedAt = l.CreatedAt.UTC()
+	l.UpdatedAt = l.UpdatedAt.UTC()
+
+	_, err := r.db.NamedExec(r.prefixSchema(pgUserListInsert), pgUserListArgs(l))
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrDuplicateKey:
+			return UserList{}, errors.Wrapf(errors.ErrExists, "user list '%s'", l.Name)
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return UserList{}, err
+			}
+
+			return r.Create(l)
+		default:
+			return UserList{}, fmt.Errorf("named exec: %s", err)
+		}
+	}
+
+	return l, nil
+}
+
+// Get returns the user list for the given id.
+func (r *PGUserListRepo) Get(id string) (UserList, error) {
+	query, args, err := r.db.BindNamed(
+		r.prefixSchema(pgUserListGet),
+		map[string]interface{}{
+			"id": id,
+		},
+	)
+	if err != nil {
+		return UserList{}, fmt.Errorf("named query: %s", err)
+	}
+
+	raw := pgUserList{}
+
+	err = r.db.Get(&raw, query, args...)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return UserList{}, err
+			}
+
+			return r.Get(id)
+		case sql.ErrNoRows:
+			return UserList{}, errors.Wrap(errors.ErrNotFound, "get user list")
+		default:
+			return UserList{}, fmt.Errorf("get: %s", err)
+		}
+	}
+
+	return raw.convert(), nil
+}
+
+// List returns all user lists ordered by name.
+func (r *PGUserListRepo) List() ([]UserList, error) {
+	raws := []pgUserList{}
+
+	err := r.db.Select(&raws, r.prefixSchema(pgUserListList))
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return nil, err
+			}
+
+			return r.List()
+		default:
+			return nil, fmt.Errorf("select: %s", err)
+		}
+	}
+
+	ls := []UserList{}
+
+	for _, raw := range raws {
+		ls = append(ls, raw.convert())
+	}
+
+	return ls, nil
+}
+
+// ListByMember returns all user lists the user is a member of ordered by
+// name.
+func (r *PGUserListRepo) ListByMember(userID string) ([]UserList, error) {
+	query, args, err := r.db.BindNamed(
+		r.prefixSchema(pgUserListListByMember),
+		map[string]interface{}{
+			"userId": userID,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("named query: %s", err)
+	}
+
+	raws := []pgUserList{}
+
+	err = r.db.Select(&raws, query, args...)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return nil, err
+			}
+
+			return r.ListByMember(userID)
+		default:
+			return nil, fmt.Errorf("select: %s", err)
+		}
+	}
+
+	ls := []UserList{}
+
+	for _, raw := range raws {
+		ls = append(ls, raw.convert())
+	}
+
+	return ls, nil
+}
+
+// Members returns the user ids of the list.
+func (r *PGUserListRepo) Members(id string) ([]string, error) {
+	query, args, err := r.db.BindNamed(
+		r.prefixSchema(pgUserListMembers),
+		map[string]interface{}{
+			"id": id,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("named query: %s", err)
+	}
+
+	ids := []string{}
+
+	err = r.db.Select(&ids, query, args...)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return nil, err
+			}
+
+			return r.Members(id)
+		default:
+			return nil, fmt.Errorf("select: %s", err)
+		}
+	}
+
+	return ids, nil
+}
+
+// Replace updates the list, increments its version and swaps its members in
+// one transaction, members are written with COPY to support large lists.
+func (r *PGUserListRepo) Replace(l UserList, ids []string) (UserList, error) {
+	l.UpdatedAt = l.UpdatedAt.UTC()
+
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return UserList{}, errors.Wrap(err, "begin")
+	}
+
+	l.Version, err = r.replace(tx, l, ids)
+	if err != nil {
+		_ = tx.Rollback()
+
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return UserList{}, err
+			}
+
+			return r.Replace(l, ids)
+		case sql.ErrNoRows:
+			return UserList{}, errors.Wrap(errors.ErrNotFound, "replace user list")
+		default:
+			return UserList{}, fmt.Errorf("replace: %s", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return UserList{}, errors.Wrap(err, "commit")
+	}
+
+	return l, nil
+}
+
+// RemoveMember removes the user from all lists in one statement, the size of
+// every list the user was removed from is decreased and its version increased.
+func (r *PGUserListRepo) RemoveMember(userID string) (int, error) {
+	res, err := r.db.NamedExec(
+		r.prefixSchema(pgUserListMemberRemove),
+		map[string]interface{}{
+			"updatedAt": time.Now().UTC(),
+			"userId":    userID,
+		},
+	)
+	if err != nil {
+		switch errors.Cause(pg.Wrap(err)) {
+		case pg.ErrRelationNotFound:
+			if err := r.Setup(); err != nil {
+				return 0, err
+			}
+
+			return r.RemoveMember(userID)
+		default:
+			return 0, fmt.Errorf("named exec: %s", err)
+		}
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rows affected: %s", err)
+	}
+
+	return int(n), nil
+}
+
+// Setup prepares the database by setting up schemas and tables.
+func (r *PGUserListRepo) Setup() error {
+	return r.Migrator().Up()
+}
+
+// Teardown cascadingly removes all database dependencies.
+func (r *PGUserListRepo) Teardown() error {
+	return r.Migrator().Reset()
+}
+
+// Migrator returns the migrator for the tables of the repo.
+func (r *PGUserListRepo) Migrator() *pg.Migrator {
+	return pg.NewMigrator(r.db, r.schema, pgUserListComponent, pgUserListMigrations)
+}
+
+// replace returns the new version of the list, which is incremented in the
+// database so concurrent uploads can't both write the same version.
+func (r *PGUserListRepo) replace(tx *sqlx.Tx, l UserList, ids []string) (int, error) {
+	query, args, err := tx.BindNamed(r.prefixSchema(pgUserListUpdate), pgUserListArgs(l))
+	if err != nil {
+		return 0, err
+	}
+
+	var version int
+
+	if err := tx.Get(&version, query, args...); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.NamedExec(
+		r.prefixSchema(pgUserListMembersDelete),
+		map[string]interface{}{
+			"id": l.ID,
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyInSchema(r.schema, "user_list_members", "list_id", "user_id"))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if _, err := stmt.Exec(l.ID, id); err != nil {
+			_ = stmt.Close()
+			return 0, err
+		}
+	}
+
+	if _, err := stmt.Exec(); err != nil {
+		_ = stmt.Close()
+		return 0, err
+	}
+
+	return version, stmt.Close()
+}
+
+func (r *PGUserListRepo) prefixSchema(query string) string {
+	return fmt.Sprintf(query, r.schema)
+}
+
+func pgUserListArgs(l UserList) map[string]interface{} {
+	return map[string]interface{}{
+		"createdAt":   l.CreatedAt,
+		"description": l.Description,
+		"id":          l.ID,
+		"name":        l.Name,
+		"size":        l.Size,
+		"updatedAt":   l.UpdatedAt,
+		"version":     l.Version,
+	}
+}
+
+type pgUserList struct {
+	ID          string    `db:"id"`
+	Name        string    `db:"name"`
+	Description string    `db:"description"`
+	Size        int       `db:"size"`
+	Version     int       `db:"version"`
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
+func (raw pgUserList) convert() UserList {
+	return UserList{
+		ID:          raw.ID,
+		Name:        raw.Name,
+		Description: raw.Description,
+		Size:        raw.Size,
+		Version:     raw.Version,
+		CreatedAt:   raw.CreatedAt.UTC(),
+		UpdatedAt:   raw.UpdatedAt.UTC(),
+	}
+}
+
+type pgSchedule struct {
+	RuleID    string        `db:"rule_id"`
+	Steps     []byte        `db:"steps"`
+	Applied   int           `db:"applied"`
+	State     ScheduleState `db:"state"`
+	CreatedAt time.Time     `db:"created_at"`
+	UpdatedAt time.Time     `db:"updated_at"`
+}
+
+func (raw pgSchedule) convert() (Schedule, error) {
+	steps := []ScheduleStep{}
+
+	if err := json.Unmarshal(raw.Steps, &steps); err != nil {
+		return Schedule{}, errors.Wrap(err, "unmarshal steps")
+	}
+
+	return Schedule{
+		RuleID:    raw.RuleID,
+		Steps:     steps,
+		Applied:   raw.Applied,
+		State:     raw.State,
+		CreatedAt: raw.CreatedAt.UTC(),
+		UpdatedAt: raw.UpdatedAt.UTC(),
+	}, nil
+}
+
+// marshalLayer returns the value for the layer column, which is NULL for rules
+// outside of any layer.
+func marshalLayer(l Layer) (interface{}, error) {
+	if l.Name == "" {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(l)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal layer")
+	}
+
+	return raw, nil
+}
+
+func unmarshalLayer(raw []byte) (Layer, error) {
+	l := Layer{}
+
+	if len(raw) == 0 || string(raw) == "null" {
+		return l, nil
+	}
+
+	if err := json.Unmarshal(raw, &l); err != nil {
+		return Layer{}, errors.Wrap(err, "unmarshal layer")
+	}
+
+	return l, nil
+}
+
+func marshalBucketing(b Bucketing) (interface{}, error) {
+	if b.Key == "" {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal bucketing")
+	}
+
+	return raw, nil
+}
+
+func unmarshalBucketing(raw []byte) (Bucketing, error) {
+	b := Bucketing{}
+
+	if len(raw) == 0 || string(raw) == "null" {
+		return b, nil
+	}
+
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return Bucketing{}, errors.Wrap(err, "unmarshal bucketing")
+	}
+
+	return b, nil
+}
+
+func buildList(rows *sqlx.Rows) ([]Rule, error) {
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	rules := []Rule{}
 
 	for rows.Next() {
 		raw := struct {
 			ID          string      `db:"id"`
 			Active      bool        `db:"active"`
 			ActivatedAt pq.NullTime `db:"activated_at"`
+			Bucketing   []byte      `db:"bucketing"`
 			Buckets     []byte      `db:"buckets"`
 			ConfigID    string      `db:"config_id"`
 			CreatedAt   time.Time   `db:"created_at"`
 			Criteria    []byte      `db:"criteria"`
 			Description string      `db:"description"`
 			EndTime     pq.NullTime `db:"end_time"`
+			Generation  int         `db:"generation"`
 			Kind        Kind        `db:"kind"`
+			Layer       []byte      `db:"layer"`
 			Name        string      `db:"name"`
+			Reshuffle   bool        `db:"reshuffle"`
 			Rollout     uint8       `db:"rollout"`
 			StartTime   pq.NullTime `db:"start_time"`
+			Sticky      bool        `db:"sticky"`
 			UpdatedAt   time.Time   `db:"updated_at"`
 		}{}
 
@@ -537,6 +2555,16 @@ func buildList(rows *sqlx.Rows) ([]Rule, error) {
 			return []Rule{}, errors.Wrap(err, "unmarshal criteria in rule scan")
 		}
 
+		layer, err := unmarshalLayer(raw.Layer)
+		if err != nil {
+			return []Rule{}, err
+		}
+
+		bucketing, err := unmarshalBucketing(raw.Bucketing)
+		if err != nil {
+			return []Rule{}, err
+		}
+
 		var activatedAt time.Time
 		if raw.ActivatedAt.Valid {
 			activatedAt = (raw.ActivatedAt).Time
@@ -556,16 +2584,21 @@ func buildList(rows *sqlx.Rows) ([]Rule, error) {
 			ID:          raw.ID,
 			active:      raw.Active,
 			activatedAt: activatedAt,
+			bucketing:   bucketing,
 			buckets:     buckets,
 			configID:    raw.ConfigID,
 			createdAt:   raw.CreatedAt,
 			criteria:    criteria,
 			description: raw.Description,
 			endTime:     endTime,
+			generation:  raw.Generation,
 			kind:        raw.Kind,
+			layer:       layer,
 			name:        raw.Name,
+			reshuffle:   raw.Reshuffle,
 			rollout:     raw.Rollout,
 			startTime:   startTime,
+			sticky:      raw.Sticky,
 			updatedAt:   raw.UpdatedAt,
 		})
 	}
@@ -79,6 +79,68 @@ func TestRepoNoCriteria(t *testing.T) {
 	testRepoNoCriteria(t, preparePGRepo)
 }
 
+func TestPostgresRepoMigrate(t *testing.T) {
+	t.Parallel()
+
+	m := preparePGRepo(t).(*PGRepo).Migrator()
+
+	for _, step := range []func() error{
+		m.Up,
+		m.Up,
+		func() error { return m.Down(0) },
+		m.Up,
+	} {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := s.Version, s.Latest; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestPostgresGuardrailRepoMetrics(t *testing.T) {
+	t.Parallel()
+
+	testGuardrailRepoMetrics(t, preparePGGuardrailRepo)
+}
+
+func TestPostgresScheduleRepoGetNotFound(t *testing.T) {
+	t.Parallel()
+
+	testScheduleRepoGetNotFound(t, preparePGScheduleRepo)
+}
+
+func TestPostgresScheduleRepoRecord(t *testing.T) {
+	t.Parallel()
+
+	testScheduleRepoRecord(t, preparePGScheduleRepo)
+}
+
+func TestPostgresSegmentRepoVersions(t *testing.T) {
+	t.Parallel()
+
+	testSegmentRepoVersions(t, preparePGSegmentRepo)
+}
+
+func TestPostgresUserListRepoMembers(t *testing.T) {
+	t.Parallel()
+
+	testUserListRepoMembers(t, preparePGUserListRepo)
+}
+
+func TestPostgresUserListRepoReplace(t *testing.T) {
+	t.Parallel()
+
+	testUserListRepoReplace(t, preparePGUserListRepo)
+}
+
 func preparePGRepo(t *testing.T) Repo {
 	db, err := sqlx.Connect("postgres", pgURI)
 	if err != nil {
@@ -94,6 +156,66 @@ func preparePGRepo(t *testing.T) Repo {
 	return r
 }
 
+func preparePGGuardrailRepo(t *testing.T) GuardrailRepo {
+	db, err := sqlx.Connect("postgres", pgURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewPostgresGuardrailRepo(db, PGGuardrailRepoSchema(t.Name()))
+
+	if err := r.Teardown(); err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func preparePGScheduleRepo(t *testing.T) ScheduleRepo {
+	db, err := sqlx.Connect("postgres", pgURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewPostgresScheduleRepo(db, PGScheduleRepoSchema(t.Name()))
+
+	if err := r.Teardown(); err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func preparePGSegmentRepo(t *testing.T) SegmentRepo {
+	db, err := sqlx.Connect("postgres", pgURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewPostgresSegmentRepo(db, PGSegmentRepoSchema(t.Name()))
+
+	if err := r.Teardown(); err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func preparePGUserListRepo(t *testing.T) UserListRepo {
+	db, err := sqlx.Connect("postgres", pgURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewPostgresUserListRepo(db, PGUserListRepoSchema(t.Name()))
+
+	if err := r.Teardown(); err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
 func init() {
 	u, err := user.Current()
 	if err != nil {
@@ -0,0 +1,409 @@
+package rule
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+func validatePrerequisiteCriterion(c Criterion) error {
+	var ok bool
+
+	switch c.Key {
+	case ParameterValue:
+		if c.Path == "" {
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: path missing", c.Key)
+		}
+
+		switch c.Comparator {
+		case ComparatorEQ, ComparatorNQ:
+			switch c.Value.(type) {
+			case bool, float64, string:
+				ok = true
+			}
+		case ComparatorGT, ComparatorLT:
+			_, ok = c.Value.(float64)
+		default:
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+		}
+	case RuleDecision:
+		switch c.Comparator {
+		case ComparatorEQ, ComparatorNQ:
+			var name string
+
+			if name, ok = c.Value.(string); ok && name == "" {
+				return errors.Wrapf(errors.ErrInvalidRule, "%s: rule name missing", c.Key)
+			}
+		default:
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+		}
+	}
+
+	if !ok {
+		return errors.Wrapf(
+			errors.ErrInvalidTypeToMatch,
+			"%s: value %v invalid for '%s'",
+			c.Key,
+			c.Value,
+			c.Comparator,
+		)
+	}
+
+	return nil
+}
+
+// matchPrerequisite matches the criterion against the state of the current
+// render, i.e. the parameters computed so far and the rules applied before.
+func matchPrerequisite(c Criterion, ctx Context) error {
+	if err := validatePrerequisiteCriterion(c); err != nil {
+		return err
+	}
+
+	switch c.Key {
+	case ParameterValue:
+		return matchParameterValue(c, ctx.params)
+	case RuleDecision:
+		return matchRuleDecision(c, ctx.applied)
+	}
+
+	return nil
+}
+
+// matchParameterValue compares the rendered value of the parameter named by
+// the path. Parameters which are absent or of another type never match.
+func matchParameterValue(c Criterion, params Parameters) error {
+	input, ok := params[c.Path]
+	if !ok {
+		return errors.Wrapf(errors.ErrCriterionNotMatch, "parameter '%s' missing", c.Path)
+	}
+
+	switch expected := c.Value.(type) {
+	case float64:
+		ok = matchParameterNumber(c.Comparator, expected, input)
+	case bool, string:
+		ok = reflect.TypeOf(input) == reflect.TypeOf(expected) &&
+			(input == expected) == (c.Comparator == ComparatorEQ)
+	}
+
+	if !ok {
+		return errors.Wrapf(
+			errors.ErrCriterionNotMatch,
+			"parameter '%s' %v doesn't match %s %v",
+			c.Path,
+			input,
+			c.Comparator,
+			c.Value,
+		)
+	}
+
+	return nil
+}
+
+func matchParameterNumber(comparator Comparator, expected float64, input interface{}) bool {
+	in, ok := toFloat(input)
+	if !ok {
+		return false
+	}
+
+	switch comparator {
+	case ComparatorEQ:
+		return in == expected
+	case ComparatorNQ:
+		return in != expected
+	case ComparatorGT:
+		return in > expected
+	case ComparatorLT:
+		return in < expected
+	default:
+		return false
+	}
+}
+
+// matchRuleDecision checks if the rule with the name of the value was applied
+// earlier in the render. If the path is set the rule must have applied the
+// bucket of that name.
+func matchRuleDecision(c Criterion, applied map[string]string) error {
+	bucket, ok := applied[c.Value.(string)]
+	if ok && c.Path != "" {
+		ok = bucket == c.Path
+	}
+
+	if ok != (c.Comparator == ComparatorEQ) {
+		return errors.Wrapf(
+			errors.ErrCriterionNotMatch,
+			"rule '%s' decision doesn't match %s %s",
+			c.Value,
+			c.Comparator,
+			c.Path,
+		)
+	}
+
+	return nil
+}
+
+// appliedBucket returns the name of the bucket the rule applied given the
+// decision taken.
+func (r Rule) appliedBucket(d []int) string {
+	if r.kind == KindExperiment {
+		return r.bucket(parseDecision(d).dice).Name
+	}
+
+	return r.buckets[0].Name
+}
+
+// prerequisites returns the names of rules the rule references and the
+// parameters it matches on.
+func (r Rule) prerequisites() (rules []string, params []string) {
+	for _, c := range r.criteria {
+		switch c.Key {
+		case ParameterValue:
+			params = append(params, c.Path)
+		case RuleDecision:
+			if name, ok := c.Value.(string); ok {
+				rules = append(rules, name)
+			}
+		}
+	}
+
+	return rules, params
+}
+
+// dependencies returns the indexes of the rules r needs to be evaluated after:
+// rules it references by name and rules which set a parameter it matches on.
+// A rule matching on a parameter it sets itself sees the value before it is
+// applied, which is not a dependency.
+func dependencies(r Rule, rs []Rule) []int {
+	var (
+		deps          = []int{}
+		names, params = r.prerequisites()
+	)
+
+	for i, o := range rs {
+		switch {
+		case contains(names, o.name):
+			deps = append(deps, i)
+		case o.ID != r.ID && containsAny(params, o.Keys()):
+			deps = append(deps, i)
+		}
+	}
+
+	return deps
+}
+
+// orderRules sorts rules in order of creation, moving rules after the ones
+// they depend on. Rules in a dependency cycle are kept in order of creation
+// after all others and reported with ErrInvalidRule.
+func orderRules(rules []Rule) ([]Rule, error) {
+	rs := make([]Rule, len(rules))
+
+	copy(rs, rules)
+	sort.SliceStable(rs, func(i, j int) bool {
+		return rs[i].createdAt.Before(rs[j].createdAt)
+	})
+
+	var (
+		deps    = make([][]int, len(rs))
+		ordered = make([]Rule, 0, len(rs))
+		placed  = make([]bool, len(rs))
+	)
+
+	for i, r := range rs {
+		deps[i] = dependencies(r, rs)
+	}
+
+	for len(ordered) < len(rs) {
+		next := -1
+
+		for i := range rs {
+			if !placed[i] && ready(deps[i], placed) {
+				next = i
+				break
+			}
+		}
+
+		if next == -1 {
+			break
+		}
+
+		placed[next] = true
+		ordered = append(ordered, rs[next])
+	}
+
+	if len(ordered) == len(rs) {
+		return ordered, nil
+	}
+
+	cycle := []string{}
+
+	for i, r := range rs {
+		if !placed[i] {
+			cycle = append(cycle, r.name)
+			ordered = append(ordered, r)
+		}
+	}
+
+	return ordered, errors.Wrapf(
+		errors.ErrInvalidRule,
+		"dependency cycle between rules '%s'",
+		strings.Join(cycle, "', '"),
+	)
+}
+
+func ready(deps []int, placed []bool) bool {
+	for _, d := range deps {
+		if !placed[d] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func contains(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsAny(ss []string, candidates []string) bool {
+	for _, c := range candidates {
+		if contains(ss, c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type prerequisiteRuleRepo struct {
+	next Repo
+}
+
+// NewRuleRepoPrerequisiteMiddleware wraps the next Repo and rejects rules
+// which would introduce a dependency cycle among the rules of their base
+// config or share their name with another rule of it, as prerequisites
+// reference rules by name.
+func NewRuleRepoPrerequisiteMiddleware() RepoMiddleware {
+	return func(next Repo) Repo {
+		return &prerequisiteRuleRepo{
+			next: next,
+		}
+	}
+}
+
+func (r *prerequisiteRuleRepo) Create(input Rule) (Rule, error) {
+	if err := r.validate(input); err != nil {
+		return Rule{}, err
+	}
+
+	return r.next.Create(input)
+}
+
+func (r *prerequisiteRuleRepo) GetByID(id string) (Rule, error) {
+	return r.next.GetByID(id)
+}
+
+func (r *prerequisiteRuleRepo) UpdateWith(input Rule) (Rule, error) {
+	if err := r.validate(input); err != nil {
+		return Rule{}, err
+	}
+
+	return r.next.UpdateWith(input)
+}
+
+func (r *prerequisiteRuleRepo) ListAll() ([]Rule, error) {
+	return r.next.ListAll()
+}
+
+func (r *prerequisiteRuleRepo) ListActive(configID string, now time.Time) ([]Rule, error) {
+	return r.next.ListActive(configID, now)
+}
+
+func (r *prerequisiteRuleRepo) Setup() error {
+	return r.next.Setup()
+}
+
+func (r *prerequisiteRuleRepo) Teardown() error {
+	return r.next.Teardown()
+}
+
+// validate checks the name only for new or renamed rules, so rules stored
+// with a duplicate name before names had to be unique stay editable.
+func (r *prerequisiteRuleRepo) validate(input Rule) error {
+	if input.deleted {
+		return nil
+	}
+
+	all, err := r.next.ListAll()
+	if err != nil {
+		return err
+	}
+
+	if !renamed(input, all) {
+		return validateOrder(input, all)
+	}
+
+	return validateSiblings(input, all)
+}
+
+// renamed reports if the input is a new rule or its name differs from the
+// stored version.
+func renamed(input Rule, all []Rule) bool {
+	for _, r := range all {
+		if r.ID == input.ID {
+			return r.name != input.name
+		}
+	}
+
+	return true
+}
+
+// validateSiblings returns an error if the name of the input is taken by
+// another rule of its base config or the input introduces a dependency cycle.
+func validateSiblings(input Rule, all []Rule) error {
+	rs := siblings(input, all)
+
+	for _, s := range rs[1:] {
+		if s.name == input.name {
+			return errors.Wrapf(errors.ErrExists, "rule name '%s' in base config", input.name)
+		}
+	}
+
+	return validateOrder(input, all)
+}
+
+// validateOrder returns an error if the input introduces a dependency cycle
+// among the rules of its base config.
+func validateOrder(input Rule, all []Rule) error {
+	rs := siblings(input, all)
+
+	names, params := input.prerequisites()
+	if len(names) == 0 && len(params) == 0 {
+		return nil
+	}
+
+	_, err := orderRules(rs)
+
+	return err
+}
+
+// siblings returns the rules of the same base config as the input with the
+// input in place of its stored version.
+func siblings(input Rule, all []Rule) []Rule {
+	rs := []Rule{input}
+
+	for _, r := range all {
+		if r.configID == input.configID && r.ID != input.ID && !r.deleted {
+			rs = append(rs, r)
+		}
+	}
+
+	return rs
+}
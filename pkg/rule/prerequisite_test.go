@@ -0,0 +1,312 @@
+package rule
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+func TestCriterionPrerequisiteMarshal(t *testing.T) {
+	for _, want := range []Criterion{
+		{
+			Comparator: ComparatorEQ,
+			Key:        ParameterValue,
+			Path:       "feature_newcheckout_enabled",
+			Value:      true,
+		},
+		{
+			Comparator: ComparatorGT,
+			Key:        ParameterValue,
+			Path:       "price",
+			Value:      float64(5),
+		},
+		{
+			Comparator: ComparatorEQ,
+			Key:        RuleDecision,
+			Path:       "variant",
+			Value:      "checkout experiment",
+		},
+	} {
+		raw, err := json.Marshal(&want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var have Criterion
+
+		err = json.Unmarshal(raw, &have)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
+
+func TestCriterionPrerequisiteInvalid(t *testing.T) {
+	cases := map[string]error{
+		`{"comparator": 1, "key": 601, "value": true}`:                  errors.ErrInvalidRule,
+		`{"comparator": 3, "key": 601, "path": "price", "value": [1]}`:  errors.ErrInvalidRule,
+		`{"comparator": 0, "key": 601, "path": "price", "value": "5"}`:  errors.ErrInvalidTypeToMatch,
+		`{"comparator": 1, "key": 601, "path": "price", "value": [1]}`:  errors.ErrInvalidTypeToMatch,
+		`{"comparator": 0, "key": 602, "value": "checkout experiment"}`: errors.ErrInvalidRule,
+		`{"comparator": 1, "key": 602, "value": ""}`:                    errors.ErrInvalidRule,
+		`{"comparator": 1, "key": 602, "value": 1}`:                     errors.ErrInvalidTypeToMatch,
+	}
+
+	for input, want := range cases {
+		var c Criterion
+
+		err := json.Unmarshal([]byte(input), &c)
+		if have := errors.Cause(err); have != want {
+			t.Errorf("%s: have %v, want %v", input, have, want)
+		}
+	}
+}
+
+func TestPrerequisiteMatch(t *testing.T) {
+	ctx := Context{
+		params: Parameters{
+			"feature_newcheckout_enabled": true,
+			"paywall":                     "default",
+			"price":                       5,
+		},
+		applied: map[string]string{
+			"checkout experiment": "variant",
+		},
+	}
+
+	cases := []struct {
+		criterion Criterion
+		err       error
+	}{
+		{Criterion{Comparator: ComparatorEQ, Key: ParameterValue, Path: "feature_newcheckout_enabled", Value: true}, nil},
+		{Criterion{Comparator: ComparatorNQ, Key: ParameterValue, Path: "feature_newcheckout_enabled", Value: true}, errors.ErrCriterionNotMatch},
+		{Criterion{Comparator: ComparatorEQ, Key: ParameterValue, Path: "paywall", Value: "default"}, nil},
+		{Criterion{Comparator: ComparatorNQ, Key: ParameterValue, Path: "paywall", Value: true}, errors.ErrCriterionNotMatch},
+		{Criterion{Comparator: ComparatorGT, Key: ParameterValue, Path: "price", Value: float64(4)}, nil},
+		{Criterion{Comparator: ComparatorLT, Key: ParameterValue, Path: "price", Value: float64(4)}, errors.ErrCriterionNotMatch},
+		{Criterion{Comparator: ComparatorEQ, Key: ParameterValue, Path: "missing", Value: true}, errors.ErrCriterionNotMatch},
+		{Criterion{Comparator: ComparatorEQ, Key: RuleDecision, Value: "checkout experiment"}, nil},
+		{Criterion{Comparator: ComparatorEQ, Key: RuleDecision, Path: "variant", Value: "checkout experiment"}, nil},
+		{Criterion{Comparator: ComparatorEQ, Key: RuleDecision, Path: "control", Value: "checkout experiment"}, errors.ErrCriterionNotMatch},
+		{Criterion{Comparator: ComparatorNQ, Key: RuleDecision, Path: "control", Value: "checkout experiment"}, nil},
+		{Criterion{Comparator: ComparatorNQ, Key: RuleDecision, Value: "paywall rollout"}, nil},
+		{Criterion{Comparator: ComparatorEQ, Key: RuleDecision, Value: "paywall rollout"}, errors.ErrCriterionNotMatch},
+	}
+
+	for _, c := range cases {
+		err := c.criterion.match(ctx)
+		if have, want := errors.Cause(err), c.err; have != want {
+			t.Errorf("%s %s %v: have %v, want %v", c.criterion.Comparator, c.criterion.Path, c.criterion.Value, have, want)
+		}
+	}
+}
+
+func TestEvaluatePrerequisite(t *testing.T) {
+	now := time.Now()
+
+	paywall := Rule{
+		active: true,
+		buckets: []Bucket{
+			{Name: "default", Parameters: Parameters{"paywall": "new"}},
+		},
+		configID:  "base-1",
+		createdAt: now,
+		criteria: Criteria{
+			{
+				Comparator: ComparatorEQ,
+				Key:        ParameterValue,
+				Path:       "feature_newcheckout_enabled",
+				Value:      true,
+			},
+		},
+		ID:   "rule-1",
+		kind: KindOverride,
+		name: "new paywall",
+	}
+	checkout := Rule{
+		active: true,
+		buckets: []Bucket{
+			{Name: "default", Parameters: Parameters{"feature_newcheckout_enabled": true}},
+		},
+		configID:  "base-1",
+		createdAt: now.Add(time.Second),
+		ID:        "rule-2",
+		kind:      KindOverride,
+		name:      "new checkout",
+	}
+	banner := Rule{
+		active: true,
+		buckets: []Bucket{
+			{Name: "default", Parameters: Parameters{"banner": true}},
+		},
+		configID:  "base-1",
+		createdAt: now.Add(-time.Second),
+		criteria: Criteria{
+			{
+				Comparator: ComparatorEQ,
+				Key:        RuleDecision,
+				Value:      "new paywall",
+			},
+		},
+		ID:   "rule-3",
+		kind: KindOverride,
+		name: "paywall banner",
+	}
+
+	base := Parameters{
+		"banner":                      false,
+		"feature_newcheckout_enabled": false,
+		"paywall":                     "old",
+	}
+
+	params, _, err := Evaluate(base, []Rule{banner, paywall, checkout}, Context{}, nil, HashDice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Parameters{
+		"banner":                      true,
+		"feature_newcheckout_enabled": true,
+		"paywall":                     "new",
+	}
+
+	if have := params; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	params, _, err = Evaluate(base, []Rule{banner, paywall}, Context{}, nil, HashDice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := params, base; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestOrderRulesCycle(t *testing.T) {
+	now := time.Now()
+
+	newRule := func(id, name string, createdAt time.Time, key string, c Criterion) Rule {
+		return Rule{
+			buckets: []Bucket{
+				{Name: "default", Parameters: Parameters{key: true}},
+			},
+			configID:  "base-1",
+			createdAt: createdAt,
+			criteria:  Criteria{c},
+			ID:        id,
+			name:      name,
+		}
+	}
+
+	var (
+		a = newRule("rule-1", "a", now, "feature_a", Criterion{
+			Comparator: ComparatorEQ,
+			Key:        ParameterValue,
+			Path:       "feature_b",
+			Value:      true,
+		})
+		b = newRule("rule-2", "b", now.Add(time.Second), "feature_b", Criterion{
+			Comparator: ComparatorEQ,
+			Key:        RuleDecision,
+			Value:      "a",
+		})
+		self = newRule("rule-3", "self", now, "feature_c", Criterion{
+			Comparator: ComparatorEQ,
+			Key:        ParameterValue,
+			Path:       "feature_c",
+			Value:      false,
+		})
+	)
+
+	rs, err := orderRules([]Rule{a, b})
+	if have, want := errors.Cause(err), errors.ErrInvalidRule; have != want {
+		t.Fatalf("have %v, want %v", have, want)
+	}
+
+	if have, want := len(rs), 2; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	// Matching on a parameter the rule sets itself is not a cycle.
+	if _, err := orderRules([]Rule{self}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replacing the stored version of a with one that has no prerequisites
+	// resolves the cycle.
+	fixed := a
+	fixed.criteria = nil
+
+	rs, err = orderRules(siblings(fixed, []Rule{a, b}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := []string{rs[0].ID, rs[1].ID}, []string{a.ID, b.ID}; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestValidateSiblingsName(t *testing.T) {
+	var (
+		a = Rule{configID: "base-1", ID: "rule-1", name: "checkout"}
+		b = Rule{configID: "base-1", ID: "rule-2", name: "checkout"}
+	)
+
+	err := validateSiblings(b, []Rule{a})
+	if have, want := errors.Cause(err), errors.ErrExists; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	// Names are only unique per base config and among rules not deleted.
+	other := b
+	other.configID = "base-2"
+
+	if err := validateSiblings(other, []Rule{a}); err != nil {
+		t.Error(err)
+	}
+
+	deleted := a
+	deleted.deleted = true
+
+	if err := validateSiblings(b, []Rule{deleted}); err != nil {
+		t.Error(err)
+	}
+
+	// Updates of a rule don't collide with its stored version.
+	if err := validateSiblings(a, []Rule{a}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRenamed(t *testing.T) {
+	var (
+		a = Rule{configID: "base-1", ID: "rule-1", name: "checkout"}
+		b = Rule{configID: "base-1", ID: "rule-2", name: "checkout"}
+	)
+
+	// Stored duplicates stay editable as long as the name is kept.
+	if have, want := renamed(b, []Rule{a, b}), false; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	other := b
+	other.name = "payment"
+
+	if have, want := renamed(other, []Rule{a, b}), true; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := renamed(b, []Rule{a}), true; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
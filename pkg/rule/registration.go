@@ -0,0 +1,174 @@
+package rule
+
+import (
+	"time"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// registrationValue converts the decoded JSON value of a registration
+// criterion to the type it is matched with.
+func registrationValue(key CriterionKey, v interface{}) interface{} {
+	switch key {
+	case UserAccountAge:
+		return intsValue(v)
+	case UserRegistered:
+		switch t := v.(type) {
+		case string:
+			r, err := time.Parse(time.RFC3339, t)
+			if err != nil {
+				return v
+			}
+
+			return r.UTC()
+		case []interface{}:
+			ts := []time.Time{}
+
+			for _, e := range t {
+				s, ok := e.(string)
+				if !ok {
+					return v
+				}
+
+				r, err := time.Parse(time.RFC3339, s)
+				if err != nil {
+					return v
+				}
+
+				ts = append(ts, r.UTC())
+			}
+
+			return ts
+		}
+	}
+
+	return v
+}
+
+// intsValue converts decoded JSON numbers to int or []int.
+func intsValue(v interface{}) interface{} {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case []interface{}:
+		is := []int{}
+
+		for _, e := range n {
+			f, ok := e.(float64)
+			if !ok {
+				return v
+			}
+
+			is = append(is, int(f))
+		}
+
+		return is
+	default:
+		return v
+	}
+}
+
+func validateRegistrationCriterion(c Criterion) error {
+	var ok bool
+
+	switch c.Key {
+	case UserAccountAge:
+		switch c.Comparator {
+		case ComparatorGT, ComparatorLT:
+			_, ok = c.Value.(int)
+		case ComparatorBetween:
+			var r []int
+
+			if r, ok = c.Value.([]int); ok && (len(r) != 2 || r[0] > r[1]) {
+				return errors.Wrapf(errors.ErrInvalidRule, "%s: range %v invalid", c.Key, r)
+			}
+		default:
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+		}
+	case UserRegistered:
+		switch c.Comparator {
+		case ComparatorGT, ComparatorLT:
+			_, ok = c.Value.(time.Time)
+		case ComparatorBetween:
+			var r []time.Time
+
+			if r, ok = c.Value.([]time.Time); ok && (len(r) != 2 || r[0].After(r[1])) {
+				return errors.Wrapf(errors.ErrInvalidRule, "%s: range %v invalid", c.Key, r)
+			}
+		default:
+			return errors.Wrapf(errors.ErrInvalidRule, "%s: comparator '%s' not supported", c.Key, c.Comparator)
+		}
+	}
+
+	if !ok {
+		return errors.Wrapf(
+			errors.ErrInvalidTypeToMatch,
+			"%s: value %v invalid for '%s'",
+			c.Key,
+			c.Value,
+			c.Comparator,
+		)
+	}
+
+	return nil
+}
+
+// matchRegistration matches the registration date of the user. GT matches
+// users registered after and LT users registered before the given date, the
+// account age is given in full days at the time of the context. Users with
+// an unknown registration date never match.
+func matchRegistration(c Criterion, ctx Context) error {
+	if err := validateRegistrationCriterion(c); err != nil {
+		return err
+	}
+
+	registered := ctx.User.Registered
+	if registered.IsZero() {
+		return errors.Wrap(errors.ErrCriterionNotMatch, "registration date unknown")
+	}
+
+	var ok bool
+
+	switch c.Key {
+	case UserAccountAge:
+		now := ctx.Now
+		if now.IsZero() {
+			now = time.Now()
+		}
+
+		days := int(now.Sub(registered) / (24 * time.Hour))
+
+		switch c.Comparator {
+		case ComparatorGT:
+			ok = days > c.Value.(int)
+		case ComparatorLT:
+			ok = days < c.Value.(int)
+		case ComparatorBetween:
+			r := c.Value.([]int)
+			ok = days >= r[0] && days <= r[1]
+		}
+	case UserRegistered:
+		switch c.Comparator {
+		case ComparatorGT:
+			ok = registered.After(c.Value.(time.Time))
+		case ComparatorLT:
+			ok = registered.Before(c.Value.(time.Time))
+		case ComparatorBetween:
+			r := c.Value.([]time.Time)
+			ok = !registered.Before(r[0]) && registered.Before(r[1])
+		}
+	}
+
+	if !ok {
+		return errors.Wrapf(
+			errors.ErrCriterionNotMatch,
+			"registration %s doesn't match %s %s %v",
+			registered.Format(time.RFC3339),
+			c.Key,
+			c.Comparator,
+			c.Value,
+		)
+	}
+
+	return nil
+}
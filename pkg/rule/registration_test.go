@@ -0,0 +1,110 @@
+package rule
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+func TestCriterionRegistrationMarshal(t *testing.T) {
+	for _, want := range []Criterion{
+		{
+			Comparator: ComparatorLT,
+			Key:        UserRegistered,
+			Value:      time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Comparator: ComparatorBetween,
+			Key:        UserRegistered,
+			Value: []time.Time{
+				time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			Comparator: ComparatorBetween,
+			Key:        UserAccountAge,
+			Value:      []int{7, 30},
+		},
+	} {
+		raw, err := json.Marshal(&want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var have Criterion
+
+		err = json.Unmarshal(raw, &have)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
+
+func TestCriterionRegistrationInvalid(t *testing.T) {
+	cases := map[string]error{
+		`{"comparator": 7, "key": 302, "value": ["2018-02-01T00:00:00Z", "2018-01-01T00:00:00Z"]}`: errors.ErrInvalidRule,
+		`{"comparator": 1, "key": 302, "value": "2018-01-01T00:00:00Z"}`:                           errors.ErrInvalidRule,
+		`{"comparator": 0, "key": 302, "value": "2018-01-01"}`:                                     errors.ErrInvalidTypeToMatch,
+		`{"comparator": 7, "key": 306, "value": [30, 7]}`:                                          errors.ErrInvalidRule,
+		`{"comparator": 4, "key": 306, "value": "30"}`:                                             errors.ErrInvalidTypeToMatch,
+	}
+
+	for input, want := range cases {
+		err := json.Unmarshal([]byte(input), &Criterion{})
+		if have := errors.Cause(err); have != want {
+			t.Errorf("%s: have %v, want %v", input, have, want)
+		}
+	}
+}
+
+func TestCriterionRegistrationMatch(t *testing.T) {
+	var (
+		now      = time.Date(2018, 3, 5, 10, 0, 0, 0, time.UTC)
+		day      = 24 * time.Hour
+		newYear  = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+		february = time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	cases := []struct {
+		criterion  Criterion
+		registered time.Time
+		match      bool
+	}{
+		{Criterion{ComparatorGT, UserRegistered, newYear, ""}, february, true},
+		{Criterion{ComparatorGT, UserRegistered, newYear, ""}, newYear, false},
+		{Criterion{ComparatorLT, UserRegistered, newYear, ""}, newYear.Add(-time.Second), true},
+		{Criterion{ComparatorBetween, UserRegistered, []time.Time{newYear, february}, ""}, newYear, true},
+		{Criterion{ComparatorBetween, UserRegistered, []time.Time{newYear, february}, ""}, february, false},
+		{Criterion{ComparatorLT, UserRegistered, newYear, ""}, time.Time{}, false},
+		{Criterion{ComparatorBetween, UserAccountAge, []int{7, 30}, ""}, now.Add(-7 * day), true},
+		{Criterion{ComparatorBetween, UserAccountAge, []int{7, 30}, ""}, now.Add(-6 * day), false},
+		{Criterion{ComparatorBetween, UserAccountAge, []int{7, 30}, ""}, now.Add(-31 * day), false},
+		{Criterion{ComparatorGT, UserAccountAge, 30, ""}, now.Add(-31 * day), true},
+		{Criterion{ComparatorLT, UserAccountAge, 1, ""}, now.Add(-time.Hour), true},
+		{Criterion{ComparatorLT, UserAccountAge, 1, ""}, time.Time{}, false},
+	}
+
+	for _, c := range cases {
+		err := c.criterion.match(Context{
+			Now: now,
+			User: ContextUser{
+				Registered: c.registered,
+			},
+		})
+
+		switch {
+		case c.match && err != nil:
+			t.Errorf("%v at %s: have %v, want match", c.criterion, c.registered, err)
+		case !c.match && errors.Cause(err) != errors.ErrCriterionNotMatch:
+			t.Errorf("%v at %s: have %v, want %v", c.criterion, c.registered, err, errors.ErrCriterionNotMatch)
+		}
+	}
+}
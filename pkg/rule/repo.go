@@ -1,6 +1,8 @@
 package rule
 
 import (
+	"reflect"
+	"sort"
 	"time"
 
 	"golang.org/x/text/language"
@@ -33,8 +35,21 @@ type Bucket struct {
 
 // Context carries information for rule decisions to match criteria.
 type Context struct {
-	User   ContextUser
-	Locale ContextLocale
+	User      ContextUser
+	Device    ContextDevice
+	Locale    ContextLocale
+	Metadata  map[string]interface{}
+	Segments  SegmentLookup
+	UserLists UserListLookup
+	// Now is the time local time criteria are matched at, the current time
+	// is used if it is zero.
+	Now time.Time
+
+	// params and applied reflect the state of the render in progress, the
+	// parameters computed so far and the bucket names of applied rules by
+	// rule name.
+	params  Parameters
+	applied map[string]string
 }
 
 // ContextUser bundles user information for rule criteria to match.
@@ -45,9 +60,21 @@ type ContextUser struct {
 	Subscription int
 }
 
+// ContextDevice bundles device information for rule criteria to match.
+type ContextDevice struct {
+	ID        string
+	Platform  string
+	OSVersion string
+}
+
 // ContextLocale bundles locale information for rule criteria to match.
 type ContextLocale struct {
 	Locale language.Tag
+	// Offset of the device's timezone from UTC in seconds.
+	Offset int
+	// Country detected from the request, e.g. by a trusted proxy. If it is
+	// unset the region of the locale is used.
+	Country language.Region
 }
 
 // Decisions reflects a matrix of rules applied to a config and if present the
@@ -71,6 +98,82 @@ type Repo interface {
 // RepoMiddleware is a chainable behaviour modifier for Repo.
 type RepoMiddleware func(Repo) Repo
 
+// ScheduleRepo provides access to rollout schedules and their records.
+type ScheduleRepo interface {
+	lifecycle
+
+	Get(ruleID string) (Schedule, error)
+	ListRecords(ruleID string) ([]ScheduleRecord, error)
+	ListRunning() ([]Schedule, error)
+	// Put creates or replaces the schedule of the rule.
+	Put(s Schedule) (Schedule, error)
+	// Record updates the schedule read as prev to s and stores the record
+	// atomically. It fails with ErrScheduleChanged if the schedule was updated
+	// since prev was read, e.g. by another instance advancing it.
+	Record(prev, s Schedule, rec ScheduleRecord) error
+}
+
+// ScheduleRepoMiddleware is a chainable behaviour modifier for ScheduleRepo.
+type ScheduleRepoMiddleware func(ScheduleRepo) ScheduleRepo
+
+// GuardrailRepo stores guardrails of rules and the metrics collected for them.
+type GuardrailRepo interface {
+	lifecycle
+
+	// AddMetric adds count and sum of the metric to the stored aggregate.
+	AddMetric(m GuardrailMetric) error
+	// Breach disarms the guardrail of the rule.
+	Breach(ruleID string, at time.Time) error
+	Get(ruleID string) (Guardrail, error)
+	ListArmed() ([]Guardrail, error)
+	ListMetrics(ruleID string) ([]GuardrailMetric, error)
+	// Put creates or replaces the guardrail of the rule and discards the
+	// metrics collected so far.
+	Put(g Guardrail) (Guardrail, error)
+	// SetMetric replaces the stored aggregate of the metric.
+	SetMetric(m GuardrailMetric) error
+}
+
+// GuardrailRepoMiddleware is a chainable behaviour modifier for GuardrailRepo.
+type GuardrailRepoMiddleware func(GuardrailRepo) GuardrailRepo
+
+// SegmentRepo stores all versions of segments.
+type SegmentRepo interface {
+	lifecycle
+
+	// Append stores the segment as a new version, it fails with ErrExists if
+	// the version is already taken.
+	Append(s Segment) (Segment, error)
+	// Get returns the latest version of the segment unless it is deleted.
+	Get(id string) (Segment, error)
+	// List returns the latest version of all segments which are not deleted.
+	List() ([]Segment, error)
+	ListVersions(id string) ([]Segment, error)
+}
+
+// SegmentRepoMiddleware is a chainable behaviour modifier for SegmentRepo.
+type SegmentRepoMiddleware func(SegmentRepo) SegmentRepo
+
+// UserListRepo stores user lists and their members.
+type UserListRepo interface {
+	lifecycle
+
+	Create(l UserList) (UserList, error)
+	Get(id string) (UserList, error)
+	List() ([]UserList, error)
+	ListByMember(userID string) ([]UserList, error)
+	Members(id string) ([]string, error)
+	// RemoveMember removes the user from all lists and returns the number of
+	// lists it was removed from.
+	RemoveMember(userID string) (int, error)
+	// Replace stores the list, increments its version and swaps its members
+	// for the given ids. The returned list carries the new version.
+	Replace(l UserList, ids []string) (UserList, error)
+}
+
+// UserListRepoMiddleware is a chainable behaviour modifier for UserListRepo.
+type UserListRepoMiddleware func(UserListRepo) UserListRepo
+
 type lifecycle interface {
 	Setup() error
 	Teardown() error
@@ -80,6 +183,7 @@ type lifecycle interface {
 type Rule struct {
 	active      bool
 	activatedAt time.Time
+	bucketing   Bucketing
 	buckets     []Bucket
 	configID    string
 	createdAt   time.Time
@@ -87,11 +191,15 @@ type Rule struct {
 	description string
 	deleted     bool
 	endTime     time.Time
+	generation  int
 	ID          string
 	kind        Kind
+	layer       Layer
 	name        string
+	reshuffle   bool
 	rollout     uint8
 	startTime   time.Time
+	sticky      bool
 	updatedAt   time.Time
 }
 
@@ -128,6 +236,86 @@ func New(
 	return r, nil
 }
 
+// Active reports if the rule is activated, not deleted and hasn't ended at the
+// given time.
+func (r Rule) Active(now time.Time) bool {
+	if !r.active || r.deleted {
+		return false
+	}
+
+	return r.endTime.IsZero() || !r.endTime.Before(now)
+}
+
+// ConfigID returns the id of the base config the rule applies to.
+func (r Rule) ConfigID() string {
+	return r.configID
+}
+
+// Keys returns the sorted set of parameter keys overridden by any bucket.
+func (r Rule) Keys() []string {
+	seen := map[string]struct{}{}
+	keys := []string{}
+
+	for _, b := range r.buckets {
+		for k := range b.Parameters {
+			if _, ok := seen[k]; ok {
+				continue
+			}
+
+			seen[k] = struct{}{}
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Name returns the name of the rule which is unique per base config.
+func (r Rule) Name() string {
+	return r.name
+}
+
+// CopyTo returns a copy of the rule under a new id applied to the given base
+// config. The creation time is kept to preserve the evaluation order.
+func (r Rule) CopyTo(id, configID string) Rule {
+	c := r
+	c.ID = id
+	c.configID = configID
+	c.deleted = false
+	c.updatedAt = time.Time{}
+
+	return c
+}
+
+// Deactivated returns a copy of the rule which is not active anymore.
+func (r Rule) Deactivated() Rule {
+	c := r
+	c.active = false
+
+	return c
+}
+
+// SameAs reports if both rules have equal content, ignoring identity, the base
+// config they apply to and bookkeeping timestamps.
+func (r Rule) SameAs(o Rule) bool {
+	return r.active == o.active &&
+		r.bucketing == o.bucketing &&
+		r.description == o.description &&
+		r.endTime.Equal(o.endTime) &&
+		r.kind == o.kind &&
+		r.layer == o.layer &&
+		r.name == o.name &&
+		r.reshuffle == o.reshuffle &&
+		r.rollout == o.rollout &&
+		r.startTime.Equal(o.startTime) &&
+		r.sticky == o.sticky &&
+		reflect.DeepEqual(r.buckets, o.buckets) &&
+		(len(r.criteria) == 0 && len(o.criteria) == 0 ||
+			reflect.DeepEqual(r.criteria, o.criteria))
+}
+
 func (r Rule) validate() error {
 	if len(r.buckets) == 0 {
 		return errors.Wrap(errors.ErrInvalidRule, "missing buckets")
@@ -157,6 +345,22 @@ func (r Rule) validate() error {
 		return errors.Wrap(errors.ErrInvalidRule, "rollout percentage too high")
 	}
 
+	if err := r.bucketing.validate(); err != nil {
+		return err
+	}
+
+	if err := r.layer.validate(); err != nil {
+		return err
+	}
+
+	if r.layer.Name != "" && r.bucketing.Key != "" && r.bucketing.Key != BucketByUser {
+		return errors.Wrapf(errors.ErrInvalidRule, "layered rules can't bucket by '%s'", r.bucketing.Key)
+	}
+
+	if err := validateStickiness(r.sticky, r.reshuffle); err != nil {
+		return err
+	}
+
 	if len(r.buckets) > 1 {
 		totalPercentage := 0
 		for _, bucket := range r.buckets {
@@ -170,6 +374,33 @@ func (r Rule) validate() error {
 	return nil
 }
 
+// ValidateRules returns an error if the given rules of one base config
+// couldn't be stored through the Repo middlewares, i.e. if any rule is
+// invalid, shares of active rules in the same layer overlap, names are taken
+// twice or prerequisites form a cycle. It's used by operations which store
+// rules bypassing the middlewares, e.g. promotions between environments.
+func ValidateRules(rules []Rule, now time.Time) error {
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			return errors.Wrapf(err, "rule '%s'", r.name)
+		}
+
+		if err := checkLayer(r, rules, now); err != nil {
+			return err
+		}
+
+		if r.deleted {
+			continue
+		}
+
+		if err := validateSiblings(r, rules); err != nil {
+			return errors.Wrapf(err, "rule '%s'", r.name)
+		}
+	}
+
+	return nil
+}
+
 // Run given an input params and context will try to match based on the rules
 // Criteria and if matched overrides the input params with its own.
 func (r Rule) Run(input Parameters, ctx Context, decisions []int, randInt generate.RandPercentageFunc) (Parameters, []int, error) {
@@ -185,28 +416,25 @@ func (r Rule) Run(input Parameters, ctx Context, decisions []int, randInt genera
 		d      = []int{}
 	)
 
-	diceRollout := randInt()
-	if len(decisions) != 0 {
-		diceRollout = decisions[0]
-	}
-
 	switch r.kind {
 	case KindOverride:
 		params = r.buckets[0].Parameters
 	case KindExperiment:
-		return Parameters{}, nil, errors.New("experiment based rules not implemented")
+		dec := r.decide(decisions, randInt)
+		d = dec.encode()
+
+		params = r.bucket(dec.dice).Parameters
 	case KindRollout:
-		if len(decisions) != 0 {
-			d = decisions
-		} else {
-			d = append(d, diceRollout)
-		}
+		dec := r.decide(decisions, randInt)
 
-		if diceRollout <= int(r.rollout) {
-			params = r.buckets[0].Parameters
-		} else {
-			return nil, d, errors.Wrap(errors.ErrRuleNotInRollout, "rollout percentage")
+		if dec.dice > int(r.rollout) && !(r.sticky && dec.sticky) {
+			return nil, dec.encode(), errors.Wrap(errors.ErrRuleNotInRollout, "rollout percentage")
 		}
+
+		dec.sticky = r.sticky
+		d = dec.encode()
+
+		params = r.buckets[0].Parameters
 	}
 
 	for name, value := range params {
@@ -215,3 +443,19 @@ func (r Rule) Run(input Parameters, ctx Context, decisions []int, randInt genera
 
 	return input, d, nil
 }
+
+// bucket returns the bucket the dice roll falls into given the cumulative
+// percentages of the buckets.
+func (r Rule) bucket(dice int) Bucket {
+	total := 0
+
+	for _, b := range r.buckets {
+		total += b.Percentage
+
+		if dice <= total {
+			return b
+		}
+	}
+
+	return r.buckets[len(r.buckets)-1]
+}
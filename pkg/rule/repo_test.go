@@ -3,6 +3,7 @@ package rule
 import (
 	"math/rand"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
@@ -17,6 +18,14 @@ var seed = rand.New(rand.NewSource(time.Now().UnixNano()))
 
 type prepareFunc func(t *testing.T) Repo
 
+type prepareScheduleRepoFunc func(t *testing.T) ScheduleRepo
+
+type prepareGuardrailRepoFunc func(t *testing.T) GuardrailRepo
+
+type prepareSegmentRepoFunc func(t *testing.T) SegmentRepo
+
+type prepareUserListRepoFunc func(t *testing.T) UserListRepo
+
 func randIntGenerateTest() int {
 	return 61
 }
@@ -443,6 +452,59 @@ func TestRuleRolloutOutsideBucket(t *testing.T) {
 	}
 }
 
+func TestRuleExperiment(t *testing.T) {
+	t.Parallel()
+
+	r, err := New(
+		generate.RandomString(12),
+		generate.RandomString(16),
+		generate.RandomString(12),
+		generate.RandomString(12),
+		KindExperiment,
+		true,
+		nil,
+		[]Bucket{
+			{
+				Name:       "control",
+				Parameters: Parameters{"paywall_variant": "a"},
+				Percentage: 60,
+			},
+			{
+				Name:       "treatment",
+				Parameters: Parameters{"paywall_variant": "b"},
+				Percentage: 40,
+			},
+		},
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		decisions []int
+		want      string
+	}{
+		{nil, "b"},
+		{[]int{1}, "a"},
+		{[]int{60}, "a"},
+		{[]int{61}, "b"},
+	} {
+		have, d, err := r.Run(Parameters{}, Context{}, c.decisions, randIntGenerateTest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if have, want := have["paywall_variant"], c.want; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		if have, want := len(d), 1; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
+
 func testRepoGet(t *testing.T, p prepareFunc) {
 	var (
 		repo      = p(t)
@@ -490,6 +552,7 @@ func testRepoGet(t *testing.T, p prepareFunc) {
 		buckets,
 		criteria,
 	)
+	rule.bucketing = Bucketing{Key: BucketByMetadata, Path: "household_id"}
 
 	_, err = repo.Create(rule)
 	if err != nil {
@@ -517,6 +580,10 @@ func testRepoGet(t *testing.T, p prepareFunc) {
 		t.Errorf("\nhave %#v, \nwant %#v", have, want)
 	}
 
+	if have, want := r.bucketing, rule.bucketing; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
 	if have, want := r.name, rule.name; !reflect.DeepEqual(have, want) {
 		t.Errorf("\nhave %#v, \nwant %#v", have, want)
 	}
@@ -1191,6 +1258,469 @@ func testRepoCreateRollout(t *testing.T, p prepareFunc) {
 	}
 }
 
+func testGuardrailRepoMetrics(t *testing.T, p prepareGuardrailRepoFunc) {
+	var (
+		repo   = p(t)
+		now    = time.Now().UTC().Truncate(time.Millisecond)
+		ruleID = generate.RandomString(12)
+		g      = Guardrail{
+			RuleID:     ruleID,
+			Metric:     "crashes",
+			Threshold:  0.01,
+			Comparison: GuardrailIncrease,
+			MinSamples: 50,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		m = GuardrailMetric{
+			RuleID:    ruleID,
+			Metric:    g.Metric,
+			Group:     GroupRollout,
+			Count:     2,
+			Sum:       1,
+			UpdatedAt: now,
+		}
+	)
+
+	_, err := repo.Put(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, add := range []func(GuardrailMetric) error{repo.AddMetric, repo.AddMetric} {
+		if err := add(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ms, err := repo.ListMetrics(ruleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(ms), 1; have != want {
+		t.Fatalf("have %v, want %v", have, want)
+	}
+
+	if have, want := ms[0].Count, 2*m.Count; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if err := repo.SetMetric(m); err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err = repo.ListMetrics(ruleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := ms, []GuardrailMetric{m}; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if err := repo.Breach(ruleID, now); err != nil {
+		t.Fatal(err)
+	}
+
+	gs, err := repo.ListArmed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(gs), 0; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	_, err = repo.Put(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gs, err = repo.ListArmed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := gs, []Guardrail{g}; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	ms, err = repo.ListMetrics(ruleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(ms), 0; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func testScheduleRepoGetNotFound(t *testing.T, p prepareScheduleRepoFunc) {
+	_, err := p(t).Get(generate.RandomString(12))
+	if have, want := errors.Cause(err), errors.ErrNotFound; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func testScheduleRepoRecord(t *testing.T, p prepareScheduleRepoFunc) {
+	var (
+		repo   = p(t)
+		now    = time.Now().UTC().Truncate(time.Millisecond)
+		ruleID = generate.RandomString(12)
+		s      = Schedule{
+			RuleID: ruleID,
+			Steps: []ScheduleStep{
+				{Percentage: 10, At: now.Add(-time.Hour)},
+				{Percentage: 100, At: now.Add(time.Hour)},
+			},
+			State:     ScheduleRunning,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+	)
+
+	_, err := repo.Put(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ss, err := repo.ListRunning()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(ss), 1; have != want {
+		t.Fatalf("have %v, want %v", have, want)
+	}
+
+	prev := s
+
+	s.Applied = 1
+	s.State = SchedulePaused
+	s.UpdatedAt = now.Add(time.Second)
+
+	rec := ScheduleRecord{
+		ID:        ulid.MustNew(ulid.Timestamp(now), seed).String(),
+		RuleID:    ruleID,
+		Event:     ScheduleEventStep,
+		Step:      0,
+		Rollout:   10,
+		CreatedAt: now,
+	}
+
+	if err := repo.Record(prev, s, rec); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second record from the same read state lost the race.
+	dup := rec
+	dup.ID = ulid.MustNew(ulid.Timestamp(now), seed).String()
+
+	err = repo.Record(prev, s, dup)
+	if have, want := errors.Cause(err), errors.ErrScheduleChanged; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	have, err := repo.Get(ruleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := have.Applied, s.Applied; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := have.State, s.State; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := len(have.Steps), len(s.Steps); have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	ss, err = repo.ListRunning()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(ss), 0; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	recs, err := repo.ListRecords(ruleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(recs), 1; have != want {
+		t.Fatalf("have %v, want %v", have, want)
+	}
+
+	if have, want := recs[0].ID, rec.ID; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func testSegmentRepoVersions(t *testing.T, p prepareSegmentRepoFunc) {
+	var (
+		repo = p(t)
+		now  = time.Now().UTC().Truncate(time.Millisecond)
+		s    = Segment{
+			ID:   generate.RandomString(12),
+			Name: "beta testers",
+			Criteria: Criteria{
+				{
+					Comparator: ComparatorIN,
+					Key:        UserID,
+					Value:      []string{"user-1", "user-2"},
+				},
+			},
+			Version:   1,
+			CreatedAt: now,
+		}
+	)
+
+	_, err := repo.Get(s.ID)
+	if have, want := errors.Cause(err), errors.ErrNotFound; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if _, err := repo.Append(s); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.Append(s)
+	if have, want := errors.Cause(err), errors.ErrExists; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	s.Name = "alpha testers"
+	s.Version = 2
+
+	if _, err := repo.Append(s); err != nil {
+		t.Fatal(err)
+	}
+
+	have, err := repo.Get(s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(have, s) {
+		t.Errorf("have %#v, want %#v", have, s)
+	}
+
+	ss, err := repo.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(ss), 1; have != want {
+		t.Fatalf("have %v, want %v", have, want)
+	}
+
+	s.Deleted = true
+	s.Version = 3
+
+	if _, err := repo.Append(s); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.Get(s.ID)
+	if have, want := errors.Cause(err), errors.ErrNotFound; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	ss, err = repo.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(ss), 0; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	vs, err := repo.ListVersions(s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(vs), 3; have != want {
+		t.Fatalf("have %v, want %v", have, want)
+	}
+
+	if have, want := vs[0].Name, "beta testers"; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func testUserListRepoMembers(t *testing.T, p prepareUserListRepoFunc) {
+	var (
+		repo  = p(t)
+		now   = time.Now().UTC().Truncate(time.Millisecond)
+		lists = []UserList{}
+	)
+
+	for _, name := range []string{"beta", "qa", "staff"} {
+		l, err := repo.Create(UserList{
+			ID:        generate.RandomString(12),
+			Name:      name,
+			Size:      2,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		l, err = repo.Replace(l, []string{"user-1", "user-2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lists = append(lists, l)
+	}
+
+	// user-1 is no member of staff.
+	staff := lists[2]
+	staff.Size = 1
+
+	staff, err := repo.Replace(staff, []string{"user-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ls, err := repo.ListByMember("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := ls, lists[:2]; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %#v, want %#v", have, want)
+	}
+
+	n, err := repo.RemoveMember("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := n, 2; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	ls, err = repo.ListByMember("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(ls), 0; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	for _, l := range lists[:2] {
+		have, err := repo.Get(l.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Caches read the members again for the new version.
+		if have, want := have.Version, l.Version+1; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		if have, want := have.Size, l.Size-1; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		members, err := repo.Members(l.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if have, want := members, []string{"user-2"}; !reflect.DeepEqual(have, want) {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+
+	have, err := repo.Get(staff.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := have.Version, staff.Version; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func testUserListRepoReplace(t *testing.T, p prepareUserListRepoFunc) {
+	var (
+		repo = p(t)
+		now  = time.Now().UTC().Truncate(time.Millisecond)
+		l    = UserList{
+			ID:        generate.RandomString(12),
+			Name:      "qa",
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+	)
+
+	_, err := repo.Replace(l, []string{"user-1"})
+	if have, want := errors.Cause(err), errors.ErrNotFound; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if _, err := repo.Create(l); err != nil {
+		t.Fatal(err)
+	}
+
+	dup := l
+	dup.ID = generate.RandomString(12)
+
+	_, err = repo.Create(dup)
+	if have, want := errors.Cause(err), errors.ErrExists; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	for _, ids := range [][]string{
+		{"user-1", "user-2", "user-3"},
+		{"user-2", "user-4"},
+	} {
+		l.Size = len(ids)
+
+		r, err := repo.Replace(l, ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if have, want := r.Version, l.Version+1; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		l.Version = r.Version
+
+		members, err := repo.Members(l.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sort.Strings(members)
+
+		if have, want := members, ids; !reflect.DeepEqual(have, want) {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+
+	have, err := repo.Get(l.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(have, l) {
+		t.Errorf("have %#v, want %#v", have, l)
+	}
+}
+
 func generateRule(
 	active bool,
 	id, configID, name string,
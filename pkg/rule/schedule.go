@@ -0,0 +1,371 @@
+package rule
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/oklog/ulid"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// Supported states of a schedule.
+const (
+	ScheduleRunning ScheduleState = "running"
+	SchedulePaused  ScheduleState = "paused"
+	ScheduleAborted ScheduleState = "aborted"
+	ScheduleDone    ScheduleState = "done"
+)
+
+// Supported events recorded for a schedule.
+const (
+	ScheduleEventStep   ScheduleEvent = "step"
+	ScheduleEventPause  ScheduleEvent = "pause"
+	ScheduleEventResume ScheduleEvent = "resume"
+	ScheduleEventAbort  ScheduleEvent = "abort"
+)
+
+// ScheduleState defines if a schedule is advanced.
+type ScheduleState string
+
+// ScheduleEvent defines the type of a schedule record.
+type ScheduleEvent string
+
+// ScheduleStep sets the rollout percentage of the rule once its time is
+// reached.
+type ScheduleStep struct {
+	Percentage uint8
+	At         time.Time
+}
+
+// Schedule is a rollout plan for a rule of kind rollout. Applied is the
+// number of steps already applied in order.
+type Schedule struct {
+	RuleID    string
+	Steps     []ScheduleStep
+	Applied   int
+	State     ScheduleState
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// due returns the number of steps which should have been applied at the given
+// time.
+func (s Schedule) due(now time.Time) int {
+	n := 0
+
+	for _, step := range s.Steps {
+		if step.At.After(now) {
+			break
+		}
+
+		n++
+	}
+
+	return n
+}
+
+func (s Schedule) validate() error {
+	if len(s.Steps) == 0 {
+		return errors.Wrap(errors.ErrScheduleInvalid, "steps missing")
+	}
+
+	for i, step := range s.Steps {
+		if step.Percentage > 100 {
+			return errors.Wrapf(errors.ErrScheduleInvalid, "step %d: percentage too high", i)
+		}
+
+		if step.At.IsZero() {
+			return errors.Wrapf(errors.ErrScheduleInvalid, "step %d: time missing", i)
+		}
+
+		if i > 0 && !step.At.After(s.Steps[i-1].At) {
+			return errors.Wrapf(errors.ErrScheduleInvalid, "step %d: not after previous step", i)
+		}
+	}
+
+	return nil
+}
+
+// ScheduleRecord is the audit record of an event of a schedule. Rollout is the
+// percentage of the rule after the event.
+type ScheduleRecord struct {
+	ID        string
+	RuleID    string
+	Event     ScheduleEvent
+	Step      int
+	Rollout   uint8
+	CreatedAt time.Time
+}
+
+// ScheduleService manages rollout schedules and advances them.
+type ScheduleService interface {
+	Abort(ruleID string) error
+	// Advance applies the latest due step of every running schedule and
+	// returns the records of the applied steps. Schedules of rules which are
+	// inactive or whose guardrail was breached are aborted instead.
+	Advance(now time.Time) ([]ScheduleRecord, error)
+	Get(ruleID string) (Schedule, []ScheduleRecord, error)
+	Pause(ruleID string) error
+	Resume(ruleID string) error
+	Set(ruleID string, steps []ScheduleStep) (Schedule, error)
+}
+
+type scheduleService struct {
+	guardrailRepo GuardrailRepo
+	repo          Repo
+	scheduleRepo  ScheduleRepo
+	seed          *rand.Rand
+}
+
+// NewScheduleService provides rollout schedules for rules of kind rollout.
+func NewScheduleService(
+	repo Repo,
+	scheduleRepo ScheduleRepo,
+	guardrailRepo GuardrailRepo,
+) ScheduleService {
+	return &scheduleService{
+		guardrailRepo: guardrailRepo,
+		repo:          repo,
+		scheduleRepo:  scheduleRepo,
+		seed:          rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s *scheduleService) Abort(ruleID string) error {
+	return s.transition(ruleID, ScheduleAborted, ScheduleEventAbort, ScheduleRunning, SchedulePaused)
+}
+
+func (s *scheduleService) Advance(now time.Time) ([]ScheduleRecord, error) {
+	ss, err := s.scheduleRepo.ListRunning()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		firstErr error
+		recs     = []ScheduleRecord{}
+	)
+
+	for _, sc := range ss {
+		rec, ok, err := s.advance(sc, now)
+		if errors.Cause(err) == errors.ErrScheduleChanged {
+			// Another instance advanced the schedule first.
+			continue
+		}
+
+		if err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "advance schedule of rule '%s'", sc.RuleID)
+			}
+
+			continue
+		}
+
+		if ok {
+			recs = append(recs, rec)
+		}
+	}
+
+	return recs, firstErr
+}
+
+func (s *scheduleService) Get(ruleID string) (Schedule, []ScheduleRecord, error) {
+	sc, err := s.scheduleRepo.Get(ruleID)
+	if err != nil {
+		return Schedule{}, nil, err
+	}
+
+	recs, err := s.scheduleRepo.ListRecords(ruleID)
+	if err != nil {
+		return Schedule{}, nil, err
+	}
+
+	return sc, recs, nil
+}
+
+func (s *scheduleService) Pause(ruleID string) error {
+	return s.transition(ruleID, SchedulePaused, ScheduleEventPause, ScheduleRunning)
+}
+
+func (s *scheduleService) Resume(ruleID string) error {
+	return s.transition(ruleID, ScheduleRunning, ScheduleEventResume, SchedulePaused)
+}
+
+func (s *scheduleService) Set(ruleID string, steps []ScheduleStep) (Schedule, error) {
+	r, err := s.repo.GetByID(ruleID)
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	if r.kind != KindRollout {
+		return Schedule{}, errors.Wrap(errors.ErrScheduleInvalid, "rule is not of kind rollout")
+	}
+
+	now := time.Now().UTC()
+
+	sc := Schedule{
+		RuleID:    ruleID,
+		Steps:     steps,
+		State:     ScheduleRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	for i := range sc.Steps {
+		sc.Steps[i].At = sc.Steps[i].At.UTC()
+	}
+
+	if err := sc.validate(); err != nil {
+		return Schedule{}, err
+	}
+
+	return s.scheduleRepo.Put(sc)
+}
+
+func (s *scheduleService) advance(sc Schedule, now time.Time) (ScheduleRecord, bool, error) {
+	due := sc.due(now)
+	if due <= sc.Applied {
+		return ScheduleRecord{}, false, nil
+	}
+
+	r, err := s.repo.GetByID(sc.RuleID)
+	if err != nil {
+		return ScheduleRecord{}, false, err
+	}
+
+	halted, err := s.halted(r)
+	if err != nil {
+		return ScheduleRecord{}, false, err
+	}
+
+	next := sc
+	next.UpdatedAt = now.UTC()
+
+	// The rollout of a rule which was stopped must not be raised again.
+	if halted {
+		next.State = ScheduleAborted
+
+		rec, err := s.record(sc, next, ScheduleEventAbort, sc.Applied-1, r.rollout)
+		if err != nil {
+			return ScheduleRecord{}, false, err
+		}
+
+		return rec, true, nil
+	}
+
+	step := sc.Steps[due-1]
+
+	if r.rollout != step.Percentage {
+		r = r.withRollout(step.Percentage)
+
+		if _, err := s.repo.UpdateWith(r); err != nil {
+			return ScheduleRecord{}, false, err
+		}
+	}
+
+	next.Applied = due
+
+	if next.Applied == len(next.Steps) {
+		next.State = ScheduleDone
+	}
+
+	rec, err := s.record(sc, next, ScheduleEventStep, due-1, step.Percentage)
+	if err != nil {
+		return ScheduleRecord{}, false, err
+	}
+
+	return rec, true, nil
+}
+
+// halted reports if the rule was deactivated or its guardrail breached.
+func (s *scheduleService) halted(r Rule) (bool, error) {
+	if !r.active {
+		return true, nil
+	}
+
+	g, err := s.guardrailRepo.Get(r.ID)
+	if err != nil {
+		if errors.Cause(err) == errors.ErrNotFound {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return !g.Armed(), nil
+}
+
+func (s *scheduleService) transition(
+	ruleID string,
+	to ScheduleState,
+	event ScheduleEvent,
+	from ...ScheduleState,
+) error {
+	sc, err := s.scheduleRepo.Get(ruleID)
+	if err != nil {
+		return err
+	}
+
+	if sc.State == to {
+		return nil
+	}
+
+	allowed := false
+
+	for _, state := range from {
+		if sc.State == state {
+			allowed = true
+			break
+		}
+	}
+
+	if !allowed {
+		return errors.Wrapf(
+			errors.ErrScheduleInvalid,
+			"can't change schedule from %s to %s",
+			sc.State,
+			to,
+		)
+	}
+
+	r, err := s.repo.GetByID(ruleID)
+	if err != nil {
+		return err
+	}
+
+	next := sc
+	next.State = to
+	next.UpdatedAt = time.Now().UTC()
+
+	_, err = s.record(sc, next, event, sc.Applied-1, r.rollout)
+
+	return err
+}
+
+func (s *scheduleService) record(
+	prev, sc Schedule,
+	event ScheduleEvent,
+	step int,
+	rollout uint8,
+) (ScheduleRecord, error) {
+	id, err := ulid.New(ulid.Timestamp(time.Now()), s.seed)
+	if err != nil {
+		return ScheduleRecord{}, errors.Wrap(errors.ErrID, err.Error())
+	}
+
+	rec := ScheduleRecord{
+		ID:        id.String(),
+		RuleID:    sc.RuleID,
+		Event:     event,
+		Step:      step,
+		Rollout:   rollout,
+		CreatedAt: sc.UpdatedAt,
+	}
+
+	if err := s.scheduleRepo.Record(prev, sc, rec); err != nil {
+		return ScheduleRecord{}, err
+	}
+
+	return rec, nil
+}
@@ -0,0 +1,163 @@
+package rule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid"
+
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/generate"
+)
+
+func TestScheduleDue(t *testing.T) {
+	var (
+		now = time.Now()
+		s   = Schedule{
+			Steps: []ScheduleStep{
+				{Percentage: 10, At: now.Add(-2 * time.Hour)},
+				{Percentage: 50, At: now.Add(-time.Hour)},
+				{Percentage: 100, At: now.Add(time.Hour)},
+			},
+		}
+	)
+
+	for _, c := range []struct {
+		now  time.Time
+		want int
+	}{
+		{now: now.Add(-3 * time.Hour), want: 0},
+		{now: now.Add(-2 * time.Hour), want: 1},
+		{now: now, want: 2},
+		{now: now.Add(2 * time.Hour), want: 3},
+	} {
+		if have, want := s.due(c.now), c.want; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	now := time.Now()
+
+	for _, steps := range [][]ScheduleStep{
+		{},
+		{{Percentage: 101, At: now}},
+		{{Percentage: 10}},
+		{{Percentage: 10, At: now}, {Percentage: 20, At: now}},
+	} {
+		err := Schedule{Steps: steps}.validate()
+		if have, want := errors.Cause(err), errors.ErrScheduleInvalid; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+
+	s := Schedule{
+		Steps: []ScheduleStep{
+			{Percentage: 10, At: now},
+			{Percentage: 100, At: now.Add(time.Hour)},
+		},
+	}
+
+	if err := s.validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScheduleServiceAdvanceHalted(t *testing.T) {
+	var (
+		repo          = preparePGRepo(t)
+		guardrailRepo = preparePGGuardrailRepo(t)
+		svc           = NewScheduleService(repo, preparePGScheduleRepo(t), guardrailRepo)
+		now           = time.Now().UTC()
+		rollout       = uint8(10)
+	)
+
+	for _, breach := range []bool{false, true} {
+		id, _ := ulid.New(ulid.Timestamp(now), seed)
+
+		// Guarded rules are active until the guardrail breaches.
+		rule, err := New(
+			id.String(),
+			generate.RandomString(12),
+			generate.RandomString(12),
+			generate.RandomString(42),
+			KindRollout,
+			breach,
+			nil,
+			[]Bucket{
+				{
+					Name: "default",
+					Parameters: Parameters{
+						"feature_funky_toggle": true,
+					},
+				},
+			},
+			&rollout,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := repo.Create(rule); err != nil {
+			t.Fatal(err)
+		}
+
+		if breach {
+			_, err := guardrailRepo.Put(Guardrail{
+				RuleID:     id.String(),
+				Metric:     "crashes",
+				Threshold:  0.1,
+				Comparison: GuardrailIncrease,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := guardrailRepo.Breach(id.String(), now); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err = svc.Set(id.String(), []ScheduleStep{
+			{Percentage: 50, At: now.Add(-time.Hour)},
+			{Percentage: 100, At: now.Add(time.Hour)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recs, err := svc.Advance(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if have, want := len(recs), 1; have != want {
+			t.Fatalf("have %v, want %v", have, want)
+		}
+
+		if have, want := recs[0].Event, ScheduleEventAbort; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		sc, _, err := svc.Get(id.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if have, want := sc.State, ScheduleAborted; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		r, err := repo.GetByID(id.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if have, want := r.rollout, rollout; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
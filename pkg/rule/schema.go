@@ -0,0 +1,286 @@
+package rule
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// Supported parameter types.
+const (
+	TypeArray     ParameterType = "array"
+	TypeBool      ParameterType = "bool"
+	TypeLocalized ParameterType = "localized"
+	TypeNumber    ParameterType = "number"
+	TypeObject    ParameterType = "object"
+	TypeString    ParameterType = "string"
+)
+
+// ParameterType is the kind of value a parameter holds.
+type ParameterType string
+
+// TypeOf returns the ParameterType of the given value as it is found in
+// decoded JSON, or an empty type if the value can't be represented.
+func TypeOf(v interface{}) ParameterType {
+	if v == nil {
+		return ""
+	}
+
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Bool:
+		return TypeBool
+	case reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return TypeNumber
+	case reflect.String:
+		return TypeString
+	case reflect.Map:
+		return TypeObject
+	case reflect.Slice, reflect.Array:
+		return TypeArray
+	default:
+		return ""
+	}
+}
+
+// ParameterSpec declares the type, documentation and constraints of a single
+// parameter. Min and Max bound the value of numbers and the length of strings
+// and arrays. Localized parameters are objects of BCP 47 tags to string
+// variants, one of which is picked at render time; their bounds apply to
+// every variant.
+type ParameterSpec struct {
+	Default     interface{}   `json:"default,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Enum        []interface{} `json:"enum,omitempty"`
+	Max         *float64      `json:"max,omitempty"`
+	Min         *float64      `json:"min,omitempty"`
+	Type        ParameterType `json:"type"`
+}
+
+// Validate returns an error if the spec itself is inconsistent.
+func (s ParameterSpec) Validate() error {
+	switch s.Type {
+	case TypeArray, TypeBool, TypeNumber, TypeObject, TypeString:
+	case TypeLocalized:
+		if len(s.Enum) > 0 {
+			return errors.New("enum not supported for localized")
+		}
+	default:
+		return errors.Errorf("unsupported type '%s'", s.Type)
+	}
+
+	if s.Min != nil && s.Max != nil && *s.Min > *s.Max {
+		return errors.New("min greater than max")
+	}
+
+	for _, e := range s.Enum {
+		if t := TypeOf(e); t != s.Type {
+			return errors.Errorf("enum value %v is %s not %s", e, t, s.Type)
+		}
+	}
+
+	if s.Default != nil {
+		if err := s.Check(s.Default); err != nil {
+			return errors.Wrap(err, "default")
+		}
+	}
+
+	return nil
+}
+
+// Check returns an error if the value doesn't satisfy the spec.
+func (s ParameterSpec) Check(v interface{}) error {
+	if s.Type == TypeLocalized {
+		variants, err := checkLocalized(v)
+		if err != nil {
+			return err
+		}
+
+		for k, variant := range variants {
+			if err := (ParameterSpec{Max: s.Max, Min: s.Min, Type: TypeString}).Check(variant); err != nil {
+				return errors.Wrapf(err, "variant '%s'", k)
+			}
+		}
+
+		return nil
+	}
+
+	if t := TypeOf(v); t != s.Type {
+		return errors.Errorf("type '%s' != '%s'", t, s.Type)
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+
+		for _, e := range s.Enum {
+			if reflect.DeepEqual(normalise(e), normalise(v)) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return errors.Errorf("value %v not in %v", v, s.Enum)
+		}
+	}
+
+	if s.Min == nil && s.Max == nil {
+		return nil
+	}
+
+	var n float64
+
+	switch s.Type {
+	case TypeNumber:
+		n = reflect.ValueOf(normalise(v)).Float()
+	case TypeString, TypeArray:
+		n = float64(reflect.ValueOf(v).Len())
+	default:
+		return nil
+	}
+
+	if s.Min != nil && n < *s.Min {
+		return errors.Errorf("%v below min %v", n, *s.Min)
+	}
+
+	if s.Max != nil && n > *s.Max {
+		return errors.Errorf("%v above max %v", n, *s.Max)
+	}
+
+	return nil
+}
+
+// ParameterSchema is the declaration of all parameters of a base config.
+type ParameterSchema map[string]ParameterSpec
+
+// Defaults returns the default values of all parameters which declare one.
+func (s ParameterSchema) Defaults() Parameters {
+	ps := Parameters{}
+
+	for k, spec := range s {
+		if spec.Default != nil {
+			ps[k] = spec.Default
+		}
+	}
+
+	return ps
+}
+
+// Validate returns an error if the schema has an inconsistent spec.
+func (s ParameterSchema) Validate() error {
+	for _, k := range s.keys() {
+		if err := s[k].Validate(); err != nil {
+			return errors.Wrapf(errors.ErrParametersInvalid, "schema '%s': %s", k, err)
+		}
+	}
+
+	return nil
+}
+
+// Check returns an error if any of the given parameters is not declared or its
+// value doesn't satisfy the spec.
+func (s ParameterSchema) Check(ps Parameters) error {
+	keys := []string{}
+
+	for k := range ps {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		spec, ok := s[k]
+		if !ok {
+			return errors.Wrapf(errors.ErrParametersInvalid, "'%s' not declared", k)
+		}
+
+		if err := spec.Check(ps[k]); err != nil {
+			return errors.Wrapf(errors.ErrParametersInvalid, "'%s': %s", k, err)
+		}
+	}
+
+	return nil
+}
+
+func (s ParameterSchema) keys() []string {
+	keys := []string{}
+
+	for k := range s {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// normalise converts numbers to float64 to compare values independent of how
+// they were decoded.
+func normalise(v interface{}) interface{} {
+	if TypeOf(v) != TypeNumber {
+		return v
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	default:
+		return rv.Float()
+	}
+}
+
+// ValidateParameters checks the parameters of all buckets against the schema.
+func (r Rule) ValidateParameters(schema ParameterSchema) error {
+	for _, b := range r.buckets {
+		if err := schema.Check(b.Parameters); err != nil {
+			return errors.Wrapf(err, "bucket '%s'", b.Name)
+		}
+	}
+
+	return nil
+}
+
+// ValidateOverrides checks that the parameters of all buckets only override
+// keys present in base without changing the type of their value.
+func (r Rule) ValidateOverrides(base Parameters) error {
+	for _, b := range r.buckets {
+		keys := []string{}
+
+		for k := range b.Parameters {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			v, ok := base[k]
+			if !ok {
+				return errors.Wrapf(
+					errors.ErrParametersInvalid,
+					"bucket '%s': '%s' not in base config",
+					b.Name,
+					k,
+				)
+			}
+
+			if have, want := TypeOf(b.Parameters[k]), TypeOf(v); have != want {
+				return errors.Wrapf(
+					errors.ErrParametersInvalid,
+					"bucket '%s': '%s' type '%s' != '%s'",
+					b.Name,
+					k,
+					have,
+					want,
+				)
+			}
+		}
+	}
+
+	return nil
+}
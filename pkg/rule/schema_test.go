@@ -0,0 +1,174 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+func TestParameterSchemaCheck(t *testing.T) {
+	var (
+		min    = float64(1)
+		max    = float64(10)
+		schema = ParameterSchema{
+			"feature_paywall_enabled": {
+				Type: TypeBool,
+			},
+			"feature_paywall_price": {
+				Max:  &max,
+				Min:  &min,
+				Type: TypeNumber,
+			},
+			"feature_paywall_variant": {
+				Enum: []interface{}{"control", "blue"},
+				Type: TypeString,
+			},
+			"feature_paywall_products": {
+				Type: TypeArray,
+			},
+			"feature_paywall_copy": {
+				Type: TypeObject,
+			},
+			"feature_paywall_title": {
+				Max:  &max,
+				Type: TypeLocalized,
+			},
+		}
+		valid = []Parameters{
+			{"feature_paywall_enabled": true},
+			{"feature_paywall_price": float64(4)},
+			{"feature_paywall_price": 10},
+			{"feature_paywall_variant": "blue"},
+			{"feature_paywall_products": []interface{}{"monthly", "yearly"}},
+			{"feature_paywall_copy": map[string]interface{}{"title": "Go premium"}},
+			{"feature_paywall_title": map[string]interface{}{"und": "Premium", "pt-BR": "Premium"}},
+		}
+		invalid = []Parameters{
+			{"feature_unknown_enabled": true},                                                // Not declared.
+			{"feature_paywall_enabled": "true"},                                              // Type missmatch.
+			{"feature_paywall_price": float64(11)},                                           // Above max.
+			{"feature_paywall_price": float64(0.5)},                                          // Below min.
+			{"feature_paywall_variant": "red"},                                               // Not in enum.
+			{"feature_paywall_products": "monthly"},                                          // Not an array.
+			{"feature_paywall_copy": []interface{}{""}},                                      // Not an object.
+			{"feature_paywall_title": "Premium"},                                             // Not localized.
+			{"feature_paywall_title": map[string]interface{}{"de": "Premium"}},               // Fallback missing.
+			{"feature_paywall_title": map[string]interface{}{"und": "Premium", "xx_1": "a"}}, // Not a language tag.
+			{"feature_paywall_title": map[string]interface{}{"und": "Premium", "de": 1}},     // Variant not a string.
+			{"feature_paywall_title": map[string]interface{}{"und": "Premium upgrade now"}},  // Variant above max.
+		}
+	)
+
+	if err := schema.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ps := range valid {
+		if err := schema.Check(ps); err != nil {
+			t.Errorf("%v: %s", ps, err)
+		}
+	}
+
+	for _, ps := range invalid {
+		err := schema.Check(ps)
+		if have, want := errors.Cause(err), errors.ErrParametersInvalid; have != want {
+			t.Errorf("%v: have %v, want %v", ps, have, want)
+		}
+	}
+}
+
+func TestParameterSchemaValidate(t *testing.T) {
+	var (
+		min   = float64(10)
+		max   = float64(1)
+		cases = []ParameterSchema{
+			{"feature_x_enabled": {Type: "integer"}},                                               // Unsupported type.
+			{"feature_x_enabled": {Type: TypeBool, Default: "yes"}},                                // Default type missmatch.
+			{"feature_x_amount": {Type: TypeNumber, Min: &min, Max: &max}},                         // Min greater than max.
+			{"feature_x_variant": {Type: TypeString, Enum: []interface{}{1}}},                      // Enum type missmatch.
+			{"feature_x_amount": {Type: TypeNumber, Default: 5, Min: &min}},                        // Default below min.
+			{"feature_x_variant": {Type: TypeString, Default: "c", Enum: []interface{}{"a", "b"}}}, // Default not in enum.
+			{"feature_x_title": {Type: TypeLocalized, Enum: []interface{}{"a"}}},                   // Enum on localized.
+		}
+	)
+
+	for _, c := range cases {
+		err := c.Validate()
+		if have, want := errors.Cause(err), errors.ErrParametersInvalid; have != want {
+			t.Errorf("%v: have %v, want %v", c, have, want)
+		}
+	}
+}
+
+func TestRuleValidateParameters(t *testing.T) {
+	var (
+		schema = ParameterSchema{
+			"feature_paywall_price": {
+				Type: TypeNumber,
+			},
+		}
+		r = Rule{
+			buckets: []Bucket{
+				{
+					Name: "default",
+					Parameters: Parameters{
+						"feature_paywall_price": "free",
+					},
+				},
+			},
+		}
+	)
+
+	err := r.ValidateParameters(schema)
+	if have, want := errors.Cause(err), errors.ErrParametersInvalid; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestRuleValidateOverrides(t *testing.T) {
+	var (
+		base = Parameters{
+			"feature_paywall_enabled": false,
+			"feature_paywall_price":   5,
+		}
+		cases = []struct {
+			params Parameters
+			valid  bool
+		}{
+			{
+				params: Parameters{"feature_paywall_price": 9.99},
+				valid:  true,
+			}, // Matching type.
+			{
+				params: Parameters{"feature_paywall_price": "free"},
+			}, // Type changed.
+			{
+				params: Parameters{"feature_paywall_copy": "Buy now"},
+			}, // Key missing from base.
+		}
+	)
+
+	for _, c := range cases {
+		r := Rule{
+			buckets: []Bucket{
+				{
+					Name:       "default",
+					Parameters: c.params,
+				},
+			},
+		}
+
+		err := r.ValidateOverrides(base)
+		if c.valid {
+			if err != nil {
+				t.Errorf("have %v, want %v", err, nil)
+			}
+
+			continue
+		}
+
+		if have, want := errors.Cause(err), errors.ErrParametersInvalid; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
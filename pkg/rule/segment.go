@@ -0,0 +1,388 @@
+package rule
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// Segment is a named set of criteria rules can reference with a SegmentID
+// criterion. Every change is stored as a new version, rules always match
+// against the latest one.
+type Segment struct {
+	ID          string
+	Name        string
+	Description string
+	Criteria    Criteria
+	Version     int
+	Deleted     bool
+	CreatedAt   time.Time
+}
+
+func (s Segment) validate() error {
+	if s.ID == "" {
+		return errors.Wrap(errors.ErrSegmentInvalid, "id missing")
+	}
+
+	if s.Name == "" {
+		return errors.Wrap(errors.ErrSegmentInvalid, "name missing")
+	}
+
+	if len(s.Criteria) == 0 {
+		return errors.Wrap(errors.ErrSegmentInvalid, "criteria missing")
+	}
+
+	for _, c := range s.Criteria {
+		switch c.Key {
+		case SegmentID:
+			return errors.Wrap(errors.ErrSegmentInvalid, "segments can't reference segments")
+		case ParameterValue, RuleDecision:
+			return errors.Wrap(errors.ErrSegmentInvalid, "segments can't reference the render")
+		}
+	}
+
+	return nil
+}
+
+// SegmentLookup resolves segments referenced by criteria.
+type SegmentLookup interface {
+	Lookup(id string) (Segment, error)
+}
+
+type segmentCacheEntry struct {
+	err     error
+	expires time.Time
+	segment Segment
+}
+
+type segmentCache struct {
+	entries map[string]segmentCacheEntry
+	mu      sync.RWMutex
+	repo    SegmentRepo
+	ttl     time.Duration
+}
+
+// NewSegmentCache returns a SegmentLookup which keeps segments read from the
+// repo for the given duration. Changes to a segment apply to all rules
+// referencing it once the cached entry expired.
+func NewSegmentCache(repo SegmentRepo, ttl time.Duration) SegmentLookup {
+	return &segmentCache{
+		entries: map[string]segmentCacheEntry{},
+		repo:    repo,
+		ttl:     ttl,
+	}
+}
+
+func (c *segmentCache) Lookup(id string) (Segment, error) {
+	now := time.Now()
+
+	c.mu.RLock()
+	e, ok := c.entries[id]
+	c.mu.RUnlock()
+
+	if ok && now.Before(e.expires) {
+		return e.segment, e.err
+	}
+
+	s, err := c.repo.Get(id)
+	if err != nil && errors.Cause(err) != errors.ErrNotFound {
+		return Segment{}, err
+	}
+
+	c.mu.Lock()
+	c.entries[id] = segmentCacheEntry{
+		err:     err,
+		expires: now.Add(c.ttl),
+		segment: s,
+	}
+	c.mu.Unlock()
+
+	return s, err
+}
+
+// segmentMap is a SegmentLookup over a fixed set of segments.
+type segmentMap map[string]Segment
+
+func (m segmentMap) Lookup(id string) (Segment, error) {
+	s, ok := m[id]
+	if !ok {
+		return Segment{}, errors.Wrapf(errors.ErrNotFound, "segment '%s'", id)
+	}
+
+	return s, nil
+}
+
+// matchSegment matches if all criteria of the segment match for ComparatorEQ
+// and if any of them doesn't for ComparatorNQ.
+func matchSegment(comparator Comparator, s Segment, ctx Context) error {
+	in := true
+
+	for _, c := range s.Criteria {
+		err := c.match(ctx)
+		if err != nil {
+			if errors.Cause(err) != errors.ErrCriterionNotMatch {
+				return err
+			}
+
+			in = false
+			break
+		}
+	}
+
+	switch comparator {
+	case ComparatorEQ:
+		if !in {
+			return errors.Wrapf(errors.ErrCriterionNotMatch, "not in segment '%s'", s.Name)
+		}
+	case ComparatorNQ:
+		if in {
+			return errors.Wrapf(errors.ErrCriterionNotMatch, "in segment '%s'", s.Name)
+		}
+	default:
+		return errors.Errorf("comparator '%s' not supported", comparator)
+	}
+
+	return nil
+}
+
+// segmentIDs returns the ids of all segments the rule references.
+func (r Rule) segmentIDs() []string {
+	ids := []string{}
+
+	for _, c := range r.criteria {
+		if c.Key != SegmentID {
+			continue
+		}
+
+		if id, ok := c.Value.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// SegmentService manages segments.
+type SegmentService interface {
+	Create(name, description string, criteria Criteria) (Segment, error)
+	// Delete removes the segment unless a rule references it.
+	Delete(id string) error
+	Get(id string) (Segment, error)
+	List() ([]Segment, error)
+	Update(id, name, description string, criteria Criteria) (Segment, error)
+	// Usage returns all rules referencing the segment.
+	Usage(id string) ([]Rule, error)
+	Versions(id string) ([]Segment, error)
+}
+
+type segmentService struct {
+	repo        Repo
+	seed        *rand.Rand
+	segmentRepo SegmentRepo
+}
+
+// NewSegmentService provides segments for rules to reference.
+func NewSegmentService(segmentRepo SegmentRepo, repo Repo) SegmentService {
+	return &segmentService{
+		repo:        repo,
+		seed:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		segmentRepo: segmentRepo,
+	}
+}
+
+func (s *segmentService) Create(
+	name, description string,
+	criteria Criteria,
+) (Segment, error) {
+	id, err := ulid.New(ulid.Timestamp(time.Now()), s.seed)
+	if err != nil {
+		return Segment{}, errors.Wrap(errors.ErrID, err.Error())
+	}
+
+	seg := Segment{
+		ID:          id.String(),
+		Name:        name,
+		Description: description,
+		Criteria:    criteria,
+		Version:     1,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	if err := s.prepare(seg); err != nil {
+		return Segment{}, err
+	}
+
+	return s.segmentRepo.Append(seg)
+}
+
+func (s *segmentService) Delete(id string) error {
+	seg, err := s.segmentRepo.Get(id)
+	if err != nil {
+		return err
+	}
+
+	rs, err := s.Usage(id)
+	if err != nil {
+		return err
+	}
+
+	if len(rs) > 0 {
+		return errors.Wrapf(errors.ErrInUse, "segment '%s' used by %d rules", seg.Name, len(rs))
+	}
+
+	seg.Deleted = true
+	seg.Version++
+	seg.CreatedAt = time.Now().UTC()
+
+	_, err = s.segmentRepo.Append(seg)
+
+	return err
+}
+
+func (s *segmentService) Get(id string) (Segment, error) {
+	return s.segmentRepo.Get(id)
+}
+
+func (s *segmentService) List() ([]Segment, error) {
+	return s.segmentRepo.List()
+}
+
+func (s *segmentService) Update(
+	id, name, description string,
+	criteria Criteria,
+) (Segment, error) {
+	seg, err := s.segmentRepo.Get(id)
+	if err != nil {
+		return Segment{}, err
+	}
+
+	seg.Name = name
+	seg.Description = description
+	seg.Criteria = criteria
+	seg.Version++
+	seg.CreatedAt = time.Now().UTC()
+
+	if err := s.prepare(seg); err != nil {
+		return Segment{}, err
+	}
+
+	return s.segmentRepo.Append(seg)
+}
+
+func (s *segmentService) Usage(id string) ([]Rule, error) {
+	rs, err := s.repo.ListAll()
+	if err != nil {
+		return nil, err
+	}
+
+	used := []Rule{}
+
+	for _, r := range rs {
+		for _, sid := range r.segmentIDs() {
+			if sid == id {
+				used = append(used, r)
+				break
+			}
+		}
+	}
+
+	sort.Slice(used, func(i, j int) bool { return used[i].ID < used[j].ID })
+
+	return used, nil
+}
+
+func (s *segmentService) Versions(id string) ([]Segment, error) {
+	return s.segmentRepo.ListVersions(id)
+}
+
+// prepare validates the segment and ensures its name is unique.
+func (s *segmentService) prepare(seg Segment) error {
+	if err := seg.validate(); err != nil {
+		return err
+	}
+
+	ss, err := s.segmentRepo.List()
+	if err != nil {
+		return err
+	}
+
+	for _, o := range ss {
+		if o.ID != seg.ID && o.Name == seg.Name {
+			return errors.Wrapf(errors.ErrExists, "segment '%s'", seg.Name)
+		}
+	}
+
+	return nil
+}
+
+type segmentRuleRepo struct {
+	next        Repo
+	segmentRepo SegmentRepo
+}
+
+// NewRuleRepoSegmentMiddleware wraps the next Repo and rejects rules which
+// reference segments that don't exist.
+func NewRuleRepoSegmentMiddleware(segmentRepo SegmentRepo) RepoMiddleware {
+	return func(next Repo) Repo {
+		return &segmentRuleRepo{
+			next:        next,
+			segmentRepo: segmentRepo,
+		}
+	}
+}
+
+func (r *segmentRuleRepo) Create(input Rule) (Rule, error) {
+	if err := r.validate(input); err != nil {
+		return Rule{}, err
+	}
+
+	return r.next.Create(input)
+}
+
+func (r *segmentRuleRepo) GetByID(id string) (Rule, error) {
+	return r.next.GetByID(id)
+}
+
+func (r *segmentRuleRepo) UpdateWith(input Rule) (Rule, error) {
+	if err := r.validate(input); err != nil {
+		return Rule{}, err
+	}
+
+	return r.next.UpdateWith(input)
+}
+
+func (r *segmentRuleRepo) ListAll() ([]Rule, error) {
+	return r.next.ListAll()
+}
+
+func (r *segmentRuleRepo) ListActive(configID string, now time.Time) ([]Rule, error) {
+	return r.next.ListActive(configID, now)
+}
+
+func (r *segmentRuleRepo) Setup() error {
+	return r.next.Setup()
+}
+
+func (r *segmentRuleRepo) Teardown() error {
+	return r.next.Teardown()
+}
+
+func (r *segmentRuleRepo) validate(input Rule) error {
+	for _, id := range input.segmentIDs() {
+		_, err := r.segmentRepo.Get(id)
+		if err != nil {
+			if errors.Cause(err) == errors.ErrNotFound {
+				return errors.Wrapf(errors.ErrInvalidRule, "segment '%s' not found", id)
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+func TestSegmentMatch(t *testing.T) {
+	t.Parallel()
+
+	segments := segmentMap{
+		"segment-1": {
+			ID:   "segment-1",
+			Name: "beta testers",
+			Criteria: Criteria{
+				{
+					Comparator: ComparatorIN,
+					Key:        UserID,
+					Value:      []string{"user-1", "user-2"},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		comparator Comparator
+		userID     string
+		err        error
+	}{
+		{ComparatorEQ, "user-1", nil},
+		{ComparatorEQ, "user-3", errors.ErrCriterionNotMatch},
+		{ComparatorNQ, "user-1", errors.ErrCriterionNotMatch},
+		{ComparatorNQ, "user-3", nil},
+	}
+
+	for _, c := range cases {
+		criterion := Criterion{
+			Comparator: c.comparator,
+			Key:        SegmentID,
+			Value:      "segment-1",
+		}
+
+		err := criterion.match(Context{
+			Segments: segments,
+			User: ContextUser{
+				ID: c.userID,
+			},
+		})
+		if have, want := errors.Cause(err), c.err; have != want {
+			t.Errorf("%s %s: have %v, want %v", c.comparator, c.userID, have, want)
+		}
+	}
+
+	criterion := Criterion{
+		Comparator: ComparatorEQ,
+		Key:        SegmentID,
+		Value:      "segment-2",
+	}
+
+	err := criterion.match(Context{Segments: segments})
+	if have, want := errors.Cause(err), errors.ErrNotFound; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestSegmentValidate(t *testing.T) {
+	t.Parallel()
+
+	criteria := Criteria{
+		{
+			Comparator: ComparatorEQ,
+			Key:        SegmentID,
+			Value:      "segment-2",
+		},
+	}
+
+	for _, s := range []Segment{
+		{ID: "segment-1", Criteria: criteria},
+		{ID: "segment-1", Name: "empty"},
+		{ID: "segment-1", Name: "nested", Criteria: criteria},
+	} {
+		err := s.validate()
+		if have, want := errors.Cause(err), errors.ErrSegmentInvalid; have != want {
+			t.Errorf("%s: have %v, want %v", s.Name, have, want)
+		}
+	}
+}
@@ -8,7 +8,10 @@ type Service interface {
 	Deactivate(id string) error
 	GetByID(id string) (Rule, error)
 	List() (List, error)
+	UpdateBucketing(id string, bucketing Bucketing) error
+	UpdateLayer(id, layer string, share uint8) error
 	UpdateRollout(id string, rollout uint8) error
+	UpdateStickiness(id string, sticky, reshuffle bool) error
 }
 
 type service struct {
@@ -32,6 +35,7 @@ func (s *service) Activate(id string) error {
 		return nil
 	}
 
+	r = r.restarted()
 	r.active = true
 	r.activatedAt = time.Now()
 
@@ -65,6 +69,42 @@ func (s *service) List() (List, error) {
 	return s.repo.ListAll()
 }
 
+func (s *service) UpdateBucketing(id string, bucketing Bucketing) error {
+	r, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	r, err = r.WithBucketing(bucketing)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.repo.UpdateWith(r)
+
+	return err
+}
+
+func (s *service) UpdateLayer(id, layer string, share uint8) error {
+	r, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	if r.layer.Name == layer && r.layer.Share == share {
+		return nil
+	}
+
+	r, err = r.InLayer(layer, share)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.repo.UpdateWith(r)
+
+	return err
+}
+
 func (s *service) UpdateRollout(id string, rollout uint8) error {
 	r, err := s.repo.GetByID(id)
 	if err != nil {
@@ -75,7 +115,27 @@ func (s *service) UpdateRollout(id string, rollout uint8) error {
 		return nil
 	}
 
-	r.rollout = rollout
+	r = r.withRollout(rollout)
+
+	_, err = s.repo.UpdateWith(r)
+
+	return err
+}
+
+func (s *service) UpdateStickiness(id string, sticky, reshuffle bool) error {
+	r, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	if r.sticky == sticky && r.reshuffle == reshuffle {
+		return nil
+	}
+
+	r, err = r.WithStickiness(sticky, reshuffle)
+	if err != nil {
+		return err
+	}
 
 	_, err = s.repo.UpdateWith(r)
 
@@ -0,0 +1,445 @@
+package rule
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// SnapshotVersion is the format version of snapshots produced by this package.
+// It is only bumped on incompatible changes of the wire format. New fields are
+// added as optional and ignored by readers which don't know them.
+const SnapshotVersion = 1
+
+// Snapshot is a point in time copy of all base configs of a client and their
+// active rules, which can be evaluated in-process without persistence.
+type Snapshot struct {
+	Bases     []SnapshotBase
+	ClientID  string
+	Rules     []Rule
+	Segments  []Segment
+	Version   int
+	CreatedAt time.Time
+}
+
+// SnapshotBase is the subset of a base config needed for evaluation. The
+// schema is carried to resolve localized parameters.
+type SnapshotBase struct {
+	ID         string
+	Name       string
+	Parameters Parameters
+	Platforms  PlatformParameters
+	Schema     ParameterSchema
+}
+
+// NewSnapshot returns a snapshot of the given bases and the active rules among
+// rules which belong to one of them.
+func NewSnapshot(clientID string, bases []SnapshotBase, rules []Rule, now time.Time) Snapshot {
+	ids := map[string]struct{}{}
+
+	for _, b := range bases {
+		ids[b.ID] = struct{}{}
+	}
+
+	rs := []Rule{}
+
+	for _, r := range rules {
+		if _, ok := ids[r.configID]; !ok || !r.active || r.deleted {
+			continue
+		}
+
+		rs = append(rs, r)
+	}
+
+	return Snapshot{
+		Bases:     bases,
+		ClientID:  clientID,
+		Rules:     rs,
+		Version:   SnapshotVersion,
+		CreatedAt: now,
+	}
+}
+
+// WithSegments returns a copy of the snapshot which carries the given segments
+// its rules reference, so they can be evaluated without a SegmentLookup.
+func (s Snapshot) WithSegments(segments []Segment) Snapshot {
+	ids := map[string]struct{}{}
+
+	for _, r := range s.Rules {
+		for _, id := range r.segmentIDs() {
+			ids[id] = struct{}{}
+		}
+	}
+
+	c := s
+	c.Segments = nil
+
+	for _, seg := range segments {
+		if _, ok := ids[seg.ID]; !ok || seg.Deleted {
+			continue
+		}
+
+		c.Segments = append(c.Segments, seg)
+	}
+
+	return c
+}
+
+// Render evaluates the rules of the named base config for the user in ctx at
+// the given time. Percentage based decisions are taken with HashDice, which
+// matches the rendering of the config API only if it runs with hash based
+// bucketing, the default. With random bucketing results differ for every
+// percentage based rule. User lists are not part of snapshots and have to be
+// provided with ctx.UserLists. Sticky rules only keep the user in their bucket
+// if the decisions of the last render are passed as previous.
+func (s Snapshot) Render(
+	baseName string,
+	ctx Context,
+	previous Decisions,
+	now time.Time,
+) (Parameters, Decisions, error) {
+	var base *SnapshotBase
+
+	for i := range s.Bases {
+		if s.Bases[i].Name == baseName {
+			base = &s.Bases[i]
+			break
+		}
+	}
+
+	if base == nil {
+		return nil, nil, errors.Wrapf(errors.ErrNotFound, "base config '%s'", baseName)
+	}
+
+	rs := []Rule{}
+
+	for _, r := range s.Rules {
+		if r.configID != base.ID || !r.active || r.deleted {
+			continue
+		}
+
+		if !r.startTime.IsZero() && r.startTime.After(now) {
+			continue
+		}
+
+		if !r.endTime.IsZero() && r.endTime.Before(now) {
+			continue
+		}
+
+		rs = append(rs, r)
+	}
+
+	if ctx.Now.IsZero() {
+		ctx.Now = now
+	}
+
+	if ctx.Segments == nil {
+		m := segmentMap{}
+
+		for _, seg := range s.Segments {
+			m[seg.ID] = seg
+		}
+
+		ctx.Segments = m
+	}
+
+	params, decisions, err := Evaluate(
+		base.Platforms.Apply(base.Parameters, ctx.Device.Platform),
+		rs,
+		ctx,
+		previous,
+		HashDice,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return base.Schema.Localize(params, ctx.Locale.Locale), decisions, nil
+}
+
+// MarshalJSON to satisfy json.Marshaler.
+func (s Snapshot) MarshalJSON() ([]byte, error) {
+	v := snapshotJSON{
+		Bases:     []snapshotBaseJSON{},
+		ClientID:  s.ClientID,
+		Rules:     []snapshotRuleJSON{},
+		Version:   s.Version,
+		CreatedAt: s.CreatedAt.UTC(),
+	}
+
+	for _, b := range s.Bases {
+		v.Bases = append(v.Bases, snapshotBaseJSON{
+			ID:         b.ID,
+			Name:       b.Name,
+			Parameters: b.Parameters,
+			Platforms:  b.Platforms,
+			Schema:     b.Schema,
+		})
+	}
+
+	for _, r := range s.Rules {
+		v.Rules = append(v.Rules, toSnapshotRuleJSON(r))
+	}
+
+	for _, seg := range s.Segments {
+		v.Segments = append(v.Segments, snapshotSegmentJSON{
+			Criteria: seg.Criteria,
+			ID:       seg.ID,
+			Name:     seg.Name,
+			Version:  seg.Version,
+		})
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON to satisfy json.Unmarshaler.
+func (s *Snapshot) UnmarshalJSON(raw []byte) error {
+	v := snapshotJSON{}
+
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return err
+	}
+
+	if v.Version > SnapshotVersion {
+		return errors.Wrapf(
+			errors.ErrSnapshotVersion,
+			"version %d newer than supported %d",
+			v.Version,
+			SnapshotVersion,
+		)
+	}
+
+	s.Bases = []SnapshotBase{}
+	s.ClientID = v.ClientID
+	s.Rules = []Rule{}
+	s.Segments = nil
+	s.Version = v.Version
+	s.CreatedAt = v.CreatedAt
+
+	for _, b := range v.Bases {
+		s.Bases = append(s.Bases, SnapshotBase{
+			ID:         b.ID,
+			Name:       b.Name,
+			Parameters: b.Parameters,
+			Platforms:  b.Platforms,
+			Schema:     b.Schema,
+		})
+	}
+
+	for _, r := range v.Rules {
+		s.Rules = append(s.Rules, r.rule())
+	}
+
+	for _, seg := range v.Segments {
+		s.Segments = append(s.Segments, Segment{
+			Criteria: seg.Criteria,
+			ID:       seg.ID,
+			Name:     seg.Name,
+			Version:  seg.Version,
+		})
+	}
+
+	return nil
+}
+
+// SignSnapshot encodes the snapshot and signs it with the given secret.
+func SignSnapshot(s Snapshot, secret []byte) ([]byte, error) {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal snapshot")
+	}
+
+	return json.Marshal(signedSnapshot{
+		Signature: signature(raw, secret),
+		Snapshot:  raw,
+	})
+}
+
+// VerifySnapshot checks the signature of an encoded snapshot with the given
+// secret and decodes it.
+func VerifySnapshot(raw, secret []byte) (Snapshot, error) {
+	v := signedSnapshot{}
+
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return Snapshot{}, errors.Wrap(err, "unmarshal signed snapshot")
+	}
+
+	if !hmac.Equal([]byte(v.Signature), []byte(signature(v.Snapshot, secret))) {
+		return Snapshot{}, errors.Wrap(errors.ErrSignatureMissmatch, "snapshot")
+	}
+
+	s := Snapshot{}
+
+	if err := json.Unmarshal(v.Snapshot, &s); err != nil {
+		return Snapshot{}, errors.Wrap(err, "unmarshal snapshot")
+	}
+
+	return s, nil
+}
+
+func signature(raw, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(raw)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type signedSnapshot struct {
+	Signature string          `json:"signature"`
+	Snapshot  json.RawMessage `json:"snapshot"`
+}
+
+type snapshotJSON struct {
+	Bases     []snapshotBaseJSON    `json:"bases"`
+	ClientID  string                `json:"client_id"`
+	Rules     []snapshotRuleJSON    `json:"rules"`
+	Segments  []snapshotSegmentJSON `json:"segments,omitempty"`
+	Version   int                   `json:"version"`
+	CreatedAt time.Time             `json:"created_at"`
+}
+
+type snapshotBaseJSON struct {
+	ID         string             `json:"id"`
+	Name       string             `json:"name"`
+	Parameters Parameters         `json:"parameters"`
+	Platforms  PlatformParameters `json:"platforms,omitempty"`
+	Schema     ParameterSchema    `json:"schema,omitempty"`
+}
+
+type snapshotBucketJSON struct {
+	Name       string     `json:"name"`
+	Parameters Parameters `json:"parameters"`
+	Percentage int        `json:"percentage"`
+}
+
+type snapshotRuleJSON struct {
+	Bucketing  *Bucketing           `json:"bucketing,omitempty"`
+	Buckets    []snapshotBucketJSON `json:"buckets"`
+	ConfigID   string               `json:"config_id"`
+	Criteria   Criteria             `json:"criteria"`
+	Generation int                  `json:"generation,omitempty"`
+	ID         string               `json:"id"`
+	Kind       Kind                 `json:"kind"`
+	Layer      *snapshotLayerJSON   `json:"layer,omitempty"`
+	Name       string               `json:"name"`
+	Reshuffle  bool                 `json:"reshuffle,omitempty"`
+	Rollout    uint8                `json:"rollout"`
+	Sticky     bool                 `json:"sticky,omitempty"`
+	CreatedAt  time.Time            `json:"created_at"`
+	EndTime    *time.Time           `json:"end_time,omitempty"`
+	StartTime  *time.Time           `json:"start_time,omitempty"`
+}
+
+type snapshotSegmentJSON struct {
+	Criteria Criteria `json:"criteria"`
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Version  int      `json:"version"`
+}
+
+type snapshotLayerJSON struct {
+	Name   string `json:"name"`
+	Offset uint8  `json:"offset"`
+	Share  uint8  `json:"share"`
+}
+
+func toSnapshotRuleJSON(r Rule) snapshotRuleJSON {
+	v := snapshotRuleJSON{
+		Buckets:    []snapshotBucketJSON{},
+		ConfigID:   r.configID,
+		Criteria:   r.criteria,
+		Generation: r.generation,
+		ID:         r.ID,
+		Kind:       r.kind,
+		Name:       r.name,
+		Reshuffle:  r.reshuffle,
+		Rollout:    r.rollout,
+		Sticky:     r.sticky,
+		CreatedAt:  r.createdAt.UTC(),
+	}
+
+	if v.Criteria == nil {
+		v.Criteria = Criteria{}
+	}
+
+	if r.bucketing.Key != "" {
+		b := r.bucketing
+		v.Bucketing = &b
+	}
+
+	for _, b := range r.buckets {
+		v.Buckets = append(v.Buckets, snapshotBucketJSON{
+			Name:       b.Name,
+			Parameters: b.Parameters,
+			Percentage: b.Percentage,
+		})
+	}
+
+	if !r.endTime.IsZero() {
+		t := r.endTime.UTC()
+		v.EndTime = &t
+	}
+
+	if !r.startTime.IsZero() {
+		t := r.startTime.UTC()
+		v.StartTime = &t
+	}
+
+	if r.layer.Name != "" {
+		v.Layer = &snapshotLayerJSON{
+			Name:   r.layer.Name,
+			Offset: r.layer.Offset,
+			Share:  r.layer.Share,
+		}
+	}
+
+	return v
+}
+
+func (v snapshotRuleJSON) rule() Rule {
+	r := Rule{
+		active:     true,
+		buckets:    []Bucket{},
+		configID:   v.ConfigID,
+		createdAt:  v.CreatedAt,
+		criteria:   v.Criteria,
+		generation: v.Generation,
+		ID:         v.ID,
+		kind:       v.Kind,
+		name:       v.Name,
+		reshuffle:  v.Reshuffle,
+		rollout:    v.Rollout,
+		sticky:     v.Sticky,
+	}
+
+	for _, b := range v.Buckets {
+		r.buckets = append(r.buckets, Bucket{
+			Name:       b.Name,
+			Parameters: b.Parameters,
+			Percentage: b.Percentage,
+		})
+	}
+
+	if v.EndTime != nil {
+		r.endTime = *v.EndTime
+	}
+
+	if v.StartTime != nil {
+		r.startTime = *v.StartTime
+	}
+
+	if v.Layer != nil {
+		r.layer = Layer(*v.Layer)
+	}
+
+	if v.Bucketing != nil {
+		r.bucketing = *v.Bucketing
+	}
+
+	return r
+}
@@ -0,0 +1,422 @@
+package rule
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/text/language"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// Golden snapshots as written before optional fields were added, all of them
+// have to be read by the current format.
+const (
+	snapshotGolden                   = "testdata/snapshot.golden.json"
+	snapshotGoldenBeforeBucketing    = "testdata/snapshot_before_bucketing.golden.json"
+	snapshotGoldenBeforeLayers       = "testdata/snapshot_before_layers.golden.json"
+	snapshotGoldenBeforeLocalization = "testdata/snapshot_before_localization.golden.json"
+	snapshotGoldenBeforePlatforms    = "testdata/snapshot_before_platforms.golden.json"
+	snapshotGoldenBeforeSegments     = "testdata/snapshot_before_segments.golden.json"
+	snapshotGoldenBeforeSticky       = "testdata/snapshot_before_sticky.golden.json"
+)
+
+func TestSnapshotGoldenEncode(t *testing.T) {
+	raw, err := json.Marshal(testSnapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden, err := ioutil.ReadFile(snapshotGolden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &bytes.Buffer{}
+
+	if err := json.Compact(want, golden); err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := string(raw), want.String(); have != want {
+		t.Errorf("\nhave %s\nwant %s", have, want)
+	}
+}
+
+func TestSnapshotGoldenDecode(t *testing.T) {
+	beforeBucketing := testSnapshot()
+	beforeBucketing.Rules = append([]Rule{}, beforeBucketing.Rules...)
+	beforeBucketing.Rules[0].bucketing = Bucketing{}
+	beforeBucketing.Rules[1].reshuffle = false
+
+	beforePlatforms := beforeBucketing
+	beforePlatforms.Bases = append([]SnapshotBase{}, beforePlatforms.Bases...)
+	beforePlatforms.Bases[0].Platforms = nil
+
+	beforeLocalization := beforePlatforms
+	beforeLocalization.Bases = []SnapshotBase{
+		{
+			ID:   beforeLocalization.Bases[0].ID,
+			Name: beforeLocalization.Bases[0].Name,
+			Parameters: Parameters{
+				"feature_paywall_enabled": false,
+				"feature_paywall_price":   float64(5),
+			},
+		},
+	}
+
+	beforeSegments := beforeLocalization
+	beforeSegments.Rules = append([]Rule{}, beforeSegments.Rules...)
+	beforeSegments.Rules[0].criteria = beforeSegments.Rules[0].criteria[:1]
+	beforeSegments.Segments = nil
+
+	beforeSticky := beforeSegments
+	beforeSticky.Rules = append([]Rule{}, beforeSegments.Rules...)
+	beforeSticky.Rules[0].generation = 0
+	beforeSticky.Rules[0].sticky = false
+
+	beforeLayers := beforeSticky
+	beforeLayers.Rules = append([]Rule{}, beforeSticky.Rules...)
+	beforeLayers.Rules[0].layer = Layer{}
+
+	for golden, want := range map[string]Snapshot{
+		snapshotGolden:                   testSnapshot(),
+		snapshotGoldenBeforeBucketing:    beforeBucketing,
+		snapshotGoldenBeforeLayers:       beforeLayers,
+		snapshotGoldenBeforeLocalization: beforeLocalization,
+		snapshotGoldenBeforePlatforms:    beforePlatforms,
+		snapshotGoldenBeforeSegments:     beforeSegments,
+		snapshotGoldenBeforeSticky:       beforeSticky,
+	} {
+		raw, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s := Snapshot{}
+
+		if err := json.Unmarshal(raw, &s); err != nil {
+			t.Fatal(err)
+		}
+
+		if have := s; !reflect.DeepEqual(have, want) {
+			t.Errorf("%s\nhave %#v\nwant %#v", golden, have, want)
+		}
+	}
+}
+
+func TestSnapshotUnknownFields(t *testing.T) {
+	golden, err := ioutil.ReadFile(snapshotGolden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := map[string]interface{}{}
+
+	if err := json.Unmarshal(golden, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	// Optional fields of later writers are ignored.
+	v["holdout"] = 5
+	v["rules"].([]interface{})[0].(map[string]interface{})["holdout"] = true
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Snapshot{}
+
+	if err := json.Unmarshal(raw, &s); err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := s, testSnapshot(); !reflect.DeepEqual(have, want) {
+		t.Errorf("\nhave %#v\nwant %#v", have, want)
+	}
+}
+
+func TestSnapshotNewerVersion(t *testing.T) {
+	s := testSnapshot()
+	s.Version = SnapshotVersion + 1
+
+	raw, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = json.Unmarshal(raw, &Snapshot{})
+	if have, want := errors.Cause(err), errors.ErrSnapshotVersion; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestSnapshotVerify(t *testing.T) {
+	secret := []byte("snapshot-secret")
+
+	raw, err := SignSnapshot(testSnapshot(), secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := VerifySnapshot(raw, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := s, testSnapshot(); !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	_, err = VerifySnapshot(raw, []byte("other-secret"))
+	if have, want := errors.Cause(err), errors.ErrSignatureMissmatch; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestSnapshotRender(t *testing.T) {
+	var (
+		s   = testSnapshot()
+		now = time.Date(2018, 1, 15, 12, 0, 0, 0, time.UTC)
+		ctx = Context{
+			User: ContextUser{
+				ID:           "user-2",
+				Subscription: 2,
+			},
+			Locale: ContextLocale{
+				Locale: language.MustParse("en-GB"),
+			},
+		}
+	)
+
+	have, decisions, err := s.Render("ios", ctx, nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Parameters{
+		"feature_paywall_enabled": true,
+		"feature_paywall_price":   float64(4),
+		"feature_paywall_title":   "Go premium",
+	}
+
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	// Same input has to render the same result as the evaluation the config
+	// API performs with hash based bucketing.
+	ectx := ctx
+	ectx.Segments = segmentMap{s.Segments[0].ID: s.Segments[0]}
+
+	params, ds, err := Evaluate(s.Bases[0].Parameters, s.Rules, ectx, nil, HashDice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params = s.Bases[0].Schema.Localize(params, ctx.Locale.Locale)
+
+	if !reflect.DeepEqual(params, have) {
+		t.Errorf("have %v, want %v", params, have)
+	}
+
+	if !reflect.DeepEqual(ds, decisions) {
+		t.Errorf("have %v, want %v", ds, decisions)
+	}
+
+	// Base parameters must not be altered by the evaluation.
+	if have, want := s.Bases[0].Parameters["feature_paywall_enabled"], false; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	// Rules outside of their time window are not applied.
+	have, _, err = s.Render("ios", ctx, nil, now.AddDate(1, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := have["feature_paywall_price"], float64(5); have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	// Platform overrides replace base parameters before rules are applied.
+	actx := ctx
+	actx.Device = ContextDevice{
+		Platform:  PlatformAndroid,
+		OSVersion: "8.1",
+	}
+
+	have, _, err = s.Render("ios", actx, nil, now.AddDate(1, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := have["feature_paywall_price"], float64(6); have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	// Previous decisions are kept, e.g. the position in the layer.
+	previous := Decisions{}
+
+	for k, v := range decisions {
+		previous[k] = v
+	}
+
+	previous[s.Rules[0].layer.decisionKey()] = []int{99}
+
+	have, ds, err = s.Render("ios", ctx, previous, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := have["feature_paywall_enabled"], false; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := ds[s.Rules[0].layer.decisionKey()], []int{99}; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	_, _, err = s.Render("android", ctx, nil, now)
+	if have, want := errors.Cause(err), errors.ErrNotFound; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestHashDiceStable(t *testing.T) {
+	for _, id := range []string{"a", "b", "c", "user-1", "user-2"} {
+		p := HashDice("rule", id)()
+
+		if p < 1 || p > 100 {
+			t.Errorf("have %v, want [1, 100]", p)
+		}
+
+		if have, want := HashDice("rule", id)(), p; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+}
+
+func testSnapshot() Snapshot {
+	endTime := time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	return Snapshot{
+		Bases: []SnapshotBase{
+			{
+				ID:   "base-1",
+				Name: "ios",
+				Parameters: Parameters{
+					"feature_paywall_enabled": false,
+					"feature_paywall_price":   float64(5),
+					"feature_paywall_title": map[string]interface{}{
+						"de":              "Premium holen",
+						LocalizedFallback: "Go premium",
+					},
+				},
+				Platforms: PlatformParameters{
+					PlatformAndroid: {
+						"feature_paywall_price": float64(6),
+					},
+				},
+				Schema: ParameterSchema{
+					"feature_paywall_title": {
+						Type: TypeLocalized,
+					},
+				},
+			},
+		},
+		ClientID: "client-1",
+		Rules: []Rule{
+			{
+				active: true,
+				bucketing: Bucketing{
+					Key:  BucketByMetadata,
+					Path: "household_id",
+				},
+				buckets: []Bucket{
+					{
+						Name: "default",
+						Parameters: Parameters{
+							"feature_paywall_enabled": true,
+						},
+					},
+				},
+				configID:  "base-1",
+				createdAt: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+				criteria: Criteria{
+					{
+						Comparator: ComparatorGT,
+						Key:        UserSubscription,
+						Value:      1,
+					},
+					{
+						Comparator: ComparatorEQ,
+						Key:        SegmentID,
+						Value:      "segment-1",
+					},
+				},
+				generation: 2,
+				ID:         "rule-1",
+				kind:       KindRollout,
+				layer: Layer{
+					Name:  "paywall",
+					Share: 50,
+				},
+				name:    "paywall rollout",
+				rollout: 100,
+				sticky:  true,
+			},
+			{
+				active: true,
+				buckets: []Bucket{
+					{
+						Name: "default",
+						Parameters: Parameters{
+							"feature_paywall_price": float64(4),
+						},
+					},
+				},
+				configID:  "base-1",
+				createdAt: time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC),
+				criteria: Criteria{
+					{
+						Comparator: ComparatorEQ,
+						Key:        DeviceLocationLocale,
+						Value:      language.MustParse("en-GB"),
+					},
+					{
+						Comparator: ComparatorIN,
+						Key:        UserID,
+						Value:      []string{"user-1", "user-2"},
+					},
+				},
+				endTime:   endTime,
+				ID:        "rule-2",
+				kind:      KindOverride,
+				name:      "uk discount",
+				reshuffle: true,
+			},
+		},
+		Segments: []Segment{
+			{
+				Criteria: Criteria{
+					{
+						Comparator: ComparatorIN,
+						Key:        UserID,
+						Value:      []string{"user-2", "user-3"},
+					},
+				},
+				ID:      "segment-1",
+				Name:    "beta testers",
+				Version: 2,
+			},
+		},
+		Version:   SnapshotVersion,
+		CreatedAt: time.Date(2018, 1, 3, 0, 0, 0, 0, time.UTC),
+	}
+}
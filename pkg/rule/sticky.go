@@ -0,0 +1,133 @@
+package rule
+
+import (
+	"fmt"
+
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/generate"
+)
+
+// Positions in the decisions of percentage based rules. Decisions taken
+// before any reshuffle and without stickiness only hold the dice roll.
+const (
+	decisionDice = iota
+	decisionGeneration
+	decisionSticky
+)
+
+// decision is the outcome of a percentage based rule for a user.
+type decision struct {
+	dice       int
+	generation int
+	sticky     bool
+}
+
+func parseDecision(ds []int) decision {
+	d := decision{}
+
+	if len(ds) > decisionDice {
+		d.dice = ds[decisionDice]
+	}
+
+	if len(ds) > decisionGeneration {
+		d.generation = ds[decisionGeneration]
+	}
+
+	if len(ds) > decisionSticky {
+		d.sticky = ds[decisionSticky] == 1
+	}
+
+	return d
+}
+
+// encode returns the shortest representation of the decision, so decisions
+// of rules which are neither sticky nor reshuffled keep their original form.
+func (d decision) encode() []int {
+	switch {
+	case d.sticky:
+		return []int{d.dice, d.generation, 1}
+	case d.generation != 0:
+		return []int{d.dice, d.generation}
+	default:
+		return []int{d.dice}
+	}
+}
+
+// decide returns the previous decision if it was taken in the current
+// generation of the rule, otherwise the dice is rolled again.
+func (r Rule) decide(previous []int, randInt generate.RandPercentageFunc) decision {
+	if len(previous) != 0 {
+		d := parseDecision(previous)
+
+		if d.generation == r.generation {
+			return d
+		}
+	}
+
+	return decision{
+		dice:       randInt(),
+		generation: r.generation,
+	}
+}
+
+// diceKey is the key dice rolls of the rule are derived from. Every reshuffle
+// changes the key, so users are distributed anew.
+func (r Rule) diceKey() string {
+	if r.generation == 0 {
+		return r.ID
+	}
+
+	return fmt.Sprintf("%s/%d", r.ID, r.generation)
+}
+
+// WithStickiness returns a copy of the rule with the given options. Sticky
+// rollouts keep users in once they were in, even if the rollout percentage
+// decreases later on. Reshuffled rollouts distribute users anew every time
+// the rollout restarts.
+func (r Rule) WithStickiness(sticky, reshuffle bool) (Rule, error) {
+	c := r
+	c.sticky = sticky
+	c.reshuffle = reshuffle
+
+	if err := c.validate(); err != nil {
+		return Rule{}, err
+	}
+
+	return c, nil
+}
+
+// withRollout returns a copy of the rule with the given rollout percentage.
+// Raising the percentage from zero restarts the rollout.
+func (r Rule) withRollout(rollout uint8) Rule {
+	c := r
+
+	if c.rollout == 0 && rollout > 0 {
+		c = c.restarted()
+	}
+
+	c.rollout = rollout
+
+	return c
+}
+
+// restarted returns a copy of the rule in a new generation if it reshuffles.
+func (r Rule) restarted() Rule {
+	c := r
+
+	if c.reshuffle {
+		c.generation++
+	}
+
+	return c
+}
+
+func validateStickiness(sticky, reshuffle bool) error {
+	if sticky && reshuffle {
+		return errors.Wrap(
+			errors.ErrInvalidRule,
+			"sticky rules can't be reshuffled",
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,235 @@
+package rule
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lifesum/configsum/pkg/errors"
+	"github.com/lifesum/configsum/pkg/generate"
+)
+
+func TestRuleSticky(t *testing.T) {
+	t.Parallel()
+
+	rp := uint8(80)
+
+	r, err := New(
+		generate.RandomString(12),
+		generate.RandomString(16),
+		generate.RandomString(12),
+		generate.RandomString(12),
+		KindRollout,
+		true,
+		nil,
+		[]Bucket{
+			{
+				Parameters: Parameters{"feature_x": true},
+			},
+		},
+		&rp,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = r.WithStickiness(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, d, err := r.Run(Parameters{}, Context{}, nil, randIntGenerateTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := d, []int{61, 0, 1}; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	r = r.withRollout(10)
+
+	have, _, err := r.Run(Parameters{}, Context{}, d, randIntGenerateTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := have, (Parameters{"feature_x": true}); !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	// Decisions taken before the rule became sticky don't keep users in.
+	_, _, err = r.Run(Parameters{}, Context{}, []int{61}, randIntGenerateTest)
+	if have, want := errors.Cause(err), errors.ErrRuleNotInRollout; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	// Releasing the stickiness applies the rollout percentage again.
+	r, err = r.WithStickiness(false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = r.Run(Parameters{}, Context{}, d, randIntGenerateTest)
+	if have, want := errors.Cause(err), errors.ErrRuleNotInRollout; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	_, err = r.WithStickiness(true, true)
+	if have, want := errors.Cause(err), errors.ErrInvalidRule; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestRuleReshuffle(t *testing.T) {
+	t.Parallel()
+
+	rp := uint8(0)
+
+	r, err := New(
+		generate.RandomString(12),
+		generate.RandomString(16),
+		generate.RandomString(12),
+		generate.RandomString(12),
+		KindRollout,
+		true,
+		nil,
+		[]Bucket{
+			{
+				Parameters: Parameters{"feature_x": true},
+			},
+		},
+		&rp,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = r.WithStickiness(false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := r.diceKey()
+
+	for _, c := range []struct {
+		rollout    uint8
+		generation int
+	}{
+		{rollout: 50, generation: 1},
+		{rollout: 70, generation: 1},
+		{rollout: 0, generation: 1},
+		{rollout: 30, generation: 2},
+	} {
+		r = r.withRollout(c.rollout)
+
+		if have, want := r.generation, c.generation; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+
+	if have := r.diceKey(); have == id {
+		t.Errorf("have %v, want different key", have)
+	}
+
+	// Decisions of previous generations are discarded.
+	_, d, err := r.Run(Parameters{}, Context{}, []int{10, 1}, randIntGenerateTest)
+	if have, want := errors.Cause(err), errors.ErrRuleNotInRollout; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := d, []int{61, 2}; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	// Rules without reshuffle keep their decisions.
+	r.reshuffle = false
+
+	if have, want := r.withRollout(0).withRollout(20).generation, r.generation; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestEvaluateSticky(t *testing.T) {
+	t.Parallel()
+
+	rp := uint8(80)
+
+	r, err := New(
+		"rule-1",
+		"base-1",
+		generate.RandomString(12),
+		generate.RandomString(12),
+		KindRollout,
+		true,
+		Criteria{
+			{
+				Comparator: ComparatorGT,
+				Key:        UserSubscription,
+				Value:      0,
+			},
+		},
+		[]Bucket{
+			{
+				Parameters: Parameters{"feature_x": true},
+			},
+		},
+		&rp,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = r.WithStickiness(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		base       = Parameters{"feature_x": false}
+		subscribed = Context{User: ContextUser{ID: "user-1", Subscription: 1}}
+		dice       = RandDice(randIntGenerateTest)
+	)
+
+	_, ds, err := Evaluate(base, []Rule{r}, subscribed, nil, dice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := ds, (Decisions{"rule-1": []int{61, 0, 1}}); !reflect.DeepEqual(have, want) {
+		t.Fatalf("have %v, want %v", have, want)
+	}
+
+	// The decision is kept while the criteria don't match.
+	params, kept, err := Evaluate(base, []Rule{r}, Context{User: ContextUser{ID: "user-1"}}, ds, dice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := params, base; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := kept, ds; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	// And while the rule is not rendered at all.
+	_, kept, err = Evaluate(base, nil, subscribed, kept, dice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := kept, ds; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	// Once the criteria match again the user stays in the decreased rollout.
+	params, _, err = Evaluate(base, []Rule{r.withRollout(10)}, subscribed, kept, dice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := params, (Parameters{"feature_x": true}); !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
@@ -1,9 +1,12 @@
 package rule
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"time"
 
 	kithttp "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/mux"
@@ -13,7 +16,8 @@ import (
 
 // URL fragments.
 const (
-	varID muxVar = "id"
+	varID    muxVar = "id"
+	varState muxVar = "state"
 )
 
 type muxVar string
@@ -68,6 +72,30 @@ func MakeHandler(svc Service, opts ...kithttp.ServerOption) http.Handler {
 		),
 	)
 
+	r.Methods("PUT").Path(`/{id:[a-zA-Z0-9]+}/bucketing`).Name("ruleUpdateBucketing").Handler(
+		kithttp.NewServer(
+			updateBucketingEndpoint(svc),
+			decodeUpdateBucketingRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	r.Methods("PUT").Path(`/{id:[a-zA-Z0-9]+}/layer`).Name("ruleUpdateLayer").Handler(
+		kithttp.NewServer(
+			updateLayerEndpoint(svc),
+			decodeUpdateLayerRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
 	r.Methods("PUT").Path(`/{id:[a-zA-Z0-9]+}/rollout`).Name("ruleUpdateRollout").Handler(
 		kithttp.NewServer(
 			updateRolloutEndpoint(svc),
@@ -80,6 +108,257 @@ func MakeHandler(svc Service, opts ...kithttp.ServerOption) http.Handler {
 		),
 	)
 
+	r.Methods("PUT").Path(`/{id:[a-zA-Z0-9]+}/stickiness`).Name("ruleUpdateStickiness").Handler(
+		kithttp.NewServer(
+			updateStickinessEndpoint(svc),
+			decodeUpdateStickinessRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	return r
+}
+
+// MakeGuardrailHandler sets up an http.Handler for the guardrails of rules and
+// the ingestion of their metrics.
+func MakeGuardrailHandler(
+	svc GuardrailService,
+	opts ...kithttp.ServerOption,
+) http.Handler {
+	r := mux.NewRouter()
+	r.StrictSlash(true)
+
+	r.Methods("GET").Path(`/{id:[a-zA-Z0-9]+}`).Name("guardrailGet").Handler(
+		kithttp.NewServer(
+			guardrailGetEndpoint(svc),
+			decodeGuardrailGetRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	r.Methods("PUT").Path(`/{id:[a-zA-Z0-9]+}`).Name("guardrailSet").Handler(
+		kithttp.NewServer(
+			guardrailSetEndpoint(svc),
+			decodeGuardrailSetRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	r.Methods("POST").Path(`/{id:[a-zA-Z0-9]+}/events`).Name("guardrailIngest").Handler(
+		kithttp.NewServer(
+			guardrailIngestEndpoint(svc),
+			decodeGuardrailIngestRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	return r
+}
+
+// MakeScheduleHandler sets up an http.Handler for the rollout schedules of
+// rules.
+func MakeScheduleHandler(
+	svc ScheduleService,
+	opts ...kithttp.ServerOption,
+) http.Handler {
+	r := mux.NewRouter()
+	r.StrictSlash(true)
+
+	r.Methods("GET").Path(`/{id:[a-zA-Z0-9]+}`).Name("scheduleGet").Handler(
+		kithttp.NewServer(
+			scheduleGetEndpoint(svc),
+			decodeScheduleGetRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	r.Methods("PUT").Path(`/{id:[a-zA-Z0-9]+}`).Name("scheduleSet").Handler(
+		kithttp.NewServer(
+			scheduleSetEndpoint(svc),
+			decodeScheduleSetRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	r.Methods("PUT").Path(`/{id:[a-zA-Z0-9]+}/{state:pause|resume|abort}`).Name("scheduleState").Handler(
+		kithttp.NewServer(
+			scheduleStateEndpoint(svc),
+			decodeScheduleStateRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID, varState)),
+			)...,
+		),
+	)
+
+	return r
+}
+
+// MakeSegmentHandler sets up an http.Handler for segments.
+func MakeSegmentHandler(
+	svc SegmentService,
+	opts ...kithttp.ServerOption,
+) http.Handler {
+	r := mux.NewRouter()
+	r.StrictSlash(true)
+
+	r.Methods("GET").Path(`/`).Name("segmentList").Handler(
+		kithttp.NewServer(
+			segmentListEndpoint(svc),
+			decodeListRequest,
+			kithttp.EncodeJSONResponse,
+			opts...,
+		),
+	)
+
+	r.Methods("POST").Path(`/`).Name("segmentCreate").Handler(
+		kithttp.NewServer(
+			segmentCreateEndpoint(svc),
+			decodeSegmentCreateRequest,
+			kithttp.EncodeJSONResponse,
+			opts...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{id:[a-zA-Z0-9]+}`).Name("segmentGet").Handler(
+		kithttp.NewServer(
+			segmentGetEndpoint(svc),
+			decodeSegmentGetRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	r.Methods("PUT").Path(`/{id:[a-zA-Z0-9]+}`).Name("segmentUpdate").Handler(
+		kithttp.NewServer(
+			segmentUpdateEndpoint(svc),
+			decodeSegmentUpdateRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	r.Methods("DELETE").Path(`/{id:[a-zA-Z0-9]+}`).Name("segmentDelete").Handler(
+		kithttp.NewServer(
+			segmentDeleteEndpoint(svc),
+			decodeSegmentDeleteRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{id:[a-zA-Z0-9]+}/rules`).Name("segmentUsage").Handler(
+		kithttp.NewServer(
+			segmentUsageEndpoint(svc),
+			decodeSegmentGetRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{id:[a-zA-Z0-9]+}/versions`).Name("segmentVersions").Handler(
+		kithttp.NewServer(
+			segmentVersionsEndpoint(svc),
+			decodeSegmentGetRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	return r
+}
+
+// MakeUserListHandler sets up an http.Handler for user lists. Members are
+// uploaded as CSV or newline separated ids and replace the previous ones.
+func MakeUserListHandler(
+	svc UserListService,
+	opts ...kithttp.ServerOption,
+) http.Handler {
+	r := mux.NewRouter()
+	r.StrictSlash(true)
+
+	r.Methods("GET").Path(`/`).Name("userListList").Handler(
+		kithttp.NewServer(
+			userListListEndpoint(svc),
+			decodeListRequest,
+			kithttp.EncodeJSONResponse,
+			opts...,
+		),
+	)
+
+	r.Methods("POST").Path(`/`).Name("userListCreate").Handler(
+		kithttp.NewServer(
+			userListCreateEndpoint(svc),
+			decodeUserListCreateRequest,
+			kithttp.EncodeJSONResponse,
+			opts...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{id:[a-zA-Z0-9]+}`).Name("userListGet").Handler(
+		kithttp.NewServer(
+			userListGetEndpoint(svc),
+			decodeUserListGetRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
+	r.Methods("PUT").Path(`/{id:[a-zA-Z0-9]+}/members`).Name("userListUpload").Handler(
+		kithttp.NewServer(
+			userListUploadEndpoint(svc),
+			decodeUserListUploadRequest,
+			kithttp.EncodeJSONResponse,
+			append(
+				opts,
+				kithttp.ServerBefore(extractMuxVars(varID)),
+			)...,
+		),
+	)
+
 	return r
 }
 
@@ -114,6 +393,261 @@ func decodeListRequest(ctx context.Context, r *http.Request) (interface{}, error
 	return struct{}{}, nil
 }
 
+func decodeGuardrailGetRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id")
+	}
+
+	return guardrailGetRequest{ruleID: id}, nil
+}
+
+func decodeGuardrailIngestRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id")
+	}
+
+	v := struct {
+		Events []struct {
+			Metric string  `json:"metric"`
+			Group  Group   `json:"group"`
+			Value  float64 `json:"value"`
+		} `json:"events"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		return nil, errors.Wrapf(errors.ErrInvalidPayload, "%s", err)
+	}
+
+	events := []GuardrailEvent{}
+
+	for _, e := range v.Events {
+		events = append(events, GuardrailEvent(e))
+	}
+
+	return guardrailIngestRequest{events: events, ruleID: id}, nil
+}
+
+func decodeGuardrailSetRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id")
+	}
+
+	v := struct {
+		Comparison GuardrailComparison `json:"comparison"`
+		Metric     string              `json:"metric"`
+		MinSamples int                 `json:"min_samples"`
+		Threshold  float64             `json:"threshold"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		return nil, errors.Wrapf(errors.ErrInvalidPayload, "%s", err)
+	}
+
+	return guardrailSetRequest{
+		comparison: v.Comparison,
+		metric:     v.Metric,
+		minSamples: v.MinSamples,
+		ruleID:     id,
+		threshold:  v.Threshold,
+	}, nil
+}
+
+func decodeScheduleGetRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id")
+	}
+
+	return scheduleGetRequest{ruleID: id}, nil
+}
+
+func decodeScheduleSetRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id")
+	}
+
+	v := struct {
+		Steps []struct {
+			Percentage uint8     `json:"percentage"`
+			At         time.Time `json:"at"`
+		} `json:"steps"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		return nil, errors.Wrapf(errors.ErrInvalidPayload, "%s", err)
+	}
+
+	steps := []ScheduleStep{}
+
+	for _, s := range v.Steps {
+		steps = append(steps, ScheduleStep(s))
+	}
+
+	return scheduleSetRequest{ruleID: id, steps: steps}, nil
+}
+
+func decodeScheduleStateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id")
+	}
+
+	state, ok := ctx.Value(varState).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "state")
+	}
+
+	req := scheduleStateRequest{ruleID: id}
+
+	switch state {
+	case "abort":
+		req.state = ScheduleAborted
+	case "pause":
+		req.state = SchedulePaused
+	default:
+		req.state = ScheduleRunning
+	}
+
+	return req, nil
+}
+
+type segmentPayload struct {
+	Criteria    Criteria `json:"criteria"`
+	Description string   `json:"description"`
+	Name        string   `json:"name"`
+}
+
+func decodeSegmentCreateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	v := segmentPayload{}
+
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		return nil, errors.Wrapf(errors.ErrInvalidPayload, "%s", err)
+	}
+
+	return segmentCreateRequest{
+		criteria:    v.Criteria,
+		description: v.Description,
+		name:        v.Name,
+	}, nil
+}
+
+func decodeSegmentDeleteRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id")
+	}
+
+	return segmentDeleteRequest{id: id}, nil
+}
+
+func decodeSegmentGetRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id")
+	}
+
+	return segmentGetRequest{id: id}, nil
+}
+
+func decodeSegmentUpdateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id")
+	}
+
+	v := segmentPayload{}
+
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		return nil, errors.Wrapf(errors.ErrInvalidPayload, "%s", err)
+	}
+
+	return segmentUpdateRequest{
+		criteria:    v.Criteria,
+		description: v.Description,
+		id:          id,
+		name:        v.Name,
+	}, nil
+}
+
+func decodeUserListCreateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	v := struct {
+		Description string `json:"description"`
+		Name        string `json:"name"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		return nil, errors.Wrapf(errors.ErrInvalidPayload, "%s", err)
+	}
+
+	return userListCreateRequest{
+		description: v.Description,
+		name:        v.Name,
+	}, nil
+}
+
+func decodeUserListGetRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id")
+	}
+
+	return userListGetRequest{id: id}, nil
+}
+
+func decodeUserListUploadRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id")
+	}
+
+	raw, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, errors.Wrapf(errors.ErrInvalidPayload, "%s", err)
+	}
+
+	return userListUploadRequest{
+		id:      id,
+		members: bytes.NewReader(raw),
+	}, nil
+}
+
+func decodeUpdateBucketingRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id")
+	}
+
+	v := Bucketing{}
+
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		return nil, errors.Wrapf(errors.ErrInvalidPayload, "%s", err)
+	}
+
+	return updateBucketingRequest{id: id, bucketing: v}, nil
+}
+
+func decodeUpdateLayerRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id")
+	}
+
+	v := struct {
+		Name  string `json:"name"`
+		Share uint8  `json:"share"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		return nil, errors.Wrapf(errors.ErrInvalidPayload, "%s", err)
+	}
+
+	return updateLayerRequest{id: id, layer: v.Name, share: v.Share}, nil
+}
+
 func decodeUpdateRolloutRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	id, ok := ctx.Value(varID).(string)
 	if !ok {
@@ -131,6 +665,28 @@ func decodeUpdateRolloutRequest(ctx context.Context, r *http.Request) (interface
 	return updateRolloutRequest{id: id, rollout: v.Rollout}, nil
 }
 
+func decodeUpdateStickinessRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := ctx.Value(varID).(string)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrVarMissing, "id")
+	}
+
+	v := struct {
+		Reshuffle bool `json:"reshuffle"`
+		Sticky    bool `json:"sticky"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		return nil, errors.Wrapf(errors.ErrInvalidPayload, "%s", err)
+	}
+
+	return updateStickinessRequest{
+		id:        id,
+		reshuffle: v.Reshuffle,
+		sticky:    v.Sticky,
+	}, nil
+}
+
 func extractMuxVars(keys ...muxVar) kithttp.RequestFunc {
 	return func(ctx context.Context, r *http.Request) context.Context {
 		for _, k := range keys {
@@ -0,0 +1,408 @@
+package rule
+
+import (
+	"encoding/csv"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+// userListHeader is skipped if it is the first line of an upload.
+const userListHeader = "user_id"
+
+// UserList is a named set of user ids stored apart from rules, so criteria can
+// reference large allowlists by id. The version increases with every upload.
+type UserList struct {
+	ID          string
+	Name        string
+	Description string
+	Size        int
+	Version     int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (l UserList) validate() error {
+	if l.ID == "" {
+		return errors.Wrap(errors.ErrUserListInvalid, "id missing")
+	}
+
+	if l.Name == "" {
+		return errors.Wrap(errors.ErrUserListInvalid, "name missing")
+	}
+
+	return nil
+}
+
+// UserListLookup answers if a user is a member of a list.
+type UserListLookup interface {
+	Contains(listID, userID string) (bool, error)
+}
+
+type userListCacheEntry struct {
+	checked time.Time
+	members map[string]struct{}
+	version int
+}
+
+// userListRefresh is a refresh of a list in flight, which concurrent lookups
+// of the same list wait for.
+type userListRefresh struct {
+	done  chan struct{}
+	entry *userListCacheEntry
+	err   error
+}
+
+type userListCache struct {
+	entries   map[string]*userListCacheEntry
+	mu        sync.RWMutex
+	refreshes map[string]*userListRefresh
+	repo      UserListRepo
+	ttl       time.Duration
+}
+
+// NewUserListCache returns a UserListLookup which keeps the members of lists
+// in memory. After the given duration the version of a list is checked and
+// its members are only read again if it changed. Concurrent lookups of a
+// stale list share a single refresh.
+func NewUserListCache(repo UserListRepo, ttl time.Duration) UserListLookup {
+	return &userListCache{
+		entries:   map[string]*userListCacheEntry{},
+		refreshes: map[string]*userListRefresh{},
+		repo:      repo,
+		ttl:       ttl,
+	}
+}
+
+func (c *userListCache) Contains(listID, userID string) (bool, error) {
+	now := time.Now()
+
+	c.mu.RLock()
+	e, ok := c.entries[listID]
+	c.mu.RUnlock()
+
+	if !ok || now.Sub(e.checked) > c.ttl {
+		var err error
+
+		e, err = c.load(listID, now)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	_, ok = e.members[userID]
+
+	return ok, nil
+}
+
+// load refreshes the list unless a refresh of it is already in flight, in
+// which case its result is awaited.
+func (c *userListCache) load(listID string, now time.Time) (*userListCacheEntry, error) {
+	c.mu.Lock()
+
+	e, ok := c.entries[listID]
+	if ok && now.Sub(e.checked) <= c.ttl {
+		c.mu.Unlock()
+		return e, nil
+	}
+
+	if r, ok := c.refreshes[listID]; ok {
+		c.mu.Unlock()
+		<-r.done
+
+		return r.entry, r.err
+	}
+
+	r := &userListRefresh{done: make(chan struct{})}
+	c.refreshes[listID] = r
+	c.mu.Unlock()
+
+	r.entry, r.err = c.refresh(listID, e, now)
+
+	c.mu.Lock()
+	delete(c.refreshes, listID)
+	c.mu.Unlock()
+
+	close(r.done)
+
+	return r.entry, r.err
+}
+
+func (c *userListCache) refresh(
+	listID string,
+	e *userListCacheEntry,
+	now time.Time,
+) (*userListCacheEntry, error) {
+	l, err := c.repo.Get(listID)
+	if err != nil {
+		return nil, err
+	}
+
+	if e != nil && e.version == l.Version {
+		c.mu.Lock()
+		c.entries[listID] = &userListCacheEntry{
+			checked: now,
+			members: e.members,
+			version: e.version,
+		}
+		c.mu.Unlock()
+
+		return e, nil
+	}
+
+	ids, err := c.repo.Members(listID)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &userListCacheEntry{
+		checked: now,
+		members: make(map[string]struct{}, len(ids)),
+		version: l.Version,
+	}
+
+	for _, id := range ids {
+		n.members[id] = struct{}{}
+	}
+
+	c.mu.Lock()
+	c.entries[listID] = n
+	c.mu.Unlock()
+
+	return n, nil
+}
+
+// userListSet is a UserListLookup over a fixed set of lists.
+type userListSet map[string]map[string]struct{}
+
+func (s userListSet) Contains(listID, userID string) (bool, error) {
+	members, ok := s[listID]
+	if !ok {
+		return false, errors.Wrapf(errors.ErrNotFound, "user list '%s'", listID)
+	}
+
+	_, ok = members[userID]
+
+	return ok, nil
+}
+
+func matchUserList(comparator Comparator, listID string, lists UserListLookup, userID string) error {
+	ok, err := lists.Contains(listID, userID)
+	if err != nil {
+		return errors.Wrapf(err, "user list '%s'", listID)
+	}
+
+	switch comparator {
+	case ComparatorIN:
+		if !ok {
+			return errors.Wrap(errors.ErrCriterionNotMatch, "id not in user list")
+		}
+	case ComparatorNotIN:
+		if ok {
+			return errors.Wrap(errors.ErrCriterionNotMatch, "id in user list")
+		}
+	default:
+		return errors.Errorf("comparator '%s' not supported", comparator)
+	}
+
+	return nil
+}
+
+// userListIDs returns the ids of all user lists the rule references.
+func (r Rule) userListIDs() []string {
+	ids := []string{}
+
+	for _, c := range r.criteria {
+		if c.Key != UserListID {
+			continue
+		}
+
+		if id, ok := c.Value.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// parseUserIDs reads user ids from the first column of CSV or newline
+// separated input. Empty lines, duplicates and a leading user_id header are
+// skipped.
+func parseUserIDs(r io.Reader) ([]string, error) {
+	var (
+		cr   = csv.NewReader(r)
+		ids  = []string{}
+		seen = map[string]struct{}{}
+	)
+
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	for i := 0; ; i++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(errors.ErrUserListInvalid, "line %d: %s", i+1, err)
+		}
+
+		id := strings.TrimSpace(record[0])
+
+		if id == "" || (i == 0 && id == userListHeader) {
+			continue
+		}
+
+		if _, ok := seen[id]; ok {
+			continue
+		}
+
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// UserListService manages user lists.
+type UserListService interface {
+	Create(name, description string) (UserList, error)
+	Get(id string) (UserList, error)
+	List() ([]UserList, error)
+	// Upload replaces all members of the list with the user ids read from r.
+	Upload(id string, r io.Reader) (UserList, error)
+}
+
+type userListService struct {
+	repo UserListRepo
+	seed *rand.Rand
+}
+
+// NewUserListService provides user lists for criteria to reference.
+func NewUserListService(repo UserListRepo) UserListService {
+	return &userListService{
+		repo: repo,
+		seed: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s *userListService) Create(name, description string) (UserList, error) {
+	id, err := ulid.New(ulid.Timestamp(time.Now()), s.seed)
+	if err != nil {
+		return UserList{}, errors.Wrap(errors.ErrID, err.Error())
+	}
+
+	now := time.Now().UTC()
+
+	l := UserList{
+		ID:          id.String(),
+		Name:        name,
+		Description: description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := l.validate(); err != nil {
+		return UserList{}, err
+	}
+
+	return s.repo.Create(l)
+}
+
+func (s *userListService) Get(id string) (UserList, error) {
+	return s.repo.Get(id)
+}
+
+func (s *userListService) List() ([]UserList, error) {
+	return s.repo.List()
+}
+
+func (s *userListService) Upload(id string, r io.Reader) (UserList, error) {
+	l, err := s.repo.Get(id)
+	if err != nil {
+		return UserList{}, err
+	}
+
+	ids, err := parseUserIDs(r)
+	if err != nil {
+		return UserList{}, err
+	}
+
+	l.Size = len(ids)
+	l.UpdatedAt = time.Now().UTC()
+
+	return s.repo.Replace(l, ids)
+}
+
+type userListRuleRepo struct {
+	next         Repo
+	userListRepo UserListRepo
+}
+
+// NewRuleRepoUserListMiddleware wraps the next Repo and rejects rules which
+// reference user lists that don't exist.
+func NewRuleRepoUserListMiddleware(userListRepo UserListRepo) RepoMiddleware {
+	return func(next Repo) Repo {
+		return &userListRuleRepo{
+			next:         next,
+			userListRepo: userListRepo,
+		}
+	}
+}
+
+func (r *userListRuleRepo) Create(input Rule) (Rule, error) {
+	if err := r.validate(input); err != nil {
+		return Rule{}, err
+	}
+
+	return r.next.Create(input)
+}
+
+func (r *userListRuleRepo) GetByID(id string) (Rule, error) {
+	return r.next.GetByID(id)
+}
+
+func (r *userListRuleRepo) UpdateWith(input Rule) (Rule, error) {
+	if err := r.validate(input); err != nil {
+		return Rule{}, err
+	}
+
+	return r.next.UpdateWith(input)
+}
+
+func (r *userListRuleRepo) ListAll() ([]Rule, error) {
+	return r.next.ListAll()
+}
+
+func (r *userListRuleRepo) ListActive(configID string, now time.Time) ([]Rule, error) {
+	return r.next.ListActive(configID, now)
+}
+
+func (r *userListRuleRepo) Setup() error {
+	return r.next.Setup()
+}
+
+func (r *userListRuleRepo) Teardown() error {
+	return r.next.Teardown()
+}
+
+func (r *userListRuleRepo) validate(input Rule) error {
+	for _, id := range input.userListIDs() {
+		_, err := r.userListRepo.Get(id)
+		if err != nil {
+			if errors.Cause(err) == errors.ErrNotFound {
+				return errors.Wrapf(errors.ErrInvalidRule, "user list '%s' not found", id)
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package rule
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lifesum/configsum/pkg/errors"
+)
+
+func TestParseUserIDs(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string][]string{
+		"user-1\nuser-2\n\nuser-1\n":            {"user-1", "user-2"},
+		"user_id,country\nuser-1,SE\nuser-2,NO": {"user-1", "user-2"},
+		"user-3, SE\r\n user-4\r\n":             {"user-3", "user-4"},
+		"":                                      {},
+	}
+
+	for input, want := range cases {
+		have, err := parseUserIDs(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("%q: have %v, want %v", input, have, want)
+		}
+	}
+
+	_, err := parseUserIDs(strings.NewReader("\"user-1\n"))
+	if have, want := errors.Cause(err), errors.ErrUserListInvalid; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestUserListMatch(t *testing.T) {
+	t.Parallel()
+
+	lists := userListSet{
+		"list-1": {
+			"user-1": struct{}{},
+			"user-2": struct{}{},
+		},
+	}
+
+	cases := []struct {
+		comparator Comparator
+		userID     string
+		err        error
+	}{
+		{ComparatorIN, "user-1", nil},
+		{ComparatorIN, "user-3", errors.ErrCriterionNotMatch},
+		{ComparatorNotIN, "user-2", errors.ErrCriterionNotMatch},
+		{ComparatorNotIN, "user-3", nil},
+	}
+
+	for _, c := range cases {
+		criterion := Criterion{
+			Comparator: c.comparator,
+			Key:        UserListID,
+			Value:      "list-1",
+		}
+
+		err := criterion.match(Context{
+			User: ContextUser{
+				ID: c.userID,
+			},
+			UserLists: lists,
+		})
+		if have, want := errors.Cause(err), c.err; have != want {
+			t.Errorf("%s %s: have %v, want %v", c.comparator, c.userID, have, want)
+		}
+	}
+
+	criterion := Criterion{
+		Comparator: ComparatorIN,
+		Key:        UserListID,
+		Value:      "list-2",
+	}
+
+	err := criterion.match(Context{UserLists: lists})
+	if have, want := errors.Cause(err), errors.ErrNotFound; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestUserListCacheConcurrentRefresh(t *testing.T) {
+	var (
+		repo = &blockingUserListRepo{
+			release: make(chan struct{}),
+		}
+		cache = NewUserListCache(repo, time.Minute)
+		wg    = sync.WaitGroup{}
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			ok, err := cache.Contains("list-1", "user-1")
+			if err != nil {
+				t.Error(err)
+			}
+
+			if !ok {
+				t.Error("user-1 not in list")
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+
+	if have, want := repo.gets, 1; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := repo.members, 1; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+// blockingUserListRepo counts reads of list-1 and holds them until release is
+// closed.
+type blockingUserListRepo struct {
+	UserListRepo
+
+	gets    int
+	members int
+	mu      sync.Mutex
+	release chan struct{}
+}
+
+func (r *blockingUserListRepo) Get(id string) (UserList, error) {
+	r.mu.Lock()
+	r.gets++
+	r.mu.Unlock()
+
+	<-r.release
+
+	return UserList{ID: id, Version: 1}, nil
+}
+
+func (r *blockingUserListRepo) Members(id string) ([]string, error) {
+	r.mu.Lock()
+	r.members++
+	r.mu.Unlock()
+
+	return []string{"user-1"}, nil
+}
@@ -72,15 +72,15 @@ func DecodeJSONSchema(
 // ErrorEncoder translates domain specific errors to HTTP status codes.
 func ErrorEncoder(_ context.Context, err error, w http.ResponseWriter) {
 	switch errors.Cause(err) {
-	case errors.ErrExists:
+	case errors.ErrExists, errors.ErrInUse, errors.ErrScheduleChanged:
 		w.WriteHeader(http.StatusConflict)
 	case errors.ErrNotFound:
 		w.WriteHeader(http.StatusNotFound)
 	case errors.ErrClientNotFound, errors.ErrSecretMissing:
 		w.WriteHeader(http.StatusUnauthorized)
-	case errors.ErrSignatureMissing, errors.ErrSignatureMissmatch, errors.ErrUserIDMissing:
+	case errors.ErrDeviceIDMissing, errors.ErrSignatureMissing, errors.ErrSignatureMissmatch, errors.ErrUserIDInvalid, errors.ErrUserIDMissing:
 		w.WriteHeader(http.StatusUnauthorized)
-	case errors.ErrInvalidPayload, errors.ErrParametersInvalid:
+	case errors.ErrEnvInvalid, errors.ErrGuardrailInvalid, errors.ErrInvalidPayload, errors.ErrInvalidRule, errors.ErrParametersInvalid, errors.ErrScheduleInvalid, errors.ErrSegmentInvalid, errors.ErrUserListInvalid:
 		w.WriteHeader(http.StatusBadRequest)
 	default:
 		w.WriteHeader(http.StatusInternalServerError)
@@ -111,7 +111,7 @@ func PopulateRequestContext(ctx context.Context, r *http.Request) context.Contex
 }
 
 // ServerFinalizer instruments handler calls to expose Prometheus metrics and
-/// log request/response information.
+// log request/response information.
 func ServerFinalizer(
 	logger log.Logger,
 	reqObserve instrument.ObserveRequestFunc,
@@ -0,0 +1,177 @@
+package configsum
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CacheOption sets an optional parameter on the Cache.
+type CacheOption func(*Cache)
+
+// CacheErrorHandler sets a function which is called with errors from
+// background refreshes, which otherwise are only reflected by Config.Stale.
+func CacheErrorHandler(fn func(baseName, userID string, err error)) CacheOption {
+	return func(c *Cache) { c.errFn = fn }
+}
+
+// CacheTTL sets the duration after which a cached config is rendered again on
+// access. Defaults to the refresh interval.
+func CacheTTL(ttl time.Duration) CacheOption {
+	return func(c *Cache) { c.ttl = ttl }
+}
+
+// Cache keeps rendered configs in memory and serves them until they expire.
+// If rendering fails the last known config is served marked as stale.
+type Cache struct {
+	client   *Client
+	entries  map[cacheKey]*cacheEntry
+	errFn    func(baseName, userID string, err error)
+	mu       sync.RWMutex
+	now      func() time.Time
+	interval time.Duration
+	stopc    chan struct{}
+	ttl      time.Duration
+	wg       sync.WaitGroup
+}
+
+type cacheKey struct {
+	baseName string
+	userID   string
+}
+
+type cacheEntry struct {
+	config    Config
+	renderCtx Context
+	fetchedAt time.Time
+}
+
+// NewCache returns a Cache which renders through the given Client and
+// refreshes all known configs every interval once started.
+func NewCache(client *Client, interval time.Duration, options ...CacheOption) *Cache {
+	c := &Cache{
+		client:   client,
+		entries:  map[cacheKey]*cacheEntry{},
+		errFn:    func(string, string, error) {},
+		interval: interval,
+		now:      time.Now,
+		ttl:      interval,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// Get returns the cached config for base and user if it is still fresh,
+// otherwise it is rendered again. Should the render fail the last known config
+// is returned with Stale set, if there is none the error is returned.
+func (c *Cache) Get(
+	ctx context.Context,
+	baseName, userID string,
+	renderCtx Context,
+) (Config, error) {
+	key := cacheKey{baseName: baseName, userID: userID}
+
+	c.mu.RLock()
+	e, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if ok && c.now().Sub(e.fetchedAt) < c.ttl {
+		return e.config, nil
+	}
+
+	cfg, err := c.render(ctx, key, renderCtx)
+	if err != nil && cfg.Stale {
+		return cfg, nil
+	}
+
+	return cfg, err
+}
+
+// Start begins refreshing all cached configs in the background.
+func (c *Cache) Start() {
+	c.stopc = make(chan struct{})
+	c.wg.Add(1)
+
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stopc:
+				return
+			case <-ticker.C:
+				c.refresh()
+			}
+		}
+	}()
+}
+
+// Stop ends background refreshes and waits for an ongoing one to finish.
+func (c *Cache) Stop() {
+	if c.stopc == nil {
+		return
+	}
+
+	close(c.stopc)
+	c.wg.Wait()
+	c.stopc = nil
+}
+
+func (c *Cache) refresh() {
+	c.mu.RLock()
+	es := map[cacheKey]Context{}
+	for k, e := range c.entries {
+		es[k] = e.renderCtx
+	}
+	c.mu.RUnlock()
+
+	for k, renderCtx := range es {
+		ctx, cancel := context.WithTimeout(context.Background(), c.interval)
+		_, err := c.render(ctx, k, renderCtx)
+		cancel()
+
+		if err != nil {
+			c.errFn(k.baseName, k.userID, err)
+		}
+	}
+}
+
+// render updates the entry for key and returns the fresh config. If rendering
+// fails and an entry exists it is marked stale and returned with the error.
+func (c *Cache) render(
+	ctx context.Context,
+	key cacheKey,
+	renderCtx Context,
+) (Config, error) {
+	cfg, err := c.client.Render(ctx, key.baseName, key.userID, renderCtx)
+	if err != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		e, ok := c.entries[key]
+		if !ok {
+			return Config{}, err
+		}
+
+		e.config.Stale = true
+
+		return e.config, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = &cacheEntry{
+		config:    cfg,
+		renderCtx: renderCtx,
+		fetchedAt: c.now(),
+	}
+	c.mu.Unlock()
+
+	return cfg, nil
+}
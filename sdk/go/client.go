@@ -0,0 +1,178 @@
+// Package configsum provides a typed client for the configsum config API.
+package configsum
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Headers.
+const (
+	headerBaseID      = "X-Configsum-Base-Id"
+	headerBaseName    = "X-Configsum-Base-Name"
+	headerClientID    = "X-Configsum-Client-Id"
+	headerContentType = "Content-Type"
+	headerCreatedAt   = "X-Configsum-Created"
+	headerDorySig     = "X-Dory-Signature"
+	headerDoryUserID  = "X-Dory-Userid"
+	headerID          = "X-Configsum-Id"
+	headerToken       = "X-Configsum-Token"
+	headerUserID      = "X-Configsum-Userid"
+)
+
+const (
+	apiVersion     = "v1"
+	defaultTimeout = 5 * time.Second
+)
+
+// Errors.
+var (
+	ErrBaseNotFound = errors.New("base config not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrUnexpected   = errors.New("unexpected response")
+)
+
+// Option sets an optional parameter on the Client.
+type Option func(*Client)
+
+// WithDory signs requests for the Dory auth middleware with the given shared
+// secret instead of passing the user id in plain.
+func WithDory(secret string) Option {
+	return func(c *Client) { c.dorySecret = secret }
+}
+
+// WithHTTPClient sets the http.Client used to talk to the API.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// Client talks to the config API on behalf of a registered client.
+type Client struct {
+	addr       string
+	dorySecret string
+	http       *http.Client
+	token      string
+}
+
+// New returns a Client for the API listening on addr which authenticates with
+// the given client token.
+func New(addr, token string, options ...Option) *Client {
+	c := &Client{
+		addr:  strings.TrimRight(addr, "/"),
+		http:  &http.Client{Timeout: defaultTimeout},
+		token: token,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// Render requests the config for the given base and user rendered against the
+// render context.
+func (c *Client) Render(
+	ctx context.Context,
+	baseName, userID string,
+	renderCtx Context,
+) (Config, error) {
+	raw, err := json.Marshal(renderCtx)
+	if err != nil {
+		return Config{}, errors.Wrap(err, "marshal context")
+	}
+
+	req, err := http.NewRequest(
+		"PUT",
+		fmt.Sprintf("%s/%s/config/%s", c.addr, apiVersion, baseName),
+		bytes.NewReader(raw),
+	)
+	if err != nil {
+		return Config{}, errors.Wrap(err, "create request")
+	}
+
+	req = req.WithContext(ctx)
+
+	req.Header.Set(headerContentType, "application/json")
+	req.Header.Set(headerToken, c.token)
+
+	if c.dorySecret != "" {
+		req.Header.Set(headerDorySig, signDory(c.dorySecret, userID))
+		req.Header.Set(headerDoryUserID, userID)
+	} else {
+		req.Header.Set(headerUserID, userID)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return Config{}, errors.Wrap(err, "request")
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return Config{}, errors.Wrap(err, "read body")
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+	case http.StatusNotFound:
+		return Config{}, errors.Wrap(ErrBaseNotFound, baseName)
+	case http.StatusUnauthorized:
+		return Config{}, errors.Wrap(ErrUnauthorized, reason(body))
+	default:
+		return Config{}, errors.Wrapf(
+			ErrUnexpected,
+			"status %d: %s",
+			res.StatusCode,
+			reason(body),
+		)
+	}
+
+	params := map[string]interface{}{}
+
+	if err := json.Unmarshal(body, &params); err != nil {
+		return Config{}, errors.Wrap(err, "unmarshal parameters")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, res.Header.Get(headerCreatedAt))
+	if err != nil {
+		return Config{}, errors.Wrap(ErrUnexpected, "invalid created header")
+	}
+
+	return Config{
+		BaseID:     res.Header.Get(headerBaseID),
+		BaseName:   res.Header.Get(headerBaseName),
+		ClientID:   res.Header.Get(headerClientID),
+		ID:         res.Header.Get(headerID),
+		Parameters: params,
+		CreatedAt:  createdAt,
+	}, nil
+}
+
+func reason(body []byte) string {
+	v := struct {
+		Reason string `json:"reason"`
+	}{}
+
+	if err := json.Unmarshal(body, &v); err != nil || v.Reason == "" {
+		return string(body)
+	}
+
+	return v.Reason
+}
+
+func signDory(secret, userID string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(secret+userID)))
+}
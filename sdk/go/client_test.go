@@ -0,0 +1,199 @@
+package configsum
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const testToken = "secret"
+
+func TestClientRender(t *testing.T) {
+	var (
+		baseName  = "ios-app"
+		userID    = "user-1"
+		createdAt = time.Now().UTC().Truncate(time.Millisecond)
+		want      = map[string]interface{}{
+			"feature_paywall_enabled": true,
+			"feature_paywall_price":   9.99,
+		}
+		renderCtx = Context{
+			App: App{Version: "6.4.1"},
+			Device: Device{
+				Location: Location{Locale: "en-GB", TimezoneOffset: 3600},
+				OS:       OS{Platform: PlatformIOS, Version: "11.1"},
+			},
+		}
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if have, want := r.Method, "PUT"; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		if have, want := r.URL.Path, "/v1/config/"+baseName; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		if have, want := r.Header.Get(headerToken), testToken; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		if have, want := r.Header.Get(headerUserID), userID; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		c := Context{}
+
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			t.Fatal(err)
+		}
+
+		if have, want := c, renderCtx; !reflect.DeepEqual(have, want) {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		w.Header().Set(headerBaseName, baseName)
+		w.Header().Set(headerID, "config-1")
+		w.Header().Set(headerCreatedAt, createdAt.Format(time.RFC3339Nano))
+		w.WriteHeader(http.StatusCreated)
+
+		_ = json.NewEncoder(w).Encode(want)
+	}))
+	defer srv.Close()
+
+	cfg, err := New(srv.URL, testToken).Render(context.Background(), baseName, userID, renderCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := cfg.ID, "config-1"; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := cfg.CreatedAt, createdAt; !have.Equal(want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := cfg.Parameters, want; !reflect.DeepEqual(have, want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := cfg.Bool("feature_paywall_enabled", false), true; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := cfg.Number("feature_paywall_price", 0), 9.99; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := cfg.String("feature_paywall_price", "default"), "default"; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestClientRenderDory(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if have, want := r.Header.Get(headerDoryUserID), "user-1"; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		if have, want := r.Header.Get(headerDorySig), signDory("dory", "user-1"); have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+
+		w.Header().Set(headerCreatedAt, time.Now().Format(time.RFC3339Nano))
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL, testToken, WithDory("dory")).Render(context.Background(), "base", "user-1", Context{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientRenderNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"reason": "entity not found"}`))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL, testToken).Render(context.Background(), "base", "user", Context{})
+	if have, want := errors.Cause(err), ErrBaseNotFound; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
+
+func TestCacheStaleOnError(t *testing.T) {
+	var failing int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set(headerID, "config-1")
+		w.Header().Set(headerCreatedAt, time.Now().Format(time.RFC3339Nano))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"feature_x_enabled": true}`))
+	}))
+	defer srv.Close()
+
+	var (
+		now   = time.Now()
+		cache = NewCache(New(srv.URL, testToken), time.Minute)
+	)
+
+	cache.now = func() time.Time { return now }
+
+	cfg, err := cache.Get(context.Background(), "base", "user", Context{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := cfg.Stale, false; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	atomic.StoreInt32(&failing, 1)
+
+	// Still fresh, served from cache without hitting the API.
+	cfg, err = cache.Get(context.Background(), "base", "user", Context{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := cfg.Stale, false; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	cfg, err = cache.Get(context.Background(), "base", "user", Context{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := cfg.Stale, true; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	if have, want := cfg.Bool("feature_x_enabled", false), true; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	_, err = cache.Get(context.Background(), "base", "unknown", Context{})
+	if have, want := errors.Cause(err), ErrUnexpected; have != want {
+		t.Errorf("have %v, want %v", have, want)
+	}
+}
@@ -0,0 +1,61 @@
+package configsum
+
+import "time"
+
+// Config is a rendered user config.
+type Config struct {
+	BaseID     string
+	BaseName   string
+	ClientID   string
+	ID         string
+	Parameters map[string]interface{}
+	CreatedAt  time.Time
+
+	// Stale is set if the config was served from cache because a refresh
+	// failed.
+	Stale bool
+}
+
+// Bool returns the value of the parameter stored under key or def if it is
+// missing or not a boolean.
+func (c Config) Bool(key string, def bool) bool {
+	v, ok := c.Parameters[key].(bool)
+	if !ok {
+		return def
+	}
+
+	return v
+}
+
+// Number returns the value of the parameter stored under key or def if it is
+// missing or not a number.
+func (c Config) Number(key string, def float64) float64 {
+	v, ok := c.Parameters[key].(float64)
+	if !ok {
+		return def
+	}
+
+	return v
+}
+
+// Int returns the value of the parameter stored under key truncated to an int
+// or def if it is missing or not a number.
+func (c Config) Int(key string, def int) int {
+	v, ok := c.Parameters[key].(float64)
+	if !ok {
+		return def
+	}
+
+	return int(v)
+}
+
+// String returns the value of the parameter stored under key or def if it is
+// missing or not a string.
+func (c Config) String(key string, def string) string {
+	v, ok := c.Parameters[key].(string)
+	if !ok {
+		return def
+	}
+
+	return v
+}
@@ -0,0 +1,54 @@
+package configsum
+
+import "time"
+
+// Supported platforms.
+const (
+	PlatformAndroid Platform = "Android"
+	PlatformIOS     Platform = "iOS"
+	PlatformWatchOS Platform = "WatchOS"
+)
+
+// Platform is the client platform a config is rendered for.
+type Platform string
+
+// Context carries the information about app, device and user which rule
+// criteria are matched against.
+type Context struct {
+	App      App                    `json:"app"`
+	Device   Device                 `json:"device"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	User     *User                  `json:"user,omitempty"`
+}
+
+// App describes the client application.
+type App struct {
+	Version string `json:"version"`
+}
+
+// Device describes the device the app runs on.
+type Device struct {
+	Location Location `json:"location"`
+	OS       OS       `json:"os"`
+}
+
+// Location bundles the locale settings of a device.
+type Location struct {
+	// Locale according to BCP 47, e.g. en-GB.
+	Locale string `json:"locale"`
+	// TimezoneOffset is the offset from GMT in seconds.
+	TimezoneOffset int `json:"timezoneOffset"`
+}
+
+// OS describes the operating system of a device.
+type OS struct {
+	Platform Platform `json:"platform"`
+	Version  string   `json:"version"`
+}
+
+// User carries optional information about the user.
+type User struct {
+	Age          uint8      `json:"age,omitempty"`
+	Registered   *time.Time `json:"registered,omitempty"`
+	Subscription int        `json:"subscription,omitempty"`
+}
@@ -1,6 +1,7 @@
 module Data.Client exposing (Client, decoder, encoder)
 
 import Date exposing (Date)
+import Dict exposing (Dict)
 import Json.Decode as Decode exposing (Decoder, andThen, fail, succeed)
 import Json.Encode as Encode
 
@@ -11,17 +12,19 @@ type alias Client =
     , id : String
     , name : String
     , token : String
+    , tokens : Dict String String
     }
 
 
 decoder : Decoder Client
 decoder =
-    Decode.map5 Client
+    Decode.map6 Client
         (Decode.field "created_at" date)
         (Decode.field "deleted" Decode.bool)
         (Decode.field "id" Decode.string)
         (Decode.field "name" Decode.string)
         (Decode.field "token" Decode.string)
+        (Decode.field "tokens" (Decode.dict Decode.string))
 
 
 encoder : String -> Encode.Value
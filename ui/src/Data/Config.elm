@@ -1,6 +1,7 @@
 module Data.Config exposing (Config, decoder, encoder)
 
 import Date exposing (Date)
+import Dict exposing (Dict)
 import Json.Decode as Decode exposing (Decoder, andThen, fail, succeed)
 import Json.Encode as Encode
 import Data.Parameter exposing (Parameter(..))
@@ -8,9 +9,11 @@ import Data.Parameter exposing (Parameter(..))
 
 type alias Config =
     { clientId : String
+    , environment : String
     , id : String
     , name : String
     , parameters : List Parameter
+    , deprecated : Dict String Date
     , createdAt : Date
     , updatedAt : Date
     }
@@ -18,11 +21,13 @@ type alias Config =
 
 decoder : Decoder Config
 decoder =
-    Decode.map6 Config
+    Decode.map8 Config
         (Decode.field "client_id" Decode.string)
+        (Decode.field "environment" Decode.string)
         (Decode.field "id" Decode.string)
         (Decode.field "name" Decode.string)
         (Decode.field "parameters" (Decode.list Data.Parameter.decoder))
+        (Decode.field "deprecated" (Decode.dict date))
         (Decode.field "created_at" date)
         (Decode.field "updated_at" date)
 
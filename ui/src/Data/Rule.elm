@@ -1,4 +1,4 @@
-module Data.Rule exposing (Bucket, Criteria, CriteriaUser, Kind(..), Rule, decoder)
+module Data.Rule exposing (Bucket, Criteria, CriteriaUser, Kind(..), Layer, Rule, decoder)
 
 import Date exposing (Date)
 import Data.Parameter exposing (Parameter)
@@ -30,6 +30,13 @@ type alias CriteriaUser =
     }
 
 
+type alias Layer =
+    { name : String
+    , offset : Int
+    , share : Int
+    }
+
+
 type alias MatcherInt =
     { comparator : Int
     , value : Int
@@ -47,6 +54,7 @@ type alias Rule =
     , endTime : Date
     , id : String
     , kind : Kind
+    , layer : Maybe Layer
     , name : String
     , rollout : Int
     , startTime : Date
@@ -67,6 +75,7 @@ decoder =
         |> optional "end_time" date (Date.fromTime 0)
         |> required "id" Decode.string
         |> required "kind" (Decode.int |> andThen decodeKind)
+        |> optional "layer" (Decode.map Just decodeLayer) Nothing
         |> required "name" Decode.string
         |> required "rollout" Decode.int
         |> optional "start_time" date (Date.fromTime 0)
@@ -109,6 +118,14 @@ decodeKind raw =
             fail "unsupported kind"
 
 
+decodeLayer : Decoder Layer
+decodeLayer =
+    decode Layer
+        |> required "name" Decode.string
+        |> required "offset" Decode.int
+        |> required "share" Decode.int
+
+
 decodeMatcherInt : Decoder MatcherInt
 decodeMatcherInt =
     decode MatcherInt
@@ -1,5 +1,6 @@
 module Page.Clients exposing (Model, Msg, init, update, view)
 
+import Dict
 import Html
     exposing
         ( Html
@@ -131,7 +132,7 @@ viewList { clients, formName, showCreate } =
             [ thead []
                 [ tr []
                     [ th [] [ text "name" ]
-                    , th [] [ text "token" ]
+                    , th [] [ text "tokens" ]
                     ]
                 ]
             , tbody [] (List.append (List.map viewItem clients) action)
@@ -142,5 +143,13 @@ viewItem : Client -> Html Msg
 viewItem client =
     tr []
         [ td [] [ text client.name ]
-        , td [] [ text client.token ]
+        , td [] (List.map viewToken (Dict.toList client.tokens))
+        ]
+
+
+viewToken : ( String, String ) -> Html Msg
+viewToken ( env, token ) =
+    div []
+        [ strong [] [ text env ]
+        , text (" " ++ token)
         ]
@@ -265,9 +265,11 @@ viewAddConfigForm name clients =
             []
         , td []
             []
+        , td []
+            []
         ]
     , tr [ class "save", onClick FormSubmit ]
-        [ td [ class "type", colspan 4 ] [ text "save config" ]
+        [ td [ class "type", colspan 5 ] [ text "save config" ]
         ]
     ]
 
@@ -295,11 +297,28 @@ viewConfig now config showAdd parameter error =
                 , strong [ class "highlight" ] [ text config.name ]
                 ]
             , View.Error.view error
+            , viewDeprecated config
             , viewMeta config now
             , View.Parameter.viewTable action config.parameters
             ]
 
 
+viewDeprecated : Config -> Html Msg
+viewDeprecated config =
+    let
+        item ( key, date ) =
+            div []
+                [ strong [] [ text key ]
+                , text (" deprecated since " ++ (View.Date.short date))
+                ]
+    in
+        if Dict.isEmpty config.deprecated then
+            div [] []
+        else
+            div [ class "warning" ]
+                (List.map item (Dict.toList config.deprecated))
+
+
 viewItem : Dict String Client -> Config -> Html Msg
 viewItem clients config =
     let
@@ -318,6 +337,7 @@ viewItem clients config =
             ]
             [ td [] [ text config.name ]
             , td [] [ text client ]
+            , td [] [ text config.environment ]
             , td [] [ text config.id ]
             , td [] [ text (toString (List.length config.parameters)) ]
             ]
@@ -330,7 +350,7 @@ viewList model =
             if model.showAddConfig then
                 viewAddConfigForm model.formName model.clients
             else
-                [ viewAdd 4 "add config" ToggleAddConfig
+                [ viewAdd 5 "add config" ToggleAddConfig
                 ]
 
         clients =
@@ -347,6 +367,7 @@ viewList model =
                     [ tr []
                         [ th [ class "name" ] [ text "name" ]
                         , th [ class "client" ] [ text "client" ]
+                        , th [] [ text "environment" ]
                         , th [ class "id" ] [ text "id" ]
                         , th [] [ text "parameters" ]
                         ]
@@ -362,6 +383,7 @@ viewMeta config now =
         cards =
             [ ( "id", config.id )
             , ( "client", config.clientId )
+            , ( "environment", config.environment )
             , ( "created", (View.Date.short config.createdAt) )
             , ( "updated", (View.Date.pretty now config.updatedAt) )
             ]
@@ -27,7 +27,7 @@ import Task exposing (Task)
 import Time exposing (Time)
 import Api.Rule as Api
 import Data.Parameter exposing (Parameter(..))
-import Data.Rule exposing (Bucket, Criteria, CriteriaUser, Kind(Experiment, Override, Rollout), Rule, decoder)
+import Data.Rule exposing (Bucket, Criteria, CriteriaUser, Kind(Experiment, Override, Rollout), Layer, Rule, decoder)
 import Page.Errored exposing (PageLoadError, pageLoadError)
 import View.Date
 import View.Error
@@ -152,7 +152,7 @@ viewAddRuleForm =
         [ td [] []
         ]
     , tr [ class "save", onClick FormSubmit ]
-        [ td [ class "type", colspan 4 ] [ text "save rule" ]
+        [ td [ class "type", colspan 5 ] [ text "save rule" ]
         ]
     ]
 
@@ -234,6 +234,7 @@ viewList model =
                     [ th [ class "active icon" ] [ text "active" ]
                     , th [ class "name" ] [ text "name" ]
                     , th [ class "kind" ] [ text "kind" ]
+                    , th [ class "layer" ] [ text "layer" ]
                     , th [ class "config" ] [ text "config" ]
                     ]
                 ]
@@ -244,6 +245,21 @@ viewList model =
         ]
 
 
+viewLayer : Maybe Layer -> String
+viewLayer layer =
+    case layer of
+        Just l ->
+            l.name
+                ++ " ("
+                ++ toString l.offset
+                ++ "-"
+                ++ toString (l.offset + l.share)
+                ++ "%)"
+
+        Nothing ->
+            "-"
+
+
 viewListAction : Bool -> List (Html Msg)
 viewListAction showAddRule =
     case showAddRule of
@@ -251,7 +267,7 @@ viewListAction showAddRule =
             viewAddRuleForm
 
         False ->
-            [ viewAdd 4 "add rule" ToggleAddRule ]
+            [ viewAdd 5 "add rule" ToggleAddRule ]
 
 
 viewListItem : Rule -> Html Msg
@@ -273,6 +289,7 @@ viewListItem rule =
             [ td [ class "icon" ] [ span [ class ("nc-icon " ++ stateIcon) ] [] ]
             , td [] [ text rule.name ]
             , td [] [ text <| toString rule.kind ]
+            , td [] [ text <| viewLayer rule.layer ]
             , td [] [ text rule.configId ]
             ]
 
@@ -286,6 +303,7 @@ viewMeta now rule =
             , ( "created", (View.Date.short rule.createdAt) )
             , ( "updated", (View.Date.pretty now rule.updatedAt) )
             , ( "activated", (View.Date.pretty now rule.activatedAt) )
+            , ( "layer", viewLayer rule.layer )
             ]
     in
         section [ class "meta" ] <| List.map viewCard cards